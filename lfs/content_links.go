@@ -0,0 +1,93 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package lfs
+
+import (
+	"time"
+
+	"github.com/gitbundle/modules/log"
+	"github.com/gitbundle/modules/storage"
+)
+
+// DirectDownloadLink returns a Link which allows the client to download the object
+// directly from the storage backend.
+// storage.ErrURLNotSupported is returned if the store can't hand out such links.
+func (s *ContentStore) DirectDownloadLink(pointer Pointer, expiry time.Duration) (*Link, error) {
+	presigner, ok := s.presigner()
+	if !ok {
+		return nil, storage.ErrURLNotSupported
+	}
+
+	expiresAt := time.Now().Add(expiry)
+	u, err := presigner.PresignGet(pointer.RelativePath(), expiry)
+	if err != nil {
+		return nil, err
+	}
+	return &Link{Href: u.String(), ExpiresAt: &expiresAt}, nil
+}
+
+// DirectUploadLink returns a Link which allows the client to upload the object
+// directly to the storage backend.
+// storage.ErrURLNotSupported is returned if the store can't hand out such links.
+func (s *ContentStore) DirectUploadLink(pointer Pointer, expiry time.Duration) (*Link, error) {
+	presigner, ok := s.presigner()
+	if !ok {
+		return nil, storage.ErrURLNotSupported
+	}
+
+	expiresAt := time.Now().Add(expiry)
+	u, err := presigner.PresignPut(pointer.RelativePath(), expiry)
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]string{"Content-Type": "application/octet-stream"}
+	if hs, ok := presigner.(storage.PresignedUploadHeaderStorage); ok {
+		for k, v := range hs.PresignPutHeaders() {
+			header[k] = v
+		}
+	}
+	return &Link{
+		Href:      u.String(),
+		Header:    header,
+		ExpiresAt: &expiresAt,
+	}, nil
+}
+
+// ObjectActions returns the actions of a batch response object for the given operation.
+// Direct links are used if possible, otherwise proxy is asked for a link to the server
+// for the action ("download", "upload" or "verify").
+// Uploads always carry a verify action so the server can check objects it didn't receive itself.
+func (s *ContentStore) ObjectActions(operation string, pointer Pointer, expiry time.Duration, proxy func(action string) *Link) map[string]*Link {
+	switch operation {
+	case "download":
+		link, err := s.DirectDownloadLink(pointer, expiry)
+		if err != nil {
+			if err != storage.ErrURLNotSupported {
+				log.Warn("Unable to create direct download link for LFS OID[%s]: %v", pointer.Oid, err)
+			}
+			link = proxy("download")
+		}
+		return map[string]*Link{"download": link}
+	case "upload":
+		link, err := s.DirectUploadLink(pointer, expiry)
+		if err != nil {
+			if err != storage.ErrURLNotSupported {
+				log.Warn("Unable to create direct upload link for LFS OID[%s]: %v", pointer.Oid, err)
+			}
+			link = proxy("upload")
+		}
+		return map[string]*Link{"upload": link, "verify": proxy("verify")}
+	}
+	return nil
+}
+
+func (s *ContentStore) presigner() (storage.PresignedStorage, bool) {
+	if !s.ServeDirect {
+		return nil, false
+	}
+	presigner, ok := s.ObjectStorage.(storage.PresignedStorage)
+	return presigner, ok
+}
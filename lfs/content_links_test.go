@@ -0,0 +1,128 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package lfs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type presignedStorage struct {
+	storage.ObjectStorage
+}
+
+func (s *presignedStorage) PresignGet(path string, expiry time.Duration) (*url.URL, error) {
+	return url.Parse("https://bucket.example.com/" + path + "?X-Amz-Expires=" + expiry.String())
+}
+
+func (s *presignedStorage) PresignPut(path string, expiry time.Duration) (*url.URL, error) {
+	return url.Parse("https://bucket.example.com/" + path + "?upload=1")
+}
+
+type presignedHeaderStorage struct {
+	presignedStorage
+}
+
+func (s *presignedHeaderStorage) PresignPutHeaders() map[string]string {
+	return map[string]string{"x-ms-blob-type": "BlockBlob"}
+}
+
+func TestContentStoreObjectActions(t *testing.T) {
+	p := Pointer{Oid: "b5a2c96250612366ea272ffac6d9744aaf4b45aacd96aa7cfcb931ee3b558259", Size: 5}
+	proxy := func(action string) *Link {
+		return &Link{Href: "https://gitbundle.local/lfs/" + action, Header: map[string]string{"Authorization": "Bearer token"}}
+	}
+
+	local, err := storage.NewLocalStorage(context.Background(), storage.LocalStorageConfig{Path: t.TempDir()})
+	assert.NoError(t, err)
+
+	t.Run("Fallback", func(t *testing.T) {
+		for _, s := range []*ContentStore{
+			{ObjectStorage: local, ServeDirect: true},
+			{ObjectStorage: &presignedStorage{local}, ServeDirect: false},
+		} {
+			actions := s.ObjectActions("download", p, time.Minute, proxy)
+			assert.Len(t, actions, 1)
+			assert.Equal(t, "https://gitbundle.local/lfs/download", actions["download"].Href)
+
+			actions = s.ObjectActions("upload", p, time.Minute, proxy)
+			assert.Len(t, actions, 2)
+			assert.Equal(t, "https://gitbundle.local/lfs/upload", actions["upload"].Href)
+			assert.Equal(t, "https://gitbundle.local/lfs/verify", actions["verify"].Href)
+		}
+	})
+
+	t.Run("Direct", func(t *testing.T) {
+		s := &ContentStore{ObjectStorage: &presignedStorage{local}, ServeDirect: true}
+
+		before := time.Now()
+		actions := s.ObjectActions("download", p, time.Minute, proxy)
+		assert.Len(t, actions, 1)
+		download := actions["download"]
+		assert.True(t, strings.HasPrefix(download.Href, "https://bucket.example.com/b5/a2/"))
+		assert.Empty(t, download.Header)
+		assert.NotNil(t, download.ExpiresAt)
+		assert.False(t, download.ExpiresAt.Before(before.Add(time.Minute)))
+
+		actions = s.ObjectActions("upload", p, time.Minute, proxy)
+		assert.Len(t, actions, 2)
+		upload := actions["upload"]
+		assert.True(t, strings.HasPrefix(upload.Href, "https://bucket.example.com/b5/a2/"))
+		assert.Equal(t, map[string]string{"Content-Type": "application/octet-stream"}, upload.Header)
+		assert.NotNil(t, upload.ExpiresAt)
+		assert.Equal(t, "https://gitbundle.local/lfs/verify", actions["verify"].Href)
+
+		assert.Nil(t, s.ObjectActions("unknown", p, time.Minute, proxy))
+	})
+
+	t.Run("DirectWithHeaders", func(t *testing.T) {
+		s := &ContentStore{ObjectStorage: &presignedHeaderStorage{presignedStorage{local}}, ServeDirect: true}
+
+		actions := s.ObjectActions("upload", p, time.Minute, proxy)
+		assert.Equal(t, map[string]string{
+			"Content-Type":   "application/octet-stream",
+			"x-ms-blob-type": "BlockBlob",
+		}, actions["upload"].Header)
+	})
+}
+
+func TestContentStoreVerifyContent(t *testing.T) {
+	local, err := storage.NewLocalStorage(context.Background(), storage.LocalStorageConfig{Path: t.TempDir()})
+	assert.NoError(t, err)
+	s := &ContentStore{ObjectStorage: local}
+
+	p := Pointer{Oid: "b5a2c96250612366ea272ffac6d9744aaf4b45aacd96aa7cfcb931ee3b558259", Size: 5}
+
+	ok, err := s.VerifyContent(p)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = s.Save(p.RelativePath(), strings.NewReader("dummy"), 5)
+	assert.NoError(t, err)
+
+	ok, err = s.VerifyContent(p)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// same size but different content
+	_, err = s.Save(p.RelativePath(), strings.NewReader("dumm!"), 5)
+	assert.NoError(t, err)
+
+	ok, err = s.VerifyContent(p)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	exists, err := s.Exists(p)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -15,6 +15,7 @@ import (
 	"os"
 
 	"github.com/gitbundle/modules/log"
+	"github.com/gitbundle/modules/setting"
 	"github.com/gitbundle/modules/storage"
 )
 
@@ -43,11 +44,13 @@ func (err ErrRangeNotSatisfiable) Error() string {
 // ContentStore provides a simple file system based storage.
 type ContentStore struct {
 	storage.ObjectStorage
+	// ServeDirect allows handing out links which point directly at the storage backend
+	ServeDirect bool
 }
 
 // NewContentStore creates the default ContentStore
 func NewContentStore() *ContentStore {
-	contentStore := &ContentStore{ObjectStorage: storage.LFS}
+	contentStore := &ContentStore{ObjectStorage: storage.LFS, ServeDirect: setting.LFS.ServeDirect}
 	return contentStore
 }
 
@@ -114,6 +117,37 @@ func (s *ContentStore) Verify(pointer Pointer) (bool, error) {
 	return true, nil
 }
 
+// VerifyContent returns true if the object exists in the content store and both
+// its size and SHA-256 match the pointer. Objects which don't match are removed.
+// This is used to confirm uploads which bypassed the server.
+func (s *ContentStore) VerifyContent(pointer Pointer) (bool, error) {
+	if ok, err := s.Verify(pointer); !ok || err != nil {
+		return ok, err
+	}
+
+	p := pointer.RelativePath()
+	f, err := s.Open(p)
+	if err != nil {
+		log.Error("Whilst verifying LFS OID[%s]: Unable to open Error: %v", pointer.Oid, err)
+		return false, err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(io.Discard, newHashingReader(pointer.Size, pointer.Oid, f))
+	if err == ErrSizeMismatch || err == ErrHashMismatch {
+		log.Warn("LFS OID[%s] failed verification: %v", pointer.Oid, err)
+		if err := s.Delete(p); err != nil {
+			log.Error("Cleaning the LFS OID[%s] failed: %v", pointer.Oid, err)
+		}
+		return false, nil
+	} else if err != nil {
+		log.Error("Whilst verifying LFS OID[%s]: Unable to read Error: %v", pointer.Oid, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
 // ReadMetaObject will read a git_model.LFSMetaObject and return a reader
 func ReadMetaObject(pointer Pointer) (io.ReadCloser, error) {
 	contentStore := NewContentStore()
@@ -0,0 +1,36 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package log
+
+import "unsafe"
+
+//go:linkname runtime_getProfLabel runtime/pprof.runtime_getProfLabel
+func runtime_getProfLabel() unsafe.Pointer // nolint
+
+// Since go1.21 the labels are kept as a sorted list instead of a map,
+// reading them as a map corrupts the log calls of labelled goroutines.
+type label struct {
+	key   string
+	value string
+}
+
+type labelMap struct {
+	list []label
+}
+
+func getGoroutineLabels() map[string]string {
+	l := (*labelMap)(runtime_getProfLabel())
+	if l == nil {
+		return nil
+	}
+	labels := make(map[string]string, len(l.list))
+	for _, lbl := range l.list {
+		labels[lbl.key] = lbl.value
+	}
+	return labels
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cargo
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/gitbundle/modules/json"
+)
+
+// Config represents the config.json file at the root of a registry index
+// https://doc.rust-lang.org/cargo/reference/registry-index.html#index-configuration
+type Config struct {
+	DownloadURL  string `json:"dl"`
+	APIURL       string `json:"api"`
+	AuthRequired bool   `json:"auth-required,omitempty"`
+}
+
+// IndexEntry represents a single version of a package in an index file
+// https://doc.rust-lang.org/cargo/reference/registry-index.html#json-schema
+type IndexEntry struct {
+	Name         string              `json:"name"`
+	Version      string              `json:"vers"`
+	Dependencies []*IndexDependency  `json:"deps"`
+	Checksum     string              `json:"cksum"`
+	Features     map[string][]string `json:"features"`
+	Features2    map[string][]string `json:"features2,omitempty"`
+	Yanked       bool                `json:"yanked"`
+	Links        string              `json:"links,omitempty"`
+	RustVersion  string              `json:"rust_version,omitempty"`
+	// V is 2 if Features2 is used
+	V int `json:"v,omitempty"`
+}
+
+// IndexDependency represents a dependency of an index entry
+type IndexDependency struct {
+	Name            string   `json:"name"`
+	Req             string   `json:"req"`
+	Features        []string `json:"features"`
+	Optional        bool     `json:"optional"`
+	DefaultFeatures bool     `json:"default_features"`
+	Target          *string  `json:"target"`
+	Kind            string   `json:"kind"`
+	Registry        *string  `json:"registry"`
+	Package         *string  `json:"package"`
+}
+
+// IndexPath returns the path of the index file of a package relative to the index root
+func IndexPath(name string) string {
+	name = strings.ToLower(name)
+	switch len(name) {
+	case 0:
+		return ""
+	case 1:
+		return "1/" + name
+	case 2:
+		return "2/" + name
+	case 3:
+		return "3/" + name[0:1] + "/" + name
+	default:
+		return name[0:2] + "/" + name[2:4] + "/" + name
+	}
+}
+
+// NewIndexEntry creates the index entry of a package version.
+// checksum is the hex encoded SHA-256 of the .crate file.
+func NewIndexEntry(p *Package, checksum string) *IndexEntry {
+	e := &IndexEntry{
+		Name:         p.Name,
+		Version:      p.Version,
+		Dependencies: []*IndexDependency{},
+		Checksum:     checksum,
+		Features:     map[string][]string{},
+	}
+	if p.Metadata == nil {
+		return e
+	}
+
+	e.Yanked = p.Metadata.Yanked
+	e.Links = p.Metadata.Links
+	e.RustVersion = p.Metadata.RustVersion
+
+	for _, dep := range p.Metadata.Dependencies {
+		features := dep.Features
+		if features == nil {
+			features = []string{}
+		}
+		e.Dependencies = append(e.Dependencies, &IndexDependency{
+			Name:            dep.Name,
+			Req:             dep.Req,
+			Features:        features,
+			Optional:        dep.Optional,
+			DefaultFeatures: dep.DefaultFeatures,
+			Target:          optionalString(dep.Target),
+			Kind:            dep.Kind,
+			Registry:        optionalString(dep.Registry),
+			Package:         optionalString(dep.Package),
+		})
+	}
+
+	// older cargo versions fail on the newer feature syntax, so it has to go into features2
+	for feature, values := range p.Metadata.Features {
+		if values == nil {
+			values = []string{}
+		}
+		if usesFeatures2Syntax(values) {
+			if e.Features2 == nil {
+				e.Features2 = map[string][]string{}
+			}
+			e.Features2[feature] = values
+		} else {
+			e.Features[feature] = values
+		}
+	}
+	if e.Features2 != nil {
+		e.V = 2
+	}
+
+	return e
+}
+
+func usesFeatures2Syntax(values []string) bool {
+	for _, v := range values {
+		if strings.HasPrefix(v, "dep:") || strings.Contains(v, "?/") {
+			return true
+		}
+	}
+	return false
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// WriteIndexFile writes the entries in the line delimited JSON format of an index file
+func WriteIndexFile(w io.Writer, entries []*IndexEntry) error {
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseIndexFile reads the entries of an index file
+func ParseIndexFile(r io.Reader) ([]*IndexEntry, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*IndexEntry
+	for _, line := range bytes.Split(content, []byte{'\n'}) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var e IndexEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cargo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexPath(t *testing.T) {
+	cases := map[string]string{
+		"a":       "1/a",
+		"ab":      "2/ab",
+		"abc":     "3/a/abc",
+		"Cargo":   "ca/rg/cargo",
+		"serde_a": "se/rd/serde_a",
+	}
+	for name, expected := range cases {
+		assert.Equal(t, expected, IndexPath(name))
+	}
+}
+
+func TestNewIndexEntry(t *testing.T) {
+	p, err := ParseManifestFile(strings.NewReader(manifestContent))
+	assert.NoError(t, err)
+
+	e := NewIndexEntry(p, "checksum")
+	assert.Equal(t, name, e.Name)
+	assert.Equal(t, packageVersion, e.Version)
+	assert.Equal(t, "checksum", e.Checksum)
+	assert.Equal(t, "git2", e.Links)
+	assert.Equal(t, "1.60", e.RustVersion)
+	assert.False(t, e.Yanked)
+	assert.Equal(t, map[string][]string{"default": {"log-feature"}, "log-feature": {}}, e.Features)
+	assert.Equal(t, map[string][]string{"serde": {"dep:serde"}}, e.Features2)
+	assert.Equal(t, 2, e.V)
+	assert.Len(t, e.Dependencies, 7)
+
+	b, err := json.Marshal(e.Dependencies[2])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"renamed","req":"2","features":[],"optional":false,"default_features":false,"target":null,"kind":"normal","registry":"https://example.com/index","package":"original"}`, string(b))
+
+	p.Metadata.Yanked = true
+	p.Metadata.Features = nil
+	e = NewIndexEntry(p, "checksum")
+	assert.True(t, e.Yanked)
+	assert.Empty(t, e.Features2)
+	assert.Zero(t, e.V)
+}
+
+func TestIndexFile(t *testing.T) {
+	entries := []*IndexEntry{
+		{Name: "crate", Version: "1.0.0", Dependencies: []*IndexDependency{}, Checksum: "a", Features: map[string][]string{}},
+		{Name: "crate", Version: "1.1.0", Dependencies: []*IndexDependency{}, Checksum: "b", Features: map[string][]string{}, Yanked: true},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteIndexFile(&buf, entries))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"name":"crate","vers":"1.0.0","deps":[],"cksum":"a","features":{},"yanked":false}`, lines[0])
+
+	parsed, err := ParseIndexFile(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, parsed)
+}
+
+func TestConfig(t *testing.T) {
+	b, err := json.Marshal(&Config{DownloadURL: "https://example.com/api/v1/crates", APIURL: "https://example.com", AuthRequired: true})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"dl":"https://example.com/api/v1/crates","api":"https://example.com","auth-required":true}`, string(b))
+}
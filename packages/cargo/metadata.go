@@ -0,0 +1,308 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cargo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gitbundle/modules/packages/versioning"
+	"github.com/gitbundle/modules/validation"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/go-version"
+)
+
+const (
+	// DependencyKindNormal is the kind of regular dependencies
+	DependencyKindNormal = "normal"
+	// DependencyKindDev is the kind of dev-dependencies
+	DependencyKindDev = "dev"
+	// DependencyKindBuild is the kind of build-dependencies
+	DependencyKindBuild = "build"
+)
+
+// VersionScheme defines the version semantics of Cargo packages
+var VersionScheme = versioning.Cargo
+
+var (
+	// ErrMissingManifestFile indicates a missing Cargo.toml file
+	ErrMissingManifestFile = errors.New("Cargo.toml file is missing")
+	// ErrInvalidName indicates an invalid package name
+	ErrInvalidName = errors.New("package name is invalid")
+	// ErrInvalidVersion indicates an invalid package version
+	ErrInvalidVersion = errors.New("package version is invalid")
+	// ErrInvalidDependency indicates an invalid dependency
+	ErrInvalidDependency = errors.New("dependency is invalid")
+)
+
+// Package represents a Cargo package
+type Package struct {
+	Name     string
+	Version  string
+	Metadata *Metadata
+}
+
+// Metadata represents the metadata of a Cargo package
+type Metadata struct {
+	Description      string              `json:"description,omitempty"`
+	Authors          []string            `json:"authors,omitempty"`
+	ProjectURL       string              `json:"project_url,omitempty"`
+	DocumentationURL string              `json:"documentation_url,omitempty"`
+	RepositoryURL    string              `json:"repository_url,omitempty"`
+	Keywords         []string            `json:"keywords,omitempty"`
+	Categories       []string            `json:"categories,omitempty"`
+	License          string              `json:"license,omitempty"`
+	Readme           string              `json:"readme,omitempty"`
+	Links            string              `json:"links,omitempty"`
+	RustVersion      string              `json:"rust_version,omitempty"`
+	Dependencies     []*Dependency       `json:"dependencies,omitempty"`
+	Features         map[string][]string `json:"features,omitempty"`
+	Yanked           bool                `json:"yanked,omitempty"`
+}
+
+// Dependency represents a dependency of a Cargo package
+type Dependency struct {
+	// Name is the name the dependency is used with, Package is set if it differs from the crate name
+	Name            string   `json:"name"`
+	Req             string   `json:"req"`
+	Features        []string `json:"features"`
+	Optional        bool     `json:"optional"`
+	DefaultFeatures bool     `json:"default_features"`
+	Target          string   `json:"target,omitempty"`
+	Kind            string   `json:"kind"`
+	Registry        string   `json:"registry,omitempty"`
+	Package         string   `json:"package,omitempty"`
+}
+
+// VersionRange parses the version requirement of the dependency
+func (d *Dependency) VersionRange() (versioning.Range, error) {
+	return VersionScheme.ParseRange(d.Req)
+}
+
+var nameMatch = regexp.MustCompile(`\A[a-zA-Z][a-zA-Z0-9-_]{0,63}\z`)
+
+// ParsePackage parses the metadata of a Cargo .crate archive
+func ParsePackage(r io.Reader) (*Package, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hd.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// the manifest is located in the <name>-<version> directory
+		if hd.FileInfo().Name() == "Cargo.toml" && strings.Count(hd.Name, "/") == 1 {
+			return ParseManifestFile(tr)
+		}
+	}
+
+	return nil, ErrMissingManifestFile
+}
+
+// manifestDependency is a dependency entry of Cargo.toml
+type manifestDependency struct {
+	Version         string
+	Features        []string
+	Optional        bool
+	DefaultFeatures *bool
+	Package         string
+	Registry        string
+	RegistryIndex   string
+	Path            string
+	Git             string
+}
+
+// UnmarshalTOML reads the short form `dep = "1.0"` and the table form
+func (d *manifestDependency) UnmarshalTOML(v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		d.Version = value
+		return nil
+	case map[string]interface{}:
+		for k, v := range value {
+			var ok bool
+			switch k {
+			case "version":
+				d.Version, ok = v.(string)
+			case "features":
+				var features []interface{}
+				features, ok = v.([]interface{})
+				for _, f := range features {
+					s, isString := f.(string)
+					ok = ok && isString
+					d.Features = append(d.Features, s)
+				}
+			case "optional":
+				d.Optional, ok = v.(bool)
+			case "default-features", "default_features":
+				var b bool
+				b, ok = v.(bool)
+				d.DefaultFeatures = &b
+			case "package":
+				d.Package, ok = v.(string)
+			case "registry":
+				d.Registry, ok = v.(string)
+			case "registry-index":
+				d.RegistryIndex, ok = v.(string)
+			case "path":
+				d.Path, ok = v.(string)
+			case "git":
+				d.Git, ok = v.(string)
+			default:
+				ok = true
+			}
+			if !ok {
+				return ErrInvalidDependency
+			}
+		}
+		return nil
+	}
+	return ErrInvalidDependency
+}
+
+type manifestDependencies struct {
+	Dependencies      map[string]manifestDependency `toml:"dependencies"`
+	DevDependencies   map[string]manifestDependency `toml:"dev-dependencies"`
+	BuildDependencies map[string]manifestDependency `toml:"build-dependencies"`
+}
+
+type manifest struct {
+	Package struct {
+		Name          string   `toml:"name"`
+		Version       string   `toml:"version"`
+		Description   string   `toml:"description"`
+		Authors       []string `toml:"authors"`
+		Homepage      string   `toml:"homepage"`
+		Documentation string   `toml:"documentation"`
+		Repository    string   `toml:"repository"`
+		Keywords      []string `toml:"keywords"`
+		Categories    []string `toml:"categories"`
+		License       string   `toml:"license"`
+		Readme        string   `toml:"readme"`
+		Links         string   `toml:"links"`
+		RustVersion   string   `toml:"rust-version"`
+	} `toml:"package"`
+	manifestDependencies
+	Target   map[string]manifestDependencies `toml:"target"`
+	Features map[string][]string             `toml:"features"`
+}
+
+// ParseManifestFile parses a Cargo.toml file to retrieve the metadata of a Cargo package
+func ParseManifestFile(r io.Reader) (*Package, error) {
+	var m manifest
+	if _, err := toml.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+
+	if !nameMatch.MatchString(m.Package.Name) {
+		return nil, ErrInvalidName
+	}
+
+	if _, err := version.NewSemver(m.Package.Version); err != nil {
+		return nil, ErrInvalidVersion
+	}
+
+	metadata := &Metadata{
+		Description:      m.Package.Description,
+		Authors:          m.Package.Authors,
+		ProjectURL:       m.Package.Homepage,
+		DocumentationURL: m.Package.Documentation,
+		RepositoryURL:    m.Package.Repository,
+		Keywords:         m.Package.Keywords,
+		Categories:       m.Package.Categories,
+		License:          m.Package.License,
+		Readme:           m.Package.Readme,
+		Links:            m.Package.Links,
+		RustVersion:      m.Package.RustVersion,
+		Features:         m.Features,
+	}
+
+	for _, u := range []*string{&metadata.ProjectURL, &metadata.DocumentationURL, &metadata.RepositoryURL} {
+		if !validation.IsValidURL(*u) {
+			*u = ""
+		}
+	}
+
+	metadata.Dependencies = append(metadata.Dependencies, convertDependencies(m.manifestDependencies, "")...)
+	targets := make([]string, 0, len(m.Target))
+	for target := range m.Target {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		metadata.Dependencies = append(metadata.Dependencies, convertDependencies(m.Target[target], target)...)
+	}
+
+	return &Package{
+		Name:     m.Package.Name,
+		Version:  m.Package.Version,
+		Metadata: metadata,
+	}, nil
+}
+
+func convertDependencies(deps manifestDependencies, target string) []*Dependency {
+	var result []*Dependency
+	for _, kind := range []struct {
+		name string
+		deps map[string]manifestDependency
+	}{
+		{DependencyKindNormal, deps.Dependencies},
+		{DependencyKindDev, deps.DevDependencies},
+		{DependencyKindBuild, deps.BuildDependencies},
+	} {
+		names := make([]string, 0, len(kind.deps))
+		for name := range kind.deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			md := kind.deps[name]
+			dep := &Dependency{
+				Name:            name,
+				Req:             md.Version,
+				Features:        md.Features,
+				Optional:        md.Optional,
+				DefaultFeatures: true,
+				Target:          target,
+				Kind:            kind.name,
+				Registry:        md.RegistryIndex,
+				Package:         md.Package,
+			}
+			if dep.Req == "" {
+				// path and git dependencies without a version are stripped when publishing
+				dep.Req = "*"
+			}
+			if dep.Features == nil {
+				dep.Features = []string{}
+			}
+			if md.DefaultFeatures != nil {
+				dep.DefaultFeatures = *md.DefaultFeatures
+			}
+			result = append(result, dep)
+		}
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cargo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	name           = "gitbundle-crate"
+	packageVersion = "1.2.3"
+	description    = "Package Description"
+	homepage       = "https://gitbundle.com"
+	license        = "MIT OR Apache-2.0"
+)
+
+const manifestContent = `[package]
+name = "` + name + `"
+version = "` + packageVersion + `"
+description = "` + description + `"
+authors = ["GitBundle Authors"]
+homepage = "` + homepage + `"
+documentation = "invalid url"
+license = "` + license + `"
+keywords = ["git", "bundle"]
+rust-version = "1.60"
+links = "git2"
+
+[dependencies]
+serde = { version = "1.0", features = ["derive"], optional = true }
+log = "0.4"
+local = { path = "../local" }
+renamed = { version = "2", package = "original", default-features = false, registry-index = "https://example.com/index" }
+
+[dev-dependencies]
+tempfile = "3"
+
+[build-dependencies]
+cc = "1.0"
+
+[target.'cfg(windows)'.dependencies]
+winapi = "0.3"
+
+[features]
+default = ["log-feature"]
+log-feature = []
+serde = ["dep:serde"]
+`
+
+func createArchive(files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		hdr := &tar.Header{
+			Name: name,
+			Mode: 0o600,
+			Size: int64(len(content)),
+		}
+		tw.WriteHeader(hdr)
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	return &buf
+}
+
+func TestParsePackage(t *testing.T) {
+	t.Run("MissingManifestFile", func(t *testing.T) {
+		data := createArchive(map[string]string{name + "-" + packageVersion + "/src/lib.rs": ""})
+
+		p, err := ParsePackage(data)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrMissingManifestFile)
+	})
+
+	t.Run("NestedManifestFile", func(t *testing.T) {
+		data := createArchive(map[string]string{name + "-" + packageVersion + "/sub/Cargo.toml": manifestContent})
+
+		p, err := ParsePackage(data)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrMissingManifestFile)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		data := createArchive(map[string]string{name + "-" + packageVersion + "/Cargo.toml": manifestContent})
+
+		p, err := ParsePackage(data)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+		assert.Equal(t, name, p.Name)
+		assert.Equal(t, packageVersion, p.Version)
+	})
+}
+
+func TestParseManifestFile(t *testing.T) {
+	t.Run("InvalidName", func(t *testing.T) {
+		for _, invalid := range []string{"", "1crate", "crate name", strings.Repeat("a", 65)} {
+			p, err := ParseManifestFile(strings.NewReader(`[package]
+name = "` + invalid + `"
+version = "1.0.0"`))
+			assert.Nil(t, p)
+			assert.ErrorIs(t, err, ErrInvalidName)
+		}
+	})
+
+	t.Run("InvalidVersion", func(t *testing.T) {
+		p, err := ParseManifestFile(strings.NewReader(`[package]
+name = "crate"
+version = "1.x"`))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidVersion)
+	})
+
+	t.Run("InvalidDependency", func(t *testing.T) {
+		p, err := ParseManifestFile(strings.NewReader(`[package]
+name = "crate"
+version = "1.0.0"
+
+[dependencies]
+log = 1`))
+		assert.Nil(t, p)
+		assert.Error(t, err)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		p, err := ParseManifestFile(strings.NewReader(manifestContent))
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+
+		assert.Equal(t, name, p.Name)
+		assert.Equal(t, packageVersion, p.Version)
+		assert.Equal(t, description, p.Metadata.Description)
+		assert.Equal(t, []string{"GitBundle Authors"}, p.Metadata.Authors)
+		assert.Equal(t, homepage, p.Metadata.ProjectURL)
+		assert.Empty(t, p.Metadata.DocumentationURL)
+		assert.Equal(t, license, p.Metadata.License)
+		assert.Equal(t, []string{"git", "bundle"}, p.Metadata.Keywords)
+		assert.Equal(t, "1.60", p.Metadata.RustVersion)
+		assert.Equal(t, "git2", p.Metadata.Links)
+		assert.False(t, p.Metadata.Yanked)
+		assert.Equal(t, map[string][]string{
+			"default":     {"log-feature"},
+			"log-feature": {},
+			"serde":       {"dep:serde"},
+		}, p.Metadata.Features)
+
+		assert.Equal(t, []*Dependency{
+			{Name: "local", Req: "*", Features: []string{}, DefaultFeatures: true, Kind: DependencyKindNormal},
+			{Name: "log", Req: "0.4", Features: []string{}, DefaultFeatures: true, Kind: DependencyKindNormal},
+			{Name: "renamed", Req: "2", Features: []string{}, DefaultFeatures: false, Kind: DependencyKindNormal, Registry: "https://example.com/index", Package: "original"},
+			{Name: "serde", Req: "1.0", Features: []string{"derive"}, Optional: true, DefaultFeatures: true, Kind: DependencyKindNormal},
+			{Name: "tempfile", Req: "3", Features: []string{}, DefaultFeatures: true, Kind: DependencyKindDev},
+			{Name: "cc", Req: "1.0", Features: []string{}, DefaultFeatures: true, Kind: DependencyKindBuild},
+			{Name: "winapi", Req: "0.3", Features: []string{}, DefaultFeatures: true, Kind: DependencyKindNormal, Target: "cfg(windows)"},
+		}, p.Metadata.Dependencies)
+	})
+}
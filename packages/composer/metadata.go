@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/packages/versioning"
 	"github.com/gitbundle/modules/validation"
 
 	"github.com/hashicorp/go-version"
@@ -30,6 +31,9 @@ var (
 	ErrInvalidVersion = errors.New("package version is invalid")
 )
 
+// VersionScheme defines the version semantics of Composer packages
+var VersionScheme = versioning.Composer
+
 // Package represents a Composer package
 type Package struct {
 	Name     string
@@ -0,0 +1,236 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package composer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/packages/versioning"
+)
+
+// https://getcomposer.org/doc/05-repositories.md#composer
+// https://github.com/composer/metadata-minifier
+
+const (
+	// MetadataURLPlaceholder is replaced by Composer with the name of the requested package
+	MetadataURLPlaceholder = "%package%"
+
+	minifiedFormat = "composer/2.0"
+	unsetValue     = "__unset"
+	devSuffix      = "~dev"
+
+	// timeFormat is the ISO 8601 format Composer expects
+	timeFormat = "2006-01-02T15:04:05-07:00"
+)
+
+var metadataPathSuffix = regexp.MustCompile(`\A(.+?)(~dev)?\.json\z`)
+
+// IsDevVersion tests if the version is a branch version like dev-main or 1.x-dev.
+// Dev versions are listed in the ~dev metadata document.
+func IsDevVersion(v string) bool {
+	normalized, err := NormalizeVersion(v)
+	if err != nil {
+		normalized = strings.TrimSpace(v)
+	}
+	normalized = strings.ToLower(normalized)
+	return strings.HasPrefix(normalized, "dev-") || strings.HasSuffix(normalized, "-dev")
+}
+
+// NormalizeVersion converts a version into the normalized form of Composer, like v1.2-beta1 into 1.2.0.0-beta1
+func NormalizeVersion(v string) (string, error) {
+	normalized, err := versioning.Normalize(VersionScheme, v)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+	return normalized, nil
+}
+
+// MetadataPath returns the path of the metadata document of a package relative to the repository root
+func MetadataPath(name string, dev bool) string {
+	if dev {
+		return "p2/" + name + devSuffix + ".json"
+	}
+	return "p2/" + name + ".json"
+}
+
+// ParseMetadataPath parses the package name and the dev flag from a metadata path like vendor/name~dev.json
+// with or without the p2/ prefix
+func ParseMetadataPath(p string) (string, bool, error) {
+	m := metadataPathSuffix.FindStringSubmatch(strings.TrimPrefix(p, "p2/"))
+	if m == nil || !nameMatch.MatchString(m[1]) {
+		return "", false, ErrInvalidName
+	}
+	return m[1], m[2] != "", nil
+}
+
+// WritePackagesFile writes the packages.json root document of a Composer 2 repository.
+// The metadata url must contain MetadataURLPlaceholder. availablePackages is optional and
+// lets Composer skip requests for packages which don't exist in the repository.
+func WritePackagesFile(w io.Writer, metadataURL string, availablePackages []string) error {
+	if !strings.Contains(metadataURL, MetadataURLPlaceholder) {
+		return fmt.Errorf("metadata url must contain %s", MetadataURLPlaceholder)
+	}
+
+	names := make([]string, len(availablePackages))
+	copy(names, availablePackages)
+	sort.Strings(names)
+
+	return json.NewEncoder(w).Encode(&struct {
+		Packages          []struct{} `json:"packages"`
+		MetadataURL       string     `json:"metadata-url"`
+		AvailablePackages []string   `json:"available-packages,omitempty"`
+	}{
+		Packages:          []struct{}{},
+		MetadataURL:       metadataURL,
+		AvailablePackages: names,
+	})
+}
+
+// PackageVersion is a stored version of a package with its distribution file
+type PackageVersion struct {
+	*Package
+	// DistURL is the download url of the zip archive, DistSHA1 the hex encoded SHA-1 of it
+	DistURL  string
+	DistSHA1 string
+	// Reference identifies the archive content, the SHA-1 is used if it's empty
+	Reference string
+	Time      time.Time
+}
+
+// entry returns the expanded metadata of the version, empty fields are omitted
+func (pv *PackageVersion) entry() (map[string]interface{}, error) {
+	normalized, err := NormalizeVersion(pv.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	reference := pv.Reference
+	if reference == "" {
+		reference = pv.DistSHA1
+	}
+
+	e := map[string]interface{}{
+		"name":               pv.Name,
+		"version":            pv.Version,
+		"version_normalized": normalized,
+		"type":               pv.Type,
+		"dist": map[string]string{
+			"type":      "zip",
+			"url":       pv.DistURL,
+			"reference": reference,
+			"shasum":    pv.DistSHA1,
+		},
+	}
+	if !pv.Time.IsZero() {
+		e["time"] = pv.Time.UTC().Format(timeFormat)
+	}
+
+	if m := pv.Metadata; m != nil {
+		set := func(key string, value interface{}, empty bool) {
+			if !empty {
+				e[key] = value
+			}
+		}
+		set("description", m.Description, m.Description == "")
+		set("keywords", m.Keywords, len(m.Keywords) == 0)
+		set("homepage", m.Homepage, m.Homepage == "")
+		set("license", m.License, len(m.License) == 0)
+		set("authors", m.Authors, len(m.Authors) == 0)
+		set("autoload", m.Autoload, len(m.Autoload) == 0)
+		set("autoload-dev", m.AutoloadDev, len(m.AutoloadDev) == 0)
+		set("extra", m.Extra, len(m.Extra) == 0)
+		set("require", m.Require, len(m.Require) == 0)
+		set("require-dev", m.RequireDev, len(m.RequireDev) == 0)
+		set("suggest", m.Suggest, len(m.Suggest) == 0)
+		set("provide", m.Provide, len(m.Provide) == 0)
+	}
+	return e, nil
+}
+
+// SplitDevVersions separates the tagged versions from the dev versions
+func SplitDevVersions(versions []*PackageVersion) (tagged, dev []*PackageVersion) {
+	for _, pv := range versions {
+		if IsDevVersion(pv.Version) {
+			dev = append(dev, pv)
+		} else {
+			tagged = append(tagged, pv)
+		}
+	}
+	return tagged, dev
+}
+
+// WriteMetadata writes the minified p2 metadata document of a package. The versions are listed newest first,
+// each version only contains the fields which differ from the previous one. Use SplitDevVersions to
+// create the regular and the ~dev document.
+func WriteMetadata(w io.Writer, name string, versions []*PackageVersion) error {
+	sorted := make([]*PackageVersion, len(versions))
+	copy(sorted, versions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		vi, err := VersionScheme.ParseVersion(sorted[i].Version)
+		if err != nil {
+			return false
+		}
+		vj, err := VersionScheme.ParseVersion(sorted[j].Version)
+		if err != nil {
+			return true
+		}
+		return vi.Compare(vj) > 0
+	})
+
+	minified := make([]map[string]interface{}, 0, len(sorted))
+	var expanded map[string][]byte
+	for _, pv := range sorted {
+		e, err := pv.entry()
+		if err != nil {
+			return err
+		}
+
+		encoded := make(map[string][]byte, len(e))
+		for key, value := range e {
+			b, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			encoded[key] = b
+		}
+
+		if expanded == nil {
+			expanded = encoded
+			minified = append(minified, e)
+			continue
+		}
+
+		m := make(map[string]interface{})
+		for key, value := range encoded {
+			if previous, ok := expanded[key]; !ok || !bytes.Equal(previous, value) {
+				m[key] = e[key]
+				expanded[key] = value
+			}
+		}
+		for key := range expanded {
+			if _, ok := encoded[key]; !ok {
+				m[key] = unsetValue
+				delete(expanded, key)
+			}
+		}
+		minified = append(minified, m)
+	}
+
+	return json.NewEncoder(w).Encode(&struct {
+		Minified string                              `json:"minified"`
+		Packages map[string][]map[string]interface{} `json:"packages"`
+	}{
+		Minified: minifiedFormat,
+		Packages: map[string][]map[string]interface{}{name: minified},
+	})
+}
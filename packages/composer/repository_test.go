@@ -0,0 +1,152 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package composer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeVersion(t *testing.T) {
+	for version, expected := range map[string]string{
+		"1.0.0":        "1.0.0.0",
+		"v1.2":         "1.2.0.0",
+		"1.2.3.4":      "1.2.3.4",
+		"1.0.0-beta1":  "1.0.0.0-beta1",
+		"1.0.0-b.2":    "1.0.0.0-beta2",
+		"1.0-rc1":      "1.0.0.0-RC1",
+		"1.0.0-pl3":    "1.0.0.0-patch3",
+		"1.0.0-stable": "1.0.0.0",
+		"1.0.0-dev":    "1.0.0.0-dev",
+		"1.0.0@beta":   "1.0.0.0",
+		"1.x-dev":      "1.9999999.9999999.9999999-dev",
+		"2.1.x-dev":    "2.1.9999999.9999999-dev",
+		"dev-main":     "dev-main",
+	} {
+		normalized, err := NormalizeVersion(version)
+		assert.NoError(t, err, version)
+		assert.Equal(t, expected, normalized, version)
+	}
+
+	for _, version := range []string{"", "dev-", "latest", "1.0.0-foo"} {
+		_, err := NormalizeVersion(version)
+		assert.ErrorIs(t, err, ErrInvalidVersion, version)
+	}
+
+	assert.True(t, IsDevVersion("dev-main"))
+	assert.True(t, IsDevVersion("1.x-dev"))
+	assert.True(t, IsDevVersion("1.0.0-beta1-dev"))
+	assert.False(t, IsDevVersion("1.0.0-beta1"))
+}
+
+func TestMetadataPath(t *testing.T) {
+	assert.Equal(t, "p2/gitbundle/package.json", MetadataPath("gitbundle/package", false))
+	assert.Equal(t, "p2/gitbundle/package~dev.json", MetadataPath("gitbundle/package", true))
+
+	n, dev, err := ParseMetadataPath("p2/gitbundle/package~dev.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "gitbundle/package", n)
+	assert.True(t, dev)
+
+	n, dev, err = ParseMetadataPath("gitbundle/package.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "gitbundle/package", n)
+	assert.False(t, dev)
+
+	for _, p := range []string{"p2/package.json", "p2/gitbundle/package", "p2/Gitbundle/package.json"} {
+		_, _, err = ParseMetadataPath(p)
+		assert.ErrorIs(t, err, ErrInvalidName, p)
+	}
+}
+
+func TestWritePackagesFile(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WritePackagesFile(&buf, "https://gitbundle.com/composer/p2/%package%.json", []string{"vendor/b", "vendor/a"}))
+	assert.JSONEq(t, `{"packages":[],"metadata-url":"https://gitbundle.com/composer/p2/%package%.json","available-packages":["vendor/a","vendor/b"]}`, buf.String())
+
+	assert.Error(t, WritePackagesFile(&buf, "https://gitbundle.com/composer/p2/", nil))
+}
+
+func TestWriteMetadata(t *testing.T) {
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	newVersion := func(version string, metadata *Metadata) *PackageVersion {
+		return &PackageVersion{
+			Package:  &Package{Name: name, Version: version, Type: "library", Metadata: metadata},
+			DistURL:  "https://gitbundle.com/files/" + version + ".zip",
+			DistSHA1: "sha1-" + version,
+			Time:     created,
+		}
+	}
+
+	versions := []*PackageVersion{
+		newVersion("1.0.0", &Metadata{Description: description, License: Licenses{license}, Require: map[string]string{"php": ">=7.2"}}),
+		newVersion("1.1.0", &Metadata{Description: description, License: Licenses{license}}),
+		newVersion("dev-main", &Metadata{Description: description}),
+		newVersion("2.0.0-beta1", &Metadata{Description: description, License: Licenses{license}}),
+	}
+
+	tagged, dev := SplitDevVersions(versions)
+	assert.Len(t, tagged, 3)
+	assert.Len(t, dev, 1)
+
+	writeMetadata := func(versions []*PackageVersion) []map[string]interface{} {
+		var buf bytes.Buffer
+		assert.NoError(t, WriteMetadata(&buf, name, versions))
+
+		var result struct {
+			Minified string                              `json:"minified"`
+			Packages map[string][]map[string]interface{} `json:"packages"`
+		}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &result))
+		assert.Equal(t, "composer/2.0", result.Minified)
+		return result.Packages[name]
+	}
+
+	entries := writeMetadata(tagged)
+	assert.Len(t, entries, 3)
+
+	assert.Equal(t, map[string]interface{}{
+		"name":               name,
+		"version":            "2.0.0-beta1",
+		"version_normalized": "2.0.0.0-beta1",
+		"type":               "library",
+		"description":        description,
+		"license":            []interface{}{license},
+		"time":               "2023-01-02T03:04:05+00:00",
+		"dist": map[string]interface{}{
+			"type":      "zip",
+			"url":       "https://gitbundle.com/files/2.0.0-beta1.zip",
+			"reference": "sha1-2.0.0-beta1",
+			"shasum":    "sha1-2.0.0-beta1",
+		},
+	}, entries[0])
+
+	assert.Equal(t, "1.1.0", entries[1]["version"])
+	assert.Equal(t, "1.1.0.0", entries[1]["version_normalized"])
+	assert.NotContains(t, entries[1], "name")
+	assert.NotContains(t, entries[1], "description")
+	assert.Contains(t, entries[1], "dist")
+
+	assert.Equal(t, "1.0.0", entries[2]["version"])
+	assert.Equal(t, map[string]interface{}{"php": ">=7.2"}, entries[2]["require"])
+
+	entries = writeMetadata(dev)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "dev-main", entries[0]["version_normalized"])
+
+	// a field missing in the following version is unset
+	entries = writeMetadata([]*PackageVersion{versions[1], newVersion("1.2.0", &Metadata{Require: map[string]string{"php": ">=8.0"}})})
+	assert.Equal(t, "1.2.0", entries[0]["version"])
+	assert.NotContains(t, entries[0], "description")
+	assert.Equal(t, "1.1.0", entries[1]["version"])
+	assert.Equal(t, "__unset", entries[1]["require"])
+	assert.Equal(t, description, entries[1]["description"])
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package conan
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+)
+
+// https://docs.conan.io/2/reference/conan_server.html
+
+// ErrRevisionNotFound indicates a reference without any revision
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision is a recipe or package revision with the time it was uploaded
+type Revision struct {
+	Revision string
+	Time     time.Time
+}
+
+type revisionInfo struct {
+	Revision string `json:"revision"`
+	Time     string `json:"time"`
+}
+
+func (r *Revision) info() *revisionInfo {
+	return &revisionInfo{
+		Revision: r.Revision,
+		Time:     r.Time.UTC().Format(time.RFC3339),
+	}
+}
+
+// SortRevisions returns the revisions sorted from newest to oldest.
+// Revisions uploaded at the same time keep their order.
+func SortRevisions(revisions []*Revision) []*Revision {
+	sorted := make([]*Revision, len(revisions))
+	copy(sorted, revisions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.After(sorted[j].Time)
+	})
+	return sorted
+}
+
+// LatestRevision returns the most recently uploaded revision. Conan resolves references without
+// revision and the latest endpoint to it, uploading an existing revision again makes it the latest one.
+func LatestRevision(revisions []*Revision) (*Revision, error) {
+	if len(revisions) == 0 {
+		return nil, ErrRevisionNotFound
+	}
+	return SortRevisions(revisions)[0], nil
+}
+
+func writeRevisions(w io.Writer, reference string, revisions []*Revision) error {
+	sorted := SortRevisions(revisions)
+
+	infos := make([]*revisionInfo, 0, len(sorted))
+	for _, r := range sorted {
+		infos = append(infos, r.info())
+	}
+
+	return json.NewEncoder(w).Encode(&struct {
+		Reference string          `json:"reference"`
+		Revisions []*revisionInfo `json:"revisions"`
+	}{
+		Reference: reference,
+		Revisions: infos,
+	})
+}
+
+// WriteRecipeRevisions writes the revision list of a recipe, newest first
+func WriteRecipeRevisions(w io.Writer, recipe *RecipeReference, revisions []*Revision) error {
+	return writeRevisions(w, recipe.WithRevision("").String(), revisions)
+}
+
+// WritePackageRevisions writes the revision list of a package, newest first
+func WritePackageRevisions(w io.Writer, pref *PackageReference, revisions []*Revision) error {
+	return writeRevisions(w, pref.Recipe.WithRevision(pref.Recipe.RevisionOrDefault()).String()+":"+pref.Reference, revisions)
+}
+
+// WriteLatestRevision writes the latest revision of a recipe or package
+func WriteLatestRevision(w io.Writer, revisions []*Revision) error {
+	latest, err := LatestRevision(revisions)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(latest.info())
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package conan
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevisions(t *testing.T) {
+	base := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	revisions := []*Revision{
+		{Revision: "aaa", Time: base},
+		{Revision: "ccc", Time: base.Add(2 * time.Hour)},
+		{Revision: "bbb", Time: base.Add(time.Hour)},
+	}
+
+	t.Run("Latest", func(t *testing.T) {
+		latest, err := LatestRevision(revisions)
+		assert.NoError(t, err)
+		assert.Equal(t, "ccc", latest.Revision)
+
+		latest, err = LatestRevision(nil)
+		assert.ErrorIs(t, err, ErrRevisionNotFound)
+		assert.Nil(t, latest)
+
+		var buf bytes.Buffer
+		assert.NoError(t, WriteLatestRevision(&buf, revisions))
+		assert.JSONEq(t, `{"revision":"ccc","time":"2023-01-02T05:04:05Z"}`, buf.String())
+
+		assert.ErrorIs(t, WriteLatestRevision(&buf, nil), ErrRevisionNotFound)
+	})
+
+	t.Run("RecipeRevisions", func(t *testing.T) {
+		rref, err := NewRecipeReference("name", "1.0", "user", "channel", "aaa")
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		assert.NoError(t, WriteRecipeRevisions(&buf, rref, revisions))
+		assert.JSONEq(t, `{
+			"reference":"name/1.0@user/channel",
+			"revisions":[
+				{"revision":"ccc","time":"2023-01-02T05:04:05Z"},
+				{"revision":"bbb","time":"2023-01-02T04:04:05Z"},
+				{"revision":"aaa","time":"2023-01-02T03:04:05Z"}
+			]
+		}`, buf.String())
+	})
+
+	t.Run("PackageRevisions", func(t *testing.T) {
+		rref, err := NewRecipeReference("name", "1.0", "", "", "rrev")
+		assert.NoError(t, err)
+		pref, err := NewPackageReference(rref, "pkgid", "")
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		assert.NoError(t, WritePackageRevisions(&buf, pref, revisions[:1]))
+		assert.JSONEq(t, `{
+			"reference":"name/1.0#rrev:pkgid",
+			"revisions":[{"revision":"aaa","time":"2023-01-02T03:04:05Z"}]
+		}`, buf.String())
+	})
+}
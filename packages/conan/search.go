@@ -0,0 +1,225 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package conan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSearchQuery indicates a package search query which can't be parsed
+var ErrInvalidSearchQuery = errors.New("search query is invalid")
+
+const (
+	settingsPrefix = "settings."
+	optionsPrefix  = "options."
+	noneValue      = "None"
+)
+
+// SearchQuery is a parsed package search query like os=Linux AND (arch=x86_64 OR arch=armv8)
+type SearchQuery struct {
+	root queryNode
+}
+
+type queryNode interface {
+	match(info *Conaninfo) bool
+}
+
+type queryAnd []queryNode
+
+func (q queryAnd) match(info *Conaninfo) bool {
+	for _, n := range q {
+		if !n.match(info) {
+			return false
+		}
+	}
+	return true
+}
+
+type queryOr []queryNode
+
+func (q queryOr) match(info *Conaninfo) bool {
+	for _, n := range q {
+		if n.match(info) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryCondition compares a setting or option. The key may be prefixed with settings. or options.,
+// otherwise the settings are searched before the options. The value None matches a missing key.
+type queryCondition struct {
+	key   string
+	value string
+}
+
+func (c *queryCondition) match(info *Conaninfo) bool {
+	var value string
+	var ok bool
+	switch {
+	case strings.HasPrefix(c.key, settingsPrefix):
+		value, ok = info.Settings[strings.TrimPrefix(c.key, settingsPrefix)]
+	case strings.HasPrefix(c.key, optionsPrefix):
+		value, ok = info.Options[strings.TrimPrefix(c.key, optionsPrefix)]
+	default:
+		if value, ok = info.Settings[c.key]; !ok {
+			value, ok = info.Options[c.key]
+		}
+	}
+	if !ok {
+		return c.value == noneValue
+	}
+	return value == c.value
+}
+
+// ParseSearchQuery parses a query of key=value conditions combined with AND, OR and parentheses.
+// AND binds stronger than OR. An empty query matches all packages.
+func ParseSearchQuery(query string) (*SearchQuery, error) {
+	p := &queryParser{tokens: tokenizeQuery(query)}
+	if len(p.tokens) == 0 {
+		return &SearchQuery{}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %s", ErrInvalidSearchQuery, p.tokens[p.pos])
+	}
+	return &SearchQuery{root: root}, nil
+}
+
+// Match tests if the package info matches the query
+func (q *SearchQuery) Match(info *Conaninfo) bool {
+	if q.root == nil {
+		return true
+	}
+	if info == nil {
+		info = &Conaninfo{}
+	}
+	return q.root.match(info)
+}
+
+// FilterPackages returns the packages whose info matches the query, keyed by package reference
+func (q *SearchQuery) FilterPackages(packages map[string]*Conaninfo) map[string]*Conaninfo {
+	result := make(map[string]*Conaninfo)
+	for reference, info := range packages {
+		if q.Match(info) {
+			result[reference] = info
+		}
+	}
+	return result
+}
+
+// tokenizeQuery splits the query into parentheses, = and words
+func tokenizeQuery(query string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range query {
+		switch r {
+		case '(', ')', '=':
+			flush()
+			tokens = append(tokens, string(r))
+		case ' ', '\t', '\r', '\n':
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	var nodes queryOr
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if p.peek() != "OR" {
+			break
+		}
+		p.next()
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes queryAnd
+	for {
+		n, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if p.peek() != "AND" {
+			break
+		}
+		p.next()
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseTerm() (queryNode, error) {
+	switch t := p.next(); t {
+	case "(":
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("%w: missing )", ErrInvalidSearchQuery)
+		}
+		return n, nil
+	case "", ")", "=", "AND", "OR":
+		return nil, fmt.Errorf("%w: expected condition", ErrInvalidSearchQuery)
+	default:
+		if p.next() != "=" {
+			return nil, fmt.Errorf("%w: expected = after %s", ErrInvalidSearchQuery, t)
+		}
+		value := p.next()
+		switch value {
+		case "", "(", ")", "=", "AND", "OR":
+			return nil, fmt.Errorf("%w: expected value for %s", ErrInvalidSearchQuery, t)
+		}
+		return &queryCondition{key: t, value: value}, nil
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package conan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQuery(t *testing.T) {
+	linux := &Conaninfo{
+		Settings: map[string]string{"os": "Linux", "arch": "x86_64", "compiler.version": "12"},
+		Options:  map[string]string{"shared": "True"},
+	}
+	windows := &Conaninfo{
+		Settings: map[string]string{"os": "Windows", "arch": "armv8"},
+		Options:  map[string]string{"shared": "False"},
+	}
+
+	cases := []struct {
+		Query   string
+		Linux   bool
+		Windows bool
+	}{
+		{"", true, true},
+		{"os=Linux", true, false},
+		{"os = Windows", false, true},
+		{"os=Linux AND arch=x86_64", true, false},
+		{"os=Linux AND arch=armv8", false, false},
+		{"os=Linux OR arch=armv8", true, true},
+		{"arch=armv8 OR os=Linux AND shared=False", false, true},
+		{"os=Linux AND shared=True OR arch=armv8", true, true},
+		{"(arch=armv8 OR os=Linux) AND shared=False", false, true},
+		{"compiler.version=12", true, false},
+		{"compiler.version=None", false, true},
+		{"settings.os=Linux", true, false},
+		{"options.shared=True", true, false},
+		{"settings.shared=True", false, false},
+		{"os=linux", false, false},
+	}
+
+	for _, c := range cases {
+		q, err := ParseSearchQuery(c.Query)
+		assert.NoError(t, err, c.Query)
+		assert.Equal(t, c.Linux, q.Match(linux), c.Query)
+		assert.Equal(t, c.Windows, q.Match(windows), c.Query)
+	}
+
+	q, err := ParseSearchQuery("os=Linux")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Conaninfo{"linux": linux}, q.FilterPackages(map[string]*Conaninfo{"linux": linux, "windows": windows}))
+
+	for _, query := range []string{"os", "os=", "=Linux", "os=Linux AND", "(os=Linux", "os=Linux)", "os=Linux arch=x86_64", "AND os=Linux"} {
+		_, err := ParseSearchQuery(query)
+		assert.ErrorIs(t, err, ErrInvalidSearchQuery, query)
+	}
+}
@@ -14,6 +14,7 @@ const (
 	MediaTypeImageIndex         = "application/vnd.oci.image.index.v1+json"
 	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
 	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeEmptyJSON          = "application/vnd.oci.empty.v1+json"
 )
 
 type MediaType string
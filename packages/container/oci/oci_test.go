@@ -0,0 +1,140 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	digestA = Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	digestB = Digest("sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	digestC = Digest("sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc")
+)
+
+func TestParseReferrer(t *testing.T) {
+	t.Run("NoSubject", func(t *testing.T) {
+		subject, referrer, err := ParseReferrer(MediaTypeImageManifest, []byte(`{"schemaVersion":2,"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"`+string(digestA)+`","size":1},"layers":[]}`))
+		assert.NoError(t, err)
+		assert.Nil(t, subject)
+		assert.Nil(t, referrer)
+	})
+
+	t.Run("ImageManifest", func(t *testing.T) {
+		content := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.example.sbom","digest":"` + string(digestA) + `","size":1},"layers":[],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"` + string(digestB) + `","size":10},"annotations":{"a":"b"}}`)
+		sum := sha256.Sum256(content)
+
+		subject, referrer, err := ParseReferrer(MediaTypeImageManifest, content)
+		assert.NoError(t, err)
+		assert.Equal(t, digestB, subject.Digest)
+		assert.Equal(t, &Descriptor{
+			MediaType:    MediaTypeImageManifest,
+			ArtifactType: "application/vnd.example.sbom",
+			Digest:       Digest("sha256:" + hex.EncodeToString(sum[:])),
+			Size:         int64(len(content)),
+			Annotations:  map[string]string{"a": "b"},
+		}, referrer)
+	})
+
+	t.Run("Artifact", func(t *testing.T) {
+		subject, referrer, err := ParseReferrer(MediaTypeImageManifest, []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.example.signature","config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"`+string(digestC)+`","size":2},"layers":[{"mediaType":"application/octet-stream","digest":"`+string(digestA)+`","size":1}],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"`+string(digestB)+`","size":10}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, digestB, subject.Digest)
+		assert.Equal(t, "application/vnd.example.signature", referrer.ArtifactType)
+		assert.Equal(t, MediaType(MediaTypeImageManifest), referrer.MediaType)
+
+		_, _, err = ParseReferrer(MediaTypeImageManifest, []byte(`{"schemaVersion":2,"config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"`+string(digestC)+`","size":2},"layers":[],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"`+string(digestB)+`","size":10}}`))
+		assert.ErrorIs(t, err, ErrMissingArtifactType)
+
+		_, _, err = ParseReferrer(MediaTypeImageManifest, []byte(`{"schemaVersion":2,"artifactType":"a","config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"`+string(digestC)+`","size":2},"layers":[],"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:invalid","size":10}}`))
+		assert.ErrorIs(t, err, ErrInvalidDigest)
+
+		// the removed artifact manifest isn't accepted
+		_, _, err = ParseReferrer("application/vnd.oci.artifact.manifest.v1+json", []byte(`{"mediaType":"application/vnd.oci.artifact.manifest.v1+json","artifactType":"a"}`))
+		assert.ErrorIs(t, err, ErrInvalidMediaType)
+	})
+
+	t.Run("InvalidMediaType", func(t *testing.T) {
+		_, _, err := ParseReferrer("application/json", []byte(`{}`))
+		assert.ErrorIs(t, err, ErrInvalidMediaType)
+	})
+}
+
+func TestBuildReferrersIndex(t *testing.T) {
+	referrers := []Descriptor{
+		{MediaType: MediaTypeImageManifest, ArtifactType: "application/vnd.example.sbom", Digest: digestA, Size: 1},
+		{MediaType: MediaTypeImageManifest, ArtifactType: "application/vnd.example.signature", Digest: digestB, Size: 2},
+	}
+
+	index := BuildReferrersIndex(referrers, "")
+	assert.Equal(t, 2, index.SchemaVersion)
+	assert.Equal(t, MediaType(MediaTypeImageIndex), index.MediaType)
+	assert.Equal(t, referrers, index.Manifests)
+
+	index = BuildReferrersIndex(referrers, "application/vnd.example.signature")
+	assert.Equal(t, referrers[1:], index.Manifests)
+
+	index = BuildReferrersIndex(nil, "application/vnd.example.other")
+	assert.NotNil(t, index.Manifests)
+	assert.Empty(t, index.Manifests)
+}
+
+func TestIndexValidate(t *testing.T) {
+	manifest := func(digest Digest, platform *Platform) Descriptor {
+		return Descriptor{MediaType: MediaTypeImageManifest, Digest: digest, Size: 1, Platform: platform}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		index := &Index{
+			SchemaMediaBase: SchemaMediaBase{SchemaVersion: 2, MediaType: MediaTypeImageIndex},
+			Manifests: []Descriptor{
+				manifest(digestA, &Platform{OS: "linux", Architecture: "amd64"}),
+				manifest(digestB, &Platform{OS: "linux", Architecture: "arm", Variant: "v7"}),
+				{
+					MediaType:   MediaTypeImageManifest,
+					Digest:      digestC,
+					Platform:    &Platform{OS: "unknown", Architecture: "unknown"},
+					Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest"},
+				},
+			},
+		}
+		assert.NoError(t, index.Validate())
+	})
+
+	t.Run("MissingPlatform", func(t *testing.T) {
+		index := &Index{Manifests: []Descriptor{manifest(digestA, nil)}}
+		assert.ErrorIs(t, index.Validate(), ErrMissingPlatform)
+	})
+
+	t.Run("DuplicatePlatform", func(t *testing.T) {
+		index := &Index{Manifests: []Descriptor{
+			manifest(digestA, &Platform{OS: "linux", Architecture: "arm", Variant: "v7"}),
+			manifest(digestB, &Platform{OS: "linux", Architecture: "arm", Variant: "v7"}),
+		}}
+		err := index.Validate()
+		assert.ErrorIs(t, err, ErrDuplicatePlatform)
+		assert.True(t, strings.HasSuffix(err.Error(), "linux/arm/v7"))
+
+		index.Manifests[1].Platform.OSFeatures = []string{"sse4"}
+		assert.NoError(t, index.Validate())
+	})
+
+	t.Run("InvalidDescriptor", func(t *testing.T) {
+		index := &Index{Manifests: []Descriptor{manifest("sha256:invalid", &Platform{OS: "linux", Architecture: "amd64"})}}
+		assert.ErrorIs(t, index.Validate(), ErrInvalidDigest)
+
+		index = &Index{Manifests: []Descriptor{{MediaType: "text/plain", Digest: digestA}}}
+		assert.ErrorIs(t, index.Validate(), ErrInvalidMediaType)
+
+		index = &Index{SchemaMediaBase: SchemaMediaBase{MediaType: MediaTypeImageManifest}}
+		assert.ErrorIs(t, index.Validate(), ErrInvalidMediaType)
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gitbundle/modules/json"
+)
+
+// HeaderFiltersApplied is the response header listing the filters applied to a referrers index
+const HeaderFiltersApplied = "OCI-Filters-Applied"
+
+// FilterArtifactType is the name of the artifact type filter of the referrers API
+const FilterArtifactType = "artifactType"
+
+// ReferrerDescriptor returns the descriptor listed in the referrers index of the subject.
+// The artifact type falls back to the media type of the config as required by the spec.
+func (m *Manifest) ReferrerDescriptor(digest Digest, size int64) Descriptor {
+	artifactType := m.ArtifactType
+	if artifactType == "" {
+		artifactType = string(m.Config.MediaType)
+	}
+	mediaType := m.MediaType
+	if mediaType == "" {
+		mediaType = MediaTypeImageManifest
+	}
+	return Descriptor{
+		MediaType:    mediaType,
+		ArtifactType: artifactType,
+		Digest:       digest,
+		Size:         size,
+		Annotations:  m.Annotations,
+	}
+}
+
+// ReferrerDescriptor returns the descriptor listed in the referrers index of the subject
+func (i *Index) ReferrerDescriptor(digest Digest, size int64) Descriptor {
+	mediaType := i.MediaType
+	if mediaType == "" {
+		mediaType = MediaTypeImageIndex
+	}
+	return Descriptor{
+		MediaType:    mediaType,
+		ArtifactType: i.ArtifactType,
+		Digest:       digest,
+		Size:         size,
+		Annotations:  i.Annotations,
+	}
+}
+
+// ParseReferrer parses a manifest and returns its subject and the descriptor
+// which must be added to the referrers index of the subject.
+// The subject is nil if the manifest does not reference another manifest.
+func ParseReferrer(mediaType MediaType, content []byte) (*Descriptor, *Descriptor, error) {
+	sum := sha256.Sum256(content)
+	digest := Digest("sha256:" + hex.EncodeToString(sum[:]))
+	size := int64(len(content))
+
+	var subject *Descriptor
+	var referrer Descriptor
+
+	switch {
+	case mediaType.IsImageIndex():
+		var i Index
+		if err := json.Unmarshal(content, &i); err != nil {
+			return nil, nil, err
+		}
+		subject, referrer = i.Subject, i.ReferrerDescriptor(digest, size)
+	case mediaType.IsImageManifest():
+		var m Manifest
+		if err := json.Unmarshal(content, &m); err != nil {
+			return nil, nil, err
+		}
+		// artifacts without a config use the empty config and must declare their type
+		if m.Config.MediaType == MediaTypeEmptyJSON && m.ArtifactType == "" {
+			return nil, nil, ErrMissingArtifactType
+		}
+		subject, referrer = m.Subject, m.ReferrerDescriptor(digest, size)
+	default:
+		return nil, nil, ErrInvalidMediaType
+	}
+
+	if subject == nil {
+		return nil, nil, nil
+	}
+	if !subject.Digest.Validate() {
+		return nil, nil, ErrInvalidDigest
+	}
+	referrer.MediaType = mediaType
+	return subject, &referrer, nil
+}
+
+// BuildReferrersIndex creates the image index returned by the referrers API.
+// If artifactType is not empty only referrers of that type are listed and
+// FilterArtifactType should be sent in the HeaderFiltersApplied header.
+func BuildReferrersIndex(referrers []Descriptor, artifactType string) *Index {
+	manifests := make([]Descriptor, 0, len(referrers))
+	for _, d := range referrers {
+		if artifactType != "" && d.ArtifactType != artifactType {
+			continue
+		}
+		manifests = append(manifests, d)
+	}
+
+	return &Index{
+		SchemaMediaBase: SchemaMediaBase{
+			SchemaVersion: 2,
+			MediaType:     MediaTypeImageIndex,
+		},
+		Manifests: manifests,
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalidMediaType indicates an unsupported media type
+	ErrInvalidMediaType = errors.New("invalid media type")
+	// ErrInvalidDigest indicates an invalid digest
+	ErrInvalidDigest = errors.New("invalid digest")
+	// ErrMissingArtifactType indicates an artifact without artifact type
+	ErrMissingArtifactType = errors.New("artifact type is missing")
+	// ErrMissingPlatform indicates an image index entry without platform
+	ErrMissingPlatform = errors.New("platform is missing")
+	// ErrDuplicatePlatform indicates an image index with multiple manifests for the same platform
+	ErrDuplicatePlatform = errors.New("platform is referenced multiple times")
+)
+
+const (
+	// docker buildx stores attestations as index entries with an unknown platform
+	annotationDockerReferenceType = "vnd.docker.reference.type"
+	platformUnknown               = "unknown"
+)
+
+// String returns the platform in the os/arch[/variant] notation
+func (p *Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// key identifies the platform including the OS version and features
+func (p *Platform) key() string {
+	features := append([]string(nil), p.OSFeatures...)
+	sort.Strings(features)
+	return p.String() + "|" + p.OSVersion + "|" + strings.Join(features, ",")
+}
+
+// Validate checks the media type and digest of a descriptor
+func (d *Descriptor) Validate() error {
+	if !d.MediaType.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidMediaType, d.MediaType)
+	}
+	if !d.Digest.Validate() {
+		return fmt.Errorf("%w: %s", ErrInvalidDigest, d.Digest)
+	}
+	return nil
+}
+
+// Validate checks if the image index is a valid cross-platform index.
+// Every referenced image manifest must declare its platform and each platform
+// may only be referenced once. Attestation manifests are exempt from this rule.
+func (i *Index) Validate() error {
+	if i.MediaType != "" && !i.MediaType.IsImageIndex() {
+		return fmt.Errorf("%w: %s", ErrInvalidMediaType, i.MediaType)
+	}
+	if i.Subject != nil {
+		if err := i.Subject.Validate(); err != nil {
+			return err
+		}
+	}
+
+	platforms := make(map[string]bool, len(i.Manifests))
+	for _, m := range i.Manifests {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		if !m.MediaType.IsImageManifest() {
+			continue
+		}
+		if _, ok := m.Annotations[annotationDockerReferenceType]; ok {
+			continue
+		}
+		if m.Platform == nil || m.Platform.OS == "" || m.Platform.Architecture == "" {
+			return fmt.Errorf("%w: %s", ErrMissingPlatform, m.Digest)
+		}
+		if m.Platform.OS == platformUnknown && m.Platform.Architecture == platformUnknown {
+			continue
+		}
+		key := m.Platform.key()
+		if platforms[key] {
+			return fmt.Errorf("%w: %s", ErrDuplicatePlatform, m.Platform)
+		}
+		platforms[key] = true
+	}
+	return nil
+}
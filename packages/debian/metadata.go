@@ -0,0 +1,278 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debian
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gitbundle/modules/validation"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// arMagic is the signature of ar archives
+	arMagic = "!<arch>\n"
+	// arHeaderSize is the size of a file header in ar archives
+	arHeaderSize = 60
+
+	controlTar = "control.tar"
+
+	// maxControlFileSize limits the size of the control file
+	maxControlFileSize = 1 << 20
+)
+
+var (
+	// ErrMissingControlFile indicates a missing control file
+	ErrMissingControlFile = errors.New("control file is missing")
+	// ErrInvalidArchive indicates a file which is no valid .deb archive
+	ErrInvalidArchive = errors.New("package archive is invalid")
+	// ErrUnsupportedCompression indicates an unsupported compression of the control archive
+	ErrUnsupportedCompression = errors.New("unsupported compression algorithm")
+	// ErrInvalidName indicates an invalid package name
+	ErrInvalidName = errors.New("package name is invalid")
+	// ErrInvalidVersion indicates an invalid package version
+	ErrInvalidVersion = errors.New("package version is invalid")
+	// ErrInvalidArchitecture indicates an invalid package architecture
+	ErrInvalidArchitecture = errors.New("package architecture is invalid")
+)
+
+var (
+	// https://www.debian.org/doc/debian-policy/ch-controlfields.html#source
+	namePattern = regexp.MustCompile(`\A[a-z0-9][a-z0-9+\-.]+\z`)
+	// https://www.debian.org/doc/debian-policy/ch-controlfields.html#version
+	versionPattern = regexp.MustCompile(`\A(?:[0-9]+:)?[a-zA-Z0-9.+~]+(?:-[a-zA-Z0-9.+\-~]+)?\z`)
+)
+
+// Package represents a Debian package
+type Package struct {
+	Name         string
+	Version      string
+	Architecture string
+	// Control is the content of the control file
+	Control  string
+	Metadata *Metadata
+}
+
+// Metadata represents the metadata of a Debian package
+type Metadata struct {
+	Maintainer    string   `json:"maintainer,omitempty"`
+	ProjectURL    string   `json:"project_url,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Section       string   `json:"section,omitempty"`
+	Priority      string   `json:"priority,omitempty"`
+	InstalledSize int64    `json:"installed_size,omitempty"`
+	Dependencies  []string `json:"dependencies,omitempty"`
+}
+
+// ParsePackage parses the metadata of a .deb archive
+func ParsePackage(r io.Reader) (*Package, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != arMagic {
+		return nil, ErrInvalidArchive
+	}
+
+	header := make([]byte, arHeaderSize)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF {
+				return nil, ErrMissingControlFile
+			}
+			return nil, ErrInvalidArchive
+		}
+		if header[58] != '`' || header[59] != '\n' {
+			return nil, ErrInvalidArchive
+		}
+
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || size < 0 {
+			return nil, ErrInvalidArchive
+		}
+
+		if strings.HasPrefix(name, controlTar) {
+			var inner io.Reader
+			content := io.LimitReader(br, size)
+			switch strings.TrimPrefix(name, controlTar) {
+			case "":
+				inner = content
+			case ".gz":
+				gzr, err := gzip.NewReader(content)
+				if err != nil {
+					return nil, err
+				}
+				defer gzr.Close()
+				inner = gzr
+			case ".xz":
+				xzr, err := xz.NewReader(content)
+				if err != nil {
+					return nil, err
+				}
+				inner = xzr
+			case ".zst":
+				zr, err := zstd.NewReader(content)
+				if err != nil {
+					return nil, err
+				}
+				defer zr.Close()
+				inner = zr
+			default:
+				return nil, ErrUnsupportedCompression
+			}
+			return parseControlArchive(inner)
+		}
+
+		// entries are aligned to an even offset
+		if _, err := br.Discard(int(size + size%2)); err != nil {
+			return nil, ErrInvalidArchive
+		}
+	}
+}
+
+func parseControlArchive(r io.Reader) (*Package, error) {
+	tr := tar.NewReader(r)
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrMissingControlFile
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hd.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if strings.TrimPrefix(hd.Name, "./") == "control" {
+			return ParseControlFile(io.LimitReader(tr, maxControlFileSize))
+		}
+	}
+}
+
+// ParseControlFile parses a Debian control file to retrieve the metadata
+func ParseControlFile(r io.Reader) (*Package, error) {
+	p := &Package{
+		Metadata: &Metadata{},
+	}
+
+	var control strings.Builder
+	var key string
+	var description strings.Builder
+	var depends string
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			// a control file contains a single paragraph
+			if control.Len() > 0 {
+				break
+			}
+			continue
+		}
+
+		control.WriteString(line)
+		control.WriteByte('\n')
+
+		if line[0] == ' ' || line[0] == '\t' {
+			switch key {
+			case "Description":
+				description.WriteByte('\n')
+				description.WriteString(trimmed)
+			case "Depends":
+				depends += " " + trimmed
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key = strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		switch key {
+		case "Package":
+			p.Name = value
+		case "Version":
+			p.Version = value
+		case "Architecture":
+			p.Architecture = value
+		case "Maintainer":
+			p.Metadata.Maintainer = value
+		case "Homepage":
+			if validation.IsValidURL(value) {
+				p.Metadata.ProjectURL = value
+			}
+		case "Description":
+			description.WriteString(value)
+		case "Section":
+			p.Metadata.Section = value
+		case "Priority":
+			p.Metadata.Priority = value
+		case "Installed-Size":
+			p.Metadata.InstalledSize, _ = strconv.ParseInt(value, 10, 64)
+		case "Depends":
+			depends = value
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if !namePattern.MatchString(p.Name) {
+		return nil, ErrInvalidName
+	}
+	if !versionPattern.MatchString(p.Version) {
+		return nil, ErrInvalidVersion
+	}
+	if p.Architecture == "" || strings.ContainsAny(p.Architecture, " /\t") {
+		return nil, ErrInvalidArchitecture
+	}
+
+	p.Metadata.Description = description.String()
+	for _, dep := range strings.Split(depends, ",") {
+		if dep = strings.TrimSpace(dep); dep != "" {
+			p.Metadata.Dependencies = append(p.Metadata.Dependencies, dep)
+		}
+	}
+	p.Control = strings.TrimSuffix(control.String(), "\n")
+
+	return p, nil
+}
+
+// Filename returns the canonical file name of the package
+func (p *Package) Filename() string {
+	// the epoch is not part of the file name
+	version := p.Version
+	if idx := strings.IndexByte(version, ':'); idx != -1 {
+		version = version[idx+1:]
+	}
+	return fmt.Sprintf("%s_%s_%s.deb", p.Name, version, p.Architecture)
+}
+
+// PoolPath returns the path of the package in the pool of a repository
+func (p *Package) PoolPath(component string) string {
+	prefix := p.Name[0:1]
+	if strings.HasPrefix(p.Name, "lib") && len(p.Name) > 3 {
+		prefix = p.Name[0:4]
+	}
+	return path.Join("pool", component, prefix, p.Name, p.Filename())
+}
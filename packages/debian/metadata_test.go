@@ -0,0 +1,190 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debian
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	packageName         = "gitbundle"
+	packageVersion      = "1:1.0.0-1"
+	packageArchitecture = "amd64"
+	maintainer          = "GitBundle Authors <no.reply@gitbundle.com>"
+	homepage            = "https://gitbundle.com"
+)
+
+const controlContent = `Package: ` + packageName + `
+Version: ` + packageVersion + `
+Architecture: ` + packageArchitecture + `
+Maintainer: ` + maintainer + `
+Installed-Size: 1024
+Section: vcs
+Priority: optional
+Homepage: ` + homepage + `
+Depends: libc6 (>= 2.34),
+ git
+Description: Git hosting
+ Multi line
+ description
+`
+
+func createArchive(controlName string, files map[string]string) *bytes.Buffer {
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0o600,
+			Size: int64(len(content)),
+		})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+
+	var control bytes.Buffer
+	var w io.WriteCloser
+	switch {
+	case strings.HasSuffix(controlName, ".gz"):
+		w = gzip.NewWriter(&control)
+	case strings.HasSuffix(controlName, ".xz"):
+		w, _ = xz.NewWriter(&control)
+	case strings.HasSuffix(controlName, ".zst"):
+		w, _ = zstd.NewWriter(&control)
+	default:
+		w = &nopWriteCloser{&control}
+	}
+	w.Write(tarBuf.Bytes())
+	w.Close()
+
+	var buf bytes.Buffer
+	buf.WriteString(arMagic)
+	for _, entry := range []struct {
+		name    string
+		content []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{controlName, control.Bytes()},
+		{"data.tar.gz", []byte("odd")},
+	} {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", entry.name, 0, 0, 0, "100644", len(entry.content))
+		buf.Write(entry.content)
+		if len(entry.content)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return &buf
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestParsePackage(t *testing.T) {
+	t.Run("InvalidArchive", func(t *testing.T) {
+		p, err := ParsePackage(strings.NewReader("dummy"))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidArchive)
+	})
+
+	t.Run("UnsupportedCompression", func(t *testing.T) {
+		data := createArchive("control.tar.bz2", map[string]string{"./control": controlContent})
+
+		p, err := ParsePackage(data)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrUnsupportedCompression)
+	})
+
+	t.Run("MissingControlFile", func(t *testing.T) {
+		data := createArchive("control.tar.gz", map[string]string{"./md5sums": ""})
+
+		p, err := ParsePackage(data)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrMissingControlFile)
+	})
+
+	for _, name := range []string{"control.tar", "control.tar.gz", "control.tar.xz", "control.tar.zst"} {
+		t.Run(name, func(t *testing.T) {
+			data := createArchive(name, map[string]string{"./md5sums": "", "./control": controlContent})
+
+			p, err := ParsePackage(data)
+			assert.NoError(t, err)
+			assert.NotNil(t, p)
+			assert.Equal(t, packageName, p.Name)
+			assert.Equal(t, packageVersion, p.Version)
+			assert.Equal(t, packageArchitecture, p.Architecture)
+		})
+	}
+}
+
+func TestParseControlFile(t *testing.T) {
+	buildContent := func(name, version, architecture string) string {
+		return "Package: " + name + "\nVersion: " + version + "\nArchitecture: " + architecture + "\n"
+	}
+
+	t.Run("InvalidName", func(t *testing.T) {
+		for _, name := range []string{"", "-cd", "Gitbundle", "a"} {
+			p, err := ParseControlFile(strings.NewReader(buildContent(name, packageVersion, packageArchitecture)))
+			assert.Nil(t, p)
+			assert.ErrorIs(t, err, ErrInvalidName)
+		}
+	})
+
+	t.Run("InvalidVersion", func(t *testing.T) {
+		for _, version := range []string{"", "1.0 0", "a:1.0"} {
+			p, err := ParseControlFile(strings.NewReader(buildContent(packageName, version, packageArchitecture)))
+			assert.Nil(t, p)
+			assert.ErrorIs(t, err, ErrInvalidVersion)
+		}
+	})
+
+	t.Run("InvalidArchitecture", func(t *testing.T) {
+		for _, architecture := range []string{"", "amd 64", "a/b"} {
+			p, err := ParseControlFile(strings.NewReader(buildContent(packageName, packageVersion, architecture)))
+			assert.Nil(t, p)
+			assert.ErrorIs(t, err, ErrInvalidArchitecture)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		p, err := ParseControlFile(strings.NewReader(controlContent + "\nPackage: second-paragraph\n"))
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+
+		assert.Equal(t, packageName, p.Name)
+		assert.Equal(t, packageVersion, p.Version)
+		assert.Equal(t, packageArchitecture, p.Architecture)
+		assert.Equal(t, strings.TrimSuffix(controlContent, "\n"), p.Control)
+		assert.Equal(t, maintainer, p.Metadata.Maintainer)
+		assert.Equal(t, homepage, p.Metadata.ProjectURL)
+		assert.Equal(t, "vcs", p.Metadata.Section)
+		assert.Equal(t, "optional", p.Metadata.Priority)
+		assert.EqualValues(t, 1024, p.Metadata.InstalledSize)
+		assert.Equal(t, "Git hosting\nMulti line\ndescription", p.Metadata.Description)
+		assert.Equal(t, []string{"libc6 (>= 2.34)", "git"}, p.Metadata.Dependencies)
+
+		assert.Equal(t, "gitbundle_1.0.0-1_amd64.deb", p.Filename())
+		assert.Equal(t, "pool/main/g/gitbundle/gitbundle_1.0.0-1_amd64.deb", p.PoolPath("main"))
+	})
+
+	t.Run("LibraryPoolPath", func(t *testing.T) {
+		p, err := ParseControlFile(strings.NewReader(buildContent("libgit2", "1.5.0", "arm64")))
+		assert.NoError(t, err)
+		assert.Equal(t, "pool/main/libg/libgit2/libgit2_1.5.0_arm64.deb", p.PoolPath("main"))
+	})
+}
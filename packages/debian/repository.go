@@ -0,0 +1,199 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debian
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ulikunitz/xz"
+)
+
+// PackageIndexEntry represents a package file listed in a Packages index
+type PackageIndexEntry struct {
+	Package *Package
+	// Filename is the path of the package file relative to the repository root
+	Filename string
+	Size     int64
+	MD5      string
+	SHA1     string
+	SHA256   string
+	SHA512   string
+}
+
+// NewPackageIndexEntry creates the index entry of a package file with the sums of a HashedBuffer
+func NewPackageIndexEntry(p *Package, filename string, buf packages.HashedSizeReader) *PackageIndexEntry {
+	hashMD5, hashSHA1, hashSHA256, hashSHA512 := buf.Sums()
+	return &PackageIndexEntry{
+		Package:  p,
+		Filename: filename,
+		Size:     buf.Size(),
+		MD5:      hex.EncodeToString(hashMD5),
+		SHA1:     hex.EncodeToString(hashSHA1),
+		SHA256:   hex.EncodeToString(hashSHA256),
+		SHA512:   hex.EncodeToString(hashSHA512),
+	}
+}
+
+// WritePackagesIndex writes a Packages index file
+// https://wiki.debian.org/DebianRepository/Format#A.22Packages.22_Indices
+func WritePackagesIndex(w io.Writer, entries []*PackageIndexEntry) error {
+	for i, e := range entries {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\nFilename: %s\nSize: %d\nMD5sum: %s\nSHA1: %s\nSHA256: %s\nSHA512: %s\n",
+			e.Package.Control, e.Filename, e.Size, e.MD5, e.SHA1, e.SHA256, e.SHA512); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompressIndex returns the gzip and xz compressed variants of an index file
+func CompressIndex(content []byte) (gz, xzContent []byte, err error) {
+	var gzBuf bytes.Buffer
+	gzw := gzip.NewWriter(&gzBuf)
+	if _, err := gzw.Write(content); err != nil {
+		return nil, nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	var xzBuf bytes.Buffer
+	xzw, err := xz.NewWriter(&xzBuf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := xzw.Write(content); err != nil {
+		return nil, nil, err
+	}
+	if err := xzw.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return gzBuf.Bytes(), xzBuf.Bytes(), nil
+}
+
+// ReleaseFileEntry represents an index file listed in a Release file
+type ReleaseFileEntry struct {
+	// Path is relative to the directory of the Release file, e.g. main/binary-amd64/Packages
+	Path   string
+	Size   int64
+	MD5    string
+	SHA1   string
+	SHA256 string
+	SHA512 string
+}
+
+// NewReleaseFileEntry creates the entry of an index file
+func NewReleaseFileEntry(path string, content []byte) *ReleaseFileEntry {
+	h := packages.NewMultiHasher()
+	_, _ = h.Write(content)
+	hashMD5, hashSHA1, hashSHA256, hashSHA512 := h.Sums()
+	return &ReleaseFileEntry{
+		Path:   path,
+		Size:   int64(len(content)),
+		MD5:    hex.EncodeToString(hashMD5),
+		SHA1:   hex.EncodeToString(hashSHA1),
+		SHA256: hex.EncodeToString(hashSHA256),
+		SHA512: hex.EncodeToString(hashSHA512),
+	}
+}
+
+// ReleaseOptions represents the fields of a Release file
+type ReleaseOptions struct {
+	Origin        string
+	Label         string
+	Suite         string
+	Codename      string
+	Date          time.Time
+	ValidUntil    time.Time
+	Architectures []string
+	Components    []string
+	Description   string
+}
+
+// WriteRelease writes a Release file
+// https://wiki.debian.org/DebianRepository/Format#A.22Release.22_files
+func WriteRelease(w io.Writer, opts ReleaseOptions, files []*ReleaseFileEntry) error {
+	var buf bytes.Buffer
+
+	field := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s: %s\n", key, value)
+		}
+	}
+	field("Origin", opts.Origin)
+	field("Label", opts.Label)
+	field("Suite", opts.Suite)
+	field("Codename", opts.Codename)
+	field("Date", formatReleaseDate(opts.Date))
+	field("Valid-Until", formatReleaseDate(opts.ValidUntil))
+	field("Architectures", strings.Join(opts.Architectures, " "))
+	field("Components", strings.Join(opts.Components, " "))
+	field("Description", opts.Description)
+
+	for _, sum := range []struct {
+		name string
+		hash func(*ReleaseFileEntry) string
+	}{
+		{"MD5Sum", func(f *ReleaseFileEntry) string { return f.MD5 }},
+		{"SHA1", func(f *ReleaseFileEntry) string { return f.SHA1 }},
+		{"SHA256", func(f *ReleaseFileEntry) string { return f.SHA256 }},
+		{"SHA512", func(f *ReleaseFileEntry) string { return f.SHA512 }},
+	} {
+		buf.WriteString(sum.name + ":\n")
+		for _, f := range files {
+			fmt.Fprintf(&buf, " %s %d %s\n", sum.hash(f), f.Size, f.Path)
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func formatReleaseDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("Mon, 02 Jan 2006 15:04:05 MST")
+}
+
+// SignRelease signs the content of a Release file with the private key.
+// It returns the content of the InRelease file (clearsigned) and the Release.gpg file (detached signature).
+func SignRelease(release []byte, key *openpgp.Entity) (inRelease, releaseGPG []byte, err error) {
+	var inReleaseBuf bytes.Buffer
+	cw, err := clearsign.Encode(&inReleaseBuf, key.PrivateKey, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := cw.Write(release); err != nil {
+		return nil, nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	var releaseGPGBuf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&releaseGPGBuf, key, bytes.NewReader(release), nil); err != nil {
+		return nil, nil, err
+	}
+
+	return inReleaseBuf.Bytes(), releaseGPGBuf.Bytes(), nil
+}
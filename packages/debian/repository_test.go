@@ -0,0 +1,124 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debian
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+func TestWritePackagesIndex(t *testing.T) {
+	p, err := ParseControlFile(strings.NewReader(controlContent))
+	assert.NoError(t, err)
+
+	buf, err := packages.CreateHashedBufferFromReader(strings.NewReader("deb content"), 32)
+	assert.NoError(t, err)
+	defer buf.Close()
+
+	e := NewPackageIndexEntry(p, p.PoolPath("main"), buf)
+	assert.EqualValues(t, 11, e.Size)
+	assert.Len(t, e.SHA256, 64)
+
+	var index bytes.Buffer
+	assert.NoError(t, WritePackagesIndex(&index, []*PackageIndexEntry{e, e}))
+
+	paragraphs := strings.Split(index.String(), "\n\n")
+	assert.Len(t, paragraphs, 2)
+	assert.True(t, strings.HasPrefix(paragraphs[0], p.Control+"\nFilename: pool/main/g/gitbundle/gitbundle_1.0.0-1_amd64.deb\nSize: 11\nMD5sum: "+e.MD5+"\n"))
+	assert.Contains(t, paragraphs[1], "SHA512: "+e.SHA512+"\n")
+}
+
+func TestCompressIndex(t *testing.T) {
+	content := []byte("Package: gitbundle\n")
+
+	gz, xzContent, err := CompressIndex(content)
+	assert.NoError(t, err)
+
+	gzr, err := gzip.NewReader(bytes.NewReader(gz))
+	assert.NoError(t, err)
+	decompressed, err := io.ReadAll(gzr)
+	assert.NoError(t, err)
+	assert.Equal(t, content, decompressed)
+
+	xzr, err := xz.NewReader(bytes.NewReader(xzContent))
+	assert.NoError(t, err)
+	decompressed, err = io.ReadAll(xzr)
+	assert.NoError(t, err)
+	assert.Equal(t, content, decompressed)
+}
+
+func TestWriteRelease(t *testing.T) {
+	files := []*ReleaseFileEntry{
+		NewReleaseFileEntry("main/binary-amd64/Packages", []byte("a")),
+		NewReleaseFileEntry("main/binary-amd64/Packages.gz", []byte("bc")),
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteRelease(&buf, ReleaseOptions{
+		Origin:        "GitBundle",
+		Suite:         "stable",
+		Codename:      "bookworm",
+		Date:          time.Date(2023, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600)),
+		Architectures: []string{"amd64", "arm64"},
+		Components:    []string{"main"},
+	}, files))
+
+	assert.Equal(t, `Origin: GitBundle
+Suite: stable
+Codename: bookworm
+Date: Mon, 02 Jan 2023 02:04:05 UTC
+Architectures: amd64 arm64
+Components: main
+MD5Sum:
+ 0cc175b9c0f1b6a831c399e269772661 1 main/binary-amd64/Packages
+ `+files[1].MD5+` 2 main/binary-amd64/Packages.gz
+SHA1:
+ 86f7e437faa5a7fce15d1ddcb9eaeaea377667b8 1 main/binary-amd64/Packages
+ `+files[1].SHA1+` 2 main/binary-amd64/Packages.gz
+SHA256:
+ ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb 1 main/binary-amd64/Packages
+ `+files[1].SHA256+` 2 main/binary-amd64/Packages.gz
+SHA512:
+ `+files[0].SHA512+` 1 main/binary-amd64/Packages
+ `+files[1].SHA512+` 2 main/binary-amd64/Packages.gz
+`, buf.String())
+}
+
+func TestSignRelease(t *testing.T) {
+	key, err := openpgp.NewEntity("GitBundle", "", "no.reply@gitbundle.com", nil)
+	assert.NoError(t, err)
+
+	release := []byte("Origin: GitBundle\nSuite: stable\n")
+
+	inRelease, releaseGPG, err := SignRelease(release, key)
+	assert.NoError(t, err)
+
+	keyring := openpgp.EntityList{key}
+
+	block, rest := clearsign.Decode(inRelease)
+	assert.NotNil(t, block)
+	assert.Empty(t, rest)
+	assert.Equal(t, release, block.Plaintext)
+	_, err = block.VerifySignature(keyring, nil)
+	assert.NoError(t, err)
+
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(release), bytes.NewReader(releaseGPG), nil)
+	assert.NoError(t, err)
+
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, strings.NewReader("tampered"), bytes.NewReader(releaseGPG), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goproxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+)
+
+var (
+	// ErrInvalidModulePath indicates an invalid module path
+	ErrInvalidModulePath = errors.New("module path is invalid")
+	// ErrInvalidVersion indicates an invalid or not canonical module version
+	ErrInvalidVersion = errors.New("module version is invalid")
+	// ErrModulePathMismatch indicates a go.mod file which declares another module path
+	ErrModulePathMismatch = errors.New("go.mod file declares a different module path")
+	// ErrInvalidZip indicates a module zip which violates the module zip rules
+	ErrInvalidZip = errors.New("module zip is invalid")
+)
+
+// Package represents a Go module
+type Package struct {
+	Path     string
+	Version  string
+	GoMod    []byte
+	Metadata *Metadata
+}
+
+// Metadata represents the metadata of a Go module
+type Metadata struct {
+	GoVersion    string        `json:"go_version,omitempty"`
+	Dependencies []*Dependency `json:"dependencies,omitempty"`
+	Retractions  []*Retraction `json:"retractions,omitempty"`
+	// Hash is the h1: hash of the module zip
+	Hash string `json:"hash"`
+	// GoModHash is the h1: hash of the go.mod file
+	GoModHash string `json:"go_mod_hash"`
+}
+
+// Dependency represents a requirement of a Go module
+type Dependency struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect,omitempty"`
+}
+
+// Retraction represents a retract directive, Low equals High for single versions
+type Retraction struct {
+	Low       string `json:"low"`
+	High      string `json:"high"`
+	Rationale string `json:"rationale,omitempty"`
+}
+
+// IsRetracted checks if the version is retracted by the module
+func (m *Metadata) IsRetracted(version string) bool {
+	for _, r := range m.Retractions {
+		if semver.Compare(r.Low, version) <= 0 && semver.Compare(version, r.High) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckModule validates the module path and version
+func CheckModule(modPath, version string) error {
+	if err := module.CheckPath(modPath); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidModulePath, err)
+	}
+	if module.CanonicalVersion(version) != version {
+		return ErrInvalidVersion
+	}
+	if err := module.Check(modPath, version); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidVersion, err)
+	}
+	return nil
+}
+
+// ParsePackage validates the zip of a module and extracts its go.mod file.
+// The go command synthesizes a go.mod file for modules without one, so does this function.
+func ParsePackage(r io.ReaderAt, size int64, modPath, version string) (*Package, error) {
+	if err := CheckModule(modPath, version); err != nil {
+		return nil, err
+	}
+	if err := checkZip(r, size, modPath, version); err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := modPath + "@" + version + "/"
+	files := make(map[string]*zip.File, len(zr.File))
+	names := make([]string, 0, len(zr.File))
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+
+	hash, err := dirhash.Hash1(names, func(name string) (io.ReadCloser, error) {
+		return files[name].Open()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var goMod []byte
+	if f, ok := files[prefix+"go.mod"]; ok {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		goMod, err = io.ReadAll(io.LimitReader(rc, modzip.MaxGoMod))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		goMod = []byte(fmt.Sprintf("module %s\n", modfile.AutoQuote(modPath)))
+	}
+
+	p, err := ParseGoModFile(goMod, modPath, version)
+	if err != nil {
+		return nil, err
+	}
+	p.Metadata.Hash = hash
+	return p, nil
+}
+
+// ParseGoModFile parses the go.mod file of a module
+func ParseGoModFile(content []byte, modPath, version string) (*Package, error) {
+	f, err := modfile.ParseLax("go.mod", content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.Module == nil || f.Module.Mod.Path != modPath {
+		return nil, ErrModulePathMismatch
+	}
+
+	goModHash, err := HashGoMod(content)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{
+		GoModHash: goModHash,
+	}
+	if f.Go != nil {
+		metadata.GoVersion = f.Go.Version
+	}
+	for _, r := range f.Require {
+		metadata.Dependencies = append(metadata.Dependencies, &Dependency{
+			Path:     r.Mod.Path,
+			Version:  r.Mod.Version,
+			Indirect: r.Indirect,
+		})
+	}
+	for _, r := range f.Retract {
+		metadata.Retractions = append(metadata.Retractions, &Retraction{
+			Low:       r.Low,
+			High:      r.High,
+			Rationale: r.Rationale,
+		})
+	}
+
+	return &Package{
+		Path:     modPath,
+		Version:  version,
+		GoMod:    content,
+		Metadata: metadata,
+	}, nil
+}
+
+// HashGoMod computes the h1: hash of a go.mod file as used in the /go.mod lines of go.sum
+func HashGoMod(content []byte) (string, error) {
+	return dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	})
+}
+
+// GoSumLines returns the go.sum lines of the module
+func (p *Package) GoSumLines() string {
+	return fmt.Sprintf("%s %s %s\n%s %s/go.mod %s\n", p.Path, p.Version, p.Metadata.Hash, p.Path, p.Version, p.Metadata.GoModHash)
+}
+
+// checkZip validates the zip with the rules of the go command.
+// The checks of golang.org/x/mod/zip require a file, so other readers are copied to a temporary file.
+func checkZip(r io.ReaderAt, size int64, modPath, version string) error {
+	if size > modzip.MaxZipFile {
+		return fmt.Errorf("%w: module zip file is too large", ErrInvalidZip)
+	}
+
+	f, ok := r.(*os.File)
+	if !ok {
+		tmp, err := os.CreateTemp("", "goproxy-*.zip")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}()
+
+		if _, err := io.Copy(tmp, io.NewSectionReader(r, 0, size)); err != nil {
+			return err
+		}
+		f = tmp
+	}
+
+	if _, err := modzip.CheckZip(module.Version{Path: modPath, Version: version}, f.Name()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidZip, err)
+	}
+	return nil
+}
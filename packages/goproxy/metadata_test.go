@@ -0,0 +1,137 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goproxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+const (
+	modulePath    = "gitbundle.com/test/module"
+	moduleVersion = "v1.2.3"
+	goModContent  = `module gitbundle.com/test/module
+
+go 1.18
+
+require (
+	github.com/stretchr/testify v1.8.1
+	golang.org/x/mod v0.10.0 // indirect
+)
+
+retract v1.0.0 // broken
+`
+)
+
+func createArchive(files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestCheckModule(t *testing.T) {
+	assert.NoError(t, CheckModule(modulePath, moduleVersion))
+	assert.NoError(t, CheckModule(modulePath+"/v2", "v2.0.0"))
+	assert.ErrorIs(t, CheckModule("", moduleVersion), ErrInvalidModulePath)
+	assert.ErrorIs(t, CheckModule("gitbundle.com/test module", moduleVersion), ErrInvalidModulePath)
+	assert.ErrorIs(t, CheckModule(modulePath, "1.2.3"), ErrInvalidVersion)
+	assert.ErrorIs(t, CheckModule(modulePath, "v1.2"), ErrInvalidVersion)
+	assert.ErrorIs(t, CheckModule(modulePath, "v2.0.0"), ErrInvalidVersion)
+}
+
+func TestParsePackage(t *testing.T) {
+	prefix := modulePath + "@" + moduleVersion + "/"
+
+	t.Run("InvalidPrefix", func(t *testing.T) {
+		data := createArchive(map[string]string{"other@v1.2.3/main.go": "package main"})
+
+		p, err := ParsePackage(data, data.Size(), modulePath, moduleVersion)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidZip)
+	})
+
+	t.Run("NestedGoMod", func(t *testing.T) {
+		data := createArchive(map[string]string{prefix + "sub/go.mod": "module sub"})
+
+		p, err := ParsePackage(data, data.Size(), modulePath, moduleVersion)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidZip)
+	})
+
+	t.Run("ModulePathMismatch", func(t *testing.T) {
+		data := createArchive(map[string]string{prefix + "go.mod": "module other.com/module\n"})
+
+		p, err := ParsePackage(data, data.Size(), modulePath, moduleVersion)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrModulePathMismatch)
+	})
+
+	t.Run("MissingGoMod", func(t *testing.T) {
+		data := createArchive(map[string]string{prefix + "main.go": "package main"})
+
+		p, err := ParsePackage(data, data.Size(), modulePath, moduleVersion)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+		assert.Equal(t, "module gitbundle.com/test/module\n", string(p.GoMod))
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		data := createArchive(map[string]string{
+			prefix + "go.mod":      goModContent,
+			prefix + "main.go":     "package main",
+			prefix + "pkg/util.go": "package pkg",
+		})
+
+		p, err := ParsePackage(data, data.Size(), modulePath, moduleVersion)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+
+		assert.Equal(t, modulePath, p.Path)
+		assert.Equal(t, moduleVersion, p.Version)
+		assert.Equal(t, goModContent, string(p.GoMod))
+		assert.Equal(t, "1.18", p.Metadata.GoVersion)
+		assert.Equal(t, []*Dependency{
+			{Path: "github.com/stretchr/testify", Version: "v1.8.1"},
+			{Path: "golang.org/x/mod", Version: "v0.10.0", Indirect: true},
+		}, p.Metadata.Dependencies)
+		assert.Equal(t, []*Retraction{{Low: "v1.0.0", High: "v1.0.0", Rationale: "broken"}}, p.Metadata.Retractions)
+		assert.True(t, p.Metadata.IsRetracted("v1.0.0"))
+		assert.False(t, p.Metadata.IsRetracted("v1.0.1"))
+
+		// the hash must match the one the go command computes for the zip
+		zipPath := filepath.Join(t.TempDir(), "module.zip")
+		data.Seek(0, 0)
+		var buf bytes.Buffer
+		buf.ReadFrom(data)
+		assert.NoError(t, os.WriteFile(zipPath, buf.Bytes(), 0o600))
+		expected, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, p.Metadata.Hash)
+
+		goModHash, err := HashGoMod([]byte(goModContent))
+		assert.NoError(t, err)
+		assert.Equal(t, goModHash, p.Metadata.GoModHash)
+		assert.Equal(t, modulePath+" "+moduleVersion+" "+expected+"\n"+modulePath+" "+moduleVersion+"/go.mod "+goModHash+"\n", p.GoSumLines())
+	})
+}
+
+func TestHashGoMod(t *testing.T) {
+	// go.sum line of golang.org/x/mod v0.10.0
+	hash, err := HashGoMod([]byte("module golang.org/x/mod\n\ngo 1.17\n\nrequire golang.org/x/tools v0.1.12 // tagx:ignore\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "h1:iBbtSCu2XBx23ZKBPSOrRkjjQPZFPuis4dIYUhu/chs=", hash)
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goproxy
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// SuffixInfo is the suffix of version info requests
+	SuffixInfo = ".info"
+	// SuffixMod is the suffix of go.mod requests
+	SuffixMod = ".mod"
+	// SuffixZip is the suffix of module zip requests
+	SuffixZip = ".zip"
+	// SuffixList is the name of version list requests
+	SuffixList = "list"
+	// SuffixLatest is the name of latest version requests
+	SuffixLatest = "@latest"
+)
+
+// ErrInvalidRequestPath indicates a path which is no GOPROXY request
+var ErrInvalidRequestPath = errors.New("invalid GOPROXY request path")
+
+// Info represents the response of .info and @latest requests
+// https://go.dev/ref/mod#goproxy-protocol
+type Info struct {
+	Version string    `json:"Version"`
+	Time    time.Time `json:"Time"`
+}
+
+// NewInfo creates the info of a version, the time is expected to be the commit or upload time
+func NewInfo(version string, t time.Time) *Info {
+	return &Info{
+		Version: version,
+		Time:    t.UTC(),
+	}
+}
+
+// Request represents a parsed GOPROXY request
+type Request struct {
+	Path string
+	// Version is empty for list and latest requests
+	Version string
+	// Suffix is one of the Suffix* constants
+	Suffix string
+}
+
+// ParseRequestPath parses a request path relative to the proxy root like
+// `github.com/!azure/sdk/@v/v1.0.0.info` into its unescaped parts
+func ParseRequestPath(p string) (*Request, error) {
+	p = strings.TrimPrefix(p, "/")
+
+	if strings.HasSuffix(p, "/"+SuffixLatest) {
+		modPath, err := module.UnescapePath(strings.TrimSuffix(p, "/"+SuffixLatest))
+		if err != nil {
+			return nil, ErrInvalidRequestPath
+		}
+		return &Request{Path: modPath, Suffix: SuffixLatest}, nil
+	}
+
+	idx := strings.LastIndex(p, "/@v/")
+	if idx == -1 {
+		return nil, ErrInvalidRequestPath
+	}
+	modPath, err := module.UnescapePath(p[:idx])
+	if err != nil {
+		return nil, ErrInvalidRequestPath
+	}
+	file := p[idx+len("/@v/"):]
+
+	if file == SuffixList {
+		return &Request{Path: modPath, Suffix: SuffixList}, nil
+	}
+
+	for _, suffix := range []string{SuffixInfo, SuffixMod, SuffixZip} {
+		if strings.HasSuffix(file, suffix) {
+			version, err := module.UnescapeVersion(strings.TrimSuffix(file, suffix))
+			if err != nil || version == "" {
+				return nil, ErrInvalidRequestPath
+			}
+			return &Request{Path: modPath, Version: version, Suffix: suffix}, nil
+		}
+	}
+	return nil, ErrInvalidRequestPath
+}
+
+// RequestPath builds the escaped request path of a module file.
+// For list and latest requests the version is ignored.
+func RequestPath(modPath, version, suffix string) (string, error) {
+	escapedPath, err := module.EscapePath(modPath)
+	if err != nil {
+		return "", err
+	}
+	switch suffix {
+	case SuffixLatest:
+		return escapedPath + "/" + SuffixLatest, nil
+	case SuffixList:
+		return escapedPath + "/@v/" + SuffixList, nil
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return escapedPath + "/@v/" + escapedVersion + suffix, nil
+}
+
+// WriteVersionList writes the response of a @v/list request.
+// Pseudo-versions are omitted and the versions are sorted by semver precedence.
+func WriteVersionList(w io.Writer, versions []string) error {
+	list := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if semver.IsValid(v) && !module.IsPseudoVersion(v) {
+			list = append(list, v)
+		}
+	}
+	semver.Sort(list)
+
+	for _, v := range list {
+		if _, err := io.WriteString(w, v+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LatestVersion returns the version a @latest request resolves to:
+// the highest release, else the highest pre-release, else the highest pseudo-version.
+// Versions for which isRetracted returns true are skipped unless nothing else is available.
+func LatestVersion(versions []string, isRetracted func(string) bool) string {
+	var release, prerelease, pseudo, highest string
+	for _, v := range versions {
+		if !semver.IsValid(v) {
+			continue
+		}
+		if semver.Compare(v, highest) > 0 {
+			highest = v
+		}
+		if isRetracted != nil && isRetracted(v) {
+			continue
+		}
+		target := &release
+		if module.IsPseudoVersion(v) {
+			target = &pseudo
+		} else if semver.Prerelease(v) != "" {
+			target = &prerelease
+		}
+		if semver.Compare(v, *target) > 0 {
+			*target = v
+		}
+	}
+
+	for _, v := range []string{release, prerelease, pseudo} {
+		if v != "" {
+			return v
+		}
+	}
+	return highest
+}
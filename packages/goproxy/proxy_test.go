@@ -0,0 +1,70 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goproxy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfo(t *testing.T) {
+	b, err := json.Marshal(NewInfo("v1.0.0", time.Date(2023, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Version":"v1.0.0","Time":"2023-01-02T02:04:05Z"}`, string(b))
+}
+
+func TestRequestPath(t *testing.T) {
+	cases := []struct {
+		Path     string
+		Expected *Request
+	}{
+		{"github.com/!azure/sdk/@v/list", &Request{Path: "github.com/Azure/sdk", Suffix: SuffixList}},
+		{"/github.com/!azure/sdk/@latest", &Request{Path: "github.com/Azure/sdk", Suffix: SuffixLatest}},
+		{"github.com/!azure/sdk/@v/v1.0.0-!r!c1.info", &Request{Path: "github.com/Azure/sdk", Version: "v1.0.0-RC1", Suffix: SuffixInfo}},
+		{"gitbundle.com/module/@v/v1.0.0.mod", &Request{Path: "gitbundle.com/module", Version: "v1.0.0", Suffix: SuffixMod}},
+		{"gitbundle.com/module/@v/v1.0.0.zip", &Request{Path: "gitbundle.com/module", Version: "v1.0.0", Suffix: SuffixZip}},
+	}
+	for _, c := range cases {
+		r, err := ParseRequestPath(c.Path)
+		assert.NoError(t, err, c.Path)
+		assert.Equal(t, c.Expected, r, c.Path)
+
+		p, err := RequestPath(r.Path, r.Version, r.Suffix)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.TrimPrefix(c.Path, "/"), p, c.Path)
+	}
+
+	for _, invalid := range []string{"gitbundle.com/module", "gitbundle.com/module/@v/v1.0.0.tar", "gitbundle.com/Module/@v/list", "gitbundle.com/module/@v/.info"} {
+		r, err := ParseRequestPath(invalid)
+		assert.Nil(t, r)
+		assert.ErrorIs(t, err, ErrInvalidRequestPath, invalid)
+	}
+}
+
+func TestWriteVersionList(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteVersionList(&buf, []string{"v1.10.0", "v1.2.0", "invalid", "v1.2.0-beta", "v0.0.0-20230102030405-abcdefabcdef", "v2.0.0+incompatible"}))
+	assert.Equal(t, "v1.2.0-beta\nv1.2.0\nv1.10.0\nv2.0.0+incompatible\n", buf.String())
+}
+
+func TestLatestVersion(t *testing.T) {
+	pseudo := "v0.0.0-20230102030405-abcdefabcdef"
+
+	assert.Empty(t, LatestVersion(nil, nil))
+	assert.Equal(t, pseudo, LatestVersion([]string{pseudo}, nil))
+	assert.Equal(t, "v1.1.0-rc1", LatestVersion([]string{pseudo, "v1.0.0-rc1", "v1.1.0-rc1"}, nil))
+	assert.Equal(t, "v1.0.0", LatestVersion([]string{pseudo, "v1.0.0", "v1.1.0-rc1"}, nil))
+
+	retracted := func(v string) bool { return v == "v1.2.0" }
+	assert.Equal(t, "v1.0.0", LatestVersion([]string{"v1.0.0", "v1.2.0"}, retracted))
+	assert.Equal(t, "v1.2.0", LatestVersion([]string{"v1.2.0"}, retracted))
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"encoding/hex"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+	"github.com/gitbundle/modules/packages/versioning"
+
+	"gopkg.in/yaml.v2"
+)
+
+// IndexAPIVersion is the api version of the generated index.yaml files
+const IndexAPIVersion = "v1"
+
+// ChartVersion represents a chart version listed in the index.yaml file
+type ChartVersion struct {
+	Metadata `yaml:",inline"`
+	// URLs contains the download locations of the chart archive
+	URLs    []string  `yaml:"urls"`
+	Created time.Time `yaml:"created,omitempty"`
+	// Digest is the hex encoded SHA-256 of the chart archive
+	Digest string `yaml:"digest"`
+}
+
+// NewChartVersion creates the index entry of a chart archive with the sums of a HashedBuffer
+func NewChartVersion(metadata *Metadata, url string, buf packages.HashedSizeReader, created time.Time) *ChartVersion {
+	_, _, hashSHA256, _ := buf.Sums()
+	return &ChartVersion{
+		Metadata: *metadata,
+		URLs:     []string{url},
+		Created:  created,
+		Digest:   hex.EncodeToString(hashSHA256),
+	}
+}
+
+// Index represents the index.yaml file of a chart repository
+type Index struct {
+	APIVersion string                     `yaml:"apiVersion"`
+	Entries    map[string][]*ChartVersion `yaml:"entries"`
+	Generated  time.Time                  `yaml:"generated"`
+	ServerInfo map[string]interface{}     `yaml:"serverInfo,omitempty"`
+}
+
+// NewIndex creates an empty index
+func NewIndex(generated time.Time) *Index {
+	return &Index{
+		APIVersion: IndexAPIVersion,
+		Entries:    make(map[string][]*ChartVersion),
+		Generated:  generated,
+	}
+}
+
+// Add adds a chart version to the index
+func (i *Index) Add(cv *ChartVersion) {
+	i.Entries[cv.Name] = append(i.Entries[cv.Name], cv)
+}
+
+// SortEntries sorts the versions of every chart from newest to oldest
+func (i *Index) SortEntries() {
+	for _, versions := range i.Entries {
+		sort.SliceStable(versions, func(a, b int) bool {
+			result, err := versioning.Compare(VersionScheme, versions[a].Version, versions[b].Version)
+			if err != nil {
+				return versions[a].Version > versions[b].Version
+			}
+			return result > 0
+		})
+	}
+}
+
+// WriteIndex sorts the entries of the index and writes it as index.yaml
+func WriteIndex(w io.Writer, i *Index) error {
+	i.SortEntries()
+
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(i); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ParseIndex parses an index.yaml file
+func ParseIndex(r io.Reader) (*Index, error) {
+	var i *Index
+	if err := yaml.NewDecoder(r).Decode(&i); err != nil {
+		return nil, err
+	}
+	if i.Entries == nil {
+		i.Entries = make(map[string][]*ChartVersion)
+	}
+	return i, nil
+}
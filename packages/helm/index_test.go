@@ -0,0 +1,54 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteIndex(t *testing.T) {
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	index := NewIndex(created)
+	for _, v := range []string{"1.0.0", "1.10.0", "1.2.0"} {
+		buf, err := packages.CreateHashedBufferFromReader(strings.NewReader("chart "+v), 1024)
+		assert.NoError(t, err)
+		index.Add(NewChartVersion(&Metadata{
+			APIVersion: "v2",
+			Name:       "chart",
+			Version:    v,
+		}, "https://gitbundle.com/api/packages/user/helm/charts/chart-"+v+".tgz", buf, created))
+		buf.Close()
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteIndex(&buf, index))
+
+	parsed, err := ParseIndex(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, IndexAPIVersion, parsed.APIVersion)
+	assert.True(t, created.Equal(parsed.Generated))
+	assert.Len(t, parsed.Entries["chart"], 3)
+
+	versions := make([]string, 0, 3)
+	for _, cv := range parsed.Entries["chart"] {
+		versions = append(versions, cv.Version)
+	}
+	assert.Equal(t, []string{"1.10.0", "1.2.0", "1.0.0"}, versions)
+
+	cv := parsed.Entries["chart"][0]
+	assert.Equal(t, "v2", cv.APIVersion)
+	assert.Equal(t, []string{"https://gitbundle.com/api/packages/user/helm/charts/chart-1.10.0.tgz"}, cv.URLs)
+	assert.Equal(t, index.Entries["chart"][0].Digest, cv.Digest)
+	assert.Len(t, cv.Digest, 64)
+}
@@ -12,6 +12,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/gitbundle/modules/packages/versioning"
 	"github.com/gitbundle/modules/validation"
 
 	"github.com/hashicorp/go-version"
@@ -29,6 +30,9 @@ var (
 	ErrInvalidChart = errors.New("chart is invalid")
 )
 
+// VersionScheme defines the version semantics of Helm packages
+var VersionScheme = versioning.SemVer
+
 // Metadata for a Chart file. This models the structure of a Chart.yaml file.
 type Metadata struct {
 	APIVersion   string            `json:"api_version" yaml:"apiVersion"`
@@ -67,6 +71,11 @@ type Dependency struct {
 	Alias        string        `json:"alias,omitempty" yaml:"alias,omitempty"`
 }
 
+// VersionRange parses the version constraint of the dependency, an empty constraint matches all versions
+func (d *Dependency) VersionRange() (versioning.Range, error) {
+	return VersionScheme.ParseRange(d.Version)
+}
+
 // ParseChartArchive parses the metadata of a Helm archive
 func ParseChartArchive(r io.Reader) (*Metadata, error) {
 	gzr, err := gzip.NewReader(r)
@@ -0,0 +1,128 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrInvalidProvenance indicates an invalid provenance file
+	ErrInvalidProvenance = errors.New("provenance file is invalid")
+	// ErrMissingFileDigest indicates that the provenance file contains no digest for the chart archive
+	ErrMissingFileDigest = errors.New("provenance file contains no digest for the chart archive")
+	// ErrDigestMismatch indicates that the chart archive does not match the digest in the provenance file
+	ErrDigestMismatch = errors.New("chart archive digest does not match")
+)
+
+// provenanceSeparator separates the Chart.yaml content from the file digests
+const provenanceSeparator = "\n...\n"
+
+// Provenance represents a parsed .prov file
+type Provenance struct {
+	Metadata *Metadata
+	// Files maps the chart archive filenames to their digests in the form sha256:<hex>
+	Files map[string]string
+
+	block *clearsign.Block
+}
+
+type provenanceFiles struct {
+	Files map[string]string `yaml:"files"`
+}
+
+// ParseProvenance parses the clearsigned content of a .prov file
+func ParseProvenance(data []byte) (*Provenance, error) {
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidProvenance
+	}
+
+	parts := bytes.SplitN(block.Plaintext, []byte(provenanceSeparator), 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidProvenance
+	}
+
+	var metadata *Metadata
+	if err := yaml.Unmarshal(parts[0], &metadata); err != nil || metadata == nil {
+		return nil, ErrInvalidProvenance
+	}
+
+	var files provenanceFiles
+	if err := yaml.Unmarshal(parts[1], &files); err != nil {
+		return nil, ErrInvalidProvenance
+	}
+
+	return &Provenance{
+		Metadata: metadata,
+		Files:    files.Files,
+		block:    block,
+	}, nil
+}
+
+// Verify checks the digest of the chart archive and the signature of the provenance file.
+// It returns the entity of the keyring which signed the provenance file.
+func (p *Provenance) Verify(keyring openpgp.KeyRing, filename string, chart io.Reader) (*openpgp.Entity, error) {
+	expected, ok := p.Files[filename]
+	if !ok {
+		return nil, ErrMissingFileDigest
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, chart); err != nil {
+		return nil, err
+	}
+	if expected != "sha256:"+hex.EncodeToString(h.Sum(nil)) {
+		return nil, ErrDigestMismatch
+	}
+
+	return p.block.VerifySignature(keyring, nil)
+}
+
+// CreateProvenance creates a clearsigned .prov file for a chart archive
+func CreateProvenance(metadata *Metadata, filename string, chart io.Reader, key *openpgp.Entity) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, chart); err != nil {
+		return nil, err
+	}
+
+	chartYaml, err := yaml.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	files, err := yaml.Marshal(&provenanceFiles{
+		Files: map[string]string{
+			filename: "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, key.PrivateKey, &packet.Config{DefaultHash: crypto.SHA512})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w, "%s%s%s", bytes.TrimRight(chartYaml, "\n"), provenanceSeparator, files); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	key, err := openpgp.NewEntity("GitBundle", "", "no.reply@gitbundle.com", nil)
+	assert.NoError(t, err)
+	other, err := openpgp.NewEntity("Other", "", "other@gitbundle.com", nil)
+	assert.NoError(t, err)
+
+	const filename = "chart-1.0.0.tgz"
+	const chart = "chart archive content"
+
+	metadata := &Metadata{APIVersion: "v2", Name: "chart", Version: "1.0.0", Description: "A chart"}
+
+	prov, err := CreateProvenance(metadata, filename, strings.NewReader(chart), key)
+	assert.NoError(t, err)
+
+	t.Run("Parse", func(t *testing.T) {
+		p, err := ParseProvenance(prov)
+		assert.NoError(t, err)
+		assert.Equal(t, metadata, p.Metadata)
+		assert.Len(t, p.Files, 1)
+		assert.Contains(t, p.Files, filename)
+
+		p, err = ParseProvenance([]byte("not signed"))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidProvenance)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		p, err := ParseProvenance(prov)
+		assert.NoError(t, err)
+
+		signer, err := p.Verify(openpgp.EntityList{key}, filename, strings.NewReader(chart))
+		assert.NoError(t, err)
+		assert.Equal(t, key.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+
+		_, err = p.Verify(openpgp.EntityList{key}, "other-1.0.0.tgz", strings.NewReader(chart))
+		assert.ErrorIs(t, err, ErrMissingFileDigest)
+
+		_, err = p.Verify(openpgp.EntityList{key}, filename, strings.NewReader("tampered"))
+		assert.ErrorIs(t, err, ErrDigestMismatch)
+
+		_, err = p.Verify(openpgp.EntityList{other}, filename, strings.NewReader(chart))
+		assert.Error(t, err)
+	})
+}
@@ -8,10 +8,16 @@ package maven
 import (
 	"encoding/xml"
 	"io"
+	"regexp"
+	"strings"
 
+	"github.com/gitbundle/modules/packages/versioning"
 	"github.com/gitbundle/modules/validation"
 )
 
+// VersionScheme defines the version semantics of Maven packages
+var VersionScheme = versioning.Maven
+
 // Metadata represents the metadata of a Maven package
 type Metadata struct {
 	GroupID      string        `json:"group_id,omitempty"`
@@ -30,34 +36,97 @@ type Dependency struct {
 	Version    string `json:"version,omitempty"`
 }
 
+// VersionRange parses the version of the dependency, which may be a range like [1.0,2.0)
+func (d *Dependency) VersionRange() (versioning.Range, error) {
+	return VersionScheme.ParseRange(d.Version)
+}
+
+type pomCoordinates struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+}
+
+type pomProperty struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	Scope      string `xml:"scope"`
+}
+
 type pomStruct struct {
-	XMLName     xml.Name `xml:"project"`
-	GroupID     string   `xml:"groupId"`
-	ArtifactID  string   `xml:"artifactId"`
-	Version     string   `xml:"version"`
-	Name        string   `xml:"name"`
-	Description string   `xml:"description"`
-	URL         string   `xml:"url"`
+	XMLName     xml.Name       `xml:"project"`
+	Parent      pomCoordinates `xml:"parent"`
+	GroupID     string         `xml:"groupId"`
+	ArtifactID  string         `xml:"artifactId"`
+	Version     string         `xml:"version"`
+	Name        string         `xml:"name"`
+	Description string         `xml:"description"`
+	URL         string         `xml:"url"`
 	Licenses    []struct {
 		Name         string `xml:"name"`
 		URL          string `xml:"url"`
 		Distribution string `xml:"distribution"`
 	} `xml:"licenses>license"`
-	Dependencies []struct {
-		GroupID    string `xml:"groupId"`
-		ArtifactID string `xml:"artifactId"`
-		Version    string `xml:"version"`
-		Scope      string `xml:"scope"`
-	} `xml:"dependencies>dependency"`
+	Properties struct {
+		Entries []pomProperty `xml:",any"`
+	} `xml:"properties"`
+	DependencyManagement []pomDependency `xml:"dependencyManagement>dependencies>dependency"`
+	Dependencies         []pomDependency `xml:"dependencies>dependency"`
 }
 
+// ParentResolver returns the content of a parent pom file.
+// It returns nil if the parent pom is not available.
+type ParentResolver func(groupID, artifactID, version string) (io.ReadCloser, error)
+
+// maxParentDepth limits the length of the parent chain to stop on cycles
+const maxParentDepth = 10
+
+// maxInterpolationDepth limits the resolution of properties referencing other properties
+const maxInterpolationDepth = 5
+
+var propertyPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
 // ParsePackageMetaData parses the metadata of a pom file
 func ParsePackageMetaData(r io.Reader) (*Metadata, error) {
+	return ParsePackageMetaDataWithParents(r, nil)
+}
+
+// ParsePackageMetaDataWithParents parses the metadata of a pom file.
+// Properties and managed dependency versions are inherited from the parent poms
+// provided by the resolver and are used to interpolate the dependency versions.
+func ParsePackageMetaDataWithParents(r io.Reader, resolve ParentResolver) (*Metadata, error) {
 	var pom pomStruct
 	if err := xml.NewDecoder(r).Decode(&pom); err != nil {
 		return nil, err
 	}
 
+	if pom.GroupID == "" {
+		pom.GroupID = pom.Parent.GroupID
+	}
+	if pom.Version == "" {
+		pom.Version = pom.Parent.Version
+	}
+
+	properties := make(map[string]string)
+	managed := make(map[string]string)
+	if resolve != nil && pom.Parent.ArtifactID != "" {
+		if err := inheritParent(pom.Parent, resolve, properties, managed, 1); err != nil {
+			return nil, err
+		}
+	}
+	addPomProperties(&pom, properties, managed)
+
+	managedVersions := make(map[string]string, len(managed))
+	for key, version := range managed {
+		managedVersions[interpolate(key, properties)] = version
+	}
+
 	if !validation.IsValidURL(pom.URL) {
 		pom.URL = ""
 	}
@@ -71,10 +140,16 @@ func ParsePackageMetaData(r io.Reader) (*Metadata, error) {
 
 	dependencies := make([]*Dependency, 0, len(pom.Dependencies))
 	for _, d := range pom.Dependencies {
+		groupID := interpolate(d.GroupID, properties)
+		artifactID := interpolate(d.ArtifactID, properties)
+		version := d.Version
+		if version == "" {
+			version = managedVersions[groupID+":"+artifactID]
+		}
 		dependencies = append(dependencies, &Dependency{
-			GroupID:    d.GroupID,
-			ArtifactID: d.ArtifactID,
-			Version:    d.Version,
+			GroupID:    groupID,
+			ArtifactID: artifactID,
+			Version:    interpolate(version, properties),
 		})
 	}
 
@@ -88,3 +163,70 @@ func ParsePackageMetaData(r io.Reader) (*Metadata, error) {
 		Dependencies: dependencies,
 	}, nil
 }
+
+// inheritParent collects the properties and managed dependencies of the parent chain, the nearest parent wins
+func inheritParent(parent pomCoordinates, resolve ParentResolver, properties, managed map[string]string, depth int) error {
+	if depth > maxParentDepth {
+		return nil
+	}
+
+	rc, err := resolve(parent.GroupID, parent.ArtifactID, parent.Version)
+	if err != nil {
+		return err
+	}
+	if rc == nil {
+		return nil
+	}
+	defer rc.Close()
+
+	var pom pomStruct
+	if err := xml.NewDecoder(rc).Decode(&pom); err != nil {
+		return err
+	}
+
+	if pom.Parent.ArtifactID != "" {
+		if err := inheritParent(pom.Parent, resolve, properties, managed, depth+1); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range pom.Properties.Entries {
+		properties[p.XMLName.Local] = strings.TrimSpace(p.Value)
+	}
+	for _, d := range pom.DependencyManagement {
+		managed[d.GroupID+":"+d.ArtifactID] = d.Version
+	}
+	return nil
+}
+
+// addPomProperties adds the properties of the pom and the built-in project properties
+func addPomProperties(pom *pomStruct, properties, managed map[string]string) {
+	for _, p := range pom.Properties.Entries {
+		properties[p.XMLName.Local] = strings.TrimSpace(p.Value)
+	}
+	for _, d := range pom.DependencyManagement {
+		managed[d.GroupID+":"+d.ArtifactID] = d.Version
+	}
+
+	for _, prefix := range []string{"project.", "pom.", ""} {
+		properties[prefix+"groupId"] = pom.GroupID
+		properties[prefix+"artifactId"] = pom.ArtifactID
+		properties[prefix+"version"] = pom.Version
+	}
+	properties["project.parent.groupId"] = pom.Parent.GroupID
+	properties["project.parent.artifactId"] = pom.Parent.ArtifactID
+	properties["project.parent.version"] = pom.Parent.Version
+}
+
+// interpolate replaces the ${...} expressions with the property values. Unknown properties are kept.
+func interpolate(s string, properties map[string]string) string {
+	for i := 0; i < maxInterpolationDepth && strings.Contains(s, "${"); i++ {
+		s = propertyPattern.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := properties[m[2:len(m)-1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+	return s
+}
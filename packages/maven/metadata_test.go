@@ -6,6 +6,7 @@
 package maven
 
 import (
+	"io"
 	"strings"
 	"testing"
 
@@ -72,3 +73,110 @@ func TestParsePackageMetaData(t *testing.T) {
 		assert.Equal(t, dependencyVersion, m.Dependencies[0].Version)
 	})
 }
+
+func TestParsePackageMetaDataWithParents(t *testing.T) {
+	const parentContent = `<?xml version="1.0"?>
+<project>
+  <parent>
+    <groupId>org.gitbundle</groupId>
+    <artifactId>root</artifactId>
+    <version>3</version>
+  </parent>
+  <groupId>org.gitbundle</groupId>
+  <artifactId>parent</artifactId>
+  <version>2.0.0</version>
+  <properties>
+    <git.version>4.0.0</git.version>
+  </properties>
+  <dependencyManagement>
+    <dependencies>
+      <dependency>
+        <groupId>${project.groupId}</groupId>
+        <artifactId>managed</artifactId>
+        <version>${project.version}</version>
+      </dependency>
+    </dependencies>
+  </dependencyManagement>
+</project>`
+
+	const rootContent = `<?xml version="1.0"?>
+<project>
+  <groupId>org.gitbundle</groupId>
+  <artifactId>root</artifactId>
+  <version>3</version>
+  <properties>
+    <git.version>3.0.0</git.version>
+    <core.version>${git.version}-core</core.version>
+  </properties>
+</project>`
+
+	const childContent = `<?xml version="1.0"?>
+<project>
+  <parent>
+    <groupId>org.gitbundle</groupId>
+    <artifactId>parent</artifactId>
+    <version>2.0.0</version>
+  </parent>
+  <artifactId>child</artifactId>
+  <dependencies>
+    <dependency>
+      <groupId>${project.groupId}</groupId>
+      <artifactId>sibling</artifactId>
+      <version>${project.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>org.gitbundle</groupId>
+      <artifactId>managed</artifactId>
+    </dependency>
+    <dependency>
+      <groupId>org.gitbundle.core</groupId>
+      <artifactId>git</artifactId>
+      <version>${core.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>org.gitbundle.core</groupId>
+      <artifactId>unknown</artifactId>
+      <version>${unknown.version}</version>
+    </dependency>
+  </dependencies>
+</project>`
+
+	resolved := make([]string, 0, 2)
+	resolve := func(groupID, artifactID, version string) (io.ReadCloser, error) {
+		resolved = append(resolved, groupID+":"+artifactID+":"+version)
+		switch artifactID {
+		case "parent":
+			return io.NopCloser(strings.NewReader(parentContent)), nil
+		case "root":
+			return io.NopCloser(strings.NewReader(rootContent)), nil
+		}
+		return nil, nil
+	}
+
+	m, err := ParsePackageMetaDataWithParents(strings.NewReader(childContent), resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"org.gitbundle:parent:2.0.0", "org.gitbundle:root:3"}, resolved)
+	assert.Equal(t, "org.gitbundle", m.GroupID)
+	assert.Equal(t, []*Dependency{
+		{GroupID: "org.gitbundle", ArtifactID: "sibling", Version: "2.0.0"},
+		{GroupID: "org.gitbundle", ArtifactID: "managed", Version: "2.0.0"},
+		{GroupID: "org.gitbundle.core", ArtifactID: "git", Version: "4.0.0-core"},
+		{GroupID: "org.gitbundle.core", ArtifactID: "unknown", Version: "${unknown.version}"},
+	}, m.Dependencies)
+
+	t.Run("WithoutResolver", func(t *testing.T) {
+		m, err := ParsePackageMetaData(strings.NewReader(childContent))
+		assert.NoError(t, err)
+		assert.Equal(t, "2.0.0", m.Dependencies[0].Version)
+		assert.Empty(t, m.Dependencies[1].Version)
+		assert.Equal(t, "${core.version}", m.Dependencies[2].Version)
+	})
+
+	t.Run("ResolverError", func(t *testing.T) {
+		m, err := ParsePackageMetaDataWithParents(strings.NewReader(childContent), func(string, string, string) (io.ReadCloser, error) {
+			return nil, io.ErrUnexpectedEOF
+		})
+		assert.Nil(t, m)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package maven
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+	"github.com/gitbundle/modules/packages/versioning"
+)
+
+const (
+	// MetadataFilename is the name of the metadata file at the artifact and version level
+	MetadataFilename = "maven-metadata.xml"
+
+	lastUpdatedFormat = "20060102150405"
+)
+
+// ChecksumExtensions are the extensions of the checksum files served for every file
+var ChecksumExtensions = []string{".md5", ".sha1", ".sha256", ".sha512"}
+
+// IsChecksumFile tests if the filename belongs to a checksum file
+func IsChecksumFile(filename string) bool {
+	for _, ext := range ChecksumExtensions {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChecksumFiles returns the hex encoded content of the checksum files keyed by their extension
+func ChecksumFiles(h packages.HashSummer) map[string]string {
+	hashMD5, hashSHA1, hashSHA256, hashSHA512 := h.Sums()
+	return map[string]string{
+		".md5":    hex.EncodeToString(hashMD5),
+		".sha1":   hex.EncodeToString(hashSHA1),
+		".sha256": hex.EncodeToString(hashSHA256),
+		".sha512": hex.EncodeToString(hashSHA512),
+	}
+}
+
+// File represents a generated file with the content of its checksum files
+type File struct {
+	Content   []byte
+	Checksums map[string]string
+}
+
+// NewFile creates a file from the content written by fn and calculates its checksums with a MultiHasher
+func NewFile(fn func(io.Writer) error) (*File, error) {
+	var buf bytes.Buffer
+	h := packages.NewMultiHasher()
+	if err := fn(io.MultiWriter(&buf, h)); err != nil {
+		return nil, err
+	}
+	return &File{
+		Content:   buf.Bytes(),
+		Checksums: ChecksumFiles(h),
+	}, nil
+}
+
+type metadataSnapshot struct {
+	Timestamp   string `xml:"timestamp"`
+	BuildNumber int    `xml:"buildNumber"`
+}
+
+type metadataSnapshotVersion struct {
+	Classifier string `xml:"classifier,omitempty"`
+	Extension  string `xml:"extension"`
+	Value      string `xml:"value"`
+	Updated    string `xml:"updated"`
+}
+
+type metadataVersions struct {
+	Versions []string `xml:"version"`
+}
+
+type metadataSnapshotVersions struct {
+	SnapshotVersions []*metadataSnapshotVersion `xml:"snapshotVersion"`
+}
+
+type metadataVersioning struct {
+	Latest           string                    `xml:"latest,omitempty"`
+	Release          string                    `xml:"release,omitempty"`
+	Versions         *metadataVersions         `xml:"versions,omitempty"`
+	Snapshot         *metadataSnapshot         `xml:"snapshot,omitempty"`
+	LastUpdated      string                    `xml:"lastUpdated"`
+	SnapshotVersions *metadataSnapshotVersions `xml:"snapshotVersions,omitempty"`
+}
+
+type metadataStruct struct {
+	XMLName      xml.Name           `xml:"metadata"`
+	ModelVersion string             `xml:"modelVersion,attr,omitempty"`
+	GroupID      string             `xml:"groupId"`
+	ArtifactID   string             `xml:"artifactId"`
+	Version      string             `xml:"version,omitempty"`
+	Versioning   metadataVersioning `xml:"versioning"`
+}
+
+// WriteArtifactMetadata writes the artifact level maven-metadata.xml.
+// The versions are listed from oldest to newest in the Maven version order.
+func WriteArtifactMetadata(w io.Writer, groupID, artifactID string, versions []string, lastUpdated time.Time) error {
+	versions = append([]string{}, versions...)
+	versioning.Sort(VersionScheme, versions)
+
+	m := &metadataStruct{
+		GroupID:    groupID,
+		ArtifactID: artifactID,
+		Versioning: metadataVersioning{
+			Versions:    &metadataVersions{Versions: versions},
+			LastUpdated: lastUpdated.UTC().Format(lastUpdatedFormat),
+		},
+	}
+	if len(versions) > 0 {
+		m.Versioning.Latest = versions[len(versions)-1]
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !IsSnapshotVersion(versions[i]) {
+			m.Versioning.Release = versions[i]
+			break
+		}
+	}
+	return writeXML(w, m)
+}
+
+// WriteSnapshotMetadata writes the version level maven-metadata.xml of a snapshot version.
+// It lists the newest timestamped file of every classifier and extension, checksum files are ignored.
+func WriteSnapshotMetadata(w io.Writer, groupID, artifactID, version string, filenames []string) error {
+	newest := make(map[string]*SnapshotFile)
+	var latest *SnapshotFile
+	for _, filename := range filenames {
+		if IsChecksumFile(filename) {
+			continue
+		}
+		sf, err := ParseSnapshotFilename(artifactID, version, filename)
+		if err != nil {
+			continue
+		}
+		key := sf.Classifier + "|" + sf.Extension
+		if cur, ok := newest[key]; !ok || sf.isNewerThan(cur) {
+			newest[key] = sf
+		}
+		if latest == nil || sf.isNewerThan(latest) {
+			latest = sf
+		}
+	}
+
+	m := &metadataStruct{
+		ModelVersion: "1.1.0",
+		GroupID:      groupID,
+		ArtifactID:   artifactID,
+		Version:      version,
+	}
+	snapshotVersions := make([]*metadataSnapshotVersion, 0, len(newest))
+	if latest != nil {
+		m.Versioning.Snapshot = &metadataSnapshot{
+			Timestamp:   latest.Timestamp,
+			BuildNumber: latest.BuildNumber,
+		}
+		m.Versioning.LastUpdated = latest.Updated.Format(lastUpdatedFormat)
+	}
+
+	for _, sf := range newest {
+		snapshotVersions = append(snapshotVersions, &metadataSnapshotVersion{
+			Classifier: sf.Classifier,
+			Extension:  sf.Extension,
+			Value:      sf.Value(),
+			Updated:    sf.Updated.Format(lastUpdatedFormat),
+		})
+	}
+	sort.Slice(snapshotVersions, func(i, j int) bool {
+		a, b := snapshotVersions[i], snapshotVersions[j]
+		if a.Extension != b.Extension {
+			return a.Extension < b.Extension
+		}
+		return a.Classifier < b.Classifier
+	})
+	m.Versioning.SnapshotVersions = &metadataSnapshotVersions{SnapshotVersions: snapshotVersions}
+
+	return writeXML(w, m)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package maven
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteArtifactMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteArtifactMetadata(&buf, groupID, artifactID, []string{"1.1.0-SNAPSHOT", "1.0.0", "1.0.1"}, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<metadata>
+  <groupId>org.gitbundle</groupId>
+  <artifactId>my-project</artifactId>
+  <versioning>
+    <latest>1.1.0-SNAPSHOT</latest>
+    <release>1.0.1</release>
+    <versions>
+      <version>1.0.0</version>
+      <version>1.0.1</version>
+      <version>1.1.0-SNAPSHOT</version>
+    </versions>
+    <lastUpdated>20230102030405</lastUpdated>
+  </versioning>
+</metadata>`, buf.String())
+}
+
+func TestWriteSnapshotMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSnapshotMetadata(&buf, groupID, artifactID, "1.1.0-SNAPSHOT", []string{
+		"my-project-1.1.0-20230102.030405-1.jar",
+		"my-project-1.1.0-20230102.030405-1.jar.sha1",
+		"my-project-1.1.0-20230102.030405-1.pom",
+		"my-project-1.1.0-20230103.030405-2.jar",
+		"my-project-1.1.0-20230103.030405-2-sources.jar",
+		"my-project-1.1.0-20230103.030405-2.jar.md5",
+		"maven-metadata.xml",
+	}))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<metadata modelVersion="1.1.0">
+  <groupId>org.gitbundle</groupId>
+  <artifactId>my-project</artifactId>
+  <version>1.1.0-SNAPSHOT</version>
+  <versioning>
+    <snapshot>
+      <timestamp>20230103.030405</timestamp>
+      <buildNumber>2</buildNumber>
+    </snapshot>
+    <lastUpdated>20230103030405</lastUpdated>
+    <snapshotVersions>
+      <snapshotVersion>
+        <extension>jar</extension>
+        <value>1.1.0-20230103.030405-2</value>
+        <updated>20230103030405</updated>
+      </snapshotVersion>
+      <snapshotVersion>
+        <classifier>sources</classifier>
+        <extension>jar</extension>
+        <value>1.1.0-20230103.030405-2</value>
+        <updated>20230103030405</updated>
+      </snapshotVersion>
+      <snapshotVersion>
+        <extension>pom</extension>
+        <value>1.1.0-20230102.030405-1</value>
+        <updated>20230102030405</updated>
+      </snapshotVersion>
+    </snapshotVersions>
+  </versioning>
+</metadata>`, buf.String())
+}
+
+func TestNewFile(t *testing.T) {
+	f, err := NewFile(func(w io.Writer) error {
+		_, err := io.WriteString(w, "gitbundle")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("gitbundle"), f.Content)
+	assert.Len(t, f.Checksums, len(ChecksumExtensions))
+	assert.Equal(t, "41519f4c130918db00d637532f2c5b42", f.Checksums[".md5"])
+
+	assert.True(t, IsChecksumFile("my-project-1.0.0.jar.sha512"))
+	assert.False(t, IsChecksumFile("my-project-1.0.0.jar"))
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package maven
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotSuffix is the suffix of snapshot versions
+const SnapshotSuffix = "-SNAPSHOT"
+
+const snapshotTimestampFormat = "20060102.150405"
+
+var (
+	// ErrInvalidSnapshotFilename indicates a filename which does not belong to the snapshot version
+	ErrInvalidSnapshotFilename = errors.New("snapshot filename is invalid")
+	// ErrSnapshotNotFound indicates that no timestamped file matches the requested file
+	ErrSnapshotNotFound = errors.New("snapshot file not found")
+)
+
+var snapshotPattern = regexp.MustCompile(`\A(\d{8}\.\d{6})-(\d+)(?:-([^.]+))?\.(.+)\z`)
+
+// IsSnapshotVersion tests if the version is a snapshot version
+func IsSnapshotVersion(version string) bool {
+	return strings.HasSuffix(version, SnapshotSuffix)
+}
+
+// SnapshotFile represents a file of a timestamped snapshot version,
+// e.g. my-project-1.0-20230102.030405-3-sources.jar
+type SnapshotFile struct {
+	Filename    string
+	BaseVersion string
+	Timestamp   string
+	BuildNumber int
+	Classifier  string
+	Extension   string
+	Updated     time.Time
+}
+
+// Value returns the timestamped version, e.g. 1.0-20230102.030405-3
+func (sf *SnapshotFile) Value() string {
+	return strings.TrimSuffix(sf.BaseVersion, SnapshotSuffix) + "-" + sf.Timestamp + "-" + strconv.Itoa(sf.BuildNumber)
+}
+
+func (sf *SnapshotFile) isNewerThan(other *SnapshotFile) bool {
+	if sf.Timestamp != other.Timestamp {
+		return sf.Timestamp > other.Timestamp
+	}
+	return sf.BuildNumber > other.BuildNumber
+}
+
+// ParseSnapshotFilename parses the filename of a timestamped file of a snapshot version
+func ParseSnapshotFilename(artifactID, version, filename string) (*SnapshotFile, error) {
+	if !IsSnapshotVersion(version) {
+		return nil, ErrInvalidSnapshotFilename
+	}
+
+	prefix := artifactID + "-" + strings.TrimSuffix(version, SnapshotSuffix) + "-"
+	if !strings.HasPrefix(filename, prefix) {
+		return nil, ErrInvalidSnapshotFilename
+	}
+
+	m := snapshotPattern.FindStringSubmatch(filename[len(prefix):])
+	if m == nil {
+		return nil, ErrInvalidSnapshotFilename
+	}
+
+	updated, err := time.Parse(snapshotTimestampFormat, m[1])
+	if err != nil {
+		return nil, ErrInvalidSnapshotFilename
+	}
+	buildNumber, err := strconv.Atoi(m[2])
+	if err != nil {
+		return nil, ErrInvalidSnapshotFilename
+	}
+
+	return &SnapshotFile{
+		Filename:    filename,
+		BaseVersion: version,
+		Timestamp:   m[1],
+		BuildNumber: buildNumber,
+		Classifier:  m[3],
+		Extension:   m[4],
+		Updated:     updated,
+	}, nil
+}
+
+// ResolveSnapshotFilename maps a request for a non-timestamped snapshot file like my-project-1.0-SNAPSHOT.jar
+// to the newest timestamped file with the same classifier and extension.
+func ResolveSnapshotFilename(artifactID, version, filename string, filenames []string) (string, error) {
+	prefix := artifactID + "-" + version
+	if !IsSnapshotVersion(version) || !strings.HasPrefix(filename, prefix) {
+		return "", ErrInvalidSnapshotFilename
+	}
+
+	rest := filename[len(prefix):]
+	classifier := ""
+	if strings.HasPrefix(rest, "-") {
+		var ok bool
+		classifier, rest, ok = strings.Cut(rest[1:], ".")
+		if !ok {
+			return "", ErrInvalidSnapshotFilename
+		}
+	} else if strings.HasPrefix(rest, ".") {
+		rest = rest[1:]
+	} else {
+		return "", ErrInvalidSnapshotFilename
+	}
+	extension := rest
+
+	var newest *SnapshotFile
+	for _, fn := range filenames {
+		sf, err := ParseSnapshotFilename(artifactID, version, fn)
+		if err != nil || sf.Classifier != classifier || sf.Extension != extension {
+			continue
+		}
+		if newest == nil || sf.isNewerThan(newest) {
+			newest = sf
+		}
+	}
+	if newest == nil {
+		return "", ErrSnapshotNotFound
+	}
+	return newest.Filename, nil
+}
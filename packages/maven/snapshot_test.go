@@ -0,0 +1,61 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package maven
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSnapshotFilename(t *testing.T) {
+	for _, invalid := range []string{"my-project-1.1.0-SNAPSHOT.jar", "other-1.1.0-20230102.030405-1.jar", "my-project-1.1.0-20231302.030405-1.jar"} {
+		sf, err := ParseSnapshotFilename(artifactID, "1.1.0-SNAPSHOT", invalid)
+		assert.Nil(t, sf)
+		assert.ErrorIs(t, err, ErrInvalidSnapshotFilename)
+	}
+
+	sf, err := ParseSnapshotFilename(artifactID, "1.1.0", "my-project-1.1.0-20230102.030405-1.jar")
+	assert.Nil(t, sf)
+	assert.ErrorIs(t, err, ErrInvalidSnapshotFilename)
+
+	sf, err = ParseSnapshotFilename(artifactID, "1.1.0-SNAPSHOT", "my-project-1.1.0-20230102.030405-12-sources.jar.asc")
+	assert.NoError(t, err)
+	assert.Equal(t, "20230102.030405", sf.Timestamp)
+	assert.Equal(t, 12, sf.BuildNumber)
+	assert.Equal(t, "sources", sf.Classifier)
+	assert.Equal(t, "jar.asc", sf.Extension)
+	assert.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), sf.Updated)
+	assert.Equal(t, "1.1.0-20230102.030405-12", sf.Value())
+}
+
+func TestResolveSnapshotFilename(t *testing.T) {
+	filenames := []string{
+		"my-project-1.1.0-20230102.030405-1.jar",
+		"my-project-1.1.0-20230102.030405-2.jar",
+		"my-project-1.1.0-20230101.030405-3.jar",
+		"my-project-1.1.0-20230102.030405-2.jar.sha1",
+		"my-project-1.1.0-20230101.030405-3-sources.jar",
+	}
+
+	cases := map[string]string{
+		"my-project-1.1.0-SNAPSHOT.jar":         "my-project-1.1.0-20230102.030405-2.jar",
+		"my-project-1.1.0-SNAPSHOT.jar.sha1":    "my-project-1.1.0-20230102.030405-2.jar.sha1",
+		"my-project-1.1.0-SNAPSHOT-sources.jar": "my-project-1.1.0-20230101.030405-3-sources.jar",
+	}
+	for request, expected := range cases {
+		filename, err := ResolveSnapshotFilename(artifactID, "1.1.0-SNAPSHOT", request, filenames)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, filename)
+	}
+
+	_, err := ResolveSnapshotFilename(artifactID, "1.1.0-SNAPSHOT", "my-project-1.1.0-SNAPSHOT.pom", filenames)
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+
+	_, err = ResolveSnapshotFilename(artifactID, "1.1.0-SNAPSHOT", "my-project-1.1.0-SNAPSHOTjar", filenames)
+	assert.ErrorIs(t, err, ErrInvalidSnapshotFilename)
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npm
+
+import (
+	"time"
+)
+
+// ContentTypeAbbreviated is the accept header value of clients requesting the abbreviated metadata document
+const ContentTypeAbbreviated = "application/vnd.npm.install-v1+json"
+
+// AbbreviatedPackageMetadata https://github.com/npm/registry/blob/master/docs/responses/package-metadata.md#abbreviated-metadata-format
+type AbbreviatedPackageMetadata struct {
+	Name     string                                        `json:"name"`
+	Modified time.Time                                     `json:"modified"`
+	DistTags map[string]string                             `json:"dist-tags,omitempty"`
+	Versions map[string]*AbbreviatedPackageMetadataVersion `json:"versions"`
+}
+
+// AbbreviatedPackageMetadataVersion https://github.com/npm/registry/blob/master/docs/responses/package-metadata.md#abbreviated-version-object
+type AbbreviatedPackageMetadataVersion struct {
+	Name                 string              `json:"name"`
+	Version              string              `json:"version"`
+	Deprecated           string              `json:"deprecated,omitempty"`
+	Dependencies         map[string]string   `json:"dependencies,omitempty"`
+	DevDependencies      map[string]string   `json:"devDependencies,omitempty"`
+	PeerDependencies     map[string]string   `json:"peerDependencies,omitempty"`
+	OptionalDependencies map[string]string   `json:"optionalDependencies,omitempty"`
+	Dist                 PackageDistribution `json:"dist"`
+}
+
+// NewAbbreviatedPackageMetadata creates the abbreviated metadata document which contains only the fields needed for installs
+func NewAbbreviatedPackageMetadata(pm *PackageMetadata) *AbbreviatedPackageMetadata {
+	modified, ok := pm.Time["modified"]
+	if !ok {
+		for _, t := range pm.Time {
+			if t.After(modified) {
+				modified = t
+			}
+		}
+	}
+
+	versions := make(map[string]*AbbreviatedPackageMetadataVersion, len(pm.Versions))
+	for v, meta := range pm.Versions {
+		versions[v] = &AbbreviatedPackageMetadataVersion{
+			Name:                 meta.Name,
+			Version:              meta.Version,
+			Deprecated:           meta.Deprecated,
+			Dependencies:         meta.Dependencies,
+			DevDependencies:      meta.DevDependencies,
+			PeerDependencies:     meta.PeerDependencies,
+			OptionalDependencies: meta.OptionalDependencies,
+			Dist:                 meta.Dist,
+		}
+	}
+
+	return &AbbreviatedPackageMetadata{
+		Name:     pm.Name,
+		Modified: modified,
+		DistTags: pm.DistTags,
+		Versions: versions,
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAbbreviatedPackageMetadata(t *testing.T) {
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	modified := created.Add(time.Hour)
+
+	pm := &PackageMetadata{
+		ID:          "test",
+		Name:        "test",
+		Description: "Test Description",
+		DistTags:    map[string]string{"latest": "1.0.0"},
+		Readme:      "Readme",
+		Time:        map[string]time.Time{"created": created, "1.0.0": modified},
+		Versions: map[string]*PackageMetadataVersion{
+			"1.0.0": {
+				ID:           "test@1.0.0",
+				Name:         "test",
+				Version:      "1.0.0",
+				Description:  "Test Description",
+				Readme:       "Readme",
+				Deprecated:   "deprecated",
+				Dependencies: map[string]string{"dep": "^1.0.0"},
+				Dist: PackageDistribution{
+					Integrity: "sha512-abc",
+					Shasum:    "abc",
+					Tarball:   "https://gitbundle.com/api/packages/user/npm/test/-/1.0.0/test-1.0.0.tgz",
+				},
+			},
+		},
+	}
+
+	apm := NewAbbreviatedPackageMetadata(pm)
+	assert.True(t, modified.Equal(apm.Modified))
+
+	b, err := json.Marshal(apm)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "test",
+		"modified": "2023-01-02T04:04:05Z",
+		"dist-tags": {"latest": "1.0.0"},
+		"versions": {
+			"1.0.0": {
+				"name": "test",
+				"version": "1.0.0",
+				"deprecated": "deprecated",
+				"dependencies": {"dep": "^1.0.0"},
+				"dist": {
+					"integrity": "sha512-abc",
+					"shasum": "abc",
+					"tarball": "https://gitbundle.com/api/packages/user/npm/test/-/1.0.0/test-1.0.0.tgz"
+				}
+			}
+		}
+	}`, string(b))
+
+	pm.Time["modified"] = created
+	assert.True(t, created.Equal(NewAbbreviatedPackageMetadata(pm).Modified))
+}
@@ -46,6 +46,8 @@ type Package struct {
 	Metadata Metadata
 	Filename string
 	Data     []byte
+	// Provenance is the optional sigstore bundle attached by npm publish --provenance
+	Provenance *ProvenanceBundle
 }
 
 // PackageMetadata https://github.com/npm/registry/blob/master/docs/REGISTRY-API.md#package
@@ -85,6 +87,7 @@ type PackageMetadataVersion struct {
 	Readme               string              `json:"readme,omitempty"`
 	Dist                 PackageDistribution `json:"dist"`
 	Maintainers          []User              `json:"maintainers,omitempty"`
+	Deprecated           string              `json:"deprecated,omitempty"`
 }
 
 // PackageDistribution https://github.com/npm/registry/blob/master/docs/REGISTRY-API.md#version
@@ -95,6 +98,19 @@ type PackageDistribution struct {
 	FileCount    int    `json:"fileCount,omitempty"`
 	UnpackedSize int    `json:"unpackedSize,omitempty"`
 	NpmSignature string `json:"npm-signature,omitempty"`
+	// Attestations references the provenance attestations of the version
+	Attestations *PackageAttestations `json:"attestations,omitempty"`
+}
+
+// PackageAttestations https://github.com/npm/registry/blob/master/docs/REGISTRY-API.md#version
+type PackageAttestations struct {
+	URL        string                        `json:"url"`
+	Provenance PackageAttestationsProvenance `json:"provenance"`
+}
+
+// PackageAttestationsProvenance describes the provenance attestation of a version
+type PackageAttestationsProvenance struct {
+	PredicateType string `json:"predicateType"`
 }
 
 // User https://github.com/npm/registry/blob/master/docs/REGISTRY-API.md#package
@@ -141,6 +157,34 @@ type PackageAttachment struct {
 	ContentType string `json:"content_type"`
 	Data        string `json:"data"`
 	Length      int    `json:"length"`
+	// Object contains the JSON encoded data if the attachment is not a base64 string, e.g. a sigstore bundle
+	Object []byte `json:"-"`
+}
+
+// UnmarshalJSON is needed because the data of a provenance attachment is an object instead of a string
+func (a *PackageAttachment) UnmarshalJSON(data []byte) error {
+	var tmp struct {
+		ContentType string      `json:"content_type"`
+		Data        interface{} `json:"data"`
+		Length      int         `json:"length"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	a.ContentType = tmp.ContentType
+	a.Length = tmp.Length
+	switch v := tmp.Data.(type) {
+	case nil:
+	case string:
+		a.Data = v
+	default:
+		obj, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		a.Object = obj
+	}
+	return nil
 }
 
 type packageUpload struct {
@@ -203,12 +247,14 @@ func ParsePackage(r io.Reader) (*Package, error) {
 
 		p.Filename = strings.ToLower(fmt.Sprintf("%s-%s.tgz", name, p.Version))
 
-		attachment := func() *PackageAttachment {
-			for _, a := range upload.Attachments {
-				return a
+		var attachment, provenance *PackageAttachment
+		for filename, a := range upload.Attachments {
+			if strings.HasSuffix(filename, ProvenanceAttachmentSuffix) {
+				provenance = a
+			} else {
+				attachment = a
 			}
-			return nil
-		}()
+		}
 		if attachment == nil || len(attachment.Data) == 0 {
 			return nil, ErrInvalidAttachment
 		}
@@ -240,6 +286,17 @@ func ParsePackage(r io.Reader) (*Package, error) {
 			return nil, ErrInvalidIntegrity
 		}
 
+		if provenance != nil {
+			bundle, err := ParseProvenanceBundle(provenance.Object)
+			if err != nil {
+				return nil, err
+			}
+			if err := bundle.VerifySubject(meta.Name, p.Version, data); err != nil {
+				return nil, err
+			}
+			p.Provenance = bundle
+		}
+
 		return p, nil
 	}
 
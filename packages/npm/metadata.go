@@ -5,8 +5,19 @@
 
 package npm
 
-// TagProperty is the name of the property for tag management
-const TagProperty = "npm.tag"
+import (
+	"github.com/gitbundle/modules/packages/versioning"
+)
+
+const (
+	// TagProperty is the name of the property for tag management
+	TagProperty = "npm.tag"
+	// DeprecatedProperty is the name of the property holding the deprecation message of a version
+	DeprecatedProperty = "npm.deprecated"
+)
+
+// VersionScheme defines the version semantics of npm packages
+var VersionScheme = versioning.SemVer
 
 // Metadata represents the metadata of a npm package
 type Metadata struct {
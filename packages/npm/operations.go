@@ -0,0 +1,148 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npm
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/hashicorp/go-version"
+)
+
+// LatestTag is the dist-tag npm installs by default, it can't be removed
+const LatestTag = "latest"
+
+var (
+	// ErrInvalidDistTag indicates an invalid dist-tag
+	ErrInvalidDistTag = errors.New("The dist-tag is invalid")
+	// ErrDeleteLatestTag indicates an attempt to remove the latest dist-tag
+	ErrDeleteLatestTag = errors.New("The latest dist-tag can't be removed")
+	// ErrUnexpectedAttachment indicates a package update which contains attachments
+	ErrUnexpectedAttachment = errors.New("The package update must not contain attachments")
+)
+
+// distTagMatch allows the characters which are not escaped by encodeURIComponent
+var distTagMatch = regexp.MustCompile(`\A[a-zA-Z0-9\-_.!~*'()]+\z`)
+
+// IsValidDistTag checks if the dist-tag is url safe and can't be confused with a version
+func IsValidDistTag(tag string) bool {
+	if !distTagMatch.MatchString(tag) {
+		return false
+	}
+	if _, err := version.NewVersion(tag); err == nil {
+		return false
+	}
+	return true
+}
+
+// DistTag represents a dist-tag operation of npm dist-tag add
+type DistTag struct {
+	Tag     string
+	Version string
+}
+
+// ParseDistTag parses the body of a PUT /-/package/<name>/dist-tags/<tag> request which is the JSON encoded version
+func ParseDistTag(tag string, r io.Reader) (*DistTag, error) {
+	if !IsValidDistTag(tag) {
+		return nil, ErrInvalidDistTag
+	}
+
+	var s string
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, ErrInvalidPackageVersion
+	}
+
+	v, err := version.NewSemver(s)
+	if err != nil {
+		return nil, ErrInvalidPackageVersion
+	}
+
+	return &DistTag{
+		Tag:     tag,
+		Version: v.String(),
+	}, nil
+}
+
+// ValidateDistTagDeletion checks if the dist-tag of a DELETE /-/package/<name>/dist-tags/<tag> request can be removed
+func ValidateDistTagDeletion(tag string) error {
+	if !IsValidDistTag(tag) {
+		return ErrInvalidDistTag
+	}
+	if tag == LatestTag {
+		return ErrDeleteLatestTag
+	}
+	return nil
+}
+
+// PackageUpdate represents a package document sent without attachments to modify an existing package.
+// npm deprecate and npm unpublish <name>@<version> fetch the package document, modify it and send it back.
+type PackageUpdate struct {
+	Name     string
+	DistTags map[string]string
+	Versions map[string]*PackageMetadataVersion
+}
+
+// ParsePackageUpdate parses the body of a PUT /<name> or PUT /<name>/-rev/<rev> request which does not publish a new version
+func ParsePackageUpdate(r io.Reader) (*PackageUpdate, error) {
+	var upload packageUpload
+	if err := json.NewDecoder(r).Decode(&upload); err != nil {
+		return nil, err
+	}
+
+	if len(upload.Attachments) > 0 {
+		return nil, ErrUnexpectedAttachment
+	}
+	if !validateName(upload.Name) {
+		return nil, ErrInvalidPackageName
+	}
+
+	for v, meta := range upload.Versions {
+		if meta == nil || meta.Version != v {
+			return nil, ErrInvalidPackageVersion
+		}
+		if _, err := version.NewSemver(v); err != nil {
+			return nil, ErrInvalidPackageVersion
+		}
+	}
+
+	for tag := range upload.DistTags {
+		if !IsValidDistTag(tag) {
+			return nil, ErrInvalidDistTag
+		}
+	}
+
+	return &PackageUpdate{
+		Name:     upload.Name,
+		DistTags: upload.DistTags,
+		Versions: upload.Versions,
+	}, nil
+}
+
+// Deprecations returns the deprecation message of every version in the update.
+// An empty message means the version is not deprecated (anymore).
+func (u *PackageUpdate) Deprecations() map[string]string {
+	deprecations := make(map[string]string, len(u.Versions))
+	for v, meta := range u.Versions {
+		deprecations[v] = meta.Deprecated
+	}
+	return deprecations
+}
+
+// RemovedVersions returns the existing versions which are missing in the update and must be unpublished
+func (u *PackageUpdate) RemovedVersions(existing []string) []string {
+	removed := make([]string, 0, len(existing))
+	for _, v := range existing {
+		if _, ok := u.Versions[v]; !ok {
+			removed = append(removed, v)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
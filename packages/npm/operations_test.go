@@ -0,0 +1,88 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidDistTag(t *testing.T) {
+	for _, tag := range []string{"latest", "beta", "next-1.x", "rc_2"} {
+		assert.True(t, IsValidDistTag(tag), tag)
+	}
+	for _, tag := range []string{"", "1.0.0", "v1", "1", "with space", "a/b"} {
+		assert.False(t, IsValidDistTag(tag), tag)
+	}
+}
+
+func TestParseDistTag(t *testing.T) {
+	dt, err := ParseDistTag("beta", strings.NewReader(`"1.2.0-beta.1"`))
+	assert.NoError(t, err)
+	assert.Equal(t, &DistTag{Tag: "beta", Version: "1.2.0-beta.1"}, dt)
+
+	dt, err = ParseDistTag("1.0.0", strings.NewReader(`"1.0.0"`))
+	assert.Nil(t, dt)
+	assert.ErrorIs(t, err, ErrInvalidDistTag)
+
+	dt, err = ParseDistTag("beta", strings.NewReader(`"not-a-version"`))
+	assert.Nil(t, dt)
+	assert.ErrorIs(t, err, ErrInvalidPackageVersion)
+
+	dt, err = ParseDistTag("beta", strings.NewReader(`{}`))
+	assert.Nil(t, dt)
+	assert.ErrorIs(t, err, ErrInvalidPackageVersion)
+
+	assert.NoError(t, ValidateDistTagDeletion("beta"))
+	assert.ErrorIs(t, ValidateDistTagDeletion(LatestTag), ErrDeleteLatestTag)
+	assert.ErrorIs(t, ValidateDistTagDeletion("1.0.0"), ErrInvalidDistTag)
+}
+
+func TestParsePackageUpdate(t *testing.T) {
+	t.Run("Deprecate", func(t *testing.T) {
+		u, err := ParsePackageUpdate(strings.NewReader(`{
+			"_id": "@scope/test",
+			"name": "@scope/test",
+			"dist-tags": {"latest": "1.1.0"},
+			"versions": {
+				"1.0.0": {"name": "@scope/test", "version": "1.0.0", "deprecated": "use 1.1.0"},
+				"1.1.0": {"name": "@scope/test", "version": "1.1.0", "deprecated": ""}
+			},
+			"time": {"modified": "2023-01-02T03:04:05.000Z"}
+		}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "@scope/test", u.Name)
+		assert.Equal(t, map[string]string{"1.0.0": "use 1.1.0", "1.1.0": ""}, u.Deprecations())
+		assert.Empty(t, u.RemovedVersions([]string{"1.0.0", "1.1.0"}))
+	})
+
+	t.Run("Unpublish", func(t *testing.T) {
+		u, err := ParsePackageUpdate(strings.NewReader(`{
+			"name": "test",
+			"dist-tags": {"latest": "1.0.0"},
+			"versions": {"1.0.0": {"name": "test", "version": "1.0.0"}}
+		}`))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"latest": "1.0.0"}, u.DistTags)
+		assert.Equal(t, []string{"1.1.0", "1.2.0"}, u.RemovedVersions([]string{"1.2.0", "1.0.0", "1.1.0"}))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParsePackageUpdate(strings.NewReader(`{"name": "test", "versions": {}, "_attachments": {"test-1.0.0.tgz": {"data": "AA=="}}}`))
+		assert.ErrorIs(t, err, ErrUnexpectedAttachment)
+
+		_, err = ParsePackageUpdate(strings.NewReader(`{"name": " test", "versions": {}}`))
+		assert.ErrorIs(t, err, ErrInvalidPackageName)
+
+		_, err = ParsePackageUpdate(strings.NewReader(`{"name": "test", "versions": {"1.0.0": {"version": "1.0.1"}}}`))
+		assert.ErrorIs(t, err, ErrInvalidPackageVersion)
+
+		_, err = ParsePackageUpdate(strings.NewReader(`{"name": "test", "dist-tags": {"1.0.0": "1.0.0"}, "versions": {}}`))
+		assert.ErrorIs(t, err, ErrInvalidDistTag)
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npm
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gitbundle/modules/json"
+)
+
+const (
+	// ProvenanceAttachmentSuffix is the filename suffix of the provenance attachment of an upload
+	ProvenanceAttachmentSuffix = ".sigstore"
+	// SigstoreBundleMediaType is the media type prefix of sigstore bundles
+	SigstoreBundleMediaType = "application/vnd.dev.sigstore.bundle"
+	// InTotoPayloadType is the DSSE payload type of in-toto statements
+	InTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+var (
+	// ErrInvalidProvenance indicates an invalid provenance bundle
+	ErrInvalidProvenance = errors.New("The provenance bundle is invalid")
+	// ErrProvenanceSubjectMismatch indicates a provenance bundle which does not attest the uploaded package
+	ErrProvenanceSubjectMismatch = errors.New("The provenance bundle does not match the package")
+)
+
+// ProvenanceBundle is a sigstore bundle containing the provenance attestation of a package version.
+// Only the structure and the attested subject are validated, verifying the signature against
+// the sigstore transparency log is left to the clients (npm audit signatures).
+type ProvenanceBundle struct {
+	MediaType            string                 `json:"mediaType"`
+	VerificationMaterial map[string]interface{} `json:"verificationMaterial"`
+	DSSEEnvelope         *DSSEEnvelope          `json:"dsseEnvelope"`
+	// Statement is the decoded payload of the DSSE envelope
+	Statement *InTotoStatement `json:"-"`
+}
+
+// DSSEEnvelope https://github.com/secure-systems-lab/dsse/blob/master/envelope.md
+type DSSEEnvelope struct {
+	Payload     []byte           `json:"payload"`
+	PayloadType string           `json:"payloadType"`
+	Signatures  []*DSSESignature `json:"signatures"`
+}
+
+// DSSESignature is a signature of a DSSE envelope
+type DSSESignature struct {
+	Sig   string `json:"sig"`
+	KeyID string `json:"keyid"`
+}
+
+// InTotoStatement https://github.com/in-toto/attestation/blob/main/spec/v1/statement.md
+type InTotoStatement struct {
+	Type          string                 `json:"_type"`
+	Subject       []*InTotoSubject       `json:"subject"`
+	PredicateType string                 `json:"predicateType"`
+	Predicate     map[string]interface{} `json:"predicate,omitempty"`
+}
+
+// InTotoSubject is an artifact attested by an in-toto statement
+type InTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// ParseProvenanceBundle parses a sigstore bundle and its in-toto statement
+func ParseProvenanceBundle(data []byte) (*ProvenanceBundle, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidProvenance
+	}
+
+	var bundle ProvenanceBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, ErrInvalidProvenance
+	}
+
+	if !strings.HasPrefix(bundle.MediaType, SigstoreBundleMediaType) || bundle.VerificationMaterial == nil {
+		return nil, ErrInvalidProvenance
+	}
+	if bundle.DSSEEnvelope == nil || bundle.DSSEEnvelope.PayloadType != InTotoPayloadType || len(bundle.DSSEEnvelope.Signatures) == 0 {
+		return nil, ErrInvalidProvenance
+	}
+
+	if err := json.Unmarshal(bundle.DSSEEnvelope.Payload, &bundle.Statement); err != nil || bundle.Statement == nil {
+		return nil, ErrInvalidProvenance
+	}
+	if bundle.Statement.PredicateType == "" || len(bundle.Statement.Subject) == 0 {
+		return nil, ErrInvalidProvenance
+	}
+	for _, subject := range bundle.Statement.Subject {
+		if subject == nil {
+			return nil, ErrInvalidProvenance
+		}
+	}
+
+	return &bundle, nil
+}
+
+// PackageURL returns the purl identifying a npm package version, e.g. pkg:npm/%40scope/name@1.0.0
+func PackageURL(name, version string) string {
+	return "pkg:npm/" + strings.Replace(name, "@", "%40", 1) + "@" + version
+}
+
+// VerifySubject checks if the statement attests the package version with the given tarball content
+func (b *ProvenanceBundle) VerifySubject(name, version string, data []byte) error {
+	purl := PackageURL(name, version)
+	sum := sha512.Sum512(data)
+	digest := hex.EncodeToString(sum[:])
+
+	for _, s := range b.Statement.Subject {
+		if s.Name == purl && strings.EqualFold(s.Digest["sha512"], digest) {
+			return nil
+		}
+	}
+	return ErrProvenanceSubjectMismatch
+}
+
+// Attestations returns the dist.attestations reference of the package version
+func (b *ProvenanceBundle) Attestations(url string) *PackageAttestations {
+	return &PackageAttestations{
+		URL: url,
+		Provenance: PackageAttestationsProvenance{
+			PredicateType: b.Statement.PredicateType,
+		},
+	}
+}
+
+type attestation struct {
+	PredicateType string            `json:"predicateType"`
+	Bundle        *ProvenanceBundle `json:"bundle"`
+}
+
+type attestations struct {
+	Attestations []*attestation `json:"attestations"`
+}
+
+// WriteAttestations writes the response of the /-/npm/v1/attestations/<name>@<version> endpoint
+func WriteAttestations(w io.Writer, bundles ...*ProvenanceBundle) error {
+	a := &attestations{
+		Attestations: make([]*attestation, 0, len(bundles)),
+	}
+	for _, b := range bundles {
+		a.Attestations = append(a.Attestations, &attestation{
+			PredicateType: b.Statement.PredicateType,
+			Bundle:        b,
+		})
+	}
+	return json.NewEncoder(w).Encode(a)
+}
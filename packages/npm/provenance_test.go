@@ -0,0 +1,109 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package npm
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const provenancePredicateType = "https://slsa.dev/provenance/v0.2"
+
+func createProvenanceBundle(t *testing.T, subject string, data []byte) string {
+	sum := sha512.Sum512(data)
+	statement, err := json.Marshal(&InTotoStatement{
+		Type:          "https://in-toto.io/Statement/v0.1",
+		Subject:       []*InTotoSubject{{Name: subject, Digest: map[string]string{"sha512": hex.EncodeToString(sum[:])}}},
+		PredicateType: provenancePredicateType,
+		Predicate:     map[string]interface{}{"buildType": "https://github.com/npm/cli/gha/v2"},
+	})
+	assert.NoError(t, err)
+
+	return `{
+		"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.1",
+		"verificationMaterial": {"tlogEntries": [{"logIndex": "1"}]},
+		"dsseEnvelope": {
+			"payload": "` + base64.StdEncoding.EncodeToString(statement) + `",
+			"payloadType": "application/vnd.in-toto+json",
+			"signatures": [{"sig": "c2ln", "keyid": ""}]
+		}
+	}`
+}
+
+func TestParseProvenanceBundle(t *testing.T) {
+	data := []byte("package content")
+
+	bundle, err := ParseProvenanceBundle([]byte(createProvenanceBundle(t, "pkg:npm/%40scope/test@1.0.0", data)))
+	assert.NoError(t, err)
+	assert.Equal(t, provenancePredicateType, bundle.Statement.PredicateType)
+	assert.NoError(t, bundle.VerifySubject("@scope/test", "1.0.0", data))
+	assert.ErrorIs(t, bundle.VerifySubject("@scope/test", "1.0.1", data), ErrProvenanceSubjectMismatch)
+	assert.ErrorIs(t, bundle.VerifySubject("@scope/test", "1.0.0", []byte("tampered")), ErrProvenanceSubjectMismatch)
+
+	assert.Equal(t, &PackageAttestations{
+		URL:        "https://gitbundle.com/attestations",
+		Provenance: PackageAttestationsProvenance{PredicateType: provenancePredicateType},
+	}, bundle.Attestations("https://gitbundle.com/attestations"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteAttestations(&buf, bundle))
+	assert.Contains(t, buf.String(), `"predicateType":"`+provenancePredicateType+`"`)
+	assert.Contains(t, buf.String(), `"mediaType":"application/vnd.dev.sigstore.bundle+json;version=0.1"`)
+
+	for _, invalid := range []string{
+		``,
+		`null`,
+		`{}`,
+		`{"mediaType": "application/json", "verificationMaterial": {}}`,
+		`{"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.1", "verificationMaterial": {}, "dsseEnvelope": {"payload": "e30=", "payloadType": "application/vnd.in-toto+json", "signatures": [{"sig": "c2ln"}]}}`,
+		`{"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.1", "verificationMaterial": {}, "dsseEnvelope": {"payload": "eyJwcmVkaWNhdGVUeXBlIjoieCIsInN1YmplY3QiOltudWxsXX0=", "payloadType": "application/vnd.in-toto+json", "signatures": [{"sig": "c2ln"}]}}`,
+	} {
+		bundle, err := ParseProvenanceBundle([]byte(invalid))
+		assert.Nil(t, bundle)
+		assert.ErrorIs(t, err, ErrInvalidProvenance)
+	}
+}
+
+func TestParsePackageWithProvenance(t *testing.T) {
+	data := []byte("package content")
+	sum := sha512.Sum512(data)
+
+	upload := func(subject string) *bytes.Reader {
+		return bytes.NewReader([]byte(`{
+			"_id": "test",
+			"name": "test",
+			"versions": {
+				"1.0.0": {
+					"name": "test",
+					"version": "1.0.0",
+					"dist": {"integrity": "sha512-` + base64.StdEncoding.EncodeToString(sum[:]) + `"}
+				}
+			},
+			"_attachments": {
+				"test-1.0.0.tgz": {"content_type": "application/octet-stream", "data": "` + base64.StdEncoding.EncodeToString(data) + `"},
+				"test-1.0.0.sigstore": {"content_type": "application/vnd.dev.sigstore.bundle+json;version=0.1", "data": ` + createProvenanceBundle(t, subject, data) + `}
+			}
+		}`))
+	}
+
+	p, err := ParsePackage(upload("pkg:npm/test@1.0.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, data, p.Data)
+	assert.NotNil(t, p.Provenance)
+	assert.True(t, strings.HasPrefix(p.Provenance.MediaType, SigstoreBundleMediaType))
+
+	p, err = ParsePackage(upload("pkg:npm/other@1.0.0"))
+	assert.Nil(t, p)
+	assert.ErrorIs(t, err, ErrProvenanceSubjectMismatch)
+}
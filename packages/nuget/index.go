@@ -0,0 +1,311 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package nuget
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/packages/versioning"
+)
+
+// https://learn.microsoft.com/en-us/nuget/api/overview
+
+// NormalizeVersion converts a version into its normalized form, like 1.0.0.0-Beta+abc into 1.0.0-Beta.
+// The fourth part is only kept if it's not zero. Urls use the lowercase form of the normalized version.
+func NormalizeVersion(v string) (string, error) {
+	normalized, err := versioning.Normalize(VersionScheme, v)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNuspecInvalidVersion, v)
+	}
+	return normalized, nil
+}
+
+// PackageVersion is a stored version of a package
+type PackageVersion struct {
+	*Package
+	Published time.Time
+}
+
+// FeedURLs builds the urls of the v3 resources of a feed
+type FeedURLs struct {
+	// BaseURL is the url of the feed without trailing slash, index.json is located below it
+	BaseURL string
+}
+
+// ServiceIndex returns the url of the service index
+func (u *FeedURLs) ServiceIndex() string {
+	return u.BaseURL + "/index.json"
+}
+
+// RegistrationBase returns the base url of the registration resource
+func (u *FeedURLs) RegistrationBase() string {
+	return u.BaseURL + "/registration"
+}
+
+// RegistrationIndex returns the url of the registration index of a package
+func (u *FeedURLs) RegistrationIndex(id string) string {
+	return u.RegistrationBase() + "/" + strings.ToLower(id) + "/index.json"
+}
+
+// RegistrationLeaf returns the url of the registration leaf of a package version
+func (u *FeedURLs) RegistrationLeaf(id, version string) string {
+	return u.RegistrationBase() + "/" + strings.ToLower(id) + "/" + strings.ToLower(version) + ".json"
+}
+
+// PackageBase returns the base url of the flat container resource
+func (u *FeedURLs) PackageBase() string {
+	return u.BaseURL + "/package"
+}
+
+// PackageVersions returns the url of the version list of a package
+func (u *FeedURLs) PackageVersions(id string) string {
+	return u.PackageBase() + "/" + strings.ToLower(id) + "/index.json"
+}
+
+// PackageContent returns the download url of the .nupkg file of a package version
+func (u *FeedURLs) PackageContent(id, version string) string {
+	id, version = strings.ToLower(id), strings.ToLower(version)
+	return u.PackageBase() + "/" + id + "/" + version + "/" + id + "." + version + ".nupkg"
+}
+
+// Symbols returns the base url of the symbol server
+func (u *FeedURLs) Symbols() string {
+	return u.BaseURL + "/symbols"
+}
+
+type serviceResource struct {
+	ID      string `json:"@id"`
+	Type    string `json:"@type"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// WriteServiceIndex writes the v3 index.json which lists the resources of the feed
+func WriteServiceIndex(w io.Writer, urls *FeedURLs) error {
+	resource := func(id, comment string, types ...string) []*serviceResource {
+		resources := make([]*serviceResource, 0, len(types))
+		for _, t := range types {
+			resources = append(resources, &serviceResource{ID: id, Type: t, Comment: comment})
+		}
+		return resources
+	}
+
+	var resources []*serviceResource
+	resources = append(resources, resource(urls.BaseURL+"/query", "Query endpoint of NuGet Search service",
+		"SearchQueryService", "SearchQueryService/3.0.0-beta", "SearchQueryService/3.0.0-rc")...)
+	resources = append(resources, resource(urls.RegistrationBase(), "Base URL of the package registrations",
+		"RegistrationsBaseUrl", "RegistrationsBaseUrl/3.0.0-beta", "RegistrationsBaseUrl/3.0.0-rc", "RegistrationsBaseUrl/3.4.0", "RegistrationsBaseUrl/3.6.0")...)
+	resources = append(resources, resource(urls.PackageBase(), "Base URL of where NuGet packages are stored",
+		"PackageBaseAddress/3.0.0")...)
+	resources = append(resources, resource(urls.BaseURL, "Push and delete (or unlist) of NuGet packages",
+		"PackagePublish/2.0.0")...)
+	resources = append(resources, resource(urls.BaseURL+"/symbolpackage", "Push of NuGet symbol packages",
+		"SymbolPackagePublish/4.9.0")...)
+
+	return json.NewEncoder(w).Encode(&struct {
+		Version   string             `json:"version"`
+		Resources []*serviceResource `json:"resources"`
+	}{
+		Version:   "3.0.0",
+		Resources: resources,
+	})
+}
+
+type registrationDependency struct {
+	ID    string `json:"id"`
+	Range string `json:"range"`
+}
+
+type registrationDependencyGroup struct {
+	TargetFramework string                    `json:"targetFramework,omitempty"`
+	Dependencies    []*registrationDependency `json:"dependencies,omitempty"`
+}
+
+type catalogEntry struct {
+	CatalogLeafURL   string                         `json:"@id"`
+	PackageContent   string                         `json:"packageContent"`
+	ID               string                         `json:"id"`
+	Version          string                         `json:"version"`
+	Description      string                         `json:"description,omitempty"`
+	ReleaseNotes     string                         `json:"releaseNotes,omitempty"`
+	Authors          string                         `json:"authors,omitempty"`
+	ProjectURL       string                         `json:"projectUrl,omitempty"`
+	DependencyGroups []*registrationDependencyGroup `json:"dependencyGroups,omitempty"`
+	Published        string                         `json:"published,omitempty"`
+	Listed           bool                           `json:"listed"`
+}
+
+type registrationLeaf struct {
+	RegistrationLeafURL string        `json:"@id"`
+	PackageContent      string        `json:"packageContent"`
+	CatalogEntry        *catalogEntry `json:"catalogEntry"`
+}
+
+type registrationPage struct {
+	RegistrationPageURL string              `json:"@id"`
+	Count               int                 `json:"count"`
+	Lower               string              `json:"lower"`
+	Upper               string              `json:"upper"`
+	Items               []*registrationLeaf `json:"items"`
+}
+
+func formatPublished(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func newRegistrationLeaf(urls *FeedURLs, pv *PackageVersion) (*registrationLeaf, error) {
+	version, err := NormalizeVersion(pv.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &catalogEntry{
+		CatalogLeafURL: urls.RegistrationLeaf(pv.ID, version),
+		PackageContent: urls.PackageContent(pv.ID, version),
+		ID:             pv.ID,
+		Version:        version,
+		Published:      formatPublished(pv.Published),
+		Listed:         true,
+	}
+	if m := pv.Metadata; m != nil {
+		entry.Description = m.Description
+		entry.ReleaseNotes = m.ReleaseNotes
+		entry.Authors = m.Authors
+		entry.ProjectURL = m.ProjectURL
+
+		frameworks := make([]string, 0, len(m.Dependencies))
+		for framework := range m.Dependencies {
+			frameworks = append(frameworks, framework)
+		}
+		sort.Strings(frameworks)
+		for _, framework := range frameworks {
+			group := &registrationDependencyGroup{TargetFramework: framework}
+			for _, dep := range m.Dependencies[framework] {
+				group.Dependencies = append(group.Dependencies, &registrationDependency{ID: dep.ID, Range: dep.Version})
+			}
+			entry.DependencyGroups = append(entry.DependencyGroups, group)
+		}
+	}
+
+	return &registrationLeaf{
+		RegistrationLeafURL: entry.CatalogLeafURL,
+		PackageContent:      entry.PackageContent,
+		CatalogEntry:        entry,
+	}, nil
+}
+
+// sortPackageVersions returns the versions sorted from oldest to newest.
+// Versions which normalize to the same version, like 1.0 and 1.0.0, are only listed once.
+func sortPackageVersions(versions []*PackageVersion) []*PackageVersion {
+	sorted := make([]*PackageVersion, len(versions))
+	copy(sorted, versions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		vi, errI := VersionScheme.ParseVersion(sorted[i].Version)
+		vj, errJ := VersionScheme.ParseVersion(sorted[j].Version)
+		if errI != nil || errJ != nil {
+			return errI != nil && errJ == nil
+		}
+		return vi.Compare(vj) < 0
+	})
+
+	seen := make(map[string]bool, len(sorted))
+	unique := sorted[:0]
+	for _, pv := range sorted {
+		if normalized, err := NormalizeVersion(pv.Version); err == nil {
+			key := strings.ToLower(normalized)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		unique = append(unique, pv)
+	}
+	return unique
+}
+
+// WriteRegistrationIndex writes the registration index of a package with all versions inlined in a single page
+func WriteRegistrationIndex(w io.Writer, urls *FeedURLs, id string, versions []*PackageVersion) error {
+	sorted := sortPackageVersions(versions)
+
+	leaves := make([]*registrationLeaf, 0, len(sorted))
+	for _, pv := range sorted {
+		leaf, err := newRegistrationLeaf(urls, pv)
+		if err != nil {
+			return err
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	var pages []*registrationPage
+	if len(leaves) > 0 {
+		pages = append(pages, &registrationPage{
+			RegistrationPageURL: urls.RegistrationIndex(id),
+			Count:               len(leaves),
+			Lower:               leaves[0].CatalogEntry.Version,
+			Upper:               leaves[len(leaves)-1].CatalogEntry.Version,
+			Items:               leaves,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(&struct {
+		RegistrationIndexURL string              `json:"@id"`
+		Count                int                 `json:"count"`
+		Pages                []*registrationPage `json:"items"`
+	}{
+		RegistrationIndexURL: urls.RegistrationIndex(id),
+		Count:                len(pages),
+		Pages:                pages,
+	})
+}
+
+// WriteRegistrationLeaf writes the registration leaf document of a package version
+func WriteRegistrationLeaf(w io.Writer, urls *FeedURLs, pv *PackageVersion) error {
+	leaf, err := newRegistrationLeaf(urls, pv)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(&struct {
+		RegistrationLeafURL  string `json:"@id"`
+		Listed               bool   `json:"listed"`
+		PackageContent       string `json:"packageContent"`
+		Published            string `json:"published,omitempty"`
+		RegistrationIndexURL string `json:"registration"`
+	}{
+		RegistrationLeafURL:  leaf.RegistrationLeafURL,
+		Listed:               true,
+		PackageContent:       leaf.PackageContent,
+		Published:            leaf.CatalogEntry.Published,
+		RegistrationIndexURL: urls.RegistrationIndex(pv.ID),
+	})
+}
+
+// WritePackageVersions writes the flat container version list of a package with normalized lowercase versions
+func WritePackageVersions(w io.Writer, versions []*PackageVersion) error {
+	sorted := sortPackageVersions(versions)
+
+	list := make([]string, 0, len(sorted))
+	for _, pv := range sorted {
+		version, err := NormalizeVersion(pv.Version)
+		if err != nil {
+			return err
+		}
+		list = append(list, strings.ToLower(version))
+	}
+
+	return json.NewEncoder(w).Encode(&struct {
+		Versions []string `json:"versions"`
+	}{
+		Versions: list,
+	})
+}
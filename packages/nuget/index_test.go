@@ -0,0 +1,152 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package nuget
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeVersion(t *testing.T) {
+	for version, expected := range map[string]string{
+		"1.0":           "1.0.0",
+		"1.0.0.0":       "1.0.0",
+		"1.0.0.1":       "1.0.0.1",
+		"01.002.3":      "1.2.3",
+		"1.0.0-Beta.1":  "1.0.0-Beta.1",
+		"1.0.0-rc+sha1": "1.0.0-rc",
+	} {
+		normalized, err := NormalizeVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, normalized, version)
+	}
+
+	_, err := NormalizeVersion("1.0.0.0.0")
+	assert.ErrorIs(t, err, ErrNuspecInvalidVersion)
+}
+
+func TestWriteServiceIndex(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteServiceIndex(&buf, &FeedURLs{BaseURL: "https://gitbundle.com/nuget"}))
+
+	var index struct {
+		Version   string `json:"version"`
+		Resources []struct {
+			ID   string `json:"@id"`
+			Type string `json:"@type"`
+		} `json:"resources"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &index))
+	assert.Equal(t, "3.0.0", index.Version)
+
+	types := make(map[string]string)
+	for _, r := range index.Resources {
+		types[r.Type] = r.ID
+	}
+	assert.Equal(t, "https://gitbundle.com/nuget/registration", types["RegistrationsBaseUrl/3.6.0"])
+	assert.Equal(t, "https://gitbundle.com/nuget/package", types["PackageBaseAddress/3.0.0"])
+	assert.Equal(t, "https://gitbundle.com/nuget/query", types["SearchQueryService"])
+	assert.Equal(t, "https://gitbundle.com/nuget", types["PackagePublish/2.0.0"])
+	assert.Equal(t, "https://gitbundle.com/nuget/symbolpackage", types["SymbolPackagePublish/4.9.0"])
+}
+
+func TestWriteRegistration(t *testing.T) {
+	urls := &FeedURLs{BaseURL: "https://gitbundle.com/nuget"}
+	published := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	versions := []*PackageVersion{
+		{
+			Package: &Package{ID: "GitBundle.Test", Version: "1.10.0", Metadata: &Metadata{
+				Description: "Test package",
+				Authors:     "GitBundle",
+				Dependencies: map[string][]Dependency{
+					"net6.0":        {{ID: "Newtonsoft.Json", Version: "[13.0.1, )"}},
+					".NETStandard2": {{ID: "System.Text.Json", Version: "6.0.0"}},
+				},
+			}},
+			Published: published,
+		},
+		{Package: &Package{ID: "GitBundle.Test", Version: "1.2.0-Beta"}},
+		{Package: &Package{ID: "GitBundle.Test", Version: "1.2.0"}},
+		{Package: &Package{ID: "GitBundle.Test", Version: "1.2"}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WritePackageVersions(&buf, versions))
+	assert.JSONEq(t, `{"versions":["1.2.0-beta","1.2.0","1.10.0"]}`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WriteRegistrationIndex(&buf, urls, "GitBundle.Test", versions))
+
+	var index struct {
+		ID    string `json:"@id"`
+		Count int    `json:"count"`
+		Items []struct {
+			Count int    `json:"count"`
+			Lower string `json:"lower"`
+			Upper string `json:"upper"`
+			Items []struct {
+				PackageContent string `json:"packageContent"`
+				CatalogEntry   struct {
+					ID               string `json:"id"`
+					Version          string `json:"version"`
+					Description      string `json:"description"`
+					Published        string `json:"published"`
+					DependencyGroups []struct {
+						TargetFramework string `json:"targetFramework"`
+						Dependencies    []struct {
+							ID    string `json:"id"`
+							Range string `json:"range"`
+						} `json:"dependencies"`
+					} `json:"dependencyGroups"`
+				} `json:"catalogEntry"`
+			} `json:"items"`
+		} `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &index))
+	assert.Equal(t, "https://gitbundle.com/nuget/registration/gitbundle.test/index.json", index.ID)
+	assert.Equal(t, 1, index.Count)
+	assert.Len(t, index.Items, 1)
+
+	page := index.Items[0]
+	assert.Equal(t, 3, page.Count)
+	assert.Equal(t, "1.2.0-Beta", page.Lower)
+	assert.Equal(t, "1.10.0", page.Upper)
+	assert.Len(t, page.Items, 3)
+
+	// the catalog entry keeps the case of the version, the urls are lowercase
+	assert.Equal(t, "1.2.0-Beta", page.Items[0].CatalogEntry.Version)
+	assert.Equal(t, "https://gitbundle.com/nuget/package/gitbundle.test/1.2.0-beta/gitbundle.test.1.2.0-beta.nupkg", page.Items[0].PackageContent)
+
+	leaf := page.Items[2]
+	assert.Equal(t, "https://gitbundle.com/nuget/package/gitbundle.test/1.10.0/gitbundle.test.1.10.0.nupkg", leaf.PackageContent)
+	assert.Equal(t, "GitBundle.Test", leaf.CatalogEntry.ID)
+	assert.Equal(t, "Test package", leaf.CatalogEntry.Description)
+	assert.Equal(t, "2023-01-02T03:04:05Z", leaf.CatalogEntry.Published)
+	assert.Len(t, leaf.CatalogEntry.DependencyGroups, 2)
+	assert.Equal(t, ".NETStandard2", leaf.CatalogEntry.DependencyGroups[0].TargetFramework)
+	assert.Equal(t, "net6.0", leaf.CatalogEntry.DependencyGroups[1].TargetFramework)
+	assert.Equal(t, "[13.0.1, )", leaf.CatalogEntry.DependencyGroups[1].Dependencies[0].Range)
+
+	buf.Reset()
+	assert.NoError(t, WriteRegistrationIndex(&buf, urls, "GitBundle.Test", nil))
+	assert.JSONEq(t, `{"@id":"https://gitbundle.com/nuget/registration/gitbundle.test/index.json","count":0,"items":null}`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WriteRegistrationLeaf(&buf, urls, versions[0]))
+	assert.JSONEq(t, `{
+		"@id":"https://gitbundle.com/nuget/registration/gitbundle.test/1.10.0.json",
+		"listed":true,
+		"packageContent":"https://gitbundle.com/nuget/package/gitbundle.test/1.10.0/gitbundle.test.1.10.0.nupkg",
+		"published":"2023-01-02T03:04:05Z",
+		"registration":"https://gitbundle.com/nuget/registration/gitbundle.test/index.json"
+	}`, buf.String())
+}
@@ -14,6 +14,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/gitbundle/modules/packages/versioning"
 	"github.com/gitbundle/modules/validation"
 
 	"github.com/hashicorp/go-version"
@@ -42,6 +43,9 @@ const (
 	PropertySymbolID = "nuget.symbol.id"
 )
 
+// VersionScheme defines the version semantics of NuGet packages
+var VersionScheme = versioning.NuGet
+
 var idmatch = regexp.MustCompile(`\A\w+(?:[.-]\w+)*\z`)
 
 const maxNuspecFileSize = 3 * 1024 * 1024
@@ -70,6 +74,11 @@ type Dependency struct {
 	Version string `json:"version"`
 }
 
+// VersionRange parses the version range of the dependency, a plain version is a minimum version
+func (d *Dependency) VersionRange() (versioning.Range, error) {
+	return VersionScheme.ParseRange(d.Version)
+}
+
 type nuspecPackage struct {
 	Metadata struct {
 		ID                       string `xml:"id"`
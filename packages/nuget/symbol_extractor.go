@@ -107,34 +107,57 @@ func ExtractPortablePdb(r io.ReaderAt, size int64) (PortablePdbList, error) {
 	return pdbs, nil
 }
 
-// ParseDebugHeaderID TODO
+// ParseDebugHeaderID parses the id of a portable PDB which is used as symbol lookup key
 func ParseDebugHeaderID(r io.ReadSeeker) (string, error) {
+	offset, err := findPdbStream(r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+
+	data1 := binary.LittleEndian.Uint32(b[0:4])
+	data2 := binary.LittleEndian.Uint16(b[4:6])
+	data3 := binary.LittleEndian.Uint16(b[6:8])
+	data4 := b[8:16]
+
+	return fmt.Sprintf("%08x%04x%04x%04x%012x", data1, data2, data3, data4[:2], data4[2:]), nil
+}
+
+// findPdbStream returns the offset of the #Pdb stream which starts with the PDB id
+func findPdbStream(r io.ReadSeeker) (int64, error) {
 	var magic uint32
 	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
-		return "", err
+		return 0, err
 	}
 	if magic != 0x424A5342 {
-		return "", ErrInvalidPdbMagicNumber
+		return 0, ErrInvalidPdbMagicNumber
 	}
 
 	if _, err := r.Seek(8, io.SeekCurrent); err != nil {
-		return "", err
+		return 0, err
 	}
 
 	var versionStringSize int32
 	if err := binary.Read(r, binary.LittleEndian, &versionStringSize); err != nil {
-		return "", err
+		return 0, err
 	}
 	if _, err := r.Seek(int64(versionStringSize), io.SeekCurrent); err != nil {
-		return "", err
+		return 0, err
 	}
 	if _, err := r.Seek(2, io.SeekCurrent); err != nil {
-		return "", err
+		return 0, err
 	}
 
 	var streamCount int16
 	if err := binary.Read(r, binary.LittleEndian, &streamCount); err != nil {
-		return "", err
+		return 0, err
 	}
 
 	read4ByteAlignedString := func(r io.Reader) (string, error) {
@@ -155,34 +178,20 @@ func ParseDebugHeaderID(r io.ReadSeeker) (string, error) {
 	for i := 0; i < int(streamCount); i++ {
 		var offset uint32
 		if err := binary.Read(r, binary.LittleEndian, &offset); err != nil {
-			return "", err
+			return 0, err
 		}
 		if _, err := r.Seek(4, io.SeekCurrent); err != nil {
-			return "", err
+			return 0, err
 		}
 		name, err := read4ByteAlignedString(r)
 		if err != nil {
-			return "", err
+			return 0, err
 		}
 
 		if name == "#Pdb" {
-			if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
-				return "", err
-			}
-
-			b := make([]byte, 16)
-			if _, err := r.Read(b); err != nil {
-				return "", err
-			}
-
-			data1 := binary.LittleEndian.Uint32(b[0:4])
-			data2 := binary.LittleEndian.Uint16(b[4:6])
-			data3 := binary.LittleEndian.Uint16(b[6:8])
-			data4 := b[8:16]
-
-			return fmt.Sprintf("%08x%04x%04x%04x%012x", data1, data2, data3, data4[:2], data4[2:]), nil
+			return int64(offset), nil
 		}
 	}
 
-	return "", ErrMissingPdbStream
+	return 0, ErrMissingPdbStream
 }
@@ -0,0 +1,136 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package nuget
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// https://github.com/dotnet/symstore/blob/main/docs/specs/SSQP_Key_Conventions.md
+// https://github.com/dotnet/symstore/blob/main/docs/specs/Simple_Symbol_Query_Protocol.md
+
+var (
+	// ErrInvalidSymbolKey indicates a symbol lookup path which is not of the form <file>/<sig>/<file>
+	ErrInvalidSymbolKey = errors.New("symbol key is invalid")
+	// ErrInvalidSymbolChecksum indicates a SymbolChecksum header which can't be parsed
+	ErrInvalidSymbolChecksum = errors.New("symbol checksum is invalid")
+	// ErrSymbolChecksumMismatch indicates a PDB file which matches none of the requested checksums
+	ErrSymbolChecksumMismatch = errors.New("symbol checksum does not match")
+)
+
+const (
+	// SymbolChecksumHeader is the request header debuggers use to send the expected PDB checksums
+	SymbolChecksumHeader = "SymbolChecksum"
+
+	// portablePdbAge is the age part of the lookup key of portable PDB files
+	portablePdbAge = "ffffffff"
+	// pdbIDSize is the size of the PDB id (guid and stamp) at the start of the #Pdb stream
+	pdbIDSize = 20
+)
+
+var symbolKeyPattern = regexp.MustCompile(`(?i)\A([^/]+\.pdb)/([0-9a-f]{32})ffffffff/([^/]+\.pdb)\z`)
+
+// SymbolKey returns the lookup path <file>/<sig>/<file> of a portable PDB with the id returned by ParseDebugHeaderID
+func SymbolKey(filename, id string) string {
+	filename = strings.ToLower(filename)
+	return filename + "/" + strings.ToLower(id) + portablePdbAge + "/" + filename
+}
+
+// ParseSymbolKey parses the filename and the PDB id from a lookup path like
+// test.pdb/d910bb6948bd4c6cb40155bcf52c3c94FFFFFFFF/test.pdb
+func ParseSymbolKey(key string) (string, string, error) {
+	m := symbolKeyPattern.FindStringSubmatch(strings.Trim(key, "/"))
+	if m == nil || !strings.EqualFold(m[1], m[3]) {
+		return "", "", ErrInvalidSymbolKey
+	}
+	return strings.ToLower(m[1]), strings.ToLower(m[2]), nil
+}
+
+// SymbolChecksum is a checksum sent by a debugger
+type SymbolChecksum struct {
+	Algorithm string
+	// Checksum is the lowercase hex encoded checksum
+	Checksum string
+}
+
+// ParseSymbolChecksumHeader parses the value of the SymbolChecksum header like SHA256:abc;SHA256:def
+func ParseSymbolChecksumHeader(header string) ([]*SymbolChecksum, error) {
+	var checksums []*SymbolChecksum
+	for _, value := range strings.Split(header, ";") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		algorithm, checksum, ok := strings.Cut(value, ":")
+		if !ok || algorithm == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSymbolChecksum, value)
+		}
+		if _, err := hex.DecodeString(checksum); err != nil || checksum == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSymbolChecksum, value)
+		}
+		checksums = append(checksums, &SymbolChecksum{
+			Algorithm: strings.ToUpper(algorithm),
+			Checksum:  strings.ToLower(checksum),
+		})
+	}
+	return checksums, nil
+}
+
+// ComputePdbChecksum computes the SHA256 checksum of a portable PDB which is embedded in the
+// assembly and sent in the SymbolChecksum header. The PDB id is replaced by zeros before hashing.
+func ComputePdbChecksum(r io.ReadSeeker) (string, error) {
+	offset, err := findPdbStream(r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, r, offset); err != nil {
+		return "", err
+	}
+	if _, err := h.Write(make([]byte, pdbIDSize)); err != nil {
+		return "", err
+	}
+	if _, err := r.Seek(pdbIDSize, io.SeekCurrent); err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ValidateSymbolChecksum tests if the PDB matches the SymbolChecksum header. An empty header is accepted,
+// otherwise one of the SHA256 checksums must match. Checksums of other algorithms are ignored.
+func ValidateSymbolChecksum(header string, r io.ReadSeeker) error {
+	checksums, err := ParseSymbolChecksumHeader(header)
+	if err != nil {
+		return err
+	}
+	if len(checksums) == 0 {
+		return nil
+	}
+
+	checksum, err := ComputePdbChecksum(r)
+	if err != nil {
+		return err
+	}
+	for _, c := range checksums {
+		if c.Algorithm == "SHA256" && c.Checksum == checksum {
+			return nil
+		}
+	}
+	return ErrSymbolChecksumMismatch
+}
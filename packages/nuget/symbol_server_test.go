@@ -0,0 +1,73 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package nuget
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolKey(t *testing.T) {
+	key := SymbolKey("Test.pdb", "D910BB6948BD4C6CB40155BCF52C3C94")
+	assert.Equal(t, "test.pdb/d910bb6948bd4c6cb40155bcf52c3c94ffffffff/test.pdb", key)
+
+	filename, id, err := ParseSymbolKey("/test.pdb/D910BB6948BD4C6CB40155BCF52C3C94FFFFFFFF/Test.pdb")
+	assert.NoError(t, err)
+	assert.Equal(t, "test.pdb", filename)
+	assert.Equal(t, "d910bb6948bd4c6cb40155bcf52c3c94", id)
+
+	for _, k := range []string{
+		"test.pdb/d910bb6948bd4c6cb40155bcf52c3c94/test.pdb",
+		"test.pdb/d910bb6948bd4c6cb40155bcf52c3c9400000001/test.pdb",
+		"test.pdb/d910bb6948bd4c6cb40155bcf52c3c94ffffffff/other.pdb",
+		"test.dll/d910bb6948bd4c6cb40155bcf52c3c94ffffffff/test.dll",
+	} {
+		_, _, err = ParseSymbolKey(k)
+		assert.ErrorIs(t, err, ErrInvalidSymbolKey, k)
+	}
+}
+
+func TestParseSymbolChecksumHeader(t *testing.T) {
+	checksums, err := ParseSymbolChecksumHeader("SHA256:ABCD; sha256:ef01")
+	assert.NoError(t, err)
+	assert.Equal(t, []*SymbolChecksum{{Algorithm: "SHA256", Checksum: "abcd"}, {Algorithm: "SHA256", Checksum: "ef01"}}, checksums)
+
+	checksums, err = ParseSymbolChecksumHeader("")
+	assert.NoError(t, err)
+	assert.Empty(t, checksums)
+
+	for _, h := range []string{"SHA256", "SHA256:xyz", ":abcd", "SHA256:"} {
+		_, err = ParseSymbolChecksumHeader(h)
+		assert.ErrorIs(t, err, ErrInvalidSymbolChecksum, h)
+	}
+}
+
+func TestValidateSymbolChecksum(t *testing.T) {
+	b, _ := base64.StdEncoding.DecodeString(pdbContent)
+
+	// the PDB id starts at offset 0x7c of the test file
+	zeroed := append([]byte{}, b...)
+	copy(zeroed[0x7c:0x7c+20], make([]byte, 20))
+	sum := sha256.Sum256(zeroed)
+	expected := hex.EncodeToString(sum[:])
+
+	checksum, err := ComputePdbChecksum(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, checksum)
+
+	assert.NoError(t, ValidateSymbolChecksum("", bytes.NewReader(b)))
+	assert.NoError(t, ValidateSymbolChecksum("SHA256:0000;SHA256:"+expected, bytes.NewReader(b)))
+	assert.ErrorIs(t, ValidateSymbolChecksum("SHA256:0000", bytes.NewReader(b)), ErrSymbolChecksumMismatch)
+	assert.ErrorIs(t, ValidateSymbolChecksum("SHA1:"+expected, bytes.NewReader(b)), ErrSymbolChecksumMismatch)
+
+	_, err = ComputePdbChecksum(bytes.NewReader([]byte("invalid pdb")))
+	assert.ErrorIs(t, err, ErrInvalidPdbMagicNumber)
+}
@@ -0,0 +1,323 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidLicenseExpression indicates an invalid SPDX license expression
+var ErrInvalidLicenseExpression = errors.New("license expression is invalid")
+
+// spdxLicenses contains the commonly used identifiers of the SPDX license list
+var spdxLicenses = []string{
+	"0BSD", "AFL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later", "Apache-1.1", "Apache-2.0", "Artistic-2.0",
+	"BSD-1-Clause", "BSD-2-Clause", "BSD-3-Clause", "BSD-3-Clause-Clear", "BSD-4-Clause", "BSL-1.0",
+	"CC-BY-3.0", "CC-BY-4.0", "CC-BY-SA-4.0", "CC0-1.0", "CDDL-1.0", "CDDL-1.1", "CPL-1.0",
+	"EPL-1.0", "EPL-2.0", "EUPL-1.1", "EUPL-1.2",
+	"GPL-2.0-only", "GPL-2.0-or-later", "GPL-3.0-only", "GPL-3.0-or-later",
+	"ISC", "LGPL-2.0-only", "LGPL-2.0-or-later", "LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0-only", "LGPL-3.0-or-later",
+	"MIT", "MIT-0", "MPL-1.1", "MPL-2.0", "MS-PL", "MS-RL", "NCSA", "OFL-1.1", "OpenSSL", "PHP-3.01",
+	"PostgreSQL", "PSF-2.0", "Python-2.0", "Ruby", "SSPL-1.0", "Unicode-DFS-2016", "Unlicense", "UPL-1.0",
+	"W3C", "WTFPL", "X11", "Zlib", "ZPL-2.1",
+}
+
+// spdxExceptions contains the commonly used license exceptions of the SPDX exception list
+var spdxExceptions = []string{
+	"Classpath-exception-2.0", "GCC-exception-3.1", "LLVM-exception", "OpenJDK-assembly-exception-1.0",
+}
+
+// licenseAliases maps lower case license names found in package metadata to SPDX identifiers
+var licenseAliases = map[string]string{
+	"apache 2":                                 "Apache-2.0",
+	"apache 2.0":                               "Apache-2.0",
+	"apache-2":                                 "Apache-2.0",
+	"apache license 2.0":                       "Apache-2.0",
+	"apache license version 2.0":               "Apache-2.0",
+	"apache license, version 2.0":              "Apache-2.0",
+	"apache software license":                  "Apache-2.0",
+	"the apache license, version 2.0":          "Apache-2.0",
+	"the apache software license, version 2.0": "Apache-2.0",
+	"asl 2.0":                                  "Apache-2.0",
+	"mit license":                              "MIT",
+	"the mit license":                          "MIT",
+	"expat":                                    "MIT",
+	"isc license":                              "ISC",
+	"new bsd license":                          "BSD-3-Clause",
+	"modified bsd license":                     "BSD-3-Clause",
+	"the bsd 3-clause license":                 "BSD-3-Clause",
+	"simplified bsd license":                   "BSD-2-Clause",
+	"the bsd 2-clause license":                 "BSD-2-Clause",
+	"gplv2":                                    "GPL-2.0-only",
+	"gpl-2.0":                                  "GPL-2.0-only",
+	"gpl-2.0+":                                 "GPL-2.0-or-later",
+	"gplv2+":                                   "GPL-2.0-or-later",
+	"gplv3":                                    "GPL-3.0-only",
+	"gpl-3.0":                                  "GPL-3.0-only",
+	"gpl-3.0+":                                 "GPL-3.0-or-later",
+	"gplv3+":                                   "GPL-3.0-or-later",
+	"lgpl-2.1":                                 "LGPL-2.1-only",
+	"lgpl-2.1+":                                "LGPL-2.1-or-later",
+	"lgplv3":                                   "LGPL-3.0-only",
+	"lgpl-3.0":                                 "LGPL-3.0-only",
+	"lgpl-3.0+":                                "LGPL-3.0-or-later",
+	"agpl-3.0":                                 "AGPL-3.0-only",
+	"agplv3":                                   "AGPL-3.0-only",
+	"mozilla public license 2.0":               "MPL-2.0",
+	"mozilla public license, version 2.0":      "MPL-2.0",
+	"mpl 2.0":                                  "MPL-2.0",
+	"eclipse public license 1.0":               "EPL-1.0",
+	"eclipse public license - v 1.0":           "EPL-1.0",
+	"eclipse public license 2.0":               "EPL-2.0",
+	"eclipse public license - v 2.0":           "EPL-2.0",
+	"boost software license 1.0":               "BSL-1.0",
+	"python software foundation license":       "PSF-2.0",
+	"the unlicense":                            "Unlicense",
+	"cc0":                                      "CC0-1.0",
+	"public domain (cc0)":                      "CC0-1.0",
+}
+
+var (
+	spdxLicenseIDs   = lowerCaseIndex(spdxLicenses)
+	spdxExceptionIDs = lowerCaseIndex(spdxExceptions)
+
+	licenseTokenPattern = regexp.MustCompile(`\(|\)|[^\s()]+`)
+)
+
+func lowerCaseIndex(ids []string) map[string]string {
+	m := make(map[string]string, len(ids))
+	for _, id := range ids {
+		m[strings.ToLower(id)] = id
+	}
+	return m
+}
+
+// NormalizeLicense maps a license name to its SPDX identifier.
+// It returns false if the license is unknown.
+func NormalizeLicense(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	lower := strings.ToLower(name)
+	if id, ok := spdxLicenseIDs[lower]; ok {
+		return id, true
+	}
+	if id, ok := licenseAliases[lower]; ok {
+		return id, true
+	}
+	return name, false
+}
+
+// LicenseExpression is a parsed SPDX license expression
+type LicenseExpression interface {
+	// String returns the normalized expression
+	String() string
+	// Satisfied tests if the expression can be fulfilled by the licenses accepted by fn
+	Satisfied(fn func(license string) bool) bool
+	// Licenses returns all license identifiers of the expression
+	Licenses() []string
+}
+
+type licenseID struct {
+	id        string
+	exception string
+	known     bool
+}
+
+func (l *licenseID) String() string {
+	if l.exception != "" {
+		return l.id + " WITH " + l.exception
+	}
+	return l.id
+}
+
+func (l *licenseID) Satisfied(fn func(string) bool) bool {
+	return fn(l.id)
+}
+
+func (l *licenseID) Licenses() []string {
+	return []string{l.id}
+}
+
+type licenseOperator struct {
+	op       string
+	operands []LicenseExpression
+}
+
+func (l *licenseOperator) String() string {
+	parts := make([]string, 0, len(l.operands))
+	for _, o := range l.operands {
+		if inner, ok := o.(*licenseOperator); ok && inner.op != l.op {
+			parts = append(parts, "("+o.String()+")")
+		} else {
+			parts = append(parts, o.String())
+		}
+	}
+	return strings.Join(parts, " "+l.op+" ")
+}
+
+func (l *licenseOperator) Satisfied(fn func(string) bool) bool {
+	for _, o := range l.operands {
+		satisfied := o.Satisfied(fn)
+		if l.op == "OR" && satisfied {
+			return true
+		}
+		if l.op == "AND" && !satisfied {
+			return false
+		}
+	}
+	return l.op == "AND"
+}
+
+func (l *licenseOperator) Licenses() []string {
+	licenses := make([]string, 0, len(l.operands))
+	for _, o := range l.operands {
+		licenses = append(licenses, o.Licenses()...)
+	}
+	return licenses
+}
+
+// ParseLicenseExpression parses a SPDX license expression like "MIT OR (Apache-2.0 AND BSD-3-Clause)".
+// Well-known license names are mapped to their SPDX identifiers and the informal "/" separator is read as OR.
+func ParseLicenseExpression(s string) (LicenseExpression, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, ErrInvalidLicenseExpression
+	}
+	if id, ok := NormalizeLicense(s); ok {
+		return &licenseID{id: id, known: true}, nil
+	}
+
+	p := &licenseParser{tokens: licenseTokenPattern.FindAllString(strings.ReplaceAll(s, "/", " OR "), -1)}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, ErrInvalidLicenseExpression
+	}
+	return expr, nil
+}
+
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *licenseParser) parseOr() (LicenseExpression, error) {
+	return p.parseOperator("OR", p.parseAnd)
+}
+
+func (p *licenseParser) parseAnd() (LicenseExpression, error) {
+	return p.parseOperator("AND", p.parseWith)
+}
+
+func (p *licenseParser) parseOperator(op string, next func() (LicenseExpression, error)) (LicenseExpression, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	operands := []LicenseExpression{first}
+	for strings.EqualFold(p.peek(), op) {
+		p.pos++
+		operand, err := next()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &licenseOperator{op: op, operands: operands}, nil
+}
+
+func (p *licenseParser) parseWith() (LicenseExpression, error) {
+	expr, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(p.peek(), "WITH") {
+		return expr, nil
+	}
+	p.pos++
+
+	l, ok := expr.(*licenseID)
+	exception := p.peek()
+	if !ok || exception == "" || exception == "(" || exception == ")" {
+		return nil, ErrInvalidLicenseExpression
+	}
+	p.pos++
+	if id, ok := spdxExceptionIDs[strings.ToLower(exception)]; ok {
+		exception = id
+	}
+	l.exception = exception
+	return l, nil
+}
+
+func (p *licenseParser) parseAtom() (LicenseExpression, error) {
+	token := p.peek()
+	switch {
+	case token == "" || token == ")":
+		return nil, ErrInvalidLicenseExpression
+	case token == "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, ErrInvalidLicenseExpression
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	switch strings.ToUpper(token) {
+	case "AND", "OR", "WITH":
+		return nil, ErrInvalidLicenseExpression
+	}
+	p.pos++
+
+	// a trailing + means "or later" in SPDX 2 expressions
+	orLater := strings.HasSuffix(token, "+") && !strings.HasSuffix(strings.ToLower(token), "-or-later")
+	if id, ok := NormalizeLicense(token); ok {
+		return &licenseID{id: id, known: true}, nil
+	}
+	if orLater {
+		if id, ok := NormalizeLicense(strings.TrimSuffix(token, "+") + "-or-later"); ok {
+			return &licenseID{id: id, known: true}, nil
+		}
+	}
+	return &licenseID{id: token}, nil
+}
+
+// UnknownLicenses returns the identifiers of the expression which are not on the SPDX license list
+func UnknownLicenses(expr LicenseExpression) []string {
+	var unknown []string
+	var walk func(LicenseExpression)
+	walk = func(e LicenseExpression) {
+		switch v := e.(type) {
+		case *licenseID:
+			if !v.known {
+				unknown = append(unknown, v.id)
+			}
+		case *licenseOperator:
+			for _, o := range v.operands {
+				walk(o)
+			}
+		}
+	}
+	walk(expr)
+	return unknown
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLicense(t *testing.T) {
+	cases := map[string]string{
+		"mit": "MIT",
+		"The Apache Software License, Version 2.0": "Apache-2.0",
+		" BSD-3-clause ":                 "BSD-3-Clause",
+		"GPLv3+":                         "GPL-3.0-or-later",
+		"Eclipse Public License - v 1.0": "EPL-1.0",
+	}
+	for name, expected := range cases {
+		id, ok := NormalizeLicense(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, id)
+	}
+
+	id, ok := NormalizeLicense("Proprietary")
+	assert.False(t, ok)
+	assert.Equal(t, "Proprietary", id)
+}
+
+func TestParseLicenseExpression(t *testing.T) {
+	cases := map[string]string{
+		"MIT":                                   "MIT",
+		"Apache License 2.0":                    "Apache-2.0",
+		"mit or apache-2.0":                     "MIT OR Apache-2.0",
+		"MIT/Apache-2.0":                        "MIT OR Apache-2.0",
+		"(MIT OR ISC) AND BSD-3-Clause":         "(MIT OR ISC) AND BSD-3-Clause",
+		"GPL-2.0+ WITH classpath-exception-2.0": "GPL-2.0-or-later WITH Classpath-exception-2.0",
+		"MIT AND LicenseRef-Custom":             "MIT AND LicenseRef-Custom",
+	}
+	for s, expected := range cases {
+		expr, err := ParseLicenseExpression(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, expr.String())
+	}
+
+	for _, invalid := range []string{"", "MIT OR", "(MIT", "MIT)", "AND MIT", "MIT WITH", "MIT Foo"} {
+		expr, err := ParseLicenseExpression(invalid)
+		assert.Nil(t, expr, invalid)
+		assert.ErrorIs(t, err, ErrInvalidLicenseExpression, invalid)
+	}
+
+	expr, err := ParseLicenseExpression("(MIT OR GPL-3.0-only) AND LicenseRef-Custom")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"MIT", "GPL-3.0-only", "LicenseRef-Custom"}, expr.Licenses())
+	assert.Equal(t, []string{"LicenseRef-Custom"}, UnknownLicenses(expr))
+
+	assert.True(t, expr.Satisfied(func(l string) bool { return l != "GPL-3.0-only" }))
+	assert.False(t, expr.Satisfied(func(l string) bool { return l == "MIT" }))
+}
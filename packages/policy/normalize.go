@@ -0,0 +1,211 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gitbundle/modules/packages/cargo"
+	"github.com/gitbundle/modules/packages/composer"
+	"github.com/gitbundle/modules/packages/goproxy"
+	"github.com/gitbundle/modules/packages/helm"
+	"github.com/gitbundle/modules/packages/maven"
+	"github.com/gitbundle/modules/packages/npm"
+	"github.com/gitbundle/modules/packages/nuget"
+	"github.com/gitbundle/modules/packages/pypi"
+	"github.com/gitbundle/modules/packages/rubygems"
+)
+
+// helmLicenseAnnotation is the chart annotation used by Artifact Hub for the license
+const helmLicenseAnnotation = "artifacthub.io/license"
+
+// exactVersionPattern matches versions without range operators or wildcards
+var exactVersionPattern = regexp.MustCompile(`\A=?\s*v?[0-9][0-9A-Za-z.+\-]*\z`)
+
+// exactVersion returns the version if the requirement pins a single version
+func exactVersion(requirement string) string {
+	requirement = strings.TrimSpace(requirement)
+	if !exactVersionPattern.MatchString(requirement) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(requirement, "="))
+}
+
+// splitPath splits a slash separated name into namespace and name
+func splitPath(path string) (string, string) {
+	if i := strings.LastIndexByte(path, '/'); i != -1 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// joinLicenses combines a list of license names to an expression, unknown names are converted to license references
+func joinLicenses(names []string) string {
+	licenses := make([]string, 0, len(names))
+	for _, name := range names {
+		if id, ok := NormalizeLicense(name); ok {
+			licenses = append(licenses, id)
+		} else if fields := strings.Fields(name); len(fields) == 1 {
+			licenses = append(licenses, name)
+		} else if len(fields) > 1 {
+			licenses = append(licenses, "LicenseRef-"+strings.Join(fields, "-"))
+		}
+	}
+	return strings.Join(licenses, " OR ")
+}
+
+// FromCargo normalizes a Cargo package
+func FromCargo(p *cargo.Package) *Package {
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeCargo, "", p.Name, p.Version),
+		License:    p.Metadata.License,
+	}
+	for _, d := range p.Metadata.Dependencies {
+		name := d.Name
+		if d.Package != "" {
+			name = d.Package
+		}
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypeCargo, "", name, exactVersion(d.Req)))
+	}
+	return pkg
+}
+
+// FromComposer normalizes a Composer package, platform requirements like php or ext-json are skipped
+func FromComposer(p *composer.Package) *Package {
+	vendor, name := splitPath(p.Name)
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeComposer, vendor, name, p.Version),
+		License:    joinLicenses(p.Metadata.License),
+	}
+	for dep, constraint := range p.Metadata.Require {
+		vendor, name := splitPath(dep)
+		if vendor == "" {
+			continue
+		}
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypeComposer, vendor, name, exactVersion(constraint)))
+	}
+	return pkg
+}
+
+// FromGoModule normalizes a Go module
+func FromGoModule(p *goproxy.Package) *Package {
+	namespace, name := splitPath(p.Path)
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeGolang, namespace, name, p.Version),
+	}
+	for _, d := range p.Metadata.Dependencies {
+		namespace, name := splitPath(d.Path)
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypeGolang, namespace, name, d.Version))
+	}
+	return pkg
+}
+
+// FromHelm normalizes a Helm chart, the license is read from the Artifact Hub annotation
+func FromHelm(md *helm.Metadata) *Package {
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeHelm, "", md.Name, md.Version),
+		License:    md.Annotations[helmLicenseAnnotation],
+	}
+	for _, d := range md.Dependencies {
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypeHelm, "", d.Name, exactVersion(d.Version)))
+	}
+	return pkg
+}
+
+// FromMaven normalizes a Maven package, version ranges and uninterpolated versions are skipped
+func FromMaven(md *maven.Metadata, version string) *Package {
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeMaven, md.GroupID, md.ArtifactID, version),
+		License:    joinLicenses(md.Licenses),
+	}
+	for _, d := range md.Dependencies {
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypeMaven, d.GroupID, d.ArtifactID, exactVersion(d.Version)))
+	}
+	return pkg
+}
+
+// FromNpm normalizes a npm package
+func FromNpm(p *npm.Package) *Package {
+	namespace, name := splitPath(p.Name)
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeNpm, namespace, name, p.Version),
+		License:    p.Metadata.License,
+	}
+	for dep, constraint := range p.Metadata.Dependencies {
+		namespace, name := splitPath(dep)
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypeNpm, namespace, name, exactVersion(constraint)))
+	}
+	return pkg
+}
+
+// FromNuGet normalizes a NuGet package, the dependencies of all target frameworks are merged
+func FromNuGet(p *nuget.Package) *Package {
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeNuGet, "", p.ID, p.Version),
+	}
+	seen := make(map[string]bool)
+	for _, deps := range p.Metadata.Dependencies {
+		for _, d := range deps {
+			// [1.0.0] pins an exact version, everything else is a range
+			version := ""
+			if strings.HasPrefix(d.Version, "[") && strings.HasSuffix(d.Version, "]") && !strings.Contains(d.Version, ",") {
+				version = exactVersion(d.Version[1 : len(d.Version)-1])
+			}
+			purl := NewPackageURL(TypeNuGet, "", d.ID, version)
+			if key := purl.String(); !seen[key] {
+				seen[key] = true
+				pkg.Dependencies = append(pkg.Dependencies, purl)
+			}
+		}
+	}
+	return pkg
+}
+
+// FromPyPI normalizes a PyPI package. The license classifiers are used if the license field contains no known license.
+func FromPyPI(p *pypi.Package) *Package {
+	license := p.Metadata.License
+	if _, ok := NormalizeLicense(license); !ok {
+		var classifiers []string
+		for _, c := range p.Metadata.Classifiers {
+			if strings.HasPrefix(c, "License :: ") {
+				classifiers = append(classifiers, c[strings.LastIndex(c, "::")+2:])
+			}
+		}
+		if len(classifiers) > 0 {
+			license = joinLicenses(classifiers)
+		}
+	}
+
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypePyPI, "", p.Name, p.Version),
+		License:    license,
+	}
+	for _, r := range p.Metadata.RequiresDist {
+		version := ""
+		if strings.HasPrefix(r.Specifier, "==") && !strings.ContainsAny(r.Specifier, ",*") {
+			version = exactVersion(strings.TrimPrefix(r.Specifier, "=="))
+		}
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypePyPI, "", r.Name, version))
+	}
+	return pkg
+}
+
+// FromRubyGems normalizes a RubyGems package
+func FromRubyGems(p *rubygems.Package) *Package {
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeGem, "", p.Name, p.Version),
+		License:    joinLicenses(p.Metadata.Licenses),
+	}
+	for _, d := range p.Metadata.RuntimeDependencies {
+		version := ""
+		if len(d.Version) == 1 && d.Version[0].Restriction == "=" {
+			version = d.Version[0].Version
+		}
+		pkg.Dependencies = append(pkg.Dependencies, NewPackageURL(TypeGem, "", d.Name, version))
+	}
+	return pkg
+}
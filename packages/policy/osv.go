@@ -0,0 +1,424 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/packages/versioning"
+)
+
+// https://ossf.github.io/osv-schema/
+
+// ErrInvalidVulnerability is returned if an OSV record is empty or contains empty entries
+var ErrInvalidVulnerability = errors.New("vulnerability record is invalid")
+
+// Vulnerability is an advisory in the OSV format
+type Vulnerability struct {
+	SchemaVersion    string                 `json:"schema_version,omitempty"`
+	ID               string                 `json:"id"`
+	Summary          string                 `json:"summary,omitempty"`
+	Details          string                 `json:"details,omitempty"`
+	Aliases          []string               `json:"aliases,omitempty"`
+	Modified         time.Time              `json:"modified"`
+	Published        *time.Time             `json:"published,omitempty"`
+	Withdrawn        *time.Time             `json:"withdrawn,omitempty"`
+	Affected         []*Affected            `json:"affected"`
+	Severity         []*Severity            `json:"severity,omitempty"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific,omitempty"`
+}
+
+// Affected describes the affected versions of a package
+type Affected struct {
+	Package           AffectedPackage        `json:"package"`
+	Ranges            []*Range               `json:"ranges,omitempty"`
+	Versions          []string               `json:"versions,omitempty"`
+	Severity          []*Severity            `json:"severity,omitempty"`
+	EcosystemSpecific map[string]interface{} `json:"ecosystem_specific,omitempty"`
+	DatabaseSpecific  map[string]interface{} `json:"database_specific,omitempty"`
+}
+
+// AffectedPackage identifies the affected package
+type AffectedPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Purl      string `json:"purl,omitempty"`
+}
+
+// Range is a list of events which describe the affected versions
+type Range struct {
+	Type   string   `json:"type"`
+	Repo   string   `json:"repo,omitempty"`
+	Events []*Event `json:"events"`
+}
+
+// Event marks the start or end of an affected version range
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+func (e *Event) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	case e.LastAffected != "":
+		return e.LastAffected
+	default:
+		return e.Limit
+	}
+}
+
+// Severity is a severity score of a vulnerability
+type Severity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// SeverityLevel returns the highest severity of the vulnerability.
+// The severity of the database (e.g. GitHub advisories) is preferred over the CVSS score.
+func (v *Vulnerability) SeverityLevel() SeverityLevel {
+	if s, ok := v.DatabaseSpecific["severity"].(string); ok {
+		if level := ParseSeverityLevel(s); level != SeverityUnknown {
+			return level
+		}
+	}
+
+	level := severityFromScores(v.Severity)
+	for _, a := range v.Affected {
+		if l := severityFromScores(a.Severity); l > level {
+			level = l
+		}
+		for _, specific := range []map[string]interface{}{a.EcosystemSpecific, a.DatabaseSpecific} {
+			if s, ok := specific["severity"].(string); ok {
+				if l := ParseSeverityLevel(s); l > level {
+					level = l
+				}
+			}
+		}
+	}
+	return level
+}
+
+func severityFromScores(severities []*Severity) SeverityLevel {
+	level := SeverityUnknown
+	for _, s := range severities {
+		if s.Type != "CVSS_V3" {
+			continue
+		}
+		score, err := CVSSv3BaseScore(s.Score)
+		if err != nil {
+			continue
+		}
+		if l := SeverityFromScore(score); l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// FixedVersions returns the versions which fix the vulnerability for the package
+func (v *Vulnerability) FixedVersions(purl *PackageURL) []string {
+	var fixed []string
+	for _, a := range v.affectedEntries(purl) {
+		for _, r := range a.Ranges {
+			for _, e := range r.Events {
+				if e.Fixed != "" {
+					fixed = append(fixed, e.Fixed)
+				}
+			}
+		}
+	}
+	return fixed
+}
+
+func (v *Vulnerability) affectedEntries(purl *PackageURL) []*Affected {
+	ecosystem, name := ecosystemName(purl)
+	entries := make([]*Affected, 0, 1)
+	for _, a := range v.Affected {
+		if strings.EqualFold(a.Package.Ecosystem, ecosystem) && normalizeName(ecosystem, a.Package.Name) == name {
+			entries = append(entries, a)
+		}
+	}
+	return entries
+}
+
+// Affects tests if the package version is affected by the vulnerability
+func (v *Vulnerability) Affects(purl *PackageURL) bool {
+	if v.Withdrawn != nil {
+		return false
+	}
+	for _, a := range v.affectedEntries(purl) {
+		if a.affects(purl.Type, purl.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Affected) affects(packageType, v string) bool {
+	for _, av := range a.Versions {
+		// Go versions are listed without the v prefix
+		if av == v || "v"+av == v {
+			return true
+		}
+	}
+
+	for _, r := range a.Ranges {
+		var scheme versioning.Scheme
+		switch r.Type {
+		case "SEMVER":
+			scheme = versioning.SemVer
+		case "ECOSYSTEM":
+			scheme = versioning.ForPackageType(packageType)
+		default:
+			continue
+		}
+		if rangeAffects(r, scheme, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeAffects evaluates the events sorted by version, later events override the state of earlier ones.
+// Events with versions the scheme can't parse are skipped.
+func rangeAffects(r *Range, scheme versioning.Scheme, v string) bool {
+	target, err := scheme.ParseVersion(v)
+	if err != nil {
+		return false
+	}
+
+	type parsedEvent struct {
+		event   *Event
+		version versioning.Version
+	}
+	events := make([]parsedEvent, 0, len(r.Events))
+	for _, e := range r.Events {
+		if e.Introduced == "0" {
+			events = append(events, parsedEvent{event: e})
+			continue
+		}
+		ev, err := scheme.ParseVersion(e.version())
+		if err != nil {
+			continue
+		}
+		events = append(events, parsedEvent{event: e, version: ev})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].version == nil || events[j].version == nil {
+			return events[i].version == nil && events[j].version != nil
+		}
+		return events[i].version.Compare(events[j].version) < 0
+	})
+
+	affected := false
+	for _, e := range events {
+		switch {
+		case e.event.Introduced != "":
+			if e.version == nil || target.Compare(e.version) >= 0 {
+				affected = true
+			}
+		case e.event.Fixed != "", e.event.Limit != "":
+			if target.Compare(e.version) >= 0 {
+				affected = false
+			}
+		case e.event.LastAffected != "":
+			if target.Compare(e.version) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// ecosystems maps the package url types to the OSV ecosystems
+var ecosystems = map[string]string{
+	TypeCargo:    "crates.io",
+	TypeComposer: "Packagist",
+	TypeConan:    "ConanCenter",
+	TypeGem:      "RubyGems",
+	TypeGolang:   "Go",
+	TypeMaven:    "Maven",
+	TypeNpm:      "npm",
+	TypeNuGet:    "NuGet",
+	TypePyPI:     "PyPI",
+}
+
+// ecosystemName returns the OSV ecosystem and the normalized package name of a package url
+func ecosystemName(purl *PackageURL) (string, string) {
+	ecosystem := ecosystems[purl.Type]
+	name := purl.Name
+	if purl.Namespace != "" {
+		separator := "/"
+		if purl.Type == TypeMaven {
+			separator = ":"
+		}
+		name = purl.Namespace + separator + purl.Name
+	}
+	return ecosystem, normalizeName(ecosystem, name)
+}
+
+func normalizeName(ecosystem, name string) string {
+	switch ecosystem {
+	case "PyPI":
+		return pypiNameReplacer.ReplaceAllString(strings.ToLower(name), "-")
+	case "Packagist", "NuGet", "npm", "Go":
+		return strings.ToLower(name)
+	default:
+		return name
+	}
+}
+
+// Database is an in-memory OSV advisory database
+type Database struct {
+	mu              sync.RWMutex
+	vulnerabilities map[string][]*Vulnerability
+}
+
+// NewDatabase creates an empty database
+func NewDatabase() *Database {
+	return &Database{
+		vulnerabilities: make(map[string][]*Vulnerability),
+	}
+}
+
+// Add adds a vulnerability to the database
+func (db *Database) Add(v *Vulnerability) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	seen := make(map[string]bool, len(v.Affected))
+	for _, a := range v.Affected {
+		key := a.Package.Ecosystem + "|" + normalizeName(a.Package.Ecosystem, a.Package.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		db.vulnerabilities[key] = append(db.vulnerabilities[key], v)
+	}
+}
+
+// Load reads a single OSV record
+func (db *Database) Load(r io.Reader) error {
+	var v Vulnerability
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return err
+	}
+	if err := v.validate(); err != nil {
+		return err
+	}
+	db.Add(&v)
+	return nil
+}
+
+// validate checks that the record has an id and no empty entries which would break queries
+func (v *Vulnerability) validate() error {
+	if v.ID == "" {
+		return ErrInvalidVulnerability
+	}
+	for _, s := range v.Severity {
+		if s == nil {
+			return ErrInvalidVulnerability
+		}
+	}
+	for _, a := range v.Affected {
+		if a == nil {
+			return ErrInvalidVulnerability
+		}
+		for _, s := range a.Severity {
+			if s == nil {
+				return ErrInvalidVulnerability
+			}
+		}
+		for _, r := range a.Ranges {
+			if r == nil {
+				return ErrInvalidVulnerability
+			}
+			for _, e := range r.Events {
+				if e == nil {
+					return ErrInvalidVulnerability
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// LoadDir reads all OSV records (*.json) of a directory tree
+func (db *Database) LoadDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return db.Load(f)
+	})
+}
+
+// LoadZip reads all OSV records of a zip archive like the all.zip exports of osv.dev
+func (db *Database) LoadZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || filepath.Ext(f.Name) != ".json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = db.Load(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns the vulnerabilities affecting the package version
+func (db *Database) Query(purl *PackageURL) []*Vulnerability {
+	ecosystem, name := ecosystemName(purl)
+	if ecosystem == "" || purl.Version == "" {
+		return nil
+	}
+
+	db.mu.RLock()
+	candidates := db.vulnerabilities[ecosystem+"|"+name]
+	db.mu.RUnlock()
+
+	var vulnerabilities []*Vulnerability
+	for _, v := range candidates {
+		if v.Affects(purl) {
+			vulnerabilities = append(vulnerabilities, v)
+		}
+	}
+	return vulnerabilities
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitbundle/modules/packages/versioning"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const advisoryNpm = `{
+	"schema_version": "1.4.0",
+	"id": "GHSA-aaaa-bbbb-cccc",
+	"summary": "Prototype pollution in left-pad",
+	"aliases": ["CVE-2023-0001"],
+	"modified": "2023-01-02T03:04:05Z",
+	"affected": [{
+		"package": {"ecosystem": "npm", "name": "@scope/left-pad"},
+		"ranges": [{
+			"type": "SEMVER",
+			"events": [{"introduced": "0"}, {"fixed": "1.3.0"}, {"introduced": "2.0.0"}, {"last_affected": "2.1.0"}]
+		}]
+	}],
+	"database_specific": {"severity": "CRITICAL"}
+}`
+
+const advisoryPyPI = `{
+	"id": "PYSEC-2023-1",
+	"modified": "2023-01-02T03:04:05Z",
+	"affected": [{
+		"package": {"ecosystem": "PyPI", "name": "GitBundle_Client"},
+		"versions": ["0.9"],
+		"ranges": [{"type": "GIT", "repo": "https://gitbundle.com/client", "events": [{"introduced": "0"}, {"fixed": "abcdef"}]}]
+	}],
+	"severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N"}]
+}`
+
+const advisoryWithdrawn = `{
+	"id": "GHSA-withdrawn",
+	"modified": "2023-01-02T03:04:05Z",
+	"withdrawn": "2023-01-03T03:04:05Z",
+	"affected": [{"package": {"ecosystem": "npm", "name": "@scope/left-pad"}, "versions": ["1.0.0"]}]
+}`
+
+func TestDatabaseQuery(t *testing.T) {
+	db := NewDatabase()
+	for _, advisory := range []string{advisoryNpm, advisoryPyPI, advisoryWithdrawn} {
+		assert.NoError(t, db.Load(strings.NewReader(advisory)))
+	}
+
+	leftPad := NewPackageURL(TypeNpm, "@scope", "left-pad", "")
+
+	cases := map[string]bool{
+		"0.1.0":       true,
+		"1.0.0":       true,
+		"1.2.9":       true,
+		"1.3.0":       false,
+		"1.9.0":       false,
+		"2.0.0":       true,
+		"2.1.0":       true,
+		"2.1.1":       false,
+		"2.0.0-beta1": false,
+	}
+	for v, affected := range cases {
+		vulnerabilities := db.Query(leftPad.WithVersion(v))
+		if affected {
+			assert.Len(t, vulnerabilities, 1, v)
+			assert.Equal(t, "GHSA-aaaa-bbbb-cccc", vulnerabilities[0].ID)
+			assert.Equal(t, SeverityCritical, vulnerabilities[0].SeverityLevel())
+		} else {
+			assert.Empty(t, vulnerabilities, v)
+		}
+	}
+	assert.Equal(t, []string{"1.3.0"}, db.Query(leftPad.WithVersion("1.0.0"))[0].FixedVersions(leftPad))
+
+	client := NewPackageURL(TypePyPI, "", "gitbundle.client", "0.9")
+	vulnerabilities := db.Query(client)
+	assert.Len(t, vulnerabilities, 1)
+	assert.Equal(t, SeverityMedium, vulnerabilities[0].SeverityLevel())
+	assert.Empty(t, db.Query(client.WithVersion("1.0")))
+
+	assert.Empty(t, db.Query(leftPad))
+	assert.Empty(t, db.Query(NewPackageURL(TypeHelm, "", "chart", "1.0.0")))
+}
+
+func TestRangeAffectsInvalidEvent(t *testing.T) {
+	// only the event with the invalid version is skipped
+	r := &Range{Type: "SEMVER", Events: []*Event{{Introduced: "1.0.0"}, {Fixed: "not-a-version"}, {Fixed: "2.0.0"}}}
+	assert.True(t, rangeAffects(r, versioning.SemVer, "1.5.0"))
+	assert.False(t, rangeAffects(r, versioning.SemVer, "2.0.0"))
+	assert.False(t, rangeAffects(r, versioning.SemVer, "0.9.0"))
+}
+
+func TestDatabaseLoad(t *testing.T) {
+	t.Run("Dir", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, "npm"), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "npm", "GHSA-aaaa-bbbb-cccc.json"), []byte(advisoryNpm), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0o644))
+
+		db := NewDatabase()
+		assert.NoError(t, db.LoadDir(dir))
+		assert.Len(t, db.Query(NewPackageURL(TypeNpm, "@scope", "left-pad", "1.0.0")), 1)
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create("PYSEC-2023-1.json")
+		w.Write([]byte(advisoryPyPI))
+		zw.Close()
+
+		db := NewDatabase()
+		assert.NoError(t, db.LoadZip(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+		assert.Len(t, db.Query(NewPackageURL(TypePyPI, "", "gitbundle-client", "0.9")), 1)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.Error(t, NewDatabase().Load(strings.NewReader("{")))
+		for _, record := range []string{
+			`null`,
+			`{}`,
+			`{"id": "GHSA-null", "affected": [null]}`,
+			`{"id": "GHSA-null", "affected": [{"package": {"ecosystem": "npm", "name": "a"}, "ranges": [{"type": "SEMVER", "events": [null]}]}]}`,
+		} {
+			assert.ErrorIs(t, NewDatabase().Load(strings.NewReader(record)), ErrInvalidVulnerability, record)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FindingType is the kind of a policy finding
+type FindingType string
+
+const (
+	FindingVulnerability FindingType = "vulnerability"
+	FindingLicense       FindingType = "license"
+)
+
+// Package is a package version normalized for the policy evaluation
+type Package struct {
+	PackageURL *PackageURL
+	// License is the license expression of the package
+	License string
+	// Dependencies are checked for vulnerabilities if their version is exact
+	Dependencies []*PackageURL
+}
+
+// Policy defines the accepted licenses and the severity which blocks publishing
+type Policy struct {
+	// BlockSeverity is the minimum severity of a finding which blocks the package, SeverityUnknown never blocks
+	BlockSeverity SeverityLevel
+	// LicenseAllow lists the accepted SPDX license identifiers, all licenses are accepted if it is empty
+	LicenseAllow []string
+	// LicenseDeny lists the rejected SPDX license identifiers
+	LicenseDeny []string
+	// LicenseSeverity is the severity of findings for rejected licenses
+	LicenseSeverity SeverityLevel
+	// IgnoreVulnerabilities lists vulnerability ids or aliases which are accepted
+	IgnoreVulnerabilities []string
+}
+
+// Finding is a policy violation of a package version or one of its dependencies
+type Finding struct {
+	Type FindingType
+	// PackageURL identifies the package version causing the finding
+	PackageURL      string
+	Severity        SeverityLevel
+	VulnerabilityID string
+	Aliases         []string
+	FixedVersions   []string
+	License         string
+	Message         string
+}
+
+// Report contains the findings of a package version
+type Report struct {
+	PackageURL string
+	Findings   []*Finding
+	// Blocked is true if a finding reaches the block severity of the policy
+	Blocked bool
+}
+
+// Evaluate checks the package and its dependencies against the vulnerability database and the license lists
+func (p *Policy) Evaluate(db *Database, pkg *Package) *Report {
+	report := &Report{
+		PackageURL: pkg.PackageURL.String(),
+	}
+
+	if db != nil {
+		report.Findings = append(report.Findings, p.vulnerabilityFindings(db, pkg.PackageURL)...)
+		for _, dep := range pkg.Dependencies {
+			if dep.Version == "" {
+				continue
+			}
+			report.Findings = append(report.Findings, p.vulnerabilityFindings(db, dep)...)
+		}
+	}
+
+	if f := p.licenseFinding(pkg); f != nil {
+		report.Findings = append(report.Findings, f)
+	}
+
+	for _, f := range report.Findings {
+		if p.BlockSeverity != SeverityUnknown && f.Severity >= p.BlockSeverity {
+			report.Blocked = true
+		}
+	}
+	return report
+}
+
+func (p *Policy) isIgnored(v *Vulnerability) bool {
+	for _, ignored := range p.IgnoreVulnerabilities {
+		if strings.EqualFold(ignored, v.ID) {
+			return true
+		}
+		for _, alias := range v.Aliases {
+			if strings.EqualFold(ignored, alias) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Policy) vulnerabilityFindings(db *Database, purl *PackageURL) []*Finding {
+	var findings []*Finding
+	for _, v := range db.Query(purl) {
+		if p.isIgnored(v) {
+			continue
+		}
+		findings = append(findings, &Finding{
+			Type:            FindingVulnerability,
+			PackageURL:      purl.String(),
+			Severity:        v.SeverityLevel(),
+			VulnerabilityID: v.ID,
+			Aliases:         v.Aliases,
+			FixedVersions:   v.FixedVersions(purl),
+			Message:         v.Summary,
+		})
+	}
+	return findings
+}
+
+func containsLicense(list []string, license string) bool {
+	for _, l := range list {
+		if id, _ := NormalizeLicense(l); strings.EqualFold(id, license) {
+			return true
+		}
+	}
+	return false
+}
+
+// licenseAccepted tests if the license is not denied and allowed if there is an allow list
+func (p *Policy) licenseAccepted(license string) bool {
+	if containsLicense(p.LicenseDeny, license) {
+		return false
+	}
+	return len(p.LicenseAllow) == 0 || containsLicense(p.LicenseAllow, license)
+}
+
+func (p *Policy) licenseFinding(pkg *Package) *Finding {
+	if len(p.LicenseAllow) == 0 && len(p.LicenseDeny) == 0 {
+		return nil
+	}
+
+	finding := &Finding{
+		Type:       FindingLicense,
+		PackageURL: pkg.PackageURL.String(),
+		Severity:   p.LicenseSeverity,
+		License:    pkg.License,
+	}
+
+	if strings.TrimSpace(pkg.License) == "" {
+		if len(p.LicenseAllow) == 0 {
+			return nil
+		}
+		finding.Message = "package has no license"
+		return finding
+	}
+
+	expr, err := ParseLicenseExpression(pkg.License)
+	if err != nil {
+		finding.Message = fmt.Sprintf("license expression %q is invalid", pkg.License)
+		return finding
+	}
+	finding.License = expr.String()
+
+	if expr.Satisfied(p.licenseAccepted) {
+		return nil
+	}
+	finding.Message = fmt.Sprintf("license %s is not allowed", finding.License)
+	return finding
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gitbundle/modules/packages/composer"
+	"github.com/gitbundle/modules/packages/maven"
+	"github.com/gitbundle/modules/packages/npm"
+	"github.com/gitbundle/modules/packages/nuget"
+	"github.com/gitbundle/modules/packages/pypi"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	db := NewDatabase()
+	assert.NoError(t, db.Load(strings.NewReader(advisoryNpm)))
+
+	pkg := &Package{
+		PackageURL: NewPackageURL(TypeNpm, "", "app", "1.0.0"),
+		License:    "MIT OR GPL-3.0-only",
+		Dependencies: []*PackageURL{
+			NewPackageURL(TypeNpm, "@scope", "left-pad", "1.2.0"),
+			NewPackageURL(TypeNpm, "@scope", "left-pad", ""),
+		},
+	}
+
+	t.Run("Vulnerability", func(t *testing.T) {
+		p := &Policy{BlockSeverity: SeverityCritical}
+		report := p.Evaluate(db, pkg)
+		assert.Equal(t, "pkg:npm/app@1.0.0", report.PackageURL)
+		assert.True(t, report.Blocked)
+		assert.Len(t, report.Findings, 1)
+
+		f := report.Findings[0]
+		assert.Equal(t, FindingVulnerability, f.Type)
+		assert.Equal(t, "pkg:npm/%40scope/left-pad@1.2.0", f.PackageURL)
+		assert.Equal(t, "GHSA-aaaa-bbbb-cccc", f.VulnerabilityID)
+		assert.Equal(t, []string{"CVE-2023-0001"}, f.Aliases)
+		assert.Equal(t, []string{"1.3.0"}, f.FixedVersions)
+		assert.Equal(t, SeverityCritical, f.Severity)
+
+		p.IgnoreVulnerabilities = []string{"cve-2023-0001"}
+		report = p.Evaluate(db, pkg)
+		assert.False(t, report.Blocked)
+		assert.Empty(t, report.Findings)
+
+		report = (&Policy{}).Evaluate(db, pkg)
+		assert.False(t, report.Blocked)
+		assert.Len(t, report.Findings, 1)
+	})
+
+	t.Run("License", func(t *testing.T) {
+		p := &Policy{BlockSeverity: SeverityHigh, LicenseDeny: []string{"GPL-3.0-only"}, LicenseSeverity: SeverityHigh}
+		report := p.Evaluate(nil, pkg)
+		assert.False(t, report.Blocked)
+		assert.Empty(t, report.Findings)
+
+		p.LicenseAllow = []string{"Apache License 2.0"}
+		report = p.Evaluate(nil, pkg)
+		assert.True(t, report.Blocked)
+		assert.Len(t, report.Findings, 1)
+		assert.Equal(t, FindingLicense, report.Findings[0].Type)
+		assert.Equal(t, "MIT OR GPL-3.0-only", report.Findings[0].License)
+
+		report = p.Evaluate(nil, &Package{PackageURL: pkg.PackageURL})
+		assert.Len(t, report.Findings, 1)
+		assert.Equal(t, "package has no license", report.Findings[0].Message)
+
+		report = p.Evaluate(nil, &Package{PackageURL: pkg.PackageURL, License: "MIT AND"})
+		assert.Len(t, report.Findings, 1)
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	t.Run("Maven", func(t *testing.T) {
+		pkg := FromMaven(&maven.Metadata{
+			GroupID:    "org.gitbundle",
+			ArtifactID: "my-project",
+			Licenses:   []string{"The Apache Software License, Version 2.0", "Custom License"},
+			Dependencies: []*maven.Dependency{
+				{GroupID: "org.gitbundle.core", ArtifactID: "git", Version: "5.0.0"},
+				{GroupID: "org.gitbundle.core", ArtifactID: "range", Version: "[1.0,2.0)"},
+			},
+		}, "1.0.1")
+		assert.Equal(t, "pkg:maven/org.gitbundle/my-project@1.0.1", pkg.PackageURL.String())
+		assert.Equal(t, "Apache-2.0 OR LicenseRef-Custom-License", pkg.License)
+		assert.Equal(t, "5.0.0", pkg.Dependencies[0].Version)
+		assert.Empty(t, pkg.Dependencies[1].Version)
+	})
+
+	t.Run("Composer", func(t *testing.T) {
+		pkg := FromComposer(&composer.Package{
+			Name:    "GitBundle/Client",
+			Version: "1.0.0",
+			Metadata: &composer.Metadata{
+				License: composer.Licenses{"MIT", "GPL-3.0-or-later"},
+				Require: map[string]string{"php": ">=8.0", "vendor/lib": "1.2.3"},
+			},
+		})
+		assert.Equal(t, "pkg:composer/gitbundle/client@1.0.0", pkg.PackageURL.String())
+		assert.Equal(t, "MIT OR GPL-3.0-or-later", pkg.License)
+		assert.Len(t, pkg.Dependencies, 1)
+		assert.Equal(t, "pkg:composer/vendor/lib@1.2.3", pkg.Dependencies[0].String())
+	})
+
+	t.Run("NuGet", func(t *testing.T) {
+		pkg := FromNuGet(&nuget.Package{
+			ID:      "GitBundle.Client",
+			Version: "1.0.0",
+			Metadata: &nuget.Metadata{
+				Dependencies: map[string][]nuget.Dependency{
+					"net6.0": {{ID: "Newtonsoft.Json", Version: "[13.0.1]"}},
+					"net7.0": {{ID: "Newtonsoft.Json", Version: "[13.0.1]"}, {ID: "Other", Version: "1.0.0"}},
+				},
+			},
+		})
+		assert.Equal(t, "pkg:nuget/gitbundle.client@1.0.0", pkg.PackageURL.String())
+		assert.Len(t, pkg.Dependencies, 2)
+		versions := map[string]string{}
+		for _, d := range pkg.Dependencies {
+			versions[d.Name] = d.Version
+		}
+		assert.Equal(t, map[string]string{"newtonsoft.json": "13.0.1", "other": ""}, versions)
+	})
+
+	t.Run("Npm", func(t *testing.T) {
+		pkg := FromNpm(&npm.Package{
+			Name:    "@scope/app",
+			Version: "1.0.0",
+			Metadata: npm.Metadata{
+				License:      "MIT",
+				Dependencies: map[string]string{"@scope/left-pad": "1.2.0"},
+			},
+		})
+		assert.Equal(t, "pkg:npm/%40scope/app@1.0.0", pkg.PackageURL.String())
+		assert.Equal(t, "pkg:npm/%40scope/left-pad@1.2.0", pkg.Dependencies[0].String())
+
+		pkg.Dependencies[0] = FromNpm(&npm.Package{Name: "x", Metadata: npm.Metadata{Dependencies: map[string]string{"y": "^1.2.0"}}}).Dependencies[0]
+		assert.Empty(t, pkg.Dependencies[0].Version)
+	})
+
+	t.Run("PyPI", func(t *testing.T) {
+		pkg := FromPyPI(&pypi.Package{
+			Name:    "GitBundle_Client",
+			Version: "1.0",
+			Metadata: &pypi.Metadata{
+				License:      "Copyright (c) GitBundle, see LICENSE",
+				Classifiers:  []string{"License :: OSI Approved :: MIT License", "Programming Language :: Python :: 3"},
+				RequiresDist: []*pypi.Requirement{{Name: "requests", Specifier: "==2.28.1"}, {Name: "urllib3", Specifier: ">=1.26"}},
+			},
+		})
+		assert.Equal(t, "pkg:pypi/gitbundle-client@1.0", pkg.PackageURL.String())
+		assert.Equal(t, "MIT", pkg.License)
+		assert.Equal(t, "2.28.1", pkg.Dependencies[0].Version)
+		assert.Empty(t, pkg.Dependencies[1].Version)
+	})
+}
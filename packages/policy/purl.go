@@ -0,0 +1,173 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst
+const (
+	TypeCargo    = "cargo"
+	TypeComposer = "composer"
+	TypeConan    = "conan"
+	TypeGem      = "gem"
+	TypeGolang   = "golang"
+	TypeHelm     = "helm"
+	TypeMaven    = "maven"
+	TypeNpm      = "npm"
+	TypeNuGet    = "nuget"
+	TypePyPI     = "pypi"
+)
+
+// ErrInvalidPackageURL indicates an invalid package url
+var ErrInvalidPackageURL = errors.New("package url is invalid")
+
+var pypiNameReplacer = regexp.MustCompile(`[-_.]+`)
+
+// PackageURL represents a package url (purl) which identifies a package independent of the registry
+type PackageURL struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Version    string
+	Qualifiers map[string]string
+	Subpath    string
+}
+
+// NewPackageURL creates a package url and applies the normalization rules of the package type
+func NewPackageURL(typ, namespace, name, version string) *PackageURL {
+	typ = strings.ToLower(typ)
+	switch typ {
+	case TypePyPI:
+		name = pypiNameReplacer.ReplaceAllString(strings.ToLower(name), "-")
+	case TypeComposer, TypeGolang, TypeNpm:
+		namespace = strings.ToLower(namespace)
+		name = strings.ToLower(name)
+	case TypeNuGet:
+		name = strings.ToLower(name)
+	}
+	return &PackageURL{
+		Type:      typ,
+		Namespace: namespace,
+		Name:      name,
+		Version:   version,
+	}
+}
+
+// String returns the canonical form of the package url
+func (p *PackageURL) String() string {
+	var sb strings.Builder
+	sb.WriteString("pkg:")
+	sb.WriteString(p.Type)
+	sb.WriteByte('/')
+	if p.Namespace != "" {
+		for _, segment := range strings.Split(p.Namespace, "/") {
+			sb.WriteString(escapePurlSegment(segment))
+			sb.WriteByte('/')
+		}
+	}
+	sb.WriteString(escapePurlSegment(p.Name))
+	if p.Version != "" {
+		sb.WriteByte('@')
+		sb.WriteString(url.PathEscape(p.Version))
+	}
+	if len(p.Qualifiers) > 0 {
+		keys := make([]string, 0, len(p.Qualifiers))
+		for k := range p.Qualifiers {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i == 0 {
+				sb.WriteByte('?')
+			} else {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(k)
+			sb.WriteByte('=')
+			sb.WriteString(url.PathEscape(p.Qualifiers[k]))
+		}
+	}
+	if p.Subpath != "" {
+		sb.WriteByte('#')
+		sb.WriteString(p.Subpath)
+	}
+	return sb.String()
+}
+
+// escapePurlSegment percent-encodes a path segment including the @ character which separates the version
+func escapePurlSegment(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+// WithVersion returns a copy of the package url with a different version
+func (p *PackageURL) WithVersion(version string) *PackageURL {
+	c := *p
+	c.Version = version
+	return &c
+}
+
+// ParsePackageURL parses a package url like pkg:npm/%40scope/name@1.0.0
+func ParsePackageURL(s string) (*PackageURL, error) {
+	if !strings.HasPrefix(s, "pkg:") {
+		return nil, ErrInvalidPackageURL
+	}
+	rest := strings.TrimLeft(s[4:], "/")
+
+	p := &PackageURL{}
+
+	if i := strings.LastIndexByte(rest, '#'); i != -1 {
+		p.Subpath = strings.Trim(rest[i+1:], "/")
+		rest = rest[:i]
+	}
+	if i := strings.LastIndexByte(rest, '?'); i != -1 {
+		p.Qualifiers = make(map[string]string)
+		for _, pair := range strings.Split(rest[i+1:], "&") {
+			k, v, _ := strings.Cut(pair, "=")
+			value, err := url.PathUnescape(v)
+			if err != nil {
+				return nil, ErrInvalidPackageURL
+			}
+			if value != "" {
+				p.Qualifiers[strings.ToLower(k)] = value
+			}
+		}
+		rest = rest[:i]
+	}
+
+	typ, rest, ok := strings.Cut(strings.TrimRight(rest, "/"), "/")
+	if !ok || typ == "" {
+		return nil, ErrInvalidPackageURL
+	}
+	p.Type = strings.ToLower(typ)
+
+	if i := strings.LastIndexByte(rest, '@'); i != -1 && i > strings.LastIndexByte(rest, '/') {
+		version, err := url.PathUnescape(rest[i+1:])
+		if err != nil {
+			return nil, ErrInvalidPackageURL
+		}
+		p.Version = version
+		rest = rest[:i]
+	}
+
+	segments := strings.Split(rest, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || unescaped == "" {
+			return nil, ErrInvalidPackageURL
+		}
+		segments[i] = unescaped
+	}
+	p.Name = segments[len(segments)-1]
+	p.Namespace = strings.Join(segments[:len(segments)-1], "/")
+
+	return p, nil
+}
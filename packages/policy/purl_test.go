@@ -0,0 +1,46 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageURL(t *testing.T) {
+	cases := []struct {
+		purl     *PackageURL
+		expected string
+	}{
+		{NewPackageURL(TypeNpm, "@Scope", "Name", "1.0.0"), "pkg:npm/%40scope/name@1.0.0"},
+		{NewPackageURL(TypeMaven, "org.gitbundle", "my-project", "1.0.1"), "pkg:maven/org.gitbundle/my-project@1.0.1"},
+		{NewPackageURL(TypePyPI, "", "GitBundle_Client", "1.0rc1"), "pkg:pypi/gitbundle-client@1.0rc1"},
+		{NewPackageURL(TypeGolang, "github.com/gitbundle", "modules", "v1.0.0+incompatible"), "pkg:golang/github.com/gitbundle/modules@v1.0.0+incompatible"},
+		{NewPackageURL(TypeCargo, "", "serde", ""), "pkg:cargo/serde"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.purl.String())
+
+		parsed, err := ParsePackageURL(c.expected)
+		assert.NoError(t, err)
+		assert.Equal(t, c.purl, parsed)
+	}
+
+	p, err := ParsePackageURL("pkg:maven/org.gitbundle/my-project@1.0.1?type=jar&classifier=sources#sub/path")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"type": "jar", "classifier": "sources"}, p.Qualifiers)
+	assert.Equal(t, "sub/path", p.Subpath)
+	assert.Equal(t, "pkg:maven/org.gitbundle/my-project@1.0.1?classifier=sources&type=jar#sub/path", p.String())
+	assert.Equal(t, "2.0.0", p.WithVersion("2.0.0").Version)
+	assert.Equal(t, "1.0.1", p.Version)
+
+	for _, invalid := range []string{"", "npm/name", "pkg:", "pkg:npm", "pkg:npm//name"} {
+		p, err := ParsePackageURL(invalid)
+		assert.Nil(t, p, invalid)
+		assert.ErrorIs(t, err, ErrInvalidPackageURL, invalid)
+	}
+}
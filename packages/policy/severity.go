@@ -0,0 +1,163 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// SeverityLevel is the qualitative severity of a finding
+type SeverityLevel int
+
+const (
+	SeverityUnknown SeverityLevel = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+// ErrInvalidCVSSVector indicates an invalid or unsupported CVSS vector
+var ErrInvalidCVSSVector = errors.New("CVSS vector is invalid")
+
+// String returns the name of the severity level
+func (s SeverityLevel) String() string {
+	switch s {
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseSeverityLevel parses a severity name. MODERATE is used by GitHub advisories for medium.
+func ParseSeverityLevel(s string) SeverityLevel {
+	switch strings.ToLower(s) {
+	case "low":
+		return SeverityLow
+	case "medium", "moderate":
+		return SeverityMedium
+	case "high":
+		return SeverityHigh
+	case "critical":
+		return SeverityCritical
+	default:
+		return SeverityUnknown
+	}
+}
+
+// SeverityFromScore maps a CVSS base score to the qualitative severity rating
+func SeverityFromScore(score float64) SeverityLevel {
+	switch {
+	case score >= 9.0:
+		return SeverityCritical
+	case score >= 7.0:
+		return SeverityHigh
+	case score >= 4.0:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
+
+var cvssWeights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// CVSSv3BaseScore calculates the base score of a CVSS v3.0 or v3.1 vector like
+// CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H
+func CVSSv3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) < 9 || (parts[0] != "CVSS:3.0" && parts[0] != "CVSS:3.1") {
+		return 0, ErrInvalidCVSSVector
+	}
+
+	metrics := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		k, v, ok := strings.Cut(part, ":")
+		if !ok {
+			return 0, ErrInvalidCVSSVector
+		}
+		metrics[k] = v
+	}
+
+	scopeChanged := false
+	switch metrics["S"] {
+	case "U":
+	case "C":
+		scopeChanged = true
+	default:
+		return 0, ErrInvalidCVSSVector
+	}
+
+	var pr float64
+	switch metrics["PR"] {
+	case "N":
+		pr = 0.85
+	case "L":
+		pr = 0.62
+		if scopeChanged {
+			pr = 0.68
+		}
+	case "H":
+		pr = 0.27
+		if scopeChanged {
+			pr = 0.5
+		}
+	default:
+		return 0, ErrInvalidCVSSVector
+	}
+
+	w := make(map[string]float64, len(cvssWeights))
+	for metric, values := range cvssWeights {
+		v, ok := values[metrics[metric]]
+		if !ok {
+			return 0, ErrInvalidCVSSVector
+		}
+		w[metric] = v
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	var impact float64
+	if scopeChanged {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+
+	exploitability := 8.22 * w["AV"] * w["AC"] * pr * w["UI"]
+	if scopeChanged {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp returns the smallest number with one decimal place which is equal or higher than the input
+func roundUp(v float64) float64 {
+	i := int64(math.Round(v * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCVSSv3BaseScore(t *testing.T) {
+	cases := map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H": 10.0,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N": 6.1,
+		"CVSS:3.0/AV:L/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N": 1.8,
+		"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:N/I:N/A:N": 0,
+	}
+	for vector, expected := range cases {
+		score, err := CVSSv3BaseScore(vector)
+		assert.NoError(t, err, vector)
+		assert.Equal(t, expected, score, vector)
+	}
+
+	for _, invalid := range []string{"", "CVSS:2.0/AV:N/AC:L/Au:N/C:P/I:P/A:P", "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H"} {
+		_, err := CVSSv3BaseScore(invalid)
+		assert.ErrorIs(t, err, ErrInvalidCVSSVector, invalid)
+	}
+}
+
+func TestSeverityLevel(t *testing.T) {
+	assert.Equal(t, SeverityMedium, ParseSeverityLevel("MODERATE"))
+	assert.Equal(t, SeverityCritical, ParseSeverityLevel("critical"))
+	assert.Equal(t, SeverityUnknown, ParseSeverityLevel("other"))
+
+	assert.Equal(t, SeverityCritical, SeverityFromScore(9.8))
+	assert.Equal(t, SeverityHigh, SeverityFromScore(7.0))
+	assert.Equal(t, SeverityMedium, SeverityFromScore(6.9))
+	assert.Equal(t, SeverityLow, SeverityFromScore(0.1))
+	assert.Equal(t, SeverityUnknown, SeverityFromScore(0))
+
+	assert.Equal(t, "high", SeverityHigh.String())
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pypi
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+)
+
+const (
+	// ContentTypeSimpleHTML is the content type of the PEP 691 HTML serialization
+	ContentTypeSimpleHTML = "application/vnd.pypi.simple.v1+html"
+	// ContentTypeSimpleJSON is the content type of the PEP 691 JSON serialization
+	ContentTypeSimpleJSON = "application/vnd.pypi.simple.v1+json"
+
+	// simpleAPIVersion is the implemented version of the simple repository API,
+	// 1.1 adds the size, upload-time and versions fields of PEP 700
+	simpleAPIVersion = "1.1"
+)
+
+// IndexFile represents a distribution file listed in the simple index of a project
+type IndexFile struct {
+	Filename       string
+	URL            string
+	SHA256         string
+	RequiresPython string
+	Size           int64
+	UploadTime     time.Time
+	// Yanked marks the file as yanked, YankedReason is optional
+	Yanked       bool
+	YankedReason string
+}
+
+// WriteSimpleRootHTML writes the PEP 503 HTML page listing all projects.
+// urlFn returns the URL of the project page for a normalized name.
+func WriteSimpleRootHTML(w io.Writer, names []string, urlFn func(string) string) error {
+	if _, err := fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta name=\"pypi:repository-version\" content=\"%s\">\n<title>Simple index</title>\n</head>\n<body>\n", simpleAPIVersion); err != nil {
+		return err
+	}
+	for _, name := range names {
+		normalized := NormalizeName(name)
+		if _, err := fmt.Fprintf(w, "<a href=\"%s\">%s</a><br/>\n", html.EscapeString(urlFn(normalized)), html.EscapeString(normalized)); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+// WriteSimpleProjectHTML writes the PEP 503 HTML page of a project listing its files
+func WriteSimpleProjectHTML(w io.Writer, name string, files []*IndexFile) error {
+	name = html.EscapeString(NormalizeName(name))
+	if _, err := fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta name=\"pypi:repository-version\" content=\"%s\">\n<title>Links for %s</title>\n</head>\n<body>\n<h1>Links for %s</h1>\n", simpleAPIVersion, name, name); err != nil {
+		return err
+	}
+	for _, f := range files {
+		href := f.URL
+		if f.SHA256 != "" {
+			href += "#sha256=" + f.SHA256
+		}
+		attrs := ""
+		if f.RequiresPython != "" {
+			attrs += fmt.Sprintf(" data-requires-python=\"%s\"", html.EscapeString(f.RequiresPython))
+		}
+		if f.Yanked {
+			attrs += fmt.Sprintf(" data-yanked=\"%s\"", html.EscapeString(f.YankedReason))
+		}
+		if _, err := fmt.Fprintf(w, "<a href=\"%s\"%s>%s</a><br/>\n", html.EscapeString(href), attrs, html.EscapeString(f.Filename)); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+type simpleMeta struct {
+	APIVersion string `json:"api-version"`
+}
+
+type simpleRootProject struct {
+	Name string `json:"name"`
+}
+
+type simpleRoot struct {
+	Meta     simpleMeta           `json:"meta"`
+	Projects []*simpleRootProject `json:"projects"`
+}
+
+type simpleFile struct {
+	Filename       string            `json:"filename"`
+	URL            string            `json:"url"`
+	Hashes         map[string]string `json:"hashes"`
+	RequiresPython string            `json:"requires-python,omitempty"`
+	Size           int64             `json:"size"`
+	UploadTime     string            `json:"upload-time,omitempty"`
+	// Yanked is either a boolean or the reason of the yank
+	Yanked interface{} `json:"yanked"`
+}
+
+type simpleProject struct {
+	Meta     simpleMeta    `json:"meta"`
+	Name     string        `json:"name"`
+	Versions []string      `json:"versions"`
+	Files    []*simpleFile `json:"files"`
+}
+
+// WriteSimpleRootJSON writes the PEP 691 JSON document listing all projects
+func WriteSimpleRootJSON(w io.Writer, names []string) error {
+	root := &simpleRoot{
+		Meta:     simpleMeta{APIVersion: simpleAPIVersion},
+		Projects: make([]*simpleRootProject, 0, len(names)),
+	}
+	for _, name := range names {
+		root.Projects = append(root.Projects, &simpleRootProject{Name: name})
+	}
+	return json.NewEncoder(w).Encode(root)
+}
+
+// WriteSimpleProjectJSON writes the PEP 691 JSON document of a project listing its versions and files
+func WriteSimpleProjectJSON(w io.Writer, name string, versions []string, files []*IndexFile) error {
+	if versions == nil {
+		versions = []string{}
+	}
+	project := &simpleProject{
+		Meta:     simpleMeta{APIVersion: simpleAPIVersion},
+		Name:     NormalizeName(name),
+		Versions: versions,
+		Files:    make([]*simpleFile, 0, len(files)),
+	}
+	for _, f := range files {
+		sf := &simpleFile{
+			Filename:       f.Filename,
+			URL:            f.URL,
+			Hashes:         map[string]string{},
+			RequiresPython: f.RequiresPython,
+			Size:           f.Size,
+			Yanked:         false,
+		}
+		if f.SHA256 != "" {
+			sf.Hashes["sha256"] = f.SHA256
+		}
+		if !f.UploadTime.IsZero() {
+			sf.UploadTime = f.UploadTime.UTC().Format("2006-01-02T15:04:05.000000Z")
+		}
+		if f.Yanked {
+			if f.YankedReason != "" {
+				sf.Yanked = f.YankedReason
+			} else {
+				sf.Yanked = true
+			}
+		}
+		project.Files = append(project.Files, sf)
+	}
+	return json.NewEncoder(w).Encode(project)
+}
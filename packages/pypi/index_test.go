@@ -0,0 +1,78 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pypi
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var indexFiles = []*IndexFile{
+	{
+		Filename:       "client-1.0-py3-none-any.whl",
+		URL:            "https://gitbundle.com/api/packages/user/pypi/files/client/1.0/client-1.0-py3-none-any.whl",
+		SHA256:         "abc",
+		RequiresPython: ">=3.7",
+		Size:           1024,
+		UploadTime:     time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+	},
+	{
+		Filename:     "client-0.9.tar.gz",
+		URL:          "https://gitbundle.com/api/packages/user/pypi/files/client/0.9/client-0.9.tar.gz?a=1&b=2",
+		Size:         2048,
+		Yanked:       true,
+		YankedReason: "broken <build>",
+	},
+}
+
+func TestWriteSimpleHTML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSimpleRootHTML(&buf, []string{"Client_A"}, func(name string) string { return "/simple/" + name + "/" }))
+	assert.Contains(t, buf.String(), `<meta name="pypi:repository-version" content="1.1">`)
+	assert.Contains(t, buf.String(), `<a href="/simple/client-a/">client-a</a><br/>`)
+
+	buf.Reset()
+	assert.NoError(t, WriteSimpleProjectHTML(&buf, "Client", indexFiles))
+	content := buf.String()
+	assert.Contains(t, content, "<title>Links for client</title>")
+	assert.Contains(t, content, `<a href="https://gitbundle.com/api/packages/user/pypi/files/client/1.0/client-1.0-py3-none-any.whl#sha256=abc" data-requires-python="&gt;=3.7">client-1.0-py3-none-any.whl</a><br/>`)
+	assert.Contains(t, content, `<a href="https://gitbundle.com/api/packages/user/pypi/files/client/0.9/client-0.9.tar.gz?a=1&amp;b=2" data-yanked="broken &lt;build&gt;">client-0.9.tar.gz</a><br/>`)
+}
+
+func TestWriteSimpleJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSimpleRootJSON(&buf, []string{"client"}))
+	assert.JSONEq(t, `{"meta":{"api-version":"1.1"},"projects":[{"name":"client"}]}`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WriteSimpleProjectJSON(&buf, "Client", []string{"0.9", "1.0"}, indexFiles))
+	assert.JSONEq(t, `{
+		"meta": {"api-version": "1.1"},
+		"name": "client",
+		"versions": ["0.9", "1.0"],
+		"files": [
+			{
+				"filename": "client-1.0-py3-none-any.whl",
+				"url": "https://gitbundle.com/api/packages/user/pypi/files/client/1.0/client-1.0-py3-none-any.whl",
+				"hashes": {"sha256": "abc"},
+				"requires-python": ">=3.7",
+				"size": 1024,
+				"upload-time": "2023-01-02T03:04:05.000000Z",
+				"yanked": false
+			},
+			{
+				"filename": "client-0.9.tar.gz",
+				"url": "https://gitbundle.com/api/packages/user/pypi/files/client/0.9/client-0.9.tar.gz?a=1&b=2",
+				"hashes": {},
+				"size": 2048,
+				"yanked": "broken <build>"
+			}
+		]
+	}`, buf.String())
+}
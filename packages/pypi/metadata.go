@@ -5,6 +5,55 @@
 
 package pypi
 
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/mail"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gitbundle/modules/packages/versioning"
+	"github.com/gitbundle/modules/validation"
+)
+
+// maxMetadataFileSize limits the size of the METADATA/PKG-INFO file
+const maxMetadataFileSize = 10 << 20
+
+var (
+	// ErrMissingMetadataFile indicates a missing METADATA or PKG-INFO file
+	ErrMissingMetadataFile = errors.New("metadata file is missing")
+	// ErrInvalidName indicates an invalid package name
+	ErrInvalidName = errors.New("package name is invalid")
+	// ErrInvalidVersion indicates an invalid package version
+	ErrInvalidVersion = errors.New("package version is invalid")
+	// ErrInvalidFilename indicates an invalid distribution file name
+	ErrInvalidFilename = errors.New("distribution file name is invalid")
+)
+
+// VersionScheme defines the version semantics of PyPI packages
+var VersionScheme = versioning.PEP440
+
+var (
+	// https://peps.python.org/pep-0508/#names
+	namePattern = regexp.MustCompile(`(?i)\A(?:[A-Z0-9]|[A-Z0-9][A-Z0-9._-]*[A-Z0-9])\z`)
+	// https://peps.python.org/pep-0440/#appendix-b-parsing-version-strings-with-regular-expressions
+	versionPattern   = regexp.MustCompile(`(?i)\Av?(?:[0-9]+!)?[0-9]+(?:\.[0-9]+)*(?:[-_.]?(?:a|b|c|rc|alpha|beta|pre|preview)[-_.]?[0-9]*)?(?:-[0-9]+|[-_.]?(?:post|rev|r)[-_.]?[0-9]*)?(?:[-_.]?dev[-_.]?[0-9]*)?(?:\+[a-z0-9]+(?:[-_.][a-z0-9]+)*)?\z`)
+	normalizePattern = regexp.MustCompile(`[-_.]+`)
+)
+
+// Package represents a PyPI package
+type Package struct {
+	Name     string
+	Version  string
+	Metadata *Metadata
+}
+
 // Metadata represents the metadata of a PyPI package
 type Metadata struct {
 	Author          string `json:"author,omitempty"`
@@ -14,4 +63,399 @@ type Metadata struct {
 	ProjectURL      string `json:"project_url,omitempty"`
 	License         string `json:"license,omitempty"`
 	RequiresPython  string `json:"requires_python,omitempty"`
+
+	MetadataVersion        string            `json:"metadata_version,omitempty"`
+	AuthorEmail            string            `json:"author_email,omitempty"`
+	Maintainer             string            `json:"maintainer,omitempty"`
+	MaintainerEmail        string            `json:"maintainer_email,omitempty"`
+	Keywords               []string          `json:"keywords,omitempty"`
+	Classifiers            []string          `json:"classifiers,omitempty"`
+	ProjectURLs            map[string]string `json:"project_urls,omitempty"`
+	LongDescriptionContent string            `json:"long_description_content_type,omitempty"`
+	RequiresDist           []*Requirement    `json:"requires_dist,omitempty"`
+	ProvidesExtra          []string          `json:"provides_extra,omitempty"`
+	// WheelTags are the compatibility tags of a wheel, empty for source distributions
+	WheelTags []*WheelTag `json:"wheel_tags,omitempty"`
+}
+
+// Requirement represents a Requires-Dist entry
+// https://peps.python.org/pep-0508/
+type Requirement struct {
+	Name      string   `json:"name"`
+	Extras    []string `json:"extras,omitempty"`
+	Specifier string   `json:"specifier,omitempty"`
+	// Marker is the environment marker, e.g. `python_version < "3.8"` or `extra == "test"`
+	Marker string `json:"marker,omitempty"`
+}
+
+// String returns the requirement in the PEP 508 format
+func (r *Requirement) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Name)
+	if len(r.Extras) > 0 {
+		sb.WriteString("[" + strings.Join(r.Extras, ",") + "]")
+	}
+	if r.Specifier != "" {
+		sb.WriteString(" " + r.Specifier)
+	}
+	if r.Marker != "" {
+		sb.WriteString("; " + r.Marker)
+	}
+	return sb.String()
+}
+
+// VersionRange parses the version specifier of the requirement, an empty specifier matches all versions
+func (r *Requirement) VersionRange() (versioning.Range, error) {
+	return VersionScheme.ParseRange(r.Specifier)
+}
+
+// WheelTag represents a compatibility tag of a wheel
+// https://packaging.python.org/en/latest/specifications/platform-compatibility-tags/
+type WheelTag struct {
+	Python   string `json:"python"`
+	ABI      string `json:"abi"`
+	Platform string `json:"platform"`
+}
+
+// String returns the tag in the python-abi-platform format
+func (t *WheelTag) String() string {
+	return t.Python + "-" + t.ABI + "-" + t.Platform
+}
+
+// NormalizeName normalizes a package name as defined in PEP 503
+func NormalizeName(name string) string {
+	return strings.ToLower(normalizePattern.ReplaceAllString(name, "-"))
+}
+
+// IsValidName checks if the name is a valid package name
+func IsValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// IsValidVersion checks if the version is a valid PEP 440 version
+func IsValidVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// ParseWheel parses the metadata of a wheel (.whl) file
+func ParseWheel(r io.ReaderAt, size int64) (*Package, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadataFile, wheelFile *zip.File
+	for _, f := range zr.File {
+		dir, name := path.Split(f.Name)
+		// the .dist-info directory is located in the root of the archive
+		if strings.Count(dir, "/") != 1 || !strings.HasSuffix(dir, ".dist-info/") {
+			continue
+		}
+		switch name {
+		case "METADATA":
+			metadataFile = f
+		case "WHEEL":
+			wheelFile = f
+		}
+	}
+	if metadataFile == nil {
+		return nil, ErrMissingMetadataFile
+	}
+
+	rc, err := openZipFile(metadataFile)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	p, err := ParseMetadataFile(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	if wheelFile != nil {
+		rc, err := openZipFile(wheelFile)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		if p.Metadata.WheelTags, err = parseWheelFile(rc); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func openZipFile(f *zip.File) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, maxMetadataFileSize), rc}, nil
+}
+
+// parseWheelFile reads the compatibility tags of a WHEEL file
+func parseWheelFile(r io.Reader) ([]*WheelTag, error) {
+	var tags []*WheelTag
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		key, value, ok := strings.Cut(s.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "Tag" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSpace(value), "-")
+		if len(parts) != 3 {
+			continue
+		}
+		tags = append(tags, &WheelTag{Python: parts[0], ABI: parts[1], Platform: parts[2]})
+	}
+	return tags, s.Err()
+}
+
+// ParseSdistTarGz parses the metadata of a .tar.gz source distribution
+func ParseSdistTarGz(r io.Reader) (*Package, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrMissingMetadataFile
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hd.Typeflag == tar.TypeReg && isSdistMetadataFile(hd.Name) {
+			return ParseMetadataFile(io.LimitReader(tr, maxMetadataFileSize))
+		}
+	}
+}
+
+// ParseSdistZip parses the metadata of a .zip source distribution
+func ParseSdistZip(r io.ReaderAt, size int64) (*Package, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		if isSdistMetadataFile(f.Name) {
+			rc, err := openZipFile(f)
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+
+			return ParseMetadataFile(rc)
+		}
+	}
+	return nil, ErrMissingMetadataFile
+}
+
+// isSdistMetadataFile checks if the name is the <name>-<version>/PKG-INFO file
+func isSdistMetadataFile(name string) bool {
+	name = strings.TrimPrefix(name, "./")
+	return strings.Count(name, "/") == 1 && path.Base(name) == "PKG-INFO"
+}
+
+// ParseMetadataFile parses a METADATA or PKG-INFO file
+// https://packaging.python.org/en/latest/specifications/core-metadata/
+func ParseMetadataFile(r io.Reader) (*Package, error) {
+	// the raw content is kept because the header parser collapses the folded description lines
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(content)))
+	if err != nil {
+		return nil, err
+	}
+	h := msg.Header
+
+	p := &Package{
+		Name:    h.Get("Name"),
+		Version: h.Get("Version"),
+		Metadata: &Metadata{
+			MetadataVersion:        h.Get("Metadata-Version"),
+			Summary:                h.Get("Summary"),
+			Author:                 h.Get("Author"),
+			AuthorEmail:            h.Get("Author-email"),
+			Maintainer:             h.Get("Maintainer"),
+			MaintainerEmail:        h.Get("Maintainer-email"),
+			License:                h.Get("License"),
+			RequiresPython:         h.Get("Requires-Python"),
+			Classifiers:            h["Classifier"],
+			ProvidesExtra:          h["Provides-Extra"],
+			LongDescriptionContent: h.Get("Description-Content-Type"),
+		},
+	}
+
+	if !IsValidName(p.Name) {
+		return nil, ErrInvalidName
+	}
+	if !IsValidVersion(p.Version) {
+		return nil, ErrInvalidVersion
+	}
+
+	if homepage := h.Get("Home-page"); validation.IsValidURL(homepage) {
+		p.Metadata.ProjectURL = homepage
+	}
+	if keywords := h.Get("Keywords"); keywords != "" {
+		sep := " "
+		if strings.Contains(keywords, ",") {
+			sep = ","
+		}
+		for _, k := range strings.Split(keywords, sep) {
+			if k = strings.TrimSpace(k); k != "" {
+				p.Metadata.Keywords = append(p.Metadata.Keywords, k)
+			}
+		}
+	}
+	for _, entry := range h["Project-Url"] {
+		label, url, ok := strings.Cut(entry, ",")
+		if !ok {
+			continue
+		}
+		if url = strings.TrimSpace(url); validation.IsValidURL(url) {
+			if p.Metadata.ProjectURLs == nil {
+				p.Metadata.ProjectURLs = make(map[string]string)
+			}
+			p.Metadata.ProjectURLs[strings.TrimSpace(label)] = url
+		}
+	}
+	for _, entry := range h["Requires-Dist"] {
+		if req := ParseRequirement(entry); req != nil {
+			p.Metadata.RequiresDist = append(p.Metadata.RequiresDist, req)
+		}
+	}
+
+	// metadata version 2.1 stores the description in the body, older versions in the header
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	if description := strings.TrimSpace(string(body)); description != "" {
+		p.Metadata.LongDescription = description
+	} else {
+		p.Metadata.LongDescription = unfoldDescription(rawHeaderLines(content, "Description"))
+	}
+	p.Metadata.Description = p.Metadata.LongDescription
+
+	return p, nil
+}
+
+// rawHeaderLines returns the unparsed lines of the first header with the key, the
+// first line without the key and the folded lines with their indentation
+func rawHeaderLines(content []byte, key string) []string {
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			// the header ends at the first empty line
+			break
+		}
+		if lines != nil {
+			if line[0] != ' ' && line[0] != '\t' {
+				break
+			}
+			lines = append(lines, line)
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, key) {
+			lines = append(lines, strings.TrimSpace(value))
+		}
+	}
+	return lines
+}
+
+// unfoldDescription joins the lines of a multiline header description. The folded lines are
+// indented by 8 spaces, optionally followed by a `|` to preserve the indentation of the line.
+func unfoldDescription(lines []string) string {
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimPrefix(lines[i], "        ")
+		if len(line) == len(lines[i]) {
+			line = strings.TrimLeft(line, " \t")
+		}
+		lines[i] = strings.TrimPrefix(line, "|")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+var requirementPattern = regexp.MustCompile(`\A\s*([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[([^\]]*)\])?\s*(?:\(([^)]*)\)|([^;]*))?\s*(?:;\s*(.*))?\z`)
+
+// ParseRequirement parses a PEP 508 requirement like `requests[security] (>=2.8.1) ; python_version < "2.7"`
+func ParseRequirement(s string) *Requirement {
+	m := requirementPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil
+	}
+
+	r := &Requirement{
+		Name:   m[1],
+		Marker: strings.TrimSpace(m[5]),
+	}
+	for _, extra := range strings.Split(m[2], ",") {
+		if extra = strings.TrimSpace(extra); extra != "" {
+			r.Extras = append(r.Extras, extra)
+		}
+	}
+	specifier := m[3]
+	if specifier == "" {
+		specifier = m[4]
+	}
+	r.Specifier = strings.ReplaceAll(strings.TrimSpace(specifier), " ", "")
+	return r
+}
+
+// WheelFilename represents the parts of a wheel file name
+// https://packaging.python.org/en/latest/specifications/binary-distribution-format/#file-name-convention
+type WheelFilename struct {
+	Name     string
+	Version  string
+	BuildTag string
+	Tags     []*WheelTag
+}
+
+// ParseWheelFilename parses a file name like `package-1.0-py2.py3-none-any.whl`.
+// Compressed tag sets are expanded to all combinations.
+func ParseWheelFilename(filename string) (*WheelFilename, error) {
+	if !strings.HasSuffix(filename, ".whl") {
+		return nil, ErrInvalidFilename
+	}
+
+	parts := strings.Split(strings.TrimSuffix(filename, ".whl"), "-")
+	if len(parts) != 5 && len(parts) != 6 {
+		return nil, ErrInvalidFilename
+	}
+
+	wf := &WheelFilename{
+		Name:    parts[0],
+		Version: parts[1],
+	}
+	if len(parts) == 6 {
+		wf.BuildTag = parts[2]
+		parts = append(parts[:2], parts[3:]...)
+	}
+	if !IsValidName(wf.Name) || !IsValidVersion(wf.Version) {
+		return nil, ErrInvalidFilename
+	}
+
+	for _, python := range strings.Split(parts[2], ".") {
+		for _, abi := range strings.Split(parts[3], ".") {
+			for _, platform := range strings.Split(parts[4], ".") {
+				wf.Tags = append(wf.Tags, &WheelTag{Python: python, ABI: abi, Platform: platform})
+			}
+		}
+	}
+	return wf, nil
 }
@@ -0,0 +1,233 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pypi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	packageName    = "GitBundle_Client"
+	packageVersion = "1.0.0rc1"
+	summary        = "A client"
+	homepage       = "https://gitbundle.com"
+)
+
+const metadataContent = `Metadata-Version: 2.1
+Name: ` + packageName + `
+Version: ` + packageVersion + `
+Summary: ` + summary + `
+Home-page: ` + homepage + `
+Author: GitBundle Authors
+Author-email: no.reply@gitbundle.com
+License: MIT
+Keywords: git,client
+Project-URL: Source, https://gitbundle.com/client
+Project-URL: Invalid, not a url
+Classifier: Programming Language :: Python :: 3
+Classifier: License :: OSI Approved :: MIT License
+Requires-Python: >=3.7
+Description-Content-Type: text/markdown
+Provides-Extra: test
+Requires-Dist: requests[security,socks] (>=2.8.1)
+Requires-Dist: importlib-metadata ; python_version < "3.8"
+Requires-Dist: pytest>=7,<8; extra == "test"
+
+# Client
+
+Long description
+`
+
+const wheelContent = `Wheel-Version: 1.0
+Generator: bdist_wheel (0.38.4)
+Root-Is-Purelib: true
+Tag: py2-none-any
+Tag: py3-none-any
+`
+
+func createZip(files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return bytes.NewReader(buf.Bytes())
+}
+
+func createTarGz(files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0o600,
+			Size: int64(len(content)),
+		})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	return &buf
+}
+
+func TestParseWheel(t *testing.T) {
+	t.Run("MissingMetadataFile", func(t *testing.T) {
+		data := createZip(map[string]string{"client/__init__.py": "", "nested/client.dist-info/METADATA": metadataContent})
+
+		p, err := ParseWheel(data, data.Size())
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrMissingMetadataFile)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		data := createZip(map[string]string{
+			"client/__init__.py":                           "",
+			"GitBundle_Client-1.0.0rc1.dist-info/METADATA": metadataContent,
+			"GitBundle_Client-1.0.0rc1.dist-info/WHEEL":    wheelContent,
+		})
+
+		p, err := ParseWheel(data, data.Size())
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+		assert.Equal(t, packageName, p.Name)
+		assert.Equal(t, packageVersion, p.Version)
+		assert.Equal(t, []*WheelTag{
+			{Python: "py2", ABI: "none", Platform: "any"},
+			{Python: "py3", ABI: "none", Platform: "any"},
+		}, p.Metadata.WheelTags)
+	})
+}
+
+func TestParseSdist(t *testing.T) {
+	t.Run("TarGz", func(t *testing.T) {
+		data := createTarGz(map[string]string{"client-1.0.0rc1/src/PKG-INFO": "invalid"})
+		p, err := ParseSdistTarGz(data)
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrMissingMetadataFile)
+
+		data = createTarGz(map[string]string{"client-1.0.0rc1/PKG-INFO": metadataContent})
+		p, err = ParseSdistTarGz(data)
+		assert.NoError(t, err)
+		assert.Equal(t, packageName, p.Name)
+		assert.Empty(t, p.Metadata.WheelTags)
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		data := createZip(map[string]string{"PKG-INFO": "invalid"})
+		p, err := ParseSdistZip(data, data.Size())
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrMissingMetadataFile)
+
+		data = createZip(map[string]string{"client-1.0.0rc1/PKG-INFO": metadataContent})
+		p, err = ParseSdistZip(data, data.Size())
+		assert.NoError(t, err)
+		assert.Equal(t, packageName, p.Name)
+	})
+}
+
+func TestParseMetadataFile(t *testing.T) {
+	t.Run("InvalidName", func(t *testing.T) {
+		for _, name := range []string{"", "-client", "client!"} {
+			p, err := ParseMetadataFile(strings.NewReader("Name: " + name + "\nVersion: 1.0\n"))
+			assert.Nil(t, p)
+			assert.ErrorIs(t, err, ErrInvalidName)
+		}
+	})
+
+	t.Run("InvalidVersion", func(t *testing.T) {
+		for _, version := range []string{"", "1.0.0-foo", "latest"} {
+			p, err := ParseMetadataFile(strings.NewReader("Name: client\nVersion: " + version + "\n"))
+			assert.Nil(t, p)
+			assert.ErrorIs(t, err, ErrInvalidVersion)
+		}
+	})
+
+	t.Run("HeaderDescription", func(t *testing.T) {
+		p, err := ParseMetadataFile(strings.NewReader("Metadata-Version: 1.1\nName: client\nVersion: 1.0.post1\nDescription: First line\n        |second line\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "First line\nsecond line", p.Metadata.LongDescription)
+
+		// only the marker at the start of a folded line is removed
+		p, err = ParseMetadataFile(strings.NewReader("Metadata-Version: 1.1\nName: client\nVersion: 1.0\nDescription: a | b\n        |    indented |\n        plain\n        |\nSummary: summary\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "a | b\n    indented |\nplain", p.Metadata.LongDescription)
+		assert.Equal(t, "summary", p.Metadata.Summary)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		p, err := ParseMetadataFile(strings.NewReader(metadataContent))
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+
+		md := p.Metadata
+		assert.Equal(t, "2.1", md.MetadataVersion)
+		assert.Equal(t, summary, md.Summary)
+		assert.Equal(t, homepage, md.ProjectURL)
+		assert.Equal(t, "GitBundle Authors", md.Author)
+		assert.Equal(t, "no.reply@gitbundle.com", md.AuthorEmail)
+		assert.Equal(t, "MIT", md.License)
+		assert.Equal(t, ">=3.7", md.RequiresPython)
+		assert.Equal(t, "text/markdown", md.LongDescriptionContent)
+		assert.Equal(t, "# Client\n\nLong description", md.LongDescription)
+		assert.Equal(t, md.LongDescription, md.Description)
+		assert.Equal(t, []string{"git", "client"}, md.Keywords)
+		assert.Equal(t, map[string]string{"Source": "https://gitbundle.com/client"}, md.ProjectURLs)
+		assert.Equal(t, []string{"Programming Language :: Python :: 3", "License :: OSI Approved :: MIT License"}, md.Classifiers)
+		assert.Equal(t, []string{"test"}, md.ProvidesExtra)
+		assert.Equal(t, []*Requirement{
+			{Name: "requests", Extras: []string{"security", "socks"}, Specifier: ">=2.8.1"},
+			{Name: "importlib-metadata", Marker: `python_version < "3.8"`},
+			{Name: "pytest", Specifier: ">=7,<8", Marker: `extra == "test"`},
+		}, md.RequiresDist)
+	})
+}
+
+func TestRequirement(t *testing.T) {
+	assert.Nil(t, ParseRequirement(";"))
+
+	r := ParseRequirement(`requests [security] >= 2.8.1, == 2.8.* ; python_version < "2.7"`)
+	assert.Equal(t, &Requirement{Name: "requests", Extras: []string{"security"}, Specifier: ">=2.8.1,==2.8.*", Marker: `python_version < "2.7"`}, r)
+	assert.Equal(t, `requests[security] >=2.8.1,==2.8.*; python_version < "2.7"`, r.String())
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "gitbundle-client", NormalizeName("GitBundle_Client"))
+	assert.Equal(t, "a-b-c", NormalizeName("a.-_b__c"))
+}
+
+func TestParseWheelFilename(t *testing.T) {
+	for _, invalid := range []string{"client-1.0.tar.gz", "client-1.0-py3-none.whl", "client-latest-py3-none-any.whl"} {
+		wf, err := ParseWheelFilename(invalid)
+		assert.Nil(t, wf)
+		assert.ErrorIs(t, err, ErrInvalidFilename)
+	}
+
+	wf, err := ParseWheelFilename("client-1.0-1-py2.py3-none-manylinux1_x86_64.manylinux2014_x86_64.whl")
+	assert.NoError(t, err)
+	assert.Equal(t, "client", wf.Name)
+	assert.Equal(t, "1.0", wf.Version)
+	assert.Equal(t, "1", wf.BuildTag)
+	tags := make([]string, 0, len(wf.Tags))
+	for _, tag := range wf.Tags {
+		tags = append(tags, tag.String())
+	}
+	assert.Equal(t, []string{
+		"py2-none-manylinux1_x86_64",
+		"py2-none-manylinux2014_x86_64",
+		"py3-none-manylinux1_x86_64",
+		"py3-none-manylinux2014_x86_64",
+	}, tags)
+}
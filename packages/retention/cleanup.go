@@ -0,0 +1,145 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package retention
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+)
+
+// BlobDeleter deletes blobs from the content store, it is implemented by packages.ContentStore
+type BlobDeleter interface {
+	Delete(key packages.BlobHash256Key) error
+}
+
+// BlobReferenceCounter returns the number of files of all packages which reference the blob
+type BlobReferenceCounter func(key packages.BlobHash256Key) (int64, error)
+
+// VersionDeleter removes the version of the package and its file references from the database
+type VersionDeleter func(p *Package, pv *PackageVersion) error
+
+// Removal is a version which is removed by a rule
+type Removal struct {
+	Package *Package
+	Version *PackageVersion
+	Rule    *Rule
+}
+
+// Plan lists the versions and blobs a cleanup removes
+type Plan struct {
+	Removals []*Removal
+	// Blobs are the blobs which are not referenced anymore after the versions are removed.
+	// Execute only deletes the blobs which are still unreferenced at that time.
+	Blobs []packages.BlobHash256Key
+}
+
+// Cleaner applies the cleanup rules to packages
+type Cleaner struct {
+	Rules           []*Rule
+	CountReferences BlobReferenceCounter
+	DeleteVersion   VersionDeleter
+	Store           BlobDeleter
+}
+
+// NewCleaner creates a cleaner which deletes unreferenced blobs from the content store
+func NewCleaner(rules []*Rule, countReferences BlobReferenceCounter, deleteVersion VersionDeleter, store *packages.ContentStore) *Cleaner {
+	return &Cleaner{
+		Rules:           rules,
+		CountReferences: countReferences,
+		DeleteVersion:   deleteVersion,
+		Store:           store,
+	}
+}
+
+// ruleFor returns the first rule for the package type
+func (c *Cleaner) ruleFor(packageType string) *Rule {
+	for _, r := range c.Rules {
+		if r.Type == packageType {
+			return r
+		}
+	}
+	return nil
+}
+
+// Plan evaluates the rules without removing anything.
+// A blob is only removed if all files referencing it belong to removed versions,
+// blobs shared with other versions or packages are kept.
+func (c *Cleaner) Plan(pkgs []*Package, now time.Time) (*Plan, error) {
+	plan := &Plan{}
+	removedReferences := make(map[packages.BlobHash256Key]int64)
+
+	for _, p := range pkgs {
+		rule := c.ruleFor(p.Type)
+		if rule == nil {
+			continue
+		}
+
+		versions, err := rule.Evaluate(p.Versions, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, pv := range versions {
+			plan.Removals = append(plan.Removals, &Removal{Package: p, Version: pv, Rule: rule})
+			for _, key := range pv.Blobs {
+				removedReferences[key]++
+			}
+		}
+	}
+
+	for key, removed := range removedReferences {
+		total, err := c.CountReferences(key)
+		if err != nil {
+			return nil, err
+		}
+		if total <= removed {
+			plan.Blobs = append(plan.Blobs, key)
+		}
+	}
+	sort.Slice(plan.Blobs, func(i, j int) bool {
+		return plan.Blobs[i] < plan.Blobs[j]
+	})
+
+	return plan, nil
+}
+
+// Execute removes the versions of the plan first and the blobs afterwards,
+// so a failed version removal never leaves a version with missing blobs.
+// The references of a blob are counted again before it is deleted, a blob which
+// got referenced by a file uploaded since the plan was made is kept.
+func (c *Cleaner) Execute(plan *Plan) error {
+	for _, r := range plan.Removals {
+		if err := c.DeleteVersion(r.Package, r.Version); err != nil {
+			return err
+		}
+	}
+	for _, key := range plan.Blobs {
+		count, err := c.CountReferences(key)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := c.Store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run plans the cleanup and executes it unless dryRun is set
+func (c *Cleaner) Run(pkgs []*Package, now time.Time, dryRun bool) (*Plan, error) {
+	plan, err := c.Plan(pkgs, now)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return plan, nil
+	}
+	return plan, c.Execute(plan)
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+	"github.com/gitbundle/modules/packages/versioning"
+)
+
+var (
+	// ErrInvalidPattern indicates a keep or remove pattern which is not a valid regular expression
+	ErrInvalidPattern = errors.New("pattern is invalid")
+	// ErrMissingRemoveCriterion indicates a rule without remove criterion which doesn't opt in to RemoveAll
+	ErrMissingRemoveCriterion = errors.New("rule has no remove criterion")
+)
+
+// PackageVersion is a stored version of a package
+type PackageVersion struct {
+	ID      int64
+	Version string
+	Created time.Time
+	// Blobs are the content keys of the version files, a key is listed once per file
+	Blobs []packages.BlobHash256Key
+}
+
+// Package is a package with all its stored versions
+type Package struct {
+	ID       int64
+	Type     string
+	Name     string
+	Versions []*PackageVersion
+}
+
+// Rule decides which versions of the packages of a type are removed.
+// A version is removed if it is not kept and matches all configured remove criteria.
+// A rule without remove criteria is invalid unless RemoveAll is set.
+type Rule struct {
+	// Type is the package type the rule applies to
+	Type string
+	// KeepCount keeps the newest versions according to the version semantics of the type, 0 keeps none
+	KeepCount int
+	// KeepPattern keeps versions matching the regular expression
+	KeepPattern string
+	// RemoveOlderThan removes versions created before that duration, 0 disables the criterion
+	RemoveOlderThan time.Duration
+	// RemovePattern removes versions matching the regular expression, like .+-SNAPSHOT
+	RemovePattern string
+	// RemoveAll removes all versions which are not kept, it must be set if there is no other remove criterion
+	RemoveAll bool
+
+	keepRegexp   *regexp.Regexp
+	removeRegexp *regexp.Regexp
+}
+
+// compilePattern anchors the pattern so it must match the whole version
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(`(?i)\A(?:` + pattern + `)\z`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	return re, nil
+}
+
+// Validate checks that the rule has a remove criterion and compiles its patterns
+func (r *Rule) Validate() error {
+	if !r.RemoveAll && r.RemoveOlderThan <= 0 && r.RemovePattern == "" {
+		return ErrMissingRemoveCriterion
+	}
+
+	var err error
+	if r.keepRegexp, err = compilePattern(r.KeepPattern); err != nil {
+		return err
+	}
+	r.removeRegexp, err = compilePattern(r.RemovePattern)
+	return err
+}
+
+// SortVersions sorts the versions newest first with the version semantics of the package type.
+// Versions which can't be compared are ordered by their creation time.
+func SortVersions(packageType string, versions []*PackageVersion) {
+	scheme := versioning.ForPackageType(packageType)
+
+	parsed := make(map[*PackageVersion]versioning.Version, len(versions))
+	for _, pv := range versions {
+		if v, err := scheme.ParseVersion(pv.Version); err == nil {
+			parsed[pv] = v
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, oki := parsed[versions[i]]
+		vj, okj := parsed[versions[j]]
+		if oki && okj {
+			if result := vi.Compare(vj); result != 0 {
+				return result > 0
+			}
+		}
+		return versions[i].Created.After(versions[j].Created)
+	})
+}
+
+// Evaluate returns the versions which are removed by the rule, newest first.
+// The passed versions are not modified.
+func (r *Rule) Evaluate(versions []*PackageVersion, now time.Time) ([]*PackageVersion, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	sorted := make([]*PackageVersion, len(versions))
+	copy(sorted, versions)
+	SortVersions(r.Type, sorted)
+
+	var removed []*PackageVersion
+	for i, pv := range sorted {
+		if i < r.KeepCount {
+			continue
+		}
+		if r.keepRegexp != nil && r.keepRegexp.MatchString(pv.Version) {
+			continue
+		}
+		if r.RemoveOlderThan > 0 && pv.Created.After(now.Add(-r.RemoveOlderThan)) {
+			continue
+		}
+		if r.removeRegexp != nil && !r.removeRegexp.MatchString(pv.Version) {
+			continue
+		}
+		removed = append(removed, pv)
+	}
+	return removed, nil
+}
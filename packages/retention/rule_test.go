@@ -0,0 +1,203 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package retention
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func createVersions(versions ...string) []*PackageVersion {
+	result := make([]*PackageVersion, 0, len(versions))
+	for i, v := range versions {
+		result = append(result, &PackageVersion{
+			ID:      int64(i + 1),
+			Version: v,
+			Created: now.Add(-time.Duration(len(versions)-i) * 24 * time.Hour),
+		})
+	}
+	return result
+}
+
+func versionNames(versions []*PackageVersion) []string {
+	result := make([]string, 0, len(versions))
+	for _, pv := range versions {
+		result = append(result, pv.Version)
+	}
+	return result
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	t.Run("MissingRemoveCriterion", func(t *testing.T) {
+		r := &Rule{Type: "npm", KeepCount: 1}
+		removed, err := r.Evaluate(createVersions("1.0.0", "1.1.0"), now)
+		assert.Nil(t, removed)
+		assert.ErrorIs(t, err, ErrMissingRemoveCriterion)
+		assert.ErrorIs(t, (&Rule{Type: "npm"}).Validate(), ErrMissingRemoveCriterion)
+	})
+
+	t.Run("InvalidPattern", func(t *testing.T) {
+		r := &Rule{Type: "npm", KeepPattern: "(", RemoveAll: true}
+		removed, err := r.Evaluate(createVersions("1.0.0"), now)
+		assert.Nil(t, removed)
+		assert.ErrorIs(t, err, ErrInvalidPattern)
+	})
+
+	t.Run("KeepCount", func(t *testing.T) {
+		// created in upload order, the semantic order differs
+		versions := createVersions("1.10.0", "1.2.0", "1.9.0", "0.1.0")
+		r := &Rule{Type: "npm", KeepCount: 2, RemoveAll: true}
+		removed, err := r.Evaluate(versions, now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1.2.0", "0.1.0"}, versionNames(removed))
+		assert.Equal(t, "1.10.0", versions[0].Version)
+	})
+
+	t.Run("KeepPattern", func(t *testing.T) {
+		r := &Rule{Type: "npm", KeepCount: 1, KeepPattern: `1\..*`, RemoveAll: true}
+		removed, err := r.Evaluate(createVersions("0.9.0", "1.0.0", "1.1.0", "2.0.0", "2.1.0"), now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2.0.0", "0.9.0"}, versionNames(removed))
+	})
+
+	t.Run("RemoveOlderThan", func(t *testing.T) {
+		r := &Rule{Type: "npm", RemoveOlderThan: 84 * time.Hour}
+		removed, err := r.Evaluate(createVersions("1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0"), now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1.1.0", "1.0.0"}, versionNames(removed))
+	})
+
+	t.Run("RemovePattern", func(t *testing.T) {
+		r := &Rule{Type: "maven", KeepCount: 1, RemovePattern: `.+-SNAPSHOT`}
+		removed, err := r.Evaluate(createVersions("1.0-SNAPSHOT", "1.0", "1.1-snapshot", "1.1-SNAPSHOT-1"), now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1.1-snapshot", "1.0-SNAPSHOT"}, versionNames(removed))
+	})
+
+	t.Run("PEP440", func(t *testing.T) {
+		r := &Rule{Type: "pypi", KeepCount: 2, RemovePattern: `.*dev.*`}
+		removed, err := r.Evaluate(createVersions("1.0", "1.1.dev1", "1.1rc1", "1.1.dev2", "1.1"), now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1.1.dev2", "1.1.dev1"}, versionNames(removed))
+	})
+
+	t.Run("Unparsable", func(t *testing.T) {
+		r := &Rule{Type: "container", KeepCount: 1, RemoveAll: true}
+		removed, err := r.Evaluate(createVersions("latest", "main", "nightly"), now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"main", "latest"}, versionNames(removed))
+	})
+}
+
+type memoryStore struct {
+	deleted []packages.BlobHash256Key
+}
+
+func (s *memoryStore) Delete(key packages.BlobHash256Key) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func TestCleaner(t *testing.T) {
+	createPackage := func(typ, name string, blobs map[string][]packages.BlobHash256Key, versions ...string) *Package {
+		p := &Package{Type: typ, Name: name, Versions: createVersions(versions...)}
+		for _, pv := range p.Versions {
+			pv.Blobs = blobs[pv.Version]
+		}
+		return p
+	}
+
+	npm := createPackage("npm", "client", map[string][]packages.BlobHash256Key{
+		"1.0.0": {"a"},
+		"1.1.0": {"b", "shared"},
+		"2.0.0": {"c"},
+	}, "1.0.0", "1.1.0", "2.0.0")
+	maven := createPackage("maven", "com.gitbundle:client", map[string][]packages.BlobHash256Key{
+		"1.0": {"shared"},
+	}, "1.0")
+	generic := createPackage("generic", "tool", nil, "1.0.0")
+
+	references := map[packages.BlobHash256Key]int64{"a": 1, "b": 1, "c": 1, "shared": 2}
+
+	var deletedVersions []string
+	store := &memoryStore{}
+	c := &Cleaner{
+		Rules: []*Rule{
+			{Type: "npm", KeepCount: 1, RemoveAll: true},
+			{Type: "maven", KeepCount: 5, RemoveAll: true},
+		},
+		CountReferences: func(key packages.BlobHash256Key) (int64, error) {
+			return references[key], nil
+		},
+		DeleteVersion: func(p *Package, pv *PackageVersion) error {
+			deletedVersions = append(deletedVersions, p.Name+"@"+pv.Version)
+			for _, key := range pv.Blobs {
+				references[key]--
+			}
+			return nil
+		},
+		Store: store,
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		plan, err := c.Run([]*Package{npm, maven, generic}, now, true)
+		assert.NoError(t, err)
+		assert.Len(t, plan.Removals, 2)
+		assert.Equal(t, "1.1.0", plan.Removals[0].Version.Version)
+		assert.Equal(t, "1.0.0", plan.Removals[1].Version.Version)
+		assert.Equal(t, npm, plan.Removals[0].Package)
+		assert.Equal(t, []packages.BlobHash256Key{"a", "b"}, plan.Blobs)
+		assert.Empty(t, deletedVersions)
+		assert.Empty(t, store.deleted)
+	})
+
+	t.Run("Execute", func(t *testing.T) {
+		plan, err := c.Run([]*Package{npm, maven, generic}, now, false)
+		assert.NoError(t, err)
+		assert.Len(t, plan.Removals, 2)
+		assert.Equal(t, []string{"client@1.1.0", "client@1.0.0"}, deletedVersions)
+		assert.Equal(t, []packages.BlobHash256Key{"a", "b"}, store.deleted)
+	})
+
+	t.Run("BlobReferencedSincePlan", func(t *testing.T) {
+		references = map[packages.BlobHash256Key]int64{"a": 1, "b": 1, "c": 1, "shared": 2}
+		deletedVersions = nil
+		store.deleted = nil
+
+		plan, err := c.Plan([]*Package{npm}, now)
+		assert.NoError(t, err)
+		assert.Equal(t, []packages.BlobHash256Key{"a", "b"}, plan.Blobs)
+
+		// a file uploaded after planning reuses the blob
+		references["b"]++
+		assert.NoError(t, c.Execute(plan))
+		assert.Equal(t, []packages.BlobHash256Key{"a"}, store.deleted)
+	})
+
+	t.Run("SharedBlobRemoved", func(t *testing.T) {
+		c.Rules = []*Rule{{Type: "npm", KeepCount: 1, RemoveAll: true}, {Type: "maven", RemoveAll: true}}
+		plan, err := c.Plan([]*Package{npm, maven}, now)
+		assert.NoError(t, err)
+		assert.Len(t, plan.Removals, 3)
+		assert.Equal(t, []packages.BlobHash256Key{"a", "b", "shared"}, plan.Blobs)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		c.CountReferences = func(packages.BlobHash256Key) (int64, error) {
+			return 0, errors.New("database error")
+		}
+		plan, err := c.Plan([]*Package{npm}, now)
+		assert.Nil(t, plan)
+		assert.Error(t, err)
+	})
+}
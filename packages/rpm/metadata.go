@@ -0,0 +1,477 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gitbundle/modules/validation"
+)
+
+// https://rpm-software-management.github.io/rpm/manual/format.html
+const (
+	leadSize = 96
+
+	// maxHeaderSize limits the memory used for a single header
+	maxHeaderSize = 64 << 20
+)
+
+var (
+	leadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	headerMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+// header tags
+const (
+	tagName            = 1000
+	tagVersion         = 1001
+	tagRelease         = 1002
+	tagEpoch           = 1003
+	tagSummary         = 1004
+	tagDescription     = 1005
+	tagBuildTime       = 1006
+	tagBuildHost       = 1007
+	tagSize            = 1009
+	tagVendor          = 1011
+	tagLicense         = 1014
+	tagPackager        = 1015
+	tagGroup           = 1016
+	tagURL             = 1020
+	tagArch            = 1022
+	tagFileModes       = 1030
+	tagSourceRPM       = 1044
+	tagArchiveSize     = 1046
+	tagProvideName     = 1047
+	tagRequireFlags    = 1048
+	tagRequireName     = 1049
+	tagRequireVersion  = 1050
+	tagConflictFlags   = 1053
+	tagConflictName    = 1054
+	tagConflictVersion = 1055
+	tagObsoleteName    = 1090
+	tagProvideFlags    = 1112
+	tagProvideVersion  = 1113
+	tagObsoleteFlags   = 1114
+	tagObsoleteVersion = 1115
+	tagDirIndexes      = 1116
+	tagBaseNames       = 1117
+	tagDirNames        = 1118
+	tagLongSize        = 5009
+)
+
+// header entry types
+const (
+	typeChar        = 1
+	typeInt8        = 2
+	typeInt16       = 3
+	typeInt32       = 4
+	typeInt64       = 5
+	typeString      = 6
+	typeBin         = 7
+	typeStringArray = 8
+	typeI18NString  = 9
+)
+
+// dependency flags
+const (
+	senseLess    = 1 << 1
+	senseGreater = 1 << 2
+	senseEqual   = 1 << 3
+	senseRPMLib  = 1 << 24
+)
+
+var (
+	// ErrInvalidPackage indicates a file which is no valid RPM package
+	ErrInvalidPackage = errors.New("package file is invalid")
+	// ErrInvalidName indicates an invalid package name
+	ErrInvalidName = errors.New("package name is invalid")
+	// ErrInvalidVersion indicates an invalid package version
+	ErrInvalidVersion = errors.New("package version is invalid")
+)
+
+// Package represents a RPM package
+type Package struct {
+	Name     string
+	Version  string
+	Metadata *Metadata
+}
+
+// Metadata represents the metadata of a RPM package
+type Metadata struct {
+	Epoch        string `json:"epoch,omitempty"`
+	Release      string `json:"release"`
+	Architecture string `json:"architecture"`
+	Summary      string `json:"summary,omitempty"`
+	Description  string `json:"description,omitempty"`
+	License      string `json:"license,omitempty"`
+	Vendor       string `json:"vendor,omitempty"`
+	Packager     string `json:"packager,omitempty"`
+	Group        string `json:"group,omitempty"`
+	ProjectURL   string `json:"project_url,omitempty"`
+	BuildHost    string `json:"build_host,omitempty"`
+	BuildTime    uint64 `json:"build_time,omitempty"`
+	SourceRPM    string `json:"source_rpm,omitempty"`
+
+	InstalledSize uint64 `json:"installed_size,omitempty"`
+	ArchiveSize   uint64 `json:"archive_size,omitempty"`
+	// HeaderStart and HeaderEnd are the byte offsets of the main header in the package file
+	HeaderStart int64 `json:"header_start"`
+	HeaderEnd   int64 `json:"header_end"`
+
+	Provides  []*Entry `json:"provides,omitempty"`
+	Requires  []*Entry `json:"requires,omitempty"`
+	Conflicts []*Entry `json:"conflicts,omitempty"`
+	Obsoletes []*Entry `json:"obsoletes,omitempty"`
+	Files     []*File  `json:"files,omitempty"`
+}
+
+// Entry represents a provides/requires/conflicts/obsoletes entry
+type Entry struct {
+	Name    string `json:"name" xml:"name,attr"`
+	Flags   string `json:"flags,omitempty" xml:"flags,attr,omitempty"`
+	Epoch   string `json:"epoch,omitempty" xml:"epoch,attr,omitempty"`
+	Version string `json:"version,omitempty" xml:"ver,attr,omitempty"`
+	Release string `json:"release,omitempty" xml:"rel,attr,omitempty"`
+}
+
+// File represents a file of the package
+type File struct {
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir,omitempty"`
+}
+
+// FullVersion returns the [epoch:]version-release string of the package
+func (p *Package) FullVersion() string {
+	v := p.Version + "-" + p.Metadata.Release
+	if p.Metadata.Epoch != "" && p.Metadata.Epoch != "0" {
+		v = p.Metadata.Epoch + ":" + v
+	}
+	return v
+}
+
+// Filename returns the canonical file name of the package
+func (p *Package) Filename() string {
+	return p.Name + "-" + p.Version + "-" + p.Metadata.Release + "." + p.Metadata.Architecture + ".rpm"
+}
+
+type headerEntry struct {
+	tag    uint32
+	typ    uint32
+	offset uint32
+	count  uint32
+}
+
+type header struct {
+	entries map[uint32]headerEntry
+	store   []byte
+}
+
+// ParsePackage parses the lead and headers of a RPM package
+func ParsePackage(r io.Reader) (*Package, error) {
+	lead := make([]byte, leadSize)
+	if _, err := io.ReadFull(r, lead); err != nil || !bytes.Equal(lead[0:4], leadMagic) {
+		return nil, ErrInvalidPackage
+	}
+
+	// the signature header is only used to verify the package, which is done by the clients
+	_, sigSize, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// the signature header is padded to a multiple of 8 bytes
+	padding := (8 - sigSize%8) % 8
+	if _, err := io.CopyN(io.Discard, r, padding); err != nil {
+		return nil, ErrInvalidPackage
+	}
+
+	headerStart := leadSize + sigSize + padding
+	h, size, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := h.toPackage()
+	if err != nil {
+		return nil, err
+	}
+	p.Metadata.HeaderStart = headerStart
+	p.Metadata.HeaderEnd = headerStart + size
+	return p, nil
+}
+
+// readHeader reads a header structure and returns it with its size in bytes
+func readHeader(r io.Reader) (*header, int64, error) {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil || !bytes.Equal(intro[0:4], headerMagic) {
+		return nil, 0, ErrInvalidPackage
+	}
+
+	count := int64(binary.BigEndian.Uint32(intro[8:12]))
+	storeSize := int64(binary.BigEndian.Uint32(intro[12:16]))
+	if count*16+storeSize > maxHeaderSize {
+		return nil, 0, ErrInvalidPackage
+	}
+
+	index := make([]byte, count*16)
+	if _, err := io.ReadFull(r, index); err != nil {
+		return nil, 0, ErrInvalidPackage
+	}
+	store := make([]byte, storeSize)
+	if _, err := io.ReadFull(r, store); err != nil {
+		return nil, 0, ErrInvalidPackage
+	}
+
+	h := &header{
+		entries: make(map[uint32]headerEntry, count),
+		store:   store,
+	}
+	for i := int64(0); i < count; i++ {
+		b := index[i*16 : i*16+16]
+		e := headerEntry{
+			tag:    binary.BigEndian.Uint32(b[0:4]),
+			typ:    binary.BigEndian.Uint32(b[4:8]),
+			offset: binary.BigEndian.Uint32(b[8:12]),
+			count:  binary.BigEndian.Uint32(b[12:16]),
+		}
+		if int64(e.offset) > storeSize {
+			return nil, 0, ErrInvalidPackage
+		}
+		h.entries[e.tag] = e
+	}
+
+	return h, 16 + count*16 + storeSize, nil
+}
+
+// strings returns the values of a string, string array or i18n string entry
+func (h *header) strings(tag uint32) []string {
+	e, ok := h.entries[tag]
+	if !ok || (e.typ != typeString && e.typ != typeStringArray && e.typ != typeI18NString) {
+		return nil
+	}
+
+	count := int(e.count)
+	if e.typ == typeString {
+		count = 1
+	}
+
+	data := h.store[e.offset:]
+	// every value needs at least its terminating null byte
+	if count > len(data) {
+		return nil
+	}
+
+	values := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		idx := bytes.IndexByte(data, 0)
+		if idx == -1 {
+			return nil
+		}
+		values = append(values, string(data[:idx]))
+		data = data[idx+1:]
+	}
+	return values
+}
+
+func (h *header) string(tag uint32) string {
+	// i18n strings contain one value per locale, the first is the default one
+	if values := h.strings(tag); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ints returns the values of an integer entry
+func (h *header) ints(tag uint32) []uint64 {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil
+	}
+
+	var size int
+	switch e.typ {
+	case typeChar, typeInt8:
+		size = 1
+	case typeInt16:
+		size = 2
+	case typeInt32:
+		size = 4
+	case typeInt64:
+		size = 8
+	default:
+		return nil
+	}
+
+	data := h.store[e.offset:]
+	if len(data) < size*int(e.count) {
+		return nil
+	}
+
+	values := make([]uint64, 0, e.count)
+	for i := 0; i < int(e.count); i++ {
+		b := data[i*size : i*size+size]
+		switch size {
+		case 1:
+			values = append(values, uint64(b[0]))
+		case 2:
+			values = append(values, uint64(binary.BigEndian.Uint16(b)))
+		case 4:
+			values = append(values, uint64(binary.BigEndian.Uint32(b)))
+		case 8:
+			values = append(values, binary.BigEndian.Uint64(b))
+		}
+	}
+	return values
+}
+
+func (h *header) int(tag uint32) (uint64, bool) {
+	if values := h.ints(tag); len(values) > 0 {
+		return values[0], true
+	}
+	return 0, false
+}
+
+func (h *header) toPackage() (*Package, error) {
+	p := &Package{
+		Name:    h.string(tagName),
+		Version: h.string(tagVersion),
+		Metadata: &Metadata{
+			Release:      h.string(tagRelease),
+			Architecture: h.string(tagArch),
+			Summary:      h.string(tagSummary),
+			Description:  h.string(tagDescription),
+			License:      h.string(tagLicense),
+			Vendor:       h.string(tagVendor),
+			Packager:     h.string(tagPackager),
+			Group:        h.string(tagGroup),
+			BuildHost:    h.string(tagBuildHost),
+			SourceRPM:    h.string(tagSourceRPM),
+		},
+	}
+
+	if p.Name == "" || strings.ContainsAny(p.Name, " /") {
+		return nil, ErrInvalidName
+	}
+	if p.Version == "" || strings.ContainsAny(p.Version, " -/") {
+		return nil, ErrInvalidVersion
+	}
+	if p.Metadata.Architecture == "" {
+		// source packages don't have an architecture header
+		p.Metadata.Architecture = "src"
+	}
+
+	if url := h.string(tagURL); validation.IsValidURL(url) {
+		p.Metadata.ProjectURL = url
+	}
+	if epoch, ok := h.int(tagEpoch); ok {
+		p.Metadata.Epoch = strconv.FormatUint(epoch, 10)
+	}
+	p.Metadata.BuildTime, _ = h.int(tagBuildTime)
+	p.Metadata.ArchiveSize, _ = h.int(tagArchiveSize)
+	if size, ok := h.int(tagLongSize); ok {
+		p.Metadata.InstalledSize = size
+	} else {
+		p.Metadata.InstalledSize, _ = h.int(tagSize)
+	}
+
+	p.Metadata.Provides = h.dependencies(tagProvideName, tagProvideFlags, tagProvideVersion)
+	p.Metadata.Requires = h.dependencies(tagRequireName, tagRequireFlags, tagRequireVersion)
+	p.Metadata.Conflicts = h.dependencies(tagConflictName, tagConflictFlags, tagConflictVersion)
+	p.Metadata.Obsoletes = h.dependencies(tagObsoleteName, tagObsoleteFlags, tagObsoleteVersion)
+	p.Metadata.Files = h.files()
+
+	return p, nil
+}
+
+// dependencies builds the dependency entries of the name, flags and version tags
+func (h *header) dependencies(nameTag, flagsTag, versionTag uint32) []*Entry {
+	names := h.strings(nameTag)
+	flags := h.ints(flagsTag)
+	versions := h.strings(versionTag)
+
+	entries := make([]*Entry, 0, len(names))
+	for i, name := range names {
+		e := &Entry{Name: name}
+
+		var flag uint64
+		if i < len(flags) {
+			flag = flags[i]
+		}
+		// dependencies on rpmlib features are checked by rpm itself
+		if flag&senseRPMLib != 0 {
+			continue
+		}
+
+		switch flag & (senseLess | senseGreater | senseEqual) {
+		case senseEqual:
+			e.Flags = "EQ"
+		case senseLess:
+			e.Flags = "LT"
+		case senseGreater:
+			e.Flags = "GT"
+		case senseLess | senseEqual:
+			e.Flags = "LE"
+		case senseGreater | senseEqual:
+			e.Flags = "GE"
+		}
+
+		if e.Flags != "" && i < len(versions) {
+			e.Epoch, e.Version, e.Release = splitEVR(versions[i])
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries
+}
+
+// splitEVR splits a [epoch:]version[-release] string
+func splitEVR(evr string) (epoch, version, release string) {
+	epoch = "0"
+	if idx := strings.IndexByte(evr, ':'); idx != -1 {
+		epoch = evr[:idx]
+		evr = evr[idx+1:]
+	}
+	version = evr
+	if idx := strings.LastIndexByte(evr, '-'); idx != -1 {
+		version = evr[:idx]
+		release = evr[idx+1:]
+	}
+	return epoch, version, release
+}
+
+func (h *header) files() []*File {
+	baseNames := h.strings(tagBaseNames)
+	dirNames := h.strings(tagDirNames)
+	dirIndexes := h.ints(tagDirIndexes)
+	modes := h.ints(tagFileModes)
+
+	files := make([]*File, 0, len(baseNames))
+	for i, name := range baseNames {
+		if i >= len(dirIndexes) || int(dirIndexes[i]) >= len(dirNames) {
+			break
+		}
+		f := &File{
+			Path: path.Join(dirNames[dirIndexes[i]], name),
+		}
+		if i < len(modes) {
+			// S_IFDIR
+			f.IsDir = modes[i]&0o170000 == 0o040000
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	return files
+}
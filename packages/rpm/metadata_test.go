@@ -0,0 +1,208 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	packageName    = "gitbundle"
+	packageVersion = "1.0.0"
+	packageRelease = "1.el9"
+	projectURL     = "https://gitbundle.com"
+)
+
+type testEntry struct {
+	typ   uint32
+	value interface{}
+}
+
+// buildHeader encodes a header structure
+func buildHeader(entries map[uint32]testEntry) []byte {
+	tags := make([]int, 0, len(entries))
+	for tag := range entries {
+		tags = append(tags, int(tag))
+	}
+	sort.Ints(tags)
+
+	var index, store bytes.Buffer
+	for _, tag := range tags {
+		e := entries[uint32(tag)]
+		offset := store.Len()
+		count := 1
+		switch v := e.value.(type) {
+		case string:
+			store.WriteString(v)
+			store.WriteByte(0)
+		case []string:
+			for _, s := range v {
+				store.WriteString(s)
+				store.WriteByte(0)
+			}
+			count = len(v)
+		case []uint32:
+			for store.Len()%4 != 0 {
+				store.WriteByte(0)
+			}
+			offset = store.Len()
+			for _, i := range v {
+				binary.Write(&store, binary.BigEndian, i)
+			}
+			count = len(v)
+		case []uint16:
+			for store.Len()%2 != 0 {
+				store.WriteByte(0)
+			}
+			offset = store.Len()
+			for _, i := range v {
+				binary.Write(&store, binary.BigEndian, i)
+			}
+			count = len(v)
+		}
+		binary.Write(&index, binary.BigEndian, []uint32{uint32(tag), e.typ, uint32(offset), uint32(count)})
+	}
+
+	var buf bytes.Buffer
+	buf.Write(headerMagic)
+	buf.Write([]byte{0, 0, 0, 0})
+	binary.Write(&buf, binary.BigEndian, []uint32{uint32(len(tags)), uint32(store.Len())})
+	buf.Write(index.Bytes())
+	buf.Write(store.Bytes())
+	return buf.Bytes()
+}
+
+func createPackage(entries map[uint32]testEntry) []byte {
+	var buf bytes.Buffer
+
+	lead := make([]byte, leadSize)
+	copy(lead, leadMagic)
+	buf.Write(lead)
+
+	// a signature header with a size which needs padding
+	buf.Write(buildHeader(map[uint32]testEntry{1000: {typeBin, "abc"}}))
+	for buf.Len()%8 != 0 {
+		buf.WriteByte(0)
+	}
+
+	buf.Write(buildHeader(entries))
+	buf.WriteString("payload")
+	return buf.Bytes()
+}
+
+func validEntries() map[uint32]testEntry {
+	return map[uint32]testEntry{
+		tagName:            {typeString, packageName},
+		tagVersion:         {typeString, packageVersion},
+		tagRelease:         {typeString, packageRelease},
+		tagEpoch:           {typeInt32, []uint32{2}},
+		tagSummary:         {typeI18NString, []string{"Git hosting", "Git-Hosting"}},
+		tagDescription:     {typeI18NString, []string{"Description"}},
+		tagBuildTime:       {typeInt32, []uint32{1672628645}},
+		tagBuildHost:       {typeString, "builder"},
+		tagSize:            {typeInt32, []uint32{4096}},
+		tagLicense:         {typeString, "MIT"},
+		tagGroup:           {typeI18NString, []string{"Unspecified"}},
+		tagURL:             {typeString, projectURL},
+		tagArch:            {typeString, "x86_64"},
+		tagSourceRPM:       {typeString, "gitbundle-1.0.0-1.el9.src.rpm"},
+		tagProvideName:     {typeStringArray, []string{"gitbundle", "gitbundle(x86-64)"}},
+		tagProvideFlags:    {typeInt32, []uint32{senseEqual, senseEqual}},
+		tagProvideVersion:  {typeStringArray, []string{"2:1.0.0-1.el9", "2:1.0.0-1.el9"}},
+		tagRequireName:     {typeStringArray, []string{"git", "rpmlib(CompressedFileNames)", "/bin/sh"}},
+		tagRequireFlags:    {typeInt32, []uint32{senseGreater | senseEqual, senseRPMLib | senseLess | senseEqual, 0}},
+		tagRequireVersion:  {typeStringArray, []string{"2.30", "3.0.4-1", ""}},
+		tagBaseNames:       {typeStringArray, []string{"gitbundle", "gitbundle"}},
+		tagDirNames:        {typeStringArray, []string{"/usr/bin/", "/etc/"}},
+		tagDirIndexes:      {typeInt32, []uint32{0, 1}},
+		tagFileModes:       {typeInt16, []uint16{0o100755, 0o040755}},
+		tagObsoleteName:    {typeStringArray, []string{"gitbundle-old"}},
+		tagObsoleteFlags:   {typeInt32, []uint32{senseLess}},
+		tagObsoleteVersion: {typeStringArray, []string{"1.0"}},
+	}
+}
+
+func TestParsePackage(t *testing.T) {
+	t.Run("InvalidPackage", func(t *testing.T) {
+		p, err := ParsePackage(bytes.NewReader([]byte("dummy")))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidPackage)
+
+		data := createPackage(validEntries())
+		p, err = ParsePackage(bytes.NewReader(data[:200]))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidPackage)
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		entries := validEntries()
+		delete(entries, tagName)
+
+		p, err := ParsePackage(bytes.NewReader(createPackage(entries)))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidName)
+	})
+
+	t.Run("InvalidVersion", func(t *testing.T) {
+		entries := validEntries()
+		entries[tagVersion] = testEntry{typeString, "1.0-0"}
+
+		p, err := ParsePackage(bytes.NewReader(createPackage(entries)))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidVersion)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		data := createPackage(validEntries())
+
+		p, err := ParsePackage(bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+
+		assert.Equal(t, packageName, p.Name)
+		assert.Equal(t, packageVersion, p.Version)
+		assert.Equal(t, "2:1.0.0-1.el9", p.FullVersion())
+		assert.Equal(t, "gitbundle-1.0.0-1.el9.x86_64.rpm", p.Filename())
+
+		md := p.Metadata
+		assert.Equal(t, "2", md.Epoch)
+		assert.Equal(t, packageRelease, md.Release)
+		assert.Equal(t, "x86_64", md.Architecture)
+		assert.Equal(t, "Git hosting", md.Summary)
+		assert.Equal(t, "Description", md.Description)
+		assert.Equal(t, "MIT", md.License)
+		assert.Equal(t, "Unspecified", md.Group)
+		assert.Equal(t, projectURL, md.ProjectURL)
+		assert.Equal(t, "builder", md.BuildHost)
+		assert.EqualValues(t, 1672628645, md.BuildTime)
+		assert.EqualValues(t, 4096, md.InstalledSize)
+		assert.Equal(t, "gitbundle-1.0.0-1.el9.src.rpm", md.SourceRPM)
+
+		// lead (96) + signature header (16 + 16 + 3) padded to 136
+		assert.EqualValues(t, 136, md.HeaderStart)
+		assert.EqualValues(t, len(data)-len("payload"), md.HeaderEnd)
+
+		assert.Equal(t, []*Entry{
+			{Name: "gitbundle", Flags: "EQ", Epoch: "2", Version: "1.0.0", Release: "1.el9"},
+			{Name: "gitbundle(x86-64)", Flags: "EQ", Epoch: "2", Version: "1.0.0", Release: "1.el9"},
+		}, md.Provides)
+		assert.Equal(t, []*Entry{
+			{Name: "git", Flags: "GE", Epoch: "0", Version: "2.30"},
+			{Name: "/bin/sh"},
+		}, md.Requires)
+		assert.Empty(t, md.Conflicts)
+		assert.Equal(t, []*Entry{{Name: "gitbundle-old", Flags: "LT", Epoch: "0", Version: "1.0"}}, md.Obsoletes)
+		assert.Equal(t, []*File{
+			{Path: "/usr/bin/gitbundle"},
+			{Path: "/etc/gitbundle", IsDir: true},
+		}, md.Files)
+	})
+}
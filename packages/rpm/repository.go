@@ -0,0 +1,305 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// PackageIndexEntry represents a package file listed in the primary.xml file
+type PackageIndexEntry struct {
+	Package *Package
+	// Location is the path of the package file relative to the repository root
+	Location string
+	Size     int64
+	SHA256   string
+	// FileTime is the upload time of the package file
+	FileTime time.Time
+}
+
+// NewPackageIndexEntry creates the index entry of a package file with the sums of a HashedBuffer
+func NewPackageIndexEntry(p *Package, location string, buf packages.HashedSizeReader, fileTime time.Time) *PackageIndexEntry {
+	_, _, hashSHA256, _ := buf.Sums()
+	return &PackageIndexEntry{
+		Package:  p,
+		Location: location,
+		Size:     buf.Size(),
+		SHA256:   hex.EncodeToString(hashSHA256),
+		FileTime: fileTime,
+	}
+}
+
+type primaryChecksum struct {
+	Type  string `xml:"type,attr"`
+	PkgID string `xml:"pkgid,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type primaryVersion struct {
+	Epoch   string `xml:"epoch,attr"`
+	Version string `xml:"ver,attr"`
+	Release string `xml:"rel,attr"`
+}
+
+type primaryTime struct {
+	File  int64  `xml:"file,attr"`
+	Build uint64 `xml:"build,attr"`
+}
+
+type primarySize struct {
+	Package   int64  `xml:"package,attr"`
+	Installed uint64 `xml:"installed,attr"`
+	Archive   uint64 `xml:"archive,attr"`
+}
+
+type primaryLocation struct {
+	Href string `xml:"href,attr"`
+}
+
+type primaryHeaderRange struct {
+	Start int64 `xml:"start,attr"`
+	End   int64 `xml:"end,attr"`
+}
+
+type primaryEntries struct {
+	Entries []*Entry `xml:"rpm:entry"`
+}
+
+type primaryFile struct {
+	Type string `xml:"type,attr,omitempty"`
+	Path string `xml:",chardata"`
+}
+
+type primaryFormat struct {
+	License     string             `xml:"rpm:license"`
+	Vendor      string             `xml:"rpm:vendor"`
+	Group       string             `xml:"rpm:group"`
+	BuildHost   string             `xml:"rpm:buildhost"`
+	SourceRPM   string             `xml:"rpm:sourcerpm"`
+	HeaderRange primaryHeaderRange `xml:"rpm:header-range"`
+	Provides    *primaryEntries    `xml:"rpm:provides,omitempty"`
+	Requires    *primaryEntries    `xml:"rpm:requires,omitempty"`
+	Conflicts   *primaryEntries    `xml:"rpm:conflicts,omitempty"`
+	Obsoletes   *primaryEntries    `xml:"rpm:obsoletes,omitempty"`
+	Files       []*primaryFile     `xml:"file"`
+}
+
+type primaryPackage struct {
+	Type         string          `xml:"type,attr"`
+	Name         string          `xml:"name"`
+	Architecture string          `xml:"arch"`
+	Version      primaryVersion  `xml:"version"`
+	Checksum     primaryChecksum `xml:"checksum"`
+	Summary      string          `xml:"summary"`
+	Description  string          `xml:"description"`
+	Packager     string          `xml:"packager"`
+	URL          string          `xml:"url"`
+	Time         primaryTime     `xml:"time"`
+	Size         primarySize     `xml:"size"`
+	Location     primaryLocation `xml:"location"`
+	Format       primaryFormat   `xml:"format"`
+}
+
+type primaryMetadata struct {
+	XMLName      xml.Name          `xml:"metadata"`
+	Xmlns        string            `xml:"xmlns,attr"`
+	XmlnsRpm     string            `xml:"xmlns:rpm,attr"`
+	PackageCount int               `xml:"packages,attr"`
+	Packages     []*primaryPackage `xml:"package"`
+}
+
+// WritePrimary writes the primary.xml file of a repository
+func WritePrimary(w io.Writer, entries []*PackageIndexEntry) error {
+	m := &primaryMetadata{
+		Xmlns:        "http://linux.duke.edu/metadata/common",
+		XmlnsRpm:     "http://linux.duke.edu/metadata/rpm",
+		PackageCount: len(entries),
+		Packages:     make([]*primaryPackage, 0, len(entries)),
+	}
+
+	for _, e := range entries {
+		md := e.Package.Metadata
+
+		epoch := md.Epoch
+		if epoch == "" {
+			epoch = "0"
+		}
+
+		files := make([]*primaryFile, 0, len(md.Files))
+		for _, f := range md.Files {
+			pf := &primaryFile{Path: f.Path}
+			if f.IsDir {
+				pf.Type = "dir"
+			}
+			files = append(files, pf)
+		}
+
+		m.Packages = append(m.Packages, &primaryPackage{
+			Type:         "rpm",
+			Name:         e.Package.Name,
+			Architecture: md.Architecture,
+			Version: primaryVersion{
+				Epoch:   epoch,
+				Version: e.Package.Version,
+				Release: md.Release,
+			},
+			Checksum: primaryChecksum{
+				Type:  "sha256",
+				PkgID: "YES",
+				Value: e.SHA256,
+			},
+			Summary:     md.Summary,
+			Description: md.Description,
+			Packager:    md.Packager,
+			URL:         md.ProjectURL,
+			Time: primaryTime{
+				File:  e.FileTime.Unix(),
+				Build: md.BuildTime,
+			},
+			Size: primarySize{
+				Package:   e.Size,
+				Installed: md.InstalledSize,
+				Archive:   md.ArchiveSize,
+			},
+			Location: primaryLocation{
+				Href: e.Location,
+			},
+			Format: primaryFormat{
+				License:   md.License,
+				Vendor:    md.Vendor,
+				Group:     md.Group,
+				BuildHost: md.BuildHost,
+				SourceRPM: md.SourceRPM,
+				HeaderRange: primaryHeaderRange{
+					Start: md.HeaderStart,
+					End:   md.HeaderEnd,
+				},
+				Provides:  toPrimaryEntries(md.Provides),
+				Requires:  toPrimaryEntries(md.Requires),
+				Conflicts: toPrimaryEntries(md.Conflicts),
+				Obsoletes: toPrimaryEntries(md.Obsoletes),
+				Files:     files,
+			},
+		})
+	}
+
+	return writeXML(w, m)
+}
+
+func toPrimaryEntries(entries []*Entry) *primaryEntries {
+	if len(entries) == 0 {
+		return nil
+	}
+	return &primaryEntries{Entries: entries}
+}
+
+// RepoData represents a metadata file of a repository, e.g. the compressed primary.xml
+type RepoData struct {
+	// Type is the kind of the file, e.g. primary, filelists or other
+	Type string
+	// Content is the compressed content of the file
+	Content []byte
+	// OpenSize and OpenSHA256 describe the uncompressed content of the file
+	OpenSize   int64
+	OpenSHA256 string
+}
+
+// NewRepoData compresses a metadata file with gzip
+func NewRepoData(typ string, content []byte) (*RepoData, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	return &RepoData{
+		Type:       typ,
+		Content:    buf.Bytes(),
+		OpenSize:   int64(len(content)),
+		OpenSHA256: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// Location returns the path of the file relative to the repository root
+func (d *RepoData) Location() string {
+	return "repodata/" + d.Type + ".xml.gz"
+}
+
+type repomdChecksum struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type repomdData struct {
+	Type         string          `xml:"type,attr"`
+	Checksum     repomdChecksum  `xml:"checksum"`
+	OpenChecksum repomdChecksum  `xml:"open-checksum"`
+	Location     primaryLocation `xml:"location"`
+	Timestamp    int64           `xml:"timestamp"`
+	Size         int64           `xml:"size"`
+	OpenSize     int64           `xml:"open-size"`
+}
+
+type repomd struct {
+	XMLName  xml.Name      `xml:"repomd"`
+	Xmlns    string        `xml:"xmlns,attr"`
+	XmlnsRpm string        `xml:"xmlns:rpm,attr"`
+	Revision int64         `xml:"revision"`
+	Data     []*repomdData `xml:"data"`
+}
+
+// WriteRepomd writes the repomd.xml file listing the metadata files of a repository
+func WriteRepomd(w io.Writer, revision time.Time, data []*RepoData) error {
+	m := &repomd{
+		Xmlns:    "http://linux.duke.edu/metadata/repo",
+		XmlnsRpm: "http://linux.duke.edu/metadata/rpm",
+		Revision: revision.Unix(),
+		Data:     make([]*repomdData, 0, len(data)),
+	}
+	for _, d := range data {
+		sum := sha256.Sum256(d.Content)
+		m.Data = append(m.Data, &repomdData{
+			Type:         d.Type,
+			Checksum:     repomdChecksum{Type: "sha256", Value: hex.EncodeToString(sum[:])},
+			OpenChecksum: repomdChecksum{Type: "sha256", Value: d.OpenSHA256},
+			Location:     primaryLocation{Href: d.Location()},
+			Timestamp:    revision.Unix(),
+			Size:         int64(len(d.Content)),
+			OpenSize:     d.OpenSize,
+		})
+	}
+	return writeXML(w, m)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// SignRepomd creates the armored detached signature (repomd.xml.asc) of a repomd.xml file
+func SignRepomd(content []byte, key *openpgp.Entity) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, key, bytes.NewReader(content), nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/packages"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrimary(t *testing.T) {
+	data := createPackage(validEntries())
+	p, err := ParsePackage(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	buf, err := packages.CreateHashedBufferFromReader(bytes.NewReader(data), 32)
+	assert.NoError(t, err)
+	defer buf.Close()
+
+	fileTime := time.Unix(1672700000, 0)
+	e := NewPackageIndexEntry(p, "Packages/"+p.Filename(), buf, fileTime)
+	sum := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(sum[:]), e.SHA256)
+	assert.EqualValues(t, len(data), e.Size)
+
+	var primary bytes.Buffer
+	assert.NoError(t, WritePrimary(&primary, []*PackageIndexEntry{e}))
+
+	content := primary.String()
+	assert.True(t, strings.HasPrefix(content, xml.Header))
+	for _, expected := range []string{
+		`<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="1">`,
+		`<package type="rpm"><name>gitbundle</name><arch>x86_64</arch><version epoch="2" ver="1.0.0" rel="1.el9"></version>`,
+		`<checksum type="sha256" pkgid="YES">` + e.SHA256 + `</checksum>`,
+		`<time file="1672700000" build="1672628645"></time>`,
+		`<location href="Packages/gitbundle-1.0.0-1.el9.x86_64.rpm"></location>`,
+		`<rpm:header-range start="136" end="` + strconv.FormatInt(p.Metadata.HeaderEnd, 10) + `"></rpm:header-range>`,
+		`<rpm:requires><rpm:entry name="git" flags="GE" epoch="0" ver="2.30"></rpm:entry><rpm:entry name="/bin/sh"></rpm:entry></rpm:requires>`,
+		`<file>/usr/bin/gitbundle</file><file type="dir">/etc/gitbundle</file>`,
+	} {
+		assert.Contains(t, content, expected)
+	}
+	assert.NotContains(t, content, "rpm:conflicts")
+	assert.NotContains(t, content, "rpmlib")
+}
+
+func TestWriteRepomd(t *testing.T) {
+	primary := []byte("<metadata></metadata>")
+
+	d, err := NewRepoData("primary", primary)
+	assert.NoError(t, err)
+	assert.Equal(t, "repodata/primary.xml.gz", d.Location())
+	assert.EqualValues(t, len(primary), d.OpenSize)
+
+	zr, err := gzip.NewReader(bytes.NewReader(d.Content))
+	assert.NoError(t, err)
+	decompressed, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, primary, decompressed)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteRepomd(&buf, time.Unix(1672700000, 0), []*RepoData{d}))
+
+	sum := sha256.Sum256(d.Content)
+	assert.Equal(t, xml.Header+`<repomd xmlns="http://linux.duke.edu/metadata/repo" xmlns:rpm="http://linux.duke.edu/metadata/rpm"><revision>1672700000</revision>`+
+		`<data type="primary"><checksum type="sha256">`+hex.EncodeToString(sum[:])+`</checksum><open-checksum type="sha256">`+d.OpenSHA256+`</open-checksum>`+
+		`<location href="repodata/primary.xml.gz"></location><timestamp>1672700000</timestamp><size>`+strconv.Itoa(len(d.Content))+`</size><open-size>21</open-size></data></repomd>`, buf.String())
+
+	key, err := openpgp.NewEntity("GitBundle", "", "no.reply@gitbundle.com", nil)
+	assert.NoError(t, err)
+
+	signature, err := SignRepomd(buf.Bytes(), key)
+	assert.NoError(t, err)
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{key}, bytes.NewReader(buf.Bytes()), bytes.NewReader(signature), nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,328 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rubygems
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// https://guides.rubygems.org/rubygems-org-compact-index-api/
+
+// ErrInvalidVersionsFile indicates a versions file of the compact index which can't be parsed
+var ErrInvalidVersionsFile = errors.New("versions file is invalid")
+
+const (
+	// ContentTypeCompactIndex is the content type of the compact index files
+	ContentTypeCompactIndex = "text/plain; charset=utf-8"
+
+	compactIndexSeparator = "---"
+	createdAtPrefix       = "created_at: "
+	defaultPlatform       = "ruby"
+)
+
+// CompactIndexVersion is a gem version listed in the compact index
+type CompactIndexVersion struct {
+	Version string
+	// Platform is empty or ruby for platform independent gems
+	Platform string
+	// Checksum is the hex encoded SHA256 of the .gem file
+	Checksum                string
+	Dependencies            []Dependency
+	RequiredRubyVersion     []VersionRequirement
+	RequiredRubygemsVersion []VersionRequirement
+	// Yanked versions are removed from the info file and from the versions file
+	Yanked bool
+}
+
+// NewCompactIndexVersion creates the compact index entry of a package, only runtime dependencies are listed
+func NewCompactIndexVersion(p *Package, checksum string) *CompactIndexVersion {
+	v := &CompactIndexVersion{
+		Version:  p.Version,
+		Checksum: checksum,
+	}
+	if p.Metadata != nil {
+		v.Platform = p.Metadata.Platform
+		v.Dependencies = p.Metadata.RuntimeDependencies
+		v.RequiredRubyVersion = p.Metadata.RequiredRubyVersion
+		v.RequiredRubygemsVersion = p.Metadata.RequiredRubygemsVersion
+	}
+	return v
+}
+
+// FullVersion returns the version with the platform suffix of platform specific gems, like 1.0.0-java
+func (v *CompactIndexVersion) FullVersion() string {
+	if v.Platform == "" || v.Platform == defaultPlatform {
+		return v.Version
+	}
+	return v.Version + "-" + v.Platform
+}
+
+func formatRequirements(requirements []VersionRequirement) string {
+	if len(requirements) == 0 {
+		return ">= 0"
+	}
+	parts := make([]string, 0, len(requirements))
+	for _, r := range requirements {
+		parts = append(parts, r.Restriction+" "+r.Version)
+	}
+	return strings.Join(parts, "&")
+}
+
+// infoLine formats the version like "1.0.0 dep:>= 1.0&< 2|checksum:abc,ruby:>= 2.3"
+func (v *CompactIndexVersion) infoLine() string {
+	deps := make([]string, 0, len(v.Dependencies))
+	for _, d := range v.Dependencies {
+		deps = append(deps, d.Name+":"+formatRequirements(d.Version))
+	}
+	sort.Strings(deps)
+
+	requirements := []string{"checksum:" + v.Checksum}
+	if len(v.RequiredRubyVersion) > 0 {
+		requirements = append(requirements, "ruby:"+formatRequirements(v.RequiredRubyVersion))
+	}
+	if len(v.RequiredRubygemsVersion) > 0 {
+		requirements = append(requirements, "rubygems:"+formatRequirements(v.RequiredRubygemsVersion))
+	}
+
+	return v.FullVersion() + " " + strings.Join(deps, ",") + "|" + strings.Join(requirements, ",")
+}
+
+// WriteNames writes the /names file listing all gems
+func WriteNames(w io.Writer, names []string) error {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+
+	var buf bytes.Buffer
+	buf.WriteString(compactIndexSeparator + "\n")
+	for _, name := range sorted {
+		buf.WriteString(name + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteInfo writes the /info/<gem> file of a gem. The versions are listed in the passed order
+// which should be the order they were pushed in, yanked versions are omitted.
+func WriteInfo(w io.Writer, versions []*CompactIndexVersion) error {
+	var buf bytes.Buffer
+	buf.WriteString(compactIndexSeparator + "\n")
+	for _, v := range versions {
+		if v.Yanked {
+			continue
+		}
+		buf.WriteString(v.infoLine() + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// InfoChecksum returns the hex encoded MD5 of the info file which is listed in the versions file
+func InfoChecksum(versions []*CompactIndexVersion) string {
+	h := md5.New()
+	_ = WriteInfo(h, versions)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ETag returns the quoted MD5 of a compact index file which Bundler sends as If-None-Match
+func ETag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// ReprDigest returns the value of the Repr-Digest header of a compact index file
+func ReprDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+type versionsFileGem struct {
+	name         string
+	versions     []string
+	infoChecksum string
+}
+
+func (g *versionsFileGem) indexOf(version string) int {
+	for i, v := range g.versions {
+		if v == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// VersionsFile is the /versions file of the compact index.
+// Clients fetch only the appended part of the file, so changes are appended as new lines instead of rewriting it.
+type VersionsFile struct {
+	Created time.Time
+	gems    map[string]*versionsFileGem
+}
+
+// NewVersionsFile creates an empty versions file
+func NewVersionsFile(created time.Time) *VersionsFile {
+	return &VersionsFile{
+		Created: created,
+		gems:    make(map[string]*versionsFileGem),
+	}
+}
+
+// ParseVersionsFile parses a versions file including all appended lines
+func ParseVersionsFile(r io.Reader) (*VersionsFile, error) {
+	f := NewVersionsFile(time.Time{})
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	inHeader := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inHeader {
+			if line == compactIndexSeparator {
+				inHeader = false
+			} else if strings.HasPrefix(line, createdAtPrefix) {
+				created, err := time.Parse(time.RFC3339, strings.TrimPrefix(line, createdAtPrefix))
+				if err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrInvalidVersionsFile, err)
+				}
+				f.Created = created
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidVersionsFile, line)
+		}
+		g := f.gem(fields[0])
+		for _, version := range strings.Split(fields[1], ",") {
+			if removed := strings.TrimPrefix(version, "-"); removed != version {
+				if i := g.indexOf(removed); i != -1 {
+					g.versions = append(g.versions[:i], g.versions[i+1:]...)
+				}
+			} else if g.indexOf(version) == -1 {
+				g.versions = append(g.versions, version)
+			}
+		}
+		g.infoChecksum = fields[2]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inHeader {
+		return nil, fmt.Errorf("%w: missing separator", ErrInvalidVersionsFile)
+	}
+	return f, nil
+}
+
+func (f *VersionsFile) gem(name string) *versionsFileGem {
+	g, ok := f.gems[name]
+	if !ok {
+		g = &versionsFileGem{name: name}
+		f.gems[name] = g
+	}
+	return g
+}
+
+// Versions returns the listed versions of a gem with their platform suffix
+func (f *VersionsFile) Versions(name string) []string {
+	if g, ok := f.gems[name]; ok {
+		return g.versions
+	}
+	return nil
+}
+
+// InfoChecksum returns the listed checksum of the info file of a gem
+func (f *VersionsFile) InfoChecksum(name string) string {
+	if g, ok := f.gems[name]; ok {
+		return g.infoChecksum
+	}
+	return ""
+}
+
+// Update records the current versions of a gem and writes the line which must be appended to the versions file.
+// New versions are listed, yanked and deleted versions are listed with a - prefix. If only the info file
+// changed, the last current version is listed again to publish the new checksum.
+// It returns false and writes nothing if neither the listed versions nor the info checksum changed.
+func (f *VersionsFile) Update(w io.Writer, name string, versions []*CompactIndexVersion) (bool, error) {
+	g := f.gem(name)
+
+	current := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if !v.Yanked {
+			current = append(current, v.FullVersion())
+		}
+	}
+
+	var changes []string
+	for _, v := range current {
+		if g.indexOf(v) == -1 {
+			changes = append(changes, v)
+		}
+	}
+	for _, v := range g.versions {
+		found := false
+		for _, c := range current {
+			if c == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			changes = append(changes, "-"+v)
+		}
+	}
+	checksum := InfoChecksum(versions)
+	if len(changes) == 0 {
+		if checksum == g.infoChecksum || len(current) == 0 {
+			return false, nil
+		}
+		changes = append(changes, current[len(current)-1])
+	}
+
+	g.versions = current
+	g.infoChecksum = checksum
+
+	if _, err := fmt.Fprintf(w, "%s %s %s\n", name, strings.Join(changes, ","), g.infoChecksum); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Write writes the complete versions file with a single line per gem.
+// Gems without any listed version are omitted.
+func (f *VersionsFile) Write(w io.Writer) error {
+	names := make([]string, 0, len(f.gems))
+	for name, g := range f.gems {
+		if len(g.versions) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString(createdAtPrefix + f.Created.UTC().Format(time.RFC3339) + "\n")
+	buf.WriteString(compactIndexSeparator + "\n")
+	for _, name := range names {
+		g := f.gems[name]
+		buf.WriteString(name + " " + strings.Join(g.versions, ",") + " " + g.infoChecksum + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
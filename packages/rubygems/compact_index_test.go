@@ -0,0 +1,118 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package rubygems
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteNames(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteNames(&buf, []string{"rake", "gitbundle", "rails"}))
+	assert.Equal(t, "---\ngitbundle\nrails\nrake\n", buf.String())
+}
+
+func TestWriteInfo(t *testing.T) {
+	versions := []*CompactIndexVersion{
+		NewCompactIndexVersion(&Package{
+			Name:    "gitbundle",
+			Version: "1.0.0",
+			Metadata: &Metadata{
+				Platform:            "ruby",
+				RequiredRubyVersion: []VersionRequirement{{Restriction: ">=", Version: "2.3.0"}},
+				RuntimeDependencies: []Dependency{
+					{Name: "runtime-dep", Version: []VersionRequirement{{Restriction: ">=", Version: "1.2.0"}, {Restriction: "<", Version: "2.0"}}},
+					{Name: "any-dep"},
+				},
+				DevelopmentDependencies: []Dependency{{Name: "dev-dep"}},
+			},
+		}, "abc"),
+		{Version: "1.0.1", Platform: "java", Checksum: "def"},
+		{Version: "1.0.2", Checksum: "ghi", Yanked: true},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteInfo(&buf, versions))
+	assert.Equal(t, "---\n1.0.0 any-dep:>= 0,runtime-dep:>= 1.2.0&< 2.0|checksum:abc,ruby:>= 2.3.0\n1.0.1-java |checksum:def\n", buf.String())
+
+	assert.Equal(t, strings.Trim(ETag(buf.Bytes()), `"`), InfoChecksum(versions))
+	assert.Equal(t, `"d41d8cd98f00b204e9800998ecf8427e"`, ETag(nil))
+	assert.Equal(t, "sha-256=:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=:", ReprDigest(nil))
+}
+
+func TestVersionsFile(t *testing.T) {
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := NewVersionsFile(created)
+
+	var buf bytes.Buffer
+	assert.NoError(t, f.Write(&buf))
+
+	v1 := &CompactIndexVersion{Version: "1.0.0", Checksum: "a"}
+	v2 := &CompactIndexVersion{Version: "1.1.0", Platform: "java", Checksum: "b"}
+
+	updated, err := f.Update(&buf, "gitbundle", []*CompactIndexVersion{v1})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	updated, err = f.Update(&buf, "gitbundle", []*CompactIndexVersion{v1, v2})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	updated, err = f.Update(&buf, "gitbundle", []*CompactIndexVersion{v1, v2})
+	assert.NoError(t, err)
+	assert.False(t, updated)
+
+	updated, err = f.Update(&buf, "rake", []*CompactIndexVersion{{Version: "13.0", Checksum: "c"}})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	v1.Yanked = true
+	updated, err = f.Update(&buf, "gitbundle", []*CompactIndexVersion{v1, v2})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	yankedChecksum := InfoChecksum([]*CompactIndexVersion{v1, v2})
+
+	// the info file changed without a change of the listed versions
+	v2.RequiredRubyVersion = []VersionRequirement{{Restriction: ">=", Version: "2.7"}}
+	updated, err = f.Update(&buf, "gitbundle", []*CompactIndexVersion{v1, v2})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	checksum := InfoChecksum([]*CompactIndexVersion{v1, v2})
+	assert.NotEqual(t, yankedChecksum, checksum)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, []string{
+		"created_at: 2023-01-02T03:04:05Z",
+		"---",
+		"gitbundle 1.0.0 " + InfoChecksum([]*CompactIndexVersion{{Version: "1.0.0", Checksum: "a"}}),
+		"gitbundle 1.1.0-java " + InfoChecksum([]*CompactIndexVersion{{Version: "1.0.0", Checksum: "a"}, {Version: "1.1.0", Platform: "java", Checksum: "b"}}),
+		"rake 13.0 " + InfoChecksum([]*CompactIndexVersion{{Version: "13.0", Checksum: "c"}}),
+		"gitbundle -1.0.0 " + yankedChecksum,
+		"gitbundle 1.1.0-java " + checksum,
+	}, lines)
+
+	parsed, err := ParseVersionsFile(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, created, parsed.Created)
+	assert.Equal(t, []string{"1.1.0-java"}, parsed.Versions("gitbundle"))
+	assert.Equal(t, checksum, parsed.InfoChecksum("gitbundle"))
+	assert.Equal(t, []string{"13.0"}, parsed.Versions("rake"))
+	assert.Empty(t, parsed.Versions("unknown"))
+
+	buf.Reset()
+	assert.NoError(t, parsed.Write(&buf))
+	assert.Equal(t, "created_at: 2023-01-02T03:04:05Z\n---\ngitbundle 1.1.0-java "+checksum+"\nrake 13.0 "+parsed.InfoChecksum("rake")+"\n", buf.String())
+
+	_, err = ParseVersionsFile(strings.NewReader("created_at: 2023-01-02T03:04:05Z\n"))
+	assert.ErrorIs(t, err, ErrInvalidVersionsFile)
+	_, err = ParseVersionsFile(strings.NewReader("---\ngitbundle 1.0.0\n"))
+	assert.ErrorIs(t, err, ErrInvalidVersionsFile)
+}
@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/gitbundle/modules/packages/versioning"
 	"github.com/gitbundle/modules/validation"
 
 	"gopkg.in/yaml.v2"
@@ -27,6 +28,9 @@ var (
 	ErrInvalidVersion = errors.New("Metadata file contains an invalid version")
 )
 
+// VersionScheme defines the version semantics of RubyGems packages
+var VersionScheme = versioning.RubyGems
+
 var versionMatcher = regexp.MustCompile(`\A[0-9]+(?:\.[0-9a-zA-Z]+)*(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?\z`)
 
 // Package represents a RubyGems package
@@ -62,6 +66,18 @@ type Dependency struct {
 	Version []VersionRequirement `json:"version"`
 }
 
+// VersionRange parses the combined version requirements of the dependency
+func (d *Dependency) VersionRange() (versioning.Range, error) {
+	requirements := make([]string, 0, len(d.Version))
+	for _, r := range d.Version {
+		requirements = append(requirements, r.Restriction+" "+r.Version)
+	}
+	if len(requirements) == 0 {
+		requirements = append(requirements, ">= 0")
+	}
+	return VersionScheme.ParseRange(strings.Join(requirements, ", "))
+}
+
 type gemspec struct {
 	Name    string `yaml:"name"`
 	Version struct {
@@ -88,3 +88,27 @@ yjAbmt9LsOMp8xMamFkSQ38fP5EFjdz8LA4do2C69VvqWXAJgrPbKZb58/xZXrKoW6ttW13Bhvzi
 	assert.Equal(t, "~>", rp.Metadata.DevelopmentDependencies[0].Version[0].Restriction)
 	assert.Equal(t, "5.2", rp.Metadata.DevelopmentDependencies[0].Version[0].Version)
 }
+
+func TestDependencyVersionRange(t *testing.T) {
+	d := &Dependency{
+		Name: "runtime-dep",
+		Version: []VersionRequirement{
+			{Restriction: ">=", Version: "1.2.0"},
+			{Restriction: "<", Version: "2.0"},
+		},
+	}
+
+	r, err := d.VersionRange()
+	assert.NoError(t, err)
+
+	for version, expected := range map[string]bool{"1.1": false, "1.2.0": true, "1.9.9": true, "2.0": false} {
+		v, err := VersionScheme.ParseVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, r.Contains(v), version)
+	}
+
+	r, err = (&Dependency{Name: "any"}).VersionRange()
+	assert.NoError(t, err)
+	v, _ := VersionScheme.ParseVersion("0.1")
+	assert.True(t, r.Contains(v))
+}
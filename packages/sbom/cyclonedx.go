@@ -0,0 +1,166 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/packages/policy"
+)
+
+const (
+	// ContentTypeCycloneDX is the content type of a CycloneDX JSON document
+	ContentTypeCycloneDX = "application/vnd.cyclonedx+json"
+
+	cycloneDXSpecVersion = "1.5"
+)
+
+type cdxLicense struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cdxLicenseChoice struct {
+	License    *cdxLicense `json:"license,omitempty"`
+	Expression string      `json:"expression,omitempty"`
+}
+
+type cdxHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type cdxComponent struct {
+	Type     ComponentType       `json:"type"`
+	BOMRef   string              `json:"bom-ref"`
+	Name     string              `json:"name"`
+	Version  string              `json:"version,omitempty"`
+	PURL     string              `json:"purl,omitempty"`
+	Licenses []*cdxLicenseChoice `json:"licenses,omitempty"`
+	Hashes   []*cdxHash          `json:"hashes,omitempty"`
+}
+
+type cdxTools struct {
+	Components []*cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string        `json:"timestamp"`
+	Tools     *cdxTools     `json:"tools"`
+	Component *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxBOM struct {
+	BOMFormat    string           `json:"bomFormat"`
+	SpecVersion  string           `json:"specVersion"`
+	SerialNumber string           `json:"serialNumber"`
+	Version      int              `json:"version"`
+	Metadata     *cdxMetadata     `json:"metadata"`
+	Components   []*cdxComponent  `json:"components"`
+	Dependencies []*cdxDependency `json:"dependencies"`
+}
+
+// WriteCycloneDX writes the document as CycloneDX 1.5 JSON
+func WriteCycloneDX(w io.Writer, doc *Document) error {
+	bom := &cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + doc.SerialNumber,
+		Version:      1,
+		Metadata: &cdxMetadata{
+			Timestamp: doc.Created.UTC().Format(time.RFC3339),
+			Tools: &cdxTools{
+				Components: []*cdxComponent{
+					{
+						Type:    ComponentApplication,
+						BOMRef:  doc.toolName(),
+						Name:    doc.toolName(),
+						Version: doc.ToolVersion,
+					},
+				},
+			},
+		},
+		Components:   make([]*cdxComponent, 0, len(doc.Components)),
+		Dependencies: make([]*cdxDependency, 0, len(doc.Components)+1),
+	}
+
+	if doc.Subject != nil {
+		bom.Metadata.Component = toCycloneDXComponent(doc.Subject)
+		bom.Dependencies = append(bom.Dependencies, toCycloneDXDependency(doc.Subject))
+	}
+	for _, c := range doc.Components {
+		bom.Components = append(bom.Components, toCycloneDXComponent(c))
+		bom.Dependencies = append(bom.Dependencies, toCycloneDXDependency(c))
+	}
+
+	return json.NewEncoder(w).Encode(bom)
+}
+
+func toCycloneDXComponent(c *Component) *cdxComponent {
+	cc := &cdxComponent{
+		Type:     c.Type,
+		BOMRef:   c.Ref(),
+		Name:     c.Name,
+		Version:  c.Version,
+		Licenses: toCycloneDXLicenses(c.License),
+	}
+	if cc.Type == "" {
+		cc.Type = ComponentLibrary
+	}
+	if c.PackageURL != nil {
+		cc.PURL = c.PackageURL.String()
+	}
+
+	algs := make([]string, 0, len(c.Hashes))
+	for alg := range c.Hashes {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	for _, alg := range algs {
+		cc.Hashes = append(cc.Hashes, &cdxHash{Algorithm: alg, Content: c.Hashes[alg]})
+	}
+	return cc
+}
+
+// toCycloneDXLicenses uses the license id for a single known license, the expression for
+// compound expressions and the plain name for everything else
+func toCycloneDXLicenses(license string) []*cdxLicenseChoice {
+	if license == "" {
+		return nil
+	}
+
+	expr, err := policy.ParseLicenseExpression(license)
+	if err != nil || len(policy.UnknownLicenses(expr)) > 0 {
+		return []*cdxLicenseChoice{{License: &cdxLicense{Name: license}}}
+	}
+	if ids := expr.Licenses(); len(ids) == 1 && ids[0] == expr.String() {
+		return []*cdxLicenseChoice{{License: &cdxLicense{ID: ids[0]}}}
+	}
+	return []*cdxLicenseChoice{{Expression: expr.String()}}
+}
+
+func toCycloneDXDependency(c *Component) *cdxDependency {
+	dependsOn := c.Dependencies
+	if dependsOn == nil {
+		dependsOn = []string{}
+	}
+	return &cdxDependency{Ref: c.Ref(), DependsOn: dependsOn}
+}
+
+func (d *Document) toolName() string {
+	if d.ToolName == "" {
+		return defaultToolName
+	}
+	return d.ToolName
+}
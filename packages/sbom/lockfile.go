@@ -0,0 +1,250 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/packages/policy"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Supported lockfile names
+const (
+	NpmLockfile   = "package-lock.json"
+	NpmShrinkwrap = "npm-shrinkwrap.json"
+	GoSumFile     = "go.sum"
+	CargoLockfile = "Cargo.lock"
+	npmModulesDir = "node_modules/"
+	goModSuffix   = "/go.mod"
+)
+
+var (
+	// ErrUnsupportedLockfile indicates a file which is not a known lockfile
+	ErrUnsupportedLockfile = errors.New("lockfile is not supported")
+	// ErrInvalidLockfile indicates a lockfile which can't be parsed
+	ErrInvalidLockfile = errors.New("lockfile is invalid")
+)
+
+// IsLockfile tests if the file at the path is a supported lockfile
+func IsLockfile(filepath string) bool {
+	switch path.Base(filepath) {
+	case NpmLockfile, NpmShrinkwrap, GoSumFile, CargoLockfile:
+		return true
+	}
+	return false
+}
+
+// ParseLockfile parses the locked packages of a lockfile
+func ParseLockfile(filepath string, r io.Reader) ([]*Component, error) {
+	switch path.Base(filepath) {
+	case NpmLockfile, NpmShrinkwrap:
+		return parseNpmLockfile(r)
+	case GoSumFile:
+		return parseGoSum(r)
+	case CargoLockfile:
+		return parseCargoLockfile(r)
+	}
+	return nil, ErrUnsupportedLockfile
+}
+
+type npmLockPackage struct {
+	Name         string                     `json:"name"`
+	Version      string                     `json:"version"`
+	Integrity    string                     `json:"integrity"`
+	License      string                     `json:"license"`
+	Link         bool                       `json:"link"`
+	Dependencies map[string]*npmLockPackage `json:"dependencies"`
+}
+
+type npmLockfile struct {
+	LockfileVersion int                        `json:"lockfileVersion"`
+	Packages        map[string]*npmLockPackage `json:"packages"`
+	// Dependencies is the nested package tree of lockfile version 1
+	Dependencies map[string]*npmLockPackage `json:"dependencies"`
+}
+
+// parseNpmLockfile reads the "packages" map of lockfile version 2 and 3 and falls back to
+// the nested "dependencies" of version 1
+func parseNpmLockfile(r io.Reader) ([]*Component, error) {
+	var lf npmLockfile
+	if err := json.NewDecoder(r).Decode(&lf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLockfile, err)
+	}
+
+	var components []*Component
+	add := func(name string, p *npmLockPackage) {
+		// local, git and aliased packages have no registry version
+		if p.Link || p.Version == "" || strings.Contains(p.Version, ":") {
+			return
+		}
+		c := NewPackageComponent(newNpmPackageURL(name, p.Version))
+		c.License = p.License
+		c.Hashes = parseIntegrity(p.Integrity)
+		components = append(components, c)
+	}
+
+	if len(lf.Packages) > 0 {
+		keys := make([]string, 0, len(lf.Packages))
+		for key := range lf.Packages {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			// the root package and workspace members are not installed into node_modules
+			idx := strings.LastIndex(key, npmModulesDir)
+			if idx == -1 {
+				continue
+			}
+			p := lf.Packages[key]
+			name := p.Name
+			if name == "" {
+				name = key[idx+len(npmModulesDir):]
+			}
+			add(name, p)
+		}
+		return components, nil
+	}
+
+	var walk func(map[string]*npmLockPackage)
+	walk = func(deps map[string]*npmLockPackage) {
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			add(name, deps[name])
+			walk(deps[name].Dependencies)
+		}
+	}
+	walk(lf.Dependencies)
+
+	return components, nil
+}
+
+func newNpmPackageURL(name, version string) *policy.PackageURL {
+	namespace := ""
+	if strings.HasPrefix(name, "@") {
+		if i := strings.IndexByte(name, '/'); i != -1 {
+			namespace, name = name[:i], name[i+1:]
+		}
+	}
+	return policy.NewPackageURL(policy.TypeNpm, namespace, name, version)
+}
+
+// parseIntegrity converts a subresource integrity string like sha512-<base64> into hex encoded hashes
+func parseIntegrity(integrity string) map[string]string {
+	hashes := make(map[string]string)
+	for _, field := range strings.Fields(integrity) {
+		alg, sum, ok := strings.Cut(field, "-")
+		if !ok {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(sum)
+		if err != nil {
+			continue
+		}
+		switch alg {
+		case "sha1":
+			hashes[HashSHA1] = hex.EncodeToString(data)
+		case "sha256":
+			hashes[HashSHA256] = hex.EncodeToString(data)
+		case "sha512":
+			hashes[HashSHA512] = hex.EncodeToString(data)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	return hashes
+}
+
+// parseGoSum reads the module versions of a go.sum file.
+// The h1 hashes are directory hashes and can't be expressed as file checksums.
+func parseGoSum(r io.Reader) ([]*Component, error) {
+	var components []*Component
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: malformed line %q", ErrInvalidLockfile, line)
+		}
+
+		module, version := fields[0], fields[1]
+		if strings.HasSuffix(version, goModSuffix) {
+			continue
+		}
+
+		key := module + "@" + version
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		namespace, name := "", module
+		if i := strings.LastIndexByte(module, '/'); i != -1 {
+			namespace, name = module[:i], module[i+1:]
+		}
+		c := NewPackageComponent(policy.NewPackageURL(policy.TypeGolang, namespace, name, version))
+		components = append(components, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+type cargoLockPackage struct {
+	Name     string `toml:"name"`
+	Version  string `toml:"version"`
+	Source   string `toml:"source"`
+	Checksum string `toml:"checksum"`
+}
+
+type cargoLockfile struct {
+	Packages []*cargoLockPackage `toml:"package"`
+}
+
+// parseCargoLockfile reads the registry packages of a Cargo.lock file, path and git dependencies are skipped
+func parseCargoLockfile(r io.Reader) ([]*Component, error) {
+	var lf cargoLockfile
+	if _, err := toml.NewDecoder(r).Decode(&lf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLockfile, err)
+	}
+
+	components := make([]*Component, 0, len(lf.Packages))
+	for _, p := range lf.Packages {
+		if !strings.HasPrefix(p.Source, "registry+") && !strings.HasPrefix(p.Source, "sparse+") {
+			continue
+		}
+		c := NewPackageComponent(policy.NewPackageURL(policy.TypeCargo, "", p.Name, p.Version))
+		if p.Checksum != "" {
+			c.Hashes = map[string]string{HashSHA256: p.Checksum}
+		}
+		components = append(components, c)
+	}
+	return components, nil
+}
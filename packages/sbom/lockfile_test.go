@@ -0,0 +1,143 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func refs(components []*Component) []string {
+	result := make([]string, 0, len(components))
+	for _, c := range components {
+		result = append(result, c.Ref())
+	}
+	return result
+}
+
+func TestIsLockfile(t *testing.T) {
+	assert.True(t, IsLockfile("package-lock.json"))
+	assert.True(t, IsLockfile("web/npm-shrinkwrap.json"))
+	assert.True(t, IsLockfile("go.sum"))
+	assert.True(t, IsLockfile("tools/Cargo.lock"))
+	assert.False(t, IsLockfile("package.json"))
+	assert.False(t, IsLockfile("go.mod"))
+
+	c, err := ParseLockfile("yarn.lock", strings.NewReader(""))
+	assert.Nil(t, c)
+	assert.ErrorIs(t, err, ErrUnsupportedLockfile)
+}
+
+func TestParseNpmLockfile(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		c, err := ParseLockfile("package-lock.json", strings.NewReader("{"))
+		assert.Nil(t, c)
+		assert.ErrorIs(t, err, ErrInvalidLockfile)
+	})
+
+	t.Run("Version3", func(t *testing.T) {
+		content := `{
+	"name": "app",
+	"lockfileVersion": 3,
+	"packages": {
+		"": {"name": "app", "version": "1.0.0"},
+		"node_modules/@gitbundle/client": {"version": "1.2.0", "integrity": "sha512-AAEC", "license": "MIT"},
+		"node_modules/@gitbundle/client/node_modules/ms": {"version": "2.0.0"},
+		"node_modules/alias": {"name": "ms", "version": "2.1.3"},
+		"node_modules/local": {"resolved": "packages/local", "link": true},
+		"node_modules/git-dep": {"version": "git+ssh://git@example.com/dep.git#abc"},
+		"packages/local": {"version": "0.1.0"}
+	}
+}`
+		c, err := ParseLockfile("package-lock.json", strings.NewReader(content))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"pkg:npm/%40gitbundle/client@1.2.0",
+			"pkg:npm/ms@2.0.0",
+			"pkg:npm/ms@2.1.3",
+		}, refs(c))
+		assert.Equal(t, "MIT", c[0].License)
+		assert.Equal(t, map[string]string{HashSHA512: "000102"}, c[0].Hashes)
+		assert.Equal(t, "@gitbundle/client", c[0].Name)
+	})
+
+	t.Run("Version1", func(t *testing.T) {
+		content := `{
+	"lockfileVersion": 1,
+	"dependencies": {
+		"debug": {
+			"version": "2.6.9",
+			"integrity": "sha1-AAEC",
+			"dependencies": {"ms": {"version": "2.0.0"}}
+		},
+		"ms": {"version": "2.1.3"},
+		"local": {"version": "file:../local"}
+	}
+}`
+		c, err := ParseLockfile("npm-shrinkwrap.json", strings.NewReader(content))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"pkg:npm/debug@2.6.9", "pkg:npm/ms@2.0.0", "pkg:npm/ms@2.1.3"}, refs(c))
+		assert.Equal(t, map[string]string{HashSHA1: "000102"}, c[0].Hashes)
+	})
+}
+
+func TestParseGoSum(t *testing.T) {
+	content := `github.com/stretchr/testify v1.8.1 h1:abc=
+github.com/stretchr/testify v1.8.1/go.mod h1:def=
+github.com/stretchr/testify v1.8.1 h1:abc=
+golang.org/x/mod v0.8.0/go.mod h1:ghi=
+
+gopkg.in/yaml.v2 v2.4.0 h1:jkl=
+`
+	c, err := ParseLockfile("go.sum", strings.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"pkg:golang/github.com/stretchr/testify@v1.8.1",
+		"pkg:golang/gopkg.in/yaml.v2@v2.4.0",
+	}, refs(c))
+	assert.Empty(t, c[0].Hashes)
+
+	c, err = ParseLockfile("go.sum", strings.NewReader("invalid line\n"))
+	assert.Nil(t, c)
+	assert.ErrorIs(t, err, ErrInvalidLockfile)
+}
+
+func TestParseCargoLockfile(t *testing.T) {
+	content := `version = 3
+
+[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = ["serde"]
+
+[[package]]
+name = "serde"
+version = "1.0.152"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "bb7d1f0d3021d347a83e556fc4683dea2ea09d87bccdf88ff5c12545d89d5efb"
+
+[[package]]
+name = "forked"
+version = "0.2.0"
+source = "git+https://example.com/forked.git#abc"
+
+[[package]]
+name = "log"
+version = "0.4.17"
+source = "sparse+https://index.crates.io/"
+`
+	c, err := ParseLockfile("Cargo.lock", strings.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pkg:cargo/serde@1.0.152", "pkg:cargo/log@0.4.17"}, refs(c))
+	assert.Equal(t, map[string]string{HashSHA256: "bb7d1f0d3021d347a83e556fc4683dea2ea09d87bccdf88ff5c12545d89d5efb"}, c[0].Hashes)
+	assert.Nil(t, c[1].Hashes)
+
+	c, err = ParseLockfile("Cargo.lock", strings.NewReader("[[package]\n"))
+	assert.Nil(t, c)
+	assert.ErrorIs(t, err, ErrInvalidLockfile)
+}
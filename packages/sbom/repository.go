@@ -0,0 +1,63 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gitbundle/modules/analyze"
+	"github.com/gitbundle/modules/git"
+)
+
+// maxLockfileSize is the size limit of lockfiles read from a repository
+const maxLockfileSize = 32 * 1024 * 1024
+
+// FromCommit creates a SBOM of a repository commit from the lockfiles found in its tree.
+// Lockfiles in vendored directories are skipped. The locked packages are flattened into
+// direct dependencies of the repository because lockfiles don't record the origin of a package.
+// Lockfiles which are too large or can't be parsed are skipped and listed in the warnings of the document.
+func FromCommit(name string, commit *git.Commit) (*Document, error) {
+	entries, err := commit.Tree.ListEntriesRecursive()
+	if err != nil {
+		return nil, err
+	}
+
+	subject := &Component{
+		Type:    ComponentApplication,
+		Name:    name,
+		Version: commit.ID.String(),
+	}
+	doc := NewDocument(subject)
+
+	for _, entry := range entries {
+		if !entry.IsRegular() || !IsLockfile(entry.Name()) || analyze.IsVendor(entry.Name()) {
+			continue
+		}
+		if entry.Size() > maxLockfileSize {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("%s: lockfile is larger than %d bytes", entry.Name(), maxLockfileSize))
+			continue
+		}
+
+		rc, err := entry.Blob().DataAsync()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		components, err := ParseLockfile(entry.Name(), io.LimitReader(rc, maxLockfileSize))
+		rc.Close()
+		if err != nil {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+		for _, c := range components {
+			if doc.Add(c) {
+				subject.Dependencies = append(subject.Dependencies, c.Ref())
+			}
+		}
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/gitbundle/modules/packages/policy"
+)
+
+// ComponentType is the kind of a component
+type ComponentType string
+
+const (
+	ComponentApplication ComponentType = "application"
+	ComponentLibrary     ComponentType = "library"
+)
+
+// Hash algorithms of component checksums, named like in CycloneDX
+const (
+	HashSHA1   = "SHA-1"
+	HashSHA256 = "SHA-256"
+	HashSHA512 = "SHA-512"
+)
+
+// defaultToolName is the name of the generator if the document does not set one
+const defaultToolName = "gitbundle"
+
+// Component is a package or repository listed in a SBOM
+type Component struct {
+	Type    ComponentType
+	Name    string
+	Version string
+	// PackageURL is optional for components which are not published in a registry
+	PackageURL *policy.PackageURL
+	// License is the declared license expression
+	License string
+	// Hashes maps the hash algorithm to the hex encoded checksum
+	Hashes map[string]string
+	// Dependencies are the references of the direct dependencies
+	Dependencies []string
+}
+
+// Ref returns the reference which identifies the component inside the document
+func (c *Component) Ref() string {
+	if c.PackageURL != nil {
+		return c.PackageURL.String()
+	}
+	if c.Version != "" {
+		return c.Name + "@" + c.Version
+	}
+	return c.Name
+}
+
+// NewPackageComponent creates a library component from a package url
+func NewPackageComponent(purl *policy.PackageURL) *Component {
+	name := purl.Name
+	if purl.Namespace != "" {
+		name = purl.Namespace + "/" + purl.Name
+	}
+	return &Component{
+		Type:       ComponentLibrary,
+		Name:       name,
+		Version:    purl.Version,
+		PackageURL: purl,
+	}
+}
+
+// Document is a SBOM describing a subject and the components it consists of
+type Document struct {
+	// SerialNumber is a random UUID identifying the document
+	SerialNumber string
+	Created      time.Time
+	// ToolName and ToolVersion identify the generator of the document
+	ToolName    string
+	ToolVersion string
+	// Namespace is the URI prefix of the SPDX document namespace
+	Namespace string
+	// Subject is the described package version or repository
+	Subject    *Component
+	Components []*Component
+	// Warnings lists the inputs which were skipped whilst the document was created
+	Warnings []string
+
+	refs map[string]*Component
+}
+
+// NewDocument creates a document describing the subject
+func NewDocument(subject *Component) *Document {
+	return &Document{
+		SerialNumber: newUUID(),
+		Created:      time.Now().UTC(),
+		ToolName:     defaultToolName,
+		Subject:      subject,
+		refs:         make(map[string]*Component),
+	}
+}
+
+// Add adds a component to the document. A component which is already present
+// is merged with the existing one and false is returned.
+func (d *Document) Add(c *Component) bool {
+	if d.refs == nil {
+		d.refs = make(map[string]*Component)
+	}
+
+	ref := c.Ref()
+	if existing, ok := d.refs[ref]; ok {
+		if existing.License == "" {
+			existing.License = c.License
+		}
+		for alg, sum := range c.Hashes {
+			if existing.Hashes == nil {
+				existing.Hashes = make(map[string]string)
+			}
+			if _, ok := existing.Hashes[alg]; !ok {
+				existing.Hashes[alg] = sum
+			}
+		}
+		return false
+	}
+
+	d.refs[ref] = c
+	d.Components = append(d.Components, c)
+	return true
+}
+
+// FromPackage creates a SBOM of a package version with its dependencies as components
+func FromPackage(pkg *policy.Package) *Document {
+	subject := NewPackageComponent(pkg.PackageURL)
+	subject.License = pkg.License
+
+	doc := NewDocument(subject)
+	for _, dep := range pkg.Dependencies {
+		c := NewPackageComponent(dep)
+		if doc.Add(c) {
+			subject.Dependencies = append(subject.Dependencies, c.Ref())
+		}
+	}
+	return doc
+}
+
+// newUUID creates a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/packages/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createDocument() *Document {
+	doc := FromPackage(&policy.Package{
+		PackageURL: policy.NewPackageURL(policy.TypeNpm, "@gitbundle", "client", "1.0.0"),
+		License:    "mit OR Apache-2.0",
+		Dependencies: []*policy.PackageURL{
+			policy.NewPackageURL(policy.TypeNpm, "", "ms", "2.1.3"),
+			policy.NewPackageURL(policy.TypeNpm, "", "ms", "2.1.3"),
+			policy.NewPackageURL(policy.TypeNpm, "", "debug", ""),
+		},
+	})
+	doc.SerialNumber = "3e671687-395b-41f5-a30f-a58921a69b79"
+	doc.Created = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	doc.ToolVersion = "1.0"
+	doc.Components[0].License = "MIT"
+	doc.Components[0].Hashes = map[string]string{HashSHA512: "abc", HashSHA1: "def"}
+	doc.Components[1].License = "Custom License"
+	return doc
+}
+
+func TestDocument(t *testing.T) {
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), newUUID())
+
+	doc := createDocument()
+	assert.Equal(t, "pkg:npm/%40gitbundle/client@1.0.0", doc.Subject.Ref())
+	assert.Equal(t, "@gitbundle/client", doc.Subject.Name)
+	assert.Equal(t, []string{"pkg:npm/ms@2.1.3", "pkg:npm/debug"}, doc.Subject.Dependencies)
+	assert.Len(t, doc.Components, 2)
+
+	assert.False(t, doc.Add(&Component{
+		PackageURL: policy.NewPackageURL(policy.TypeNpm, "", "ms", "2.1.3"),
+		License:    "BSD-3-Clause",
+		Hashes:     map[string]string{HashSHA256: "123"},
+	}))
+	assert.Equal(t, "MIT", doc.Components[0].License)
+	assert.Equal(t, "123", doc.Components[0].Hashes[HashSHA256])
+
+	assert.Equal(t, "repo@abc", (&Component{Name: "repo", Version: "abc"}).Ref())
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCycloneDX(&buf, createDocument()))
+	assert.JSONEq(t, `{
+	"bomFormat": "CycloneDX",
+	"specVersion": "1.5",
+	"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+	"version": 1,
+	"metadata": {
+		"timestamp": "2023-01-02T03:04:05Z",
+		"tools": {"components": [{"type": "application", "bom-ref": "gitbundle", "name": "gitbundle", "version": "1.0"}]},
+		"component": {
+			"type": "library",
+			"bom-ref": "pkg:npm/%40gitbundle/client@1.0.0",
+			"name": "@gitbundle/client",
+			"version": "1.0.0",
+			"purl": "pkg:npm/%40gitbundle/client@1.0.0",
+			"licenses": [{"expression": "MIT OR Apache-2.0"}]
+		}
+	},
+	"components": [
+		{
+			"type": "library",
+			"bom-ref": "pkg:npm/ms@2.1.3",
+			"name": "ms",
+			"version": "2.1.3",
+			"purl": "pkg:npm/ms@2.1.3",
+			"licenses": [{"license": {"id": "MIT"}}],
+			"hashes": [{"alg": "SHA-1", "content": "def"}, {"alg": "SHA-512", "content": "abc"}]
+		},
+		{
+			"type": "library",
+			"bom-ref": "pkg:npm/debug",
+			"name": "debug",
+			"purl": "pkg:npm/debug",
+			"licenses": [{"license": {"name": "Custom License"}}]
+		}
+	],
+	"dependencies": [
+		{"ref": "pkg:npm/%40gitbundle/client@1.0.0", "dependsOn": ["pkg:npm/ms@2.1.3", "pkg:npm/debug"]},
+		{"ref": "pkg:npm/ms@2.1.3", "dependsOn": []},
+		{"ref": "pkg:npm/debug", "dependsOn": []}
+	]
+}`, buf.String())
+}
+
+func TestWriteSPDX(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSPDX(&buf, createDocument()))
+	assert.JSONEq(t, `{
+	"spdxVersion": "SPDX-2.3",
+	"dataLicense": "CC0-1.0",
+	"SPDXID": "SPDXRef-DOCUMENT",
+	"name": "pkg:npm/%40gitbundle/client@1.0.0",
+	"documentNamespace": "https://spdx.org/spdxdocs/pkg-npm-40gitbundle-client-1.0.0-3e671687-395b-41f5-a30f-a58921a69b79",
+	"creationInfo": {"created": "2023-01-02T03:04:05Z", "creators": ["Tool: gitbundle-1.0"]},
+	"documentDescribes": ["SPDXRef-Package-0"],
+	"packages": [
+		{
+			"SPDXID": "SPDXRef-Package-0",
+			"name": "@gitbundle/client",
+			"versionInfo": "1.0.0",
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed": false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared": "MIT OR Apache-2.0",
+			"copyrightText": "NOASSERTION",
+			"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/%40gitbundle/client@1.0.0"}]
+		},
+		{
+			"SPDXID": "SPDXRef-Package-1",
+			"name": "ms",
+			"versionInfo": "2.1.3",
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed": false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared": "MIT",
+			"copyrightText": "NOASSERTION",
+			"checksums": [{"algorithm": "SHA1", "checksumValue": "def"}, {"algorithm": "SHA512", "checksumValue": "abc"}],
+			"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/ms@2.1.3"}]
+		},
+		{
+			"SPDXID": "SPDXRef-Package-2",
+			"name": "debug",
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed": false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared": "NOASSERTION",
+			"copyrightText": "NOASSERTION",
+			"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/debug"}]
+		}
+	],
+	"relationships": [
+		{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-0"},
+		{"spdxElementId": "SPDXRef-Package-0", "relationshipType": "DEPENDS_ON", "relatedSpdxElement": "SPDXRef-Package-1"},
+		{"spdxElementId": "SPDXRef-Package-0", "relationshipType": "DEPENDS_ON", "relatedSpdxElement": "SPDXRef-Package-2"}
+	]
+}`, buf.String())
+}
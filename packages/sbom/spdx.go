@@ -0,0 +1,192 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sbom
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/packages/policy"
+)
+
+const (
+	// ContentTypeSPDX is the content type of a SPDX JSON document
+	ContentTypeSPDX = "application/spdx+json"
+
+	spdxVersion      = "SPDX-2.3"
+	spdxDataLicense  = "CC0-1.0"
+	spdxDocumentID   = "SPDXRef-DOCUMENT"
+	spdxNoAssertion  = "NOASSERTION"
+	defaultNamespace = "https://spdx.org/spdxdocs"
+)
+
+var spdxNameReplacer = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string             `json:"SPDXID"`
+	Name             string             `json:"name"`
+	VersionInfo      string             `json:"versionInfo,omitempty"`
+	DownloadLocation string             `json:"downloadLocation"`
+	FilesAnalyzed    bool               `json:"filesAnalyzed"`
+	LicenseConcluded string             `json:"licenseConcluded"`
+	LicenseDeclared  string             `json:"licenseDeclared"`
+	CopyrightText    string             `json:"copyrightText"`
+	Checksums        []*spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []*spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string              `json:"spdxVersion"`
+	DataLicense       string              `json:"dataLicense"`
+	SPDXID            string              `json:"SPDXID"`
+	Name              string              `json:"name"`
+	DocumentNamespace string              `json:"documentNamespace"`
+	CreationInfo      *spdxCreationInfo   `json:"creationInfo"`
+	DocumentDescribes []string            `json:"documentDescribes,omitempty"`
+	Packages          []*spdxPackage      `json:"packages"`
+	Relationships     []*spdxRelationship `json:"relationships"`
+}
+
+// WriteSPDX writes the document as SPDX 2.3 JSON
+func WriteSPDX(w io.Writer, doc *Document) error {
+	name := doc.toolName()
+	if doc.Subject != nil {
+		name = doc.Subject.Ref()
+	}
+
+	namespace := doc.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	creator := "Tool: " + doc.toolName()
+	if doc.ToolVersion != "" {
+		creator += "-" + doc.ToolVersion
+	}
+
+	sd := &spdxDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       spdxDataLicense,
+		SPDXID:            spdxDocumentID,
+		Name:              name,
+		DocumentNamespace: strings.TrimSuffix(namespace, "/") + "/" + spdxNameReplacer.ReplaceAllString(name, "-") + "-" + doc.SerialNumber,
+		CreationInfo: &spdxCreationInfo{
+			Created:  doc.Created.UTC().Format(time.RFC3339),
+			Creators: []string{creator},
+		},
+		Packages:      make([]*spdxPackage, 0, len(doc.Components)+1),
+		Relationships: []*spdxRelationship{},
+	}
+
+	ids := make(map[string]string, len(doc.Components)+1)
+	components := doc.Components
+	if doc.Subject != nil {
+		components = append([]*Component{doc.Subject}, components...)
+	}
+	for i, c := range components {
+		id := "SPDXRef-Package-" + strconv.Itoa(i)
+		ids[c.Ref()] = id
+		sd.Packages = append(sd.Packages, toSPDXPackage(id, c))
+	}
+
+	if doc.Subject != nil {
+		subjectID := ids[doc.Subject.Ref()]
+		sd.DocumentDescribes = []string{subjectID}
+		sd.Relationships = append(sd.Relationships, &spdxRelationship{
+			Element: spdxDocumentID,
+			Type:    "DESCRIBES",
+			Related: subjectID,
+		})
+	}
+	for _, c := range components {
+		for _, dep := range c.Dependencies {
+			if depID, ok := ids[dep]; ok {
+				sd.Relationships = append(sd.Relationships, &spdxRelationship{
+					Element: ids[c.Ref()],
+					Type:    "DEPENDS_ON",
+					Related: depID,
+				})
+			}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(sd)
+}
+
+func toSPDXPackage(id string, c *Component) *spdxPackage {
+	p := &spdxPackage{
+		SPDXID:           id,
+		Name:             c.Name,
+		VersionInfo:      c.Version,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  toSPDXLicense(c.License),
+		CopyrightText:    spdxNoAssertion,
+	}
+
+	algs := make([]string, 0, len(c.Hashes))
+	for alg := range c.Hashes {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	for _, alg := range algs {
+		p.Checksums = append(p.Checksums, &spdxChecksum{
+			Algorithm: strings.ReplaceAll(alg, "-", ""),
+			Value:     c.Hashes[alg],
+		})
+	}
+
+	if c.PackageURL != nil {
+		p.ExternalRefs = []*spdxExternalRef{
+			{
+				Category: "PACKAGE-MANAGER",
+				Type:     "purl",
+				Locator:  c.PackageURL.String(),
+			},
+		}
+	}
+	return p
+}
+
+// toSPDXLicense returns the normalized expression or NOASSERTION if the license
+// contains identifiers which are not on the SPDX license list
+func toSPDXLicense(license string) string {
+	if license == "" {
+		return spdxNoAssertion
+	}
+	expr, err := policy.ParseLicenseExpression(license)
+	if err != nil || len(policy.UnknownLicenses(expr)) > 0 {
+		return spdxNoAssertion
+	}
+	return expr.String()
+}
@@ -0,0 +1,365 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/setting"
+	"github.com/gitbundle/modules/sync"
+	"github.com/gitbundle/modules/util"
+)
+
+var (
+	// ErrUploadSessionNotFound indicates an unknown or expired upload session
+	ErrUploadSessionNotFound = errors.New("upload session does not exist")
+	// ErrInvalidUploadOffset indicates a chunk which does not continue at the end of the received data
+	ErrInvalidUploadOffset = errors.New("upload offset is invalid")
+	// ErrUploadDigestMismatch indicates received data which does not match the expected digest
+	ErrUploadDigestMismatch = errors.New("upload digest does not match")
+)
+
+const (
+	uploadSessionIDLength  = 32
+	uploadSessionDataFile  = "data"
+	uploadSessionStateFile = "state.json"
+)
+
+var uploadSessionIDPattern = regexp.MustCompile(`\A[0-9a-f]{32}\z`)
+
+// UploadSession is a resumable upload which receives its content in chunks
+type UploadSession struct {
+	ID string `json:"id"`
+	// Size is the number of bytes received so far
+	Size int64 `json:"size"`
+	// HashState is the marshaled MultiHasher state of the received bytes
+	HashState []byte    `json:"hash_state"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+// Sums implements HashSummer and returns the checksums of the received data
+func (s *UploadSession) Sums() (hashMD5, hashSHA1, hashSHA256, hashSHA512 []byte) {
+	h, _ := s.hasher()
+	return h.Sums()
+}
+
+func (s *UploadSession) hasher() (*MultiHasher, error) {
+	h := NewMultiHasher()
+	if len(s.HashState) == 0 {
+		return h, nil
+	}
+	return h, h.UnmarshalBinary(s.HashState)
+}
+
+// UploadSessionManager stores upload sessions on disk so they survive restarts
+type UploadSessionManager struct {
+	dir   string
+	ttl   time.Duration
+	locks *sync.ExclusivePool
+	now   func() time.Time
+}
+
+// NewUploadSessionManager creates a manager storing its sessions below dir.
+// Sessions which were not updated within ttl are expired.
+func NewUploadSessionManager(dir string, ttl time.Duration) (*UploadSessionManager, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &UploadSessionManager{
+		dir:   dir,
+		ttl:   ttl,
+		locks: sync.NewExclusivePool(),
+		now:   time.Now,
+	}, nil
+}
+
+// NewDefaultUploadSessionManager creates a manager using the configured chunked upload path
+func NewDefaultUploadSessionManager(ttl time.Duration) (*UploadSessionManager, error) {
+	return NewUploadSessionManager(setting.Packages.ChunkedUploadPath, ttl)
+}
+
+// Create starts a new empty upload session
+func (m *UploadSessionManager) Create() (*UploadSession, error) {
+	id, err := util.CryptoRandomBytes(uploadSessionIDLength / 2)
+	if err != nil {
+		return nil, err
+	}
+
+	hashState, err := NewMultiHasher().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now().UTC()
+	s := &UploadSession{
+		ID:        hex.EncodeToString(id),
+		HashState: hashState,
+		Created:   now,
+		Updated:   now,
+	}
+
+	if err := os.MkdirAll(m.sessionPath(s.ID), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(m.dataPath(s.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := m.save(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the upload session with the id
+func (m *UploadSessionManager) Get(id string) (*UploadSession, error) {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return nil, ErrUploadSessionNotFound
+	}
+
+	m.locks.CheckIn(id)
+	defer m.locks.CheckOut(id)
+
+	return m.load(id)
+}
+
+// Append writes the chunk at offset which must be the number of bytes received so far.
+// If the chunk can't be read completely it is discarded and the session remains at its previous size.
+func (m *UploadSessionManager) Append(id string, offset int64, r io.Reader) (*UploadSession, error) {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return nil, ErrUploadSessionNotFound
+	}
+
+	m.locks.CheckIn(id)
+	defer m.locks.CheckOut(id)
+
+	s, err := m.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != s.Size {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrInvalidUploadOffset, s.Size, offset)
+	}
+
+	h, err := s.hasher()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(m.dataPath(id), os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// drop data of a previously interrupted chunk which was never recorded in the state
+	if err := f.Truncate(s.Size); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(s.Size, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	hashState, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	s.Size += n
+	s.HashState = hashState
+	s.Updated = m.now().UTC()
+	if err := m.save(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Finalize moves the received data into the content store and removes the session.
+// If expectedSHA256 is not empty the data must match the hex encoded digest.
+// The returned session provides the checksums of the stored blob.
+func (m *UploadSessionManager) Finalize(id, expectedSHA256 string, cs *ContentStore) (*UploadSession, error) {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return nil, ErrUploadSessionNotFound
+	}
+
+	m.locks.CheckIn(id)
+	defer m.locks.CheckOut(id)
+
+	s, err := m.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, hashSHA256, _ := s.Sums()
+	key := hex.EncodeToString(hashSHA256)
+	if expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, key) {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrUploadDigestMismatch, expectedSHA256, key)
+	}
+
+	f, err := os.Open(m.dataPath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := cs.Save(BlobHash256Key(key), io.LimitReader(f, s.Size), s.Size); err != nil {
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := util.RemoveAll(m.sessionPath(id)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Delete removes the upload session
+func (m *UploadSessionManager) Delete(id string) error {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return ErrUploadSessionNotFound
+	}
+
+	m.locks.CheckIn(id)
+	defer m.locks.CheckOut(id)
+
+	if _, err := os.Stat(m.sessionPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrUploadSessionNotFound
+		}
+		return err
+	}
+	return util.RemoveAll(m.sessionPath(id))
+}
+
+// RemoveExpired removes all sessions which were not updated within the ttl and returns their number
+func (m *UploadSessionManager) RemoveExpired() (int, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || !uploadSessionIDPattern.MatchString(id) {
+			continue
+		}
+
+		expired, err := m.removeIfExpired(id)
+		if err != nil {
+			return removed, err
+		}
+		if expired {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (m *UploadSessionManager) removeIfExpired(id string) (bool, error) {
+	m.locks.CheckIn(id)
+	defer m.locks.CheckOut(id)
+
+	updated := time.Time{}
+	s, err := m.readState(id)
+	if err == nil {
+		updated = s.Updated
+	} else if fi, err := os.Stat(m.sessionPath(id)); err == nil {
+		// the session was interrupted before its state was written
+		updated = fi.ModTime()
+	} else {
+		return false, nil
+	}
+
+	if !m.isExpired(updated) {
+		return false, nil
+	}
+	return true, util.RemoveAll(m.sessionPath(id))
+}
+
+func (m *UploadSessionManager) isExpired(updated time.Time) bool {
+	return m.ttl > 0 && updated.Add(m.ttl).Before(m.now())
+}
+
+func (m *UploadSessionManager) sessionPath(id string) string {
+	return filepath.Join(m.dir, id)
+}
+
+func (m *UploadSessionManager) dataPath(id string) string {
+	return filepath.Join(m.dir, id, uploadSessionDataFile)
+}
+
+func (m *UploadSessionManager) statePath(id string) string {
+	return filepath.Join(m.dir, id, uploadSessionStateFile)
+}
+
+// load reads the state of a session and removes the session if it is expired
+func (m *UploadSessionManager) load(id string) (*UploadSession, error) {
+	s, err := m.readState(id)
+	if err != nil {
+		return nil, err
+	}
+	if m.isExpired(s.Updated) {
+		if err := util.RemoveAll(m.sessionPath(id)); err != nil {
+			return nil, err
+		}
+		return nil, ErrUploadSessionNotFound
+	}
+	return s, nil
+}
+
+func (m *UploadSessionManager) readState(id string) (*UploadSession, error) {
+	content, err := os.ReadFile(m.statePath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrUploadSessionNotFound
+		}
+		return nil, err
+	}
+
+	s := &UploadSession{}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// save writes the state to a temporary file first so an interrupted write keeps the previous state
+func (m *UploadSessionManager) save(s *UploadSession) error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp := m.statePath(s.ID) + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return util.Rename(tmp, m.statePath(s.ID))
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package packages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	n := copy(p, "partial")
+	return n, errors.New("connection reset")
+}
+
+func TestUploadSessionManager(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := NewUploadSessionManager(filepath.Join(dir, "upload"), time.Hour)
+	assert.NoError(t, err)
+
+	t.Run("NotFound", func(t *testing.T) {
+		for _, id := range []string{"", "../etc", "0123456789abcdef0123456789abcdef"} {
+			s, err := m.Get(id)
+			assert.Nil(t, s)
+			assert.ErrorIs(t, err, ErrUploadSessionNotFound)
+		}
+		assert.ErrorIs(t, m.Delete("0123456789abcdef0123456789abcdef"), ErrUploadSessionNotFound)
+	})
+
+	t.Run("Upload", func(t *testing.T) {
+		s, err := m.Create()
+		assert.NoError(t, err)
+		assert.Len(t, s.ID, 32)
+		assert.EqualValues(t, 0, s.Size)
+
+		s, err = m.Append(s.ID, 0, strings.NewReader("git"))
+		assert.NoError(t, err)
+		assert.EqualValues(t, 3, s.Size)
+
+		_, err = m.Append(s.ID, 0, strings.NewReader("ea"))
+		assert.ErrorIs(t, err, ErrInvalidUploadOffset)
+
+		_, err = m.Append(s.ID, 3, failingReader{})
+		assert.Error(t, err)
+
+		// a new manager simulates a restart
+		m2, err := NewUploadSessionManager(filepath.Join(dir, "upload"), time.Hour)
+		assert.NoError(t, err)
+
+		s, err = m2.Get(s.ID)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 3, s.Size)
+
+		s, err = m2.Append(s.ID, 3, strings.NewReader("ea"))
+		assert.NoError(t, err)
+		assert.EqualValues(t, 5, s.Size)
+
+		hashMD5, hashSHA1, hashSHA256, hashSHA512 := s.Sums()
+		assert.Equal(t, expectedMD5, fmt.Sprintf("%x", hashMD5))
+		assert.Equal(t, expectedSHA1, fmt.Sprintf("%x", hashSHA1))
+		assert.Equal(t, expectedSHA256, fmt.Sprintf("%x", hashSHA256))
+		assert.Equal(t, expectedSHA512, fmt.Sprintf("%x", hashSHA512))
+
+		store, err := storage.NewLocalStorage(context.Background(), storage.LocalStorageConfig{Path: filepath.Join(dir, "store")})
+		assert.NoError(t, err)
+		cs := &ContentStore{store}
+
+		_, err = m2.Finalize(s.ID, strings.Repeat("0", 64), cs)
+		assert.ErrorIs(t, err, ErrUploadDigestMismatch)
+
+		s, err = m2.Finalize(s.ID, strings.ToUpper(expectedSHA256), cs)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 5, s.Size)
+
+		obj, err := cs.Get(BlobHash256Key(expectedSHA256))
+		assert.NoError(t, err)
+		content, err := io.ReadAll(obj)
+		obj.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, "gitea", string(content))
+
+		_, err = m2.Get(s.ID)
+		assert.ErrorIs(t, err, ErrUploadSessionNotFound)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		s1, err := m.Create()
+		assert.NoError(t, err)
+		s2, err := m.Create()
+		assert.NoError(t, err)
+
+		assert.NoError(t, m.Delete(s2.ID))
+		_, err = m.Get(s2.ID)
+		assert.ErrorIs(t, err, ErrUploadSessionNotFound)
+
+		interrupted := filepath.Join(dir, "upload", strings.Repeat("a", 32))
+		assert.NoError(t, os.Mkdir(interrupted, os.ModePerm))
+
+		removed, err := m.RemoveExpired()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, removed)
+
+		m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		defer func() { m.now = time.Now }()
+
+		removed, err = m.RemoveExpired()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, removed)
+
+		_, err = m.Get(s1.ID)
+		assert.ErrorIs(t, err, ErrUploadSessionNotFound)
+		assert.NoDirExists(t, interrupted)
+	})
+}
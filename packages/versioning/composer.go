@@ -0,0 +1,374 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Composer implements the version semantics of Composer with constraints like ^1.2, ~1.2.3, 1.0.*, >=1.0 <2.0 || ^3.0.
+// Branch versions like dev-main only match themselves and sort before all other versions.
+var Composer Scheme = &composerScheme{}
+
+// https://getcomposer.org/doc/articles/versions.md
+var (
+	composerPattern         = regexp.MustCompile(`(?i)\A\s*v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:\.(\d+))?(?:[._-]?(stable|beta|b|rc|alpha|a|patch|pl|p)(?:[.-]?(\d+))?)?(?:[.-]?(dev))?\s*\z`)
+	composerBranchPattern   = regexp.MustCompile(`(?i)\A\s*v?(\d+|[x*])(?:\.(\d+|[x*]))?(?:\.(\d+|[x*]))?(?:\.(\d+|[x*]))?[.-]dev\s*\z`)
+	composerStabilityFlag   = regexp.MustCompile(`(?i)@(?:stable|rc|beta|alpha|dev)\z`)
+	composerOperatorSpace   = regexp.MustCompile(`(<>|!=|>=|<=|==|=|<|>|~|\^)\s+`)
+	composerHyphenPattern   = regexp.MustCompile(`\A(\S+)\s+-\s+(\S+)\z`)
+	composerOperatorPattern = regexp.MustCompile(`\A(<>|!=|>=|<=|==|=|<|>|~|\^)?(.+)\z`)
+	composerPartialPattern  = regexp.MustCompile(`(?i)\Av?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:\.(\d+))?((?:[._-]?(?:stable|beta|b|rc|alpha|a|patch|pl|p)(?:[.-]?\d+)?)?(?:[.-]?dev)?)\z`)
+	composerWildcardPattern = regexp.MustCompile(`(?i)\Av?((?:\d+\.){0,3})[x*]\z`)
+)
+
+// composer stabilities ordered from least to most stable
+const (
+	composerDev = iota
+	composerAlpha
+	composerBeta
+	composerRC
+	composerStable
+	composerPatch
+)
+
+// composerBranchNumber is the number of an x in a branch alias like 1.x-dev
+const composerBranchNumber = 9999999
+
+type composerVersion struct {
+	raw string
+	// normalized is the normalized form of Composer, like 1.2.0.0-beta1 for v1.2-beta1
+	normalized string
+	// branch is the name of a dev-* version which has no numbers
+	branch    string
+	parts     [4]int64
+	stability int
+	number    int64
+	// dev is set if a pre-release is suffixed with -dev, like 1.0-beta2-dev
+	dev bool
+}
+
+func (v *composerVersion) String() string {
+	return v.raw
+}
+
+func (v *composerVersion) Normalized() string {
+	return v.normalized
+}
+
+func (v *composerVersion) IsPrerelease() bool {
+	return v.branch != "" || v.stability < composerStable || v.dev
+}
+
+func (v *composerVersion) Compare(o Version) int {
+	ov := o.(*composerVersion)
+	if v.branch != "" || ov.branch != "" {
+		switch {
+		case v.branch == "":
+			return 1
+		case ov.branch == "":
+			return -1
+		}
+		return strings.Compare(v.branch, ov.branch)
+	}
+	for i := range v.parts {
+		if result := compareInts(v.parts[i], ov.parts[i]); result != 0 {
+			return result
+		}
+	}
+	if result := compareInts(int64(v.stability), int64(ov.stability)); result != 0 {
+		return result
+	}
+	if result := compareInts(v.number, ov.number); result != 0 {
+		return result
+	}
+	if v.dev != ov.dev {
+		if v.dev {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+type composerScheme struct{}
+
+func (s *composerScheme) Name() string {
+	return "composer"
+}
+
+func (s *composerScheme) ParseVersion(v string) (Version, error) {
+	cv := parseComposerVersion(v)
+	if cv == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+	return cv, nil
+}
+
+func parseComposerVersion(v string) *composerVersion {
+	s := strings.TrimSpace(composerStabilityFlag.ReplaceAllString(strings.TrimSpace(v), ""))
+
+	if strings.HasPrefix(strings.ToLower(s), "dev-") {
+		if len(s) == len("dev-") {
+			return nil
+		}
+		return &composerVersion{raw: v, normalized: "dev-" + s[len("dev-"):], branch: strings.ToLower(s)}
+	}
+
+	if m := composerBranchPattern.FindStringSubmatch(s); m != nil {
+		cv := &composerVersion{raw: v, stability: composerDev}
+		// the missing parts of a branch alias like 1.x-dev are wildcards as well
+		wildcard := strings.ContainsAny(s, "xX*")
+		parts := make([]string, 0, 4)
+		for i := 0; i < 4; i++ {
+			switch p := strings.ToLower(m[i+1]); {
+			case p == "" && !wildcard:
+			case p == "", p == "x", p == "*":
+				cv.parts[i] = composerBranchNumber
+			default:
+				n, err := strconv.ParseInt(p, 10, 64)
+				if err != nil {
+					return nil
+				}
+				cv.parts[i] = n
+			}
+			parts = append(parts, strconv.FormatInt(cv.parts[i], 10))
+		}
+		cv.normalized = strings.Join(parts, ".") + "-dev"
+		return cv
+	}
+
+	m := composerPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil
+	}
+	cv := &composerVersion{raw: v, stability: composerStable}
+	parts := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		if m[i+1] != "" {
+			n, err := strconv.ParseInt(m[i+1], 10, 64)
+			if err != nil {
+				return nil
+			}
+			cv.parts[i] = n
+		}
+		parts = append(parts, strconv.FormatInt(cv.parts[i], 10))
+	}
+	cv.normalized = strings.Join(parts, ".")
+
+	var label string
+	switch strings.ToLower(m[5]) {
+	case "alpha", "a":
+		cv.stability, label = composerAlpha, "alpha"
+	case "beta", "b":
+		cv.stability, label = composerBeta, "beta"
+	case "rc":
+		cv.stability, label = composerRC, "RC"
+	case "patch", "pl", "p":
+		cv.stability, label = composerPatch, "patch"
+	}
+	if m[6] != "" {
+		n, err := strconv.ParseInt(m[6], 10, 64)
+		if err != nil {
+			return nil
+		}
+		cv.number = n
+	}
+	if label != "" {
+		cv.normalized += "-" + label + m[6]
+	}
+	if m[7] != "" {
+		if m[5] == "" {
+			cv.stability = composerDev
+		} else {
+			cv.dev = true
+		}
+		cv.normalized += "-dev"
+	}
+	return cv
+}
+
+func newComposerVersion(parts [4]int64, stability int) *composerVersion {
+	raw := fmt.Sprintf("%d.%d.%d.%d", parts[0], parts[1], parts[2], parts[3])
+	if stability == composerDev {
+		raw += "-dev"
+	}
+	return &composerVersion{raw: raw, normalized: raw, parts: parts, stability: stability}
+}
+
+// ParseRange parses a Composer constraint. Constraints separated by || match any, constraints separated by
+// a comma or space must all match.
+func (s *composerScheme) ParseRange(r string) (Range, error) {
+	cr := &constraintRange{raw: r}
+	for _, part := range strings.Split(strings.ReplaceAll(r, "||", "|"), "|") {
+		set, err := parseComposerSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		cr.sets = append(cr.sets, set)
+	}
+	return cr, nil
+}
+
+func parseComposerSet(s string) ([]*comparator, error) {
+	if s == "" {
+		return nil, ErrInvalidRange
+	}
+
+	if m := composerHyphenPattern.FindStringSubmatch(s); m != nil {
+		from, err := parseComposerPartial(m[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseComposerPartial(m[2])
+		if err != nil {
+			return nil, err
+		}
+		set := []*comparator{{op: ">=", version: from.lower()}}
+		if to.n == 4 || to.suffix != "" {
+			return append(set, &comparator{op: "<=", version: to.version}), nil
+		}
+		return append(set, &comparator{op: "<", version: to.next(to.n)}), nil
+	}
+
+	s = composerOperatorSpace.ReplaceAllString(strings.ReplaceAll(s, ",", " "), "$1")
+
+	var set []*comparator
+	for _, field := range strings.Fields(s) {
+		cs, err := parseComposerComparator(field)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+// composerPartial is a version of a constraint which may lack parts, n is the number of given parts
+type composerPartial struct {
+	version *composerVersion
+	n       int
+	// suffix contains the stability of the version, like -beta2
+	suffix string
+}
+
+func parseComposerPartial(s string) (*composerPartial, error) {
+	s = composerStabilityFlag.ReplaceAllString(s, "")
+	m := composerPartialPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, ErrInvalidRange
+	}
+	v := parseComposerVersion(s)
+	if v == nil {
+		return nil, ErrInvalidRange
+	}
+	p := &composerPartial{version: v, suffix: m[5]}
+	for i := 0; i < 4; i++ {
+		if m[i+1] != "" {
+			p.n++
+		}
+	}
+	return p, nil
+}
+
+// lower returns the smallest version matching the partial, including its dev versions
+func (p *composerPartial) lower() *composerVersion {
+	if p.suffix != "" {
+		return p.version
+	}
+	return newComposerVersion(p.version.parts, composerDev)
+}
+
+// next returns the dev version of the partial with the part at position n-1 incremented and all following parts reset
+func (p *composerPartial) next(n int) *composerVersion {
+	var parts [4]int64
+	copy(parts[:], p.version.parts[:n])
+	parts[n-1]++
+	return newComposerVersion(parts, composerDev)
+}
+
+func parseComposerComparator(s string) ([]*comparator, error) {
+	if s == "*" || strings.EqualFold(s, "x") {
+		return nil, nil
+	}
+
+	m := composerOperatorPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, ErrInvalidRange
+	}
+	op, value := m[1], m[2]
+
+	if w := composerWildcardPattern.FindStringSubmatch(value); w != nil {
+		if op != "" && op != "=" && op != "==" {
+			return nil, ErrInvalidRange
+		}
+		prefix := strings.Split(strings.TrimSuffix(w[1], "."), ".")
+		if w[1] == "" {
+			return nil, nil
+		}
+		p := &composerPartial{version: &composerVersion{}, n: len(prefix)}
+		for i, n := range prefix {
+			parsed, err := strconv.ParseInt(n, 10, 64)
+			if err != nil {
+				return nil, ErrInvalidRange
+			}
+			p.version.parts[i] = parsed
+		}
+		return []*comparator{{op: ">=", version: p.lower()}, {op: "<", version: p.next(p.n)}}, nil
+	}
+
+	// branch names only match by equality
+	if v := parseComposerVersion(value); v != nil && v.branch != "" {
+		switch op {
+		case "", "=", "==":
+			return []*comparator{{op: "=", version: v}}, nil
+		case "!=", "<>":
+			return []*comparator{{op: "!=", version: v}}, nil
+		}
+		return nil, ErrInvalidRange
+	}
+
+	p, err := parseComposerPartial(value)
+	if err != nil {
+		// branch aliases like 1.x-dev only match by equality
+		if v := parseComposerVersion(value); v != nil && (op == "" || op == "=" || op == "==") {
+			return []*comparator{{op: "=", version: v}}, nil
+		}
+		return nil, err
+	}
+
+	switch op {
+	case "~":
+		// the last given part may increase, ~1 is the same as ~1.0
+		n := p.n - 1
+		if n < 1 {
+			n = 1
+		}
+		return []*comparator{{op: ">=", version: p.lower()}, {op: "<", version: p.next(n)}}, nil
+	case "^":
+		n := 1
+		switch {
+		case p.version.parts[0] != 0 || p.n == 1:
+		case p.version.parts[1] != 0 || p.n == 2:
+			n = 2
+		default:
+			n = 3
+		}
+		return []*comparator{{op: ">=", version: p.lower()}, {op: "<", version: p.next(n)}}, nil
+	case ">=":
+		return []*comparator{{op: ">=", version: p.lower()}}, nil
+	case "<":
+		return []*comparator{{op: "<", version: p.lower()}}, nil
+	case ">", "<=":
+		return []*comparator{{op: op, version: p.version}}, nil
+	case "!=", "<>":
+		return []*comparator{{op: "!=", version: p.version}}, nil
+	}
+	return []*comparator{{op: "=", version: p.version}}, nil
+}
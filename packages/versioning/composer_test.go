@@ -0,0 +1,74 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposerCompare(t *testing.T) {
+	assertAscending(t, Composer, []string{"dev-feature", "dev-main", "1.0.0-dev", "1.0.0-alpha1", "1.0.0-beta1", "1.0.0-beta2", "1.0.0-RC1", "1.0.0", "1.0.0-p1", "1.0.1", "v1.1", "1.1.0.1", "1.x-dev"})
+
+	assertEqual(t, Composer, "1.0", "1.0.0.0")
+	assertEqual(t, Composer, "v2.0.0", "2.0.0-stable")
+	assertEqual(t, Composer, "1.0.0@beta", "1.0.0")
+
+	for version, prerelease := range map[string]bool{"1.0": false, "1.0-beta": true, "dev-main": true, "1.x-dev": true} {
+		v, err := Composer.ParseVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, prerelease, v.IsPrerelease(), version)
+	}
+
+	_, err := Composer.ParseVersion("latest")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestComposerNormalize(t *testing.T) {
+	for version, expected := range map[string]string{
+		"v1.2":         "1.2.0.0",
+		"1.0-rc1":      "1.0.0.0-RC1",
+		"1.0.0-b.2":    "1.0.0.0-beta2",
+		"1.0-dev":      "1.0.0.0-dev",
+		"1.0-beta-dev": "1.0.0.0-beta-dev",
+		"1.x-dev":      "1.9999999.9999999.9999999-dev",
+		"dev-Main":     "dev-Main",
+	} {
+		normalized, err := Normalize(Composer, version)
+		assert.NoError(t, err, version)
+		assert.Equal(t, expected, normalized, version)
+	}
+
+	// versions of other schemes are returned unchanged
+	normalized, err := Normalize(SemVer, "1.0.0-beta")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0-beta", normalized)
+
+	_, err = Normalize(Composer, "latest")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestComposerRange(t *testing.T) {
+	assertSatisfies(t, Composer, "^1.2.3", []string{"1.2.3", "1.9", "1.3.0-beta"}, []string{"1.2.2", "2.0.0", "2.0.0-dev"})
+	assertSatisfies(t, Composer, "^0.3", []string{"0.3.0", "0.3.9"}, []string{"0.4.0"})
+	assertSatisfies(t, Composer, "^0.0.3", []string{"0.0.3"}, []string{"0.0.4"})
+	assertSatisfies(t, Composer, "~1.2", []string{"1.2", "1.9"}, []string{"2.0"})
+	assertSatisfies(t, Composer, "~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3"})
+	assertSatisfies(t, Composer, "1.0.*", []string{"1.0.0", "1.0.9", "1.0.0-dev"}, []string{"1.1.0"})
+	assertSatisfies(t, Composer, "1.0 - 2.0", []string{"1.0", "2.0.5"}, []string{"2.1", "0.9"})
+	assertSatisfies(t, Composer, ">=1.0 <1.1 || >=1.2", []string{"1.0.5", "1.2"}, []string{"1.1"})
+	assertSatisfies(t, Composer, ">= 1.0, != 1.5", []string{"1.0", "1.6"}, []string{"1.5.0"})
+	assertSatisfies(t, Composer, "1.2.3", []string{"1.2.3", "1.2.3.0"}, []string{"1.2.4"})
+	assertSatisfies(t, Composer, "*", []string{"0.1", "5.0"}, nil)
+	assertSatisfies(t, Composer, "dev-main", []string{"dev-main"}, []string{"dev-feature", "1.0"})
+	assertSatisfies(t, Composer, "^1.0@dev", []string{"1.5-dev"}, []string{"2.0"})
+
+	for _, r := range []string{"", ">=a.b", ">dev-main", ">=1.*"} {
+		_, err := Composer.ParseRange(r)
+		assert.ErrorIs(t, err, ErrInvalidRange, r)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"strings"
+)
+
+// isInterval tests if the range uses the interval notation of Maven and NuGet
+func isInterval(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "[") || strings.HasPrefix(s, "(")
+}
+
+// parseIntervals parses a comma separated union of intervals like [1.0,2.0),(,1.0],[1.5]
+func parseIntervals(s string, parse func(string) (Version, error)) ([][]*comparator, error) {
+	var sets [][]*comparator
+
+	s = strings.TrimSpace(s)
+	for s != "" {
+		if s[0] != '[' && s[0] != '(' {
+			return nil, ErrInvalidRange
+		}
+		end := strings.IndexAny(s, "])")
+		if end == -1 {
+			return nil, ErrInvalidRange
+		}
+		opening, closing, inner := s[0], s[end], s[1:end]
+
+		var set []*comparator
+		lower, upper, isRange := strings.Cut(inner, ",")
+		if !isRange {
+			// [1.0] is the only valid form of a single version
+			if opening != '[' || closing != ']' {
+				return nil, ErrInvalidRange
+			}
+			v, err := parse(strings.TrimSpace(inner))
+			if err != nil {
+				return nil, ErrInvalidRange
+			}
+			set = append(set, &comparator{op: "=", version: v})
+		} else {
+			if strings.Contains(upper, ",") {
+				return nil, ErrInvalidRange
+			}
+			if lower = strings.TrimSpace(lower); lower != "" {
+				v, err := parse(lower)
+				if err != nil {
+					return nil, ErrInvalidRange
+				}
+				op := ">="
+				if opening == '(' {
+					op = ">"
+				}
+				set = append(set, &comparator{op: op, version: v})
+			}
+			if upper = strings.TrimSpace(upper); upper != "" {
+				v, err := parse(upper)
+				if err != nil {
+					return nil, ErrInvalidRange
+				}
+				op := "<="
+				if closing == ')' {
+					op = "<"
+				}
+				set = append(set, &comparator{op: op, version: v})
+			}
+		}
+		sets = append(sets, set)
+
+		s = strings.TrimSpace(s[end+1:])
+		if s != "" {
+			if s[0] != ',' {
+				return nil, ErrInvalidRange
+			}
+			s = strings.TrimSpace(s[1:])
+			if s == "" {
+				return nil, ErrInvalidRange
+			}
+		}
+	}
+	return sets, nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Maven implements the ComparableVersion semantics of Maven with version ranges like [1.0,2.0),(,1.0].
+// Every string is a valid version. A plain version used as range is a soft requirement which resolves to exactly that version.
+var Maven Scheme = &mavenScheme{}
+
+// https://maven.apache.org/ref/3.9.0/maven-artifact/apidocs/org/apache/maven/artifact/versioning/ComparableVersion.html
+
+type mavenItemKind int
+
+const (
+	mavenInt mavenItemKind = iota
+	mavenString
+	mavenList
+)
+
+type mavenItem struct {
+	kind  mavenItemKind
+	value string
+	items []*mavenItem
+}
+
+var (
+	mavenQualifiers       = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+	mavenQualifierAliases = map[string]string{"ga": "", "final": "", "release": "", "cr": "rc"}
+)
+
+// mavenReleaseIndex is the comparable qualifier of a release version
+const mavenReleaseIndex = "5"
+
+func newMavenStringItem(value string, followedByDigit bool) *mavenItem {
+	if followedByDigit && len(value) == 1 {
+		switch value {
+		case "a":
+			value = "alpha"
+		case "b":
+			value = "beta"
+		case "m":
+			value = "milestone"
+		}
+	}
+	if alias, ok := mavenQualifierAliases[value]; ok {
+		value = alias
+	}
+	return &mavenItem{kind: mavenString, value: value}
+}
+
+func newMavenItem(isDigit bool, value string) *mavenItem {
+	if isDigit {
+		return &mavenItem{kind: mavenInt, value: strings.TrimLeft(value, "0")}
+	}
+	return newMavenStringItem(value, false)
+}
+
+func (i *mavenItem) isNull() bool {
+	if i.kind == mavenList {
+		return len(i.items) == 0
+	}
+	return i.value == ""
+}
+
+func (i *mavenItem) normalize() {
+	for j := len(i.items) - 1; j >= 0; j-- {
+		last := i.items[j]
+		if last.isNull() {
+			i.items = append(i.items[:j], i.items[j+1:]...)
+		} else if last.kind != mavenList {
+			break
+		}
+	}
+}
+
+// isPrerelease tests if the item contains a qualifier which sorts before a release
+func (i *mavenItem) isPrerelease() bool {
+	switch i.kind {
+	case mavenString:
+		return mavenComparableQualifier(i.value) < mavenReleaseIndex
+	case mavenList:
+		for _, item := range i.items {
+			if item.isPrerelease() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mavenComparableQualifier(qualifier string) string {
+	for i, q := range mavenQualifiers {
+		if q == qualifier {
+			return strconv.Itoa(i)
+		}
+	}
+	return strconv.Itoa(len(mavenQualifiers)) + "-" + qualifier
+}
+
+// compare compares the item with another item which may be nil
+func (i *mavenItem) compare(o *mavenItem) int {
+	switch i.kind {
+	case mavenInt:
+		if o == nil {
+			if i.value == "" {
+				return 0
+			}
+			return 1
+		}
+		if o.kind == mavenInt {
+			return compareNumbers(i.value, o.value)
+		}
+		return 1
+	case mavenString:
+		if o == nil {
+			return strings.Compare(mavenComparableQualifier(i.value), mavenReleaseIndex)
+		}
+		if o.kind == mavenString {
+			return strings.Compare(mavenComparableQualifier(i.value), mavenComparableQualifier(o.value))
+		}
+		return -1
+	}
+
+	if o == nil {
+		for _, item := range i.items {
+			if result := item.compare(nil); result != 0 {
+				return result
+			}
+		}
+		return 0
+	}
+	switch o.kind {
+	case mavenInt:
+		return -1
+	case mavenString:
+		return 1
+	}
+	for j := 0; j < len(i.items) || j < len(o.items); j++ {
+		var l, r *mavenItem
+		if j < len(i.items) {
+			l = i.items[j]
+		}
+		if j < len(o.items) {
+			r = o.items[j]
+		}
+		var result int
+		if l == nil {
+			if r != nil {
+				result = -r.compare(nil)
+			}
+		} else {
+			result = l.compare(r)
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+func parseMavenItems(v string) *mavenItem {
+	v = strings.ToLower(v)
+
+	root := &mavenItem{kind: mavenList}
+	list := root
+	stack := []*mavenItem{root}
+
+	pushList := func() {
+		sub := &mavenItem{kind: mavenList}
+		list.items = append(list.items, sub)
+		list = sub
+		stack = append(stack, sub)
+	}
+
+	isDigit := false
+	start := 0
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '.' || c == '-':
+			if i == start {
+				list.items = append(list.items, &mavenItem{kind: mavenInt})
+			} else {
+				list.items = append(list.items, newMavenItem(isDigit, v[start:i]))
+			}
+			start = i + 1
+			if c == '-' {
+				pushList()
+			}
+		case c >= '0' && c <= '9':
+			if !isDigit && i > start {
+				list.items = append(list.items, newMavenStringItem(v[start:i], true))
+				start = i
+				pushList()
+			}
+			isDigit = true
+		default:
+			if isDigit && i > start {
+				list.items = append(list.items, newMavenItem(true, v[start:i]))
+				start = i
+				pushList()
+			}
+			isDigit = false
+		}
+	}
+	if len(v) > start {
+		list.items = append(list.items, newMavenItem(isDigit, v[start:]))
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		stack[i].normalize()
+	}
+	return root
+}
+
+type mavenVersion struct {
+	raw   string
+	items *mavenItem
+}
+
+func (v *mavenVersion) String() string {
+	return v.raw
+}
+
+func (v *mavenVersion) Compare(o Version) int {
+	return v.items.compare(o.(*mavenVersion).items)
+}
+
+func (v *mavenVersion) IsPrerelease() bool {
+	return v.items.isPrerelease()
+}
+
+type mavenScheme struct{}
+
+func (s *mavenScheme) Name() string {
+	return "maven"
+}
+
+func (s *mavenScheme) ParseVersion(v string) (Version, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+	return &mavenVersion{raw: v, items: parseMavenItems(strings.TrimSpace(v))}, nil
+}
+
+func (s *mavenScheme) ParseRange(r string) (Range, error) {
+	if !isInterval(r) {
+		v, err := s.ParseVersion(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		return &constraintRange{raw: r, sets: [][]*comparator{{{op: "=", version: v}}}}, nil
+	}
+
+	sets, err := parseIntervals(r, s.ParseVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+	}
+	return &constraintRange{raw: r, sets: sets}, nil
+}
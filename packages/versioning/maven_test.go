@@ -0,0 +1,40 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMavenCompare(t *testing.T) {
+	assertAscending(t, Maven, []string{"1-alpha", "1-alpha2", "1-beta", "1-milestone", "1-rc", "1-SNAPSHOT", "1", "1-sp", "1-foo", "1.1", "1.2-SNAPSHOT", "1.2", "1.10"})
+	assertAscending(t, Maven, []string{"1.0-alpha-1", "1.0-alpha-2", "1.0-a3", "1.0-b1", "1.0"})
+
+	for _, equal := range [][2]string{{"1", "1.0"}, {"1", "1-ga"}, {"1.0.0", "1.0.0.RELEASE"}, {"1-rc", "1-cr"}, {"1-Final", "1.0"}} {
+		assertEqual(t, Maven, equal[0], equal[1])
+	}
+
+	for version, prerelease := range map[string]bool{"1.0": false, "1.0-SNAPSHOT": true, "1.0-rc1": true, "1.0.Final": false, "1.0-sp1": false} {
+		v, err := Maven.ParseVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, prerelease, v.IsPrerelease(), version)
+	}
+}
+
+func TestMavenRange(t *testing.T) {
+	assertSatisfies(t, Maven, "[1.0,2.0)", []string{"1.0", "1.5", "2.0-SNAPSHOT"}, []string{"0.9", "2.0"})
+	assertSatisfies(t, Maven, "(,1.0]", []string{"0.1", "1.0"}, []string{"1.0.1"})
+	assertSatisfies(t, Maven, "[1.5]", []string{"1.5", "1.5.0"}, []string{"1.5.1"})
+	assertSatisfies(t, Maven, "(,1.0],[1.2,)", []string{"1.0", "1.2", "3"}, []string{"1.1"})
+	assertSatisfies(t, Maven, "1.0", []string{"1.0"}, []string{"1.1"})
+
+	for _, r := range []string{"[1.0", "(1.0)", "[1.0,2.0),", "[1,2,3]"} {
+		_, err := Maven.ParseRange(r)
+		assert.ErrorIs(t, err, ErrInvalidRange, r)
+	}
+}
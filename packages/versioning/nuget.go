@@ -0,0 +1,146 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NuGet implements the NuGet version scheme with up to four numeric parts, case-insensitive labels
+// and version ranges like [1.0,2.0), 1.* and 1.0 which means 1.0 or newer.
+var NuGet Scheme = &nugetScheme{}
+
+var (
+	nugetPattern         = regexp.MustCompile(`\A\s*[vV]?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?\s*\z`)
+	nugetWildcardPattern = regexp.MustCompile(`\A((?:\d+\.){0,3})\*\z`)
+)
+
+type nugetVersion struct {
+	raw   string
+	parts [4]int64
+	// label is the pre-release label in its original case
+	label      string
+	prerelease []string
+}
+
+func (v *nugetVersion) String() string {
+	return v.raw
+}
+
+// Normalized returns the version with three or four parts without leading zeros and without build metadata,
+// like 1.0.0-Beta for 01.0.0.0-Beta+abc. The case of the pre-release label is kept.
+func (v *nugetVersion) Normalized() string {
+	n := 3
+	if v.parts[3] != 0 {
+		n = 4
+	}
+	parts := make([]string, 0, n)
+	for _, p := range v.parts[:n] {
+		parts = append(parts, strconv.FormatInt(p, 10))
+	}
+	normalized := strings.Join(parts, ".")
+	if v.label != "" {
+		normalized += "-" + v.label
+	}
+	return normalized
+}
+
+func (v *nugetVersion) Compare(o Version) int {
+	ov := o.(*nugetVersion)
+	for i := range v.parts {
+		if result := compareInts(v.parts[i], ov.parts[i]); result != 0 {
+			return result
+		}
+	}
+	return comparePrerelease(v.prerelease, ov.prerelease)
+}
+
+func (v *nugetVersion) IsPrerelease() bool {
+	return len(v.prerelease) > 0
+}
+
+type nugetScheme struct{}
+
+func (s *nugetScheme) Name() string {
+	return "nuget"
+}
+
+func (s *nugetScheme) ParseVersion(v string) (Version, error) {
+	nv := parseNuGetVersion(v)
+	if nv == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+	return nv, nil
+}
+
+func parseNuGetVersion(v string) *nugetVersion {
+	m := nugetPattern.FindStringSubmatch(v)
+	if m == nil {
+		return nil
+	}
+	nv := &nugetVersion{raw: v}
+	for i := 0; i < 4; i++ {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return nil
+		}
+		nv.parts[i] = n
+	}
+	if m[5] != "" {
+		nv.label = m[5]
+		nv.prerelease = strings.Split(strings.ToLower(m[5]), ".")
+	}
+	return nv
+}
+
+// ParseRange parses a NuGet version range. A plain version is a minimum version, a floating version like 1.2.*
+// matches all versions with that prefix.
+func (s *nugetScheme) ParseRange(r string) (Range, error) {
+	cr := &constraintRange{raw: r, allow: allowExplicitPrerelease}
+
+	trimmed := strings.TrimSpace(r)
+	switch {
+	case isInterval(trimmed):
+		sets, err := parseIntervals(trimmed, s.ParseVersion)
+		if err != nil || len(sets) != 1 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		cr.sets = sets
+	case nugetWildcardPattern.MatchString(trimmed):
+		prefix := strings.Split(strings.TrimSuffix(trimmed, "*"), ".")
+		prefix = prefix[:len(prefix)-1]
+		if len(prefix) == 0 {
+			cr.sets = [][]*comparator{nil}
+			break
+		}
+		lower := &nugetVersion{raw: strings.Join(prefix, ".")}
+		upper := &nugetVersion{}
+		for i, p := range prefix {
+			n, err := strconv.ParseInt(p, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+			}
+			lower.parts[i] = n
+			upper.parts[i] = n
+		}
+		upper.parts[len(prefix)-1]++
+		upper.raw = fmt.Sprintf("%d.%d.%d.%d", upper.parts[0], upper.parts[1], upper.parts[2], upper.parts[3])
+		cr.sets = [][]*comparator{{{op: ">=", version: lower}, {op: "<", version: upper}}}
+	default:
+		v := parseNuGetVersion(trimmed)
+		if v == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		cr.sets = [][]*comparator{{{op: ">=", version: v}}}
+	}
+	return cr, nil
+}
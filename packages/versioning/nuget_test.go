@@ -0,0 +1,52 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNuGetCompare(t *testing.T) {
+	assertAscending(t, NuGet, []string{"1.0.0-Beta", "1.0.0-rc", "1.0.0", "1.0.0.1", "1.1"})
+
+	assertEqual(t, NuGet, "1.0.0-BETA", "1.0.0-beta")
+	assertEqual(t, NuGet, "1.0", "1.0.0.0")
+	assertEqual(t, NuGet, "1.0.0+abc", "1.0.0")
+
+	_, err := NuGet.ParseVersion("1.0.0.0.0")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestNuGetNormalize(t *testing.T) {
+	for version, expected := range map[string]string{
+		"1.0":           "1.0.0",
+		"1.0.0.0":       "1.0.0",
+		"1.0.0.1":       "1.0.0.1",
+		"01.002.3":      "1.2.3",
+		"1.0.0-Beta.1":  "1.0.0-Beta.1",
+		"1.0.0-rc+sha1": "1.0.0-rc",
+	} {
+		normalized, err := Normalize(NuGet, version)
+		assert.NoError(t, err, version)
+		assert.Equal(t, expected, normalized, version)
+	}
+}
+
+func TestNuGetRange(t *testing.T) {
+	assertSatisfies(t, NuGet, "1.0", []string{"1.0", "5.0"}, []string{"0.9", "2.0.0-beta"})
+	assertSatisfies(t, NuGet, "[1.0,2.0)", []string{"1.0", "1.9"}, []string{"2.0", "1.5.0-beta"})
+	assertSatisfies(t, NuGet, "(1.0,)", []string{"1.0.1"}, []string{"1.0"})
+	assertSatisfies(t, NuGet, "[1.0]", []string{"1.0.0.0"}, []string{"1.0.1"})
+	assertSatisfies(t, NuGet, "[1.0.0-beta,2.0)", []string{"1.0.0-beta", "1.5.0-rc"}, []string{"1.0.0-alpha"})
+	assertSatisfies(t, NuGet, "1.2.*", []string{"1.2.0", "1.2.9.1"}, []string{"1.3.0", "1.1.9"})
+	assertSatisfies(t, NuGet, "1.*", []string{"1.0", "1.99"}, []string{"2.0"})
+	assertSatisfies(t, NuGet, "*", []string{"0.1", "9.0"}, []string{"1.0-beta"})
+
+	_, err := NuGet.ParseRange("[1.0,2.0),[3.0,4.0)")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PEP440 implements the Python version scheme with specifiers like ~=1.4, ==1.*, !=1.5 and >=1.0,<2
+var PEP440 Scheme = &pep440Scheme{}
+
+// https://peps.python.org/pep-0440/#appendix-b-parsing-version-strings-with-regular-expressions
+var (
+	pep440Pattern          = regexp.MustCompile(`(?i)\A\s*v?(?:(?:(?P<epoch>[0-9]+)!)?(?P<release>[0-9]+(?:\.[0-9]+)*)(?P<pre>[-_\.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_\.]?(?P<pre_n>[0-9]+)?)?(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_\.]?(?P<post_l>post|rev|r)[-_\.]?(?P<post_n2>[0-9]+)?))?(?P<dev>[-_\.]?(?P<dev_l>dev)[-_\.]?(?P<dev_n>[0-9]+)?)?)(?:\+(?P<local>[a-z0-9]+(?:[-_\.][a-z0-9]+)*))?\s*\z`)
+	pep440SpecifierPattern = regexp.MustCompile(`\A\s*(~=|===|==|!=|<=|>=|<|>)\s*(\S+)\s*\z`)
+)
+
+type pep440Version struct {
+	raw     string
+	epoch   int64
+	release []int64
+	// preRank orders the phases dev-only < a < b < rc < final
+	preRank int
+	pre     int64
+	hasPost bool
+	post    int64
+	hasDev  bool
+	dev     int64
+	local   []string
+}
+
+func (v *pep440Version) String() string {
+	return v.raw
+}
+
+func (v *pep440Version) IsPrerelease() bool {
+	return v.hasDev || v.preRank < 4
+}
+
+func (v *pep440Version) Compare(o Version) int {
+	ov := o.(*pep440Version)
+	if result := v.comparePublic(ov); result != 0 {
+		return result
+	}
+	return comparePEP440Local(v.local, ov.local)
+}
+
+// compareBase compares the epoch and release segments
+func (v *pep440Version) compareBase(o *pep440Version) int {
+	if result := compareInts(v.epoch, o.epoch); result != 0 {
+		return result
+	}
+	for i := 0; i < len(v.release) || i < len(o.release); i++ {
+		var a, b int64
+		if i < len(v.release) {
+			a = v.release[i]
+		}
+		if i < len(o.release) {
+			b = o.release[i]
+		}
+		if result := compareInts(a, b); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// comparePublic compares the versions without their local segments
+func (v *pep440Version) comparePublic(o *pep440Version) int {
+	if result := v.compareBase(o); result != 0 {
+		return result
+	}
+	if result := compareInts(int64(v.preRank), int64(o.preRank)); result != 0 {
+		return result
+	}
+	if result := compareInts(v.pre, o.pre); result != 0 {
+		return result
+	}
+	if v.hasPost != o.hasPost {
+		if v.hasPost {
+			return 1
+		}
+		return -1
+	}
+	if result := compareInts(v.post, o.post); result != 0 {
+		return result
+	}
+	if v.hasDev != o.hasDev {
+		if v.hasDev {
+			return -1
+		}
+		return 1
+	}
+	return compareInts(v.dev, o.dev)
+}
+
+// hasReleasePrefix tests if the epoch matches and the release starts with the release of the prefix,
+// missing release segments are treated as zero
+func (v *pep440Version) hasReleasePrefix(prefix *pep440Version) bool {
+	if v.epoch != prefix.epoch {
+		return false
+	}
+	for i, p := range prefix.release {
+		var r int64
+		if i < len(v.release) {
+			r = v.release[i]
+		}
+		if r != p {
+			return false
+		}
+	}
+	return true
+}
+
+func comparePEP440Local(a, b []string) int {
+	// a version without local segment sorts before the same version with one
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, nb := isNumeric(a[i]), isNumeric(b[i])
+		var result int
+		switch {
+		case na && nb:
+			result = compareNumbers(a[i], b[i])
+		case na:
+			result = 1
+		case nb:
+			result = -1
+		default:
+			result = strings.Compare(a[i], b[i])
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+type pep440Scheme struct{}
+
+func (s *pep440Scheme) Name() string {
+	return "pep440"
+}
+
+func (s *pep440Scheme) ParseVersion(v string) (Version, error) {
+	pv := parsePEP440Version(v)
+	if pv == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+	return pv, nil
+}
+
+func parsePEP440Version(v string) *pep440Version {
+	m := pep440Pattern.FindStringSubmatch(v)
+	if m == nil {
+		return nil
+	}
+	group := func(name string) string {
+		return m[pep440Pattern.SubexpIndex(name)]
+	}
+	parseInt := func(s string) int64 {
+		i, _ := strconv.ParseInt(s, 10, 64)
+		return i
+	}
+
+	pv := &pep440Version{
+		raw:   v,
+		epoch: parseInt(group("epoch")),
+	}
+	for _, r := range strings.Split(group("release"), ".") {
+		pv.release = append(pv.release, parseInt(r))
+	}
+
+	if group("post") != "" {
+		pv.hasPost = true
+		pv.post = parseInt(group("post_n1") + group("post_n2"))
+	}
+	if group("dev") != "" {
+		pv.hasDev = true
+		pv.dev = parseInt(group("dev_n"))
+	}
+
+	switch strings.ToLower(group("pre_l")) {
+	case "a", "alpha":
+		pv.preRank = 1
+	case "b", "beta":
+		pv.preRank = 2
+	case "c", "rc", "pre", "preview":
+		pv.preRank = 3
+	default:
+		pv.preRank = 4
+		if pv.hasDev && !pv.hasPost {
+			pv.preRank = 0
+		}
+	}
+	pv.pre = parseInt(group("pre_n"))
+
+	if local := group("local"); local != "" {
+		pv.local = strings.FieldsFunc(strings.ToLower(local), func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		})
+	}
+	return pv
+}
+
+// ParseRange parses comma separated version specifiers of PEP 440
+func (s *pep440Scheme) ParseRange(r string) (Range, error) {
+	var set []*comparator
+	for _, part := range strings.Split(r, ",") {
+		if strings.TrimSpace(part) == "" {
+			if strings.TrimSpace(r) == "" {
+				break
+			}
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		c, err := parsePEP440Specifier(part)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		set = append(set, c)
+	}
+	return &constraintRange{raw: r, sets: [][]*comparator{set}, allow: allowExplicitPrerelease}, nil
+}
+
+func parsePEP440Specifier(s string) (*comparator, error) {
+	m := pep440SpecifierPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, ErrInvalidRange
+	}
+	op, value := m[1], m[2]
+
+	if op == "===" {
+		return &comparator{op: op, match: func(v Version) bool {
+			return strings.EqualFold(strings.TrimSpace(v.String()), value)
+		}}, nil
+	}
+
+	if strings.HasSuffix(value, ".*") {
+		if op != "==" && op != "!=" {
+			return nil, ErrInvalidRange
+		}
+		prefix := parsePEP440Version(strings.TrimSuffix(value, ".*"))
+		if prefix == nil {
+			return nil, ErrInvalidRange
+		}
+		negate := op == "!="
+		return &comparator{op: op, match: func(v Version) bool {
+			return v.(*pep440Version).hasReleasePrefix(prefix) != negate
+		}}, nil
+	}
+
+	spec := parsePEP440Version(value)
+	if spec == nil {
+		return nil, ErrInvalidRange
+	}
+	c := &comparator{op: op, version: spec}
+
+	switch op {
+	case "~=":
+		if len(spec.release) < 2 || len(spec.local) > 0 {
+			return nil, ErrInvalidRange
+		}
+		prefix := &pep440Version{epoch: spec.epoch, release: spec.release[:len(spec.release)-1]}
+		c.match = func(v Version) bool {
+			pv := v.(*pep440Version)
+			return pv.comparePublic(spec) >= 0 && pv.hasReleasePrefix(prefix)
+		}
+	case "==", "!=":
+		// the local segment of the candidate is ignored if the specifier has none
+		negate := op == "!="
+		c.match = func(v Version) bool {
+			pv := v.(*pep440Version)
+			equal := pv.comparePublic(spec) == 0 && (len(spec.local) == 0 || comparePEP440Local(pv.local, spec.local) == 0)
+			return equal != negate
+		}
+	case "<=", ">=":
+		c.match = func(v Version) bool {
+			result := v.(*pep440Version).comparePublic(spec)
+			if op == "<=" {
+				return result <= 0
+			}
+			return result >= 0
+		}
+	case "<":
+		// a pre-release of the specified version is excluded unless the specified version is a pre-release
+		c.match = func(v Version) bool {
+			pv := v.(*pep440Version)
+			if pv.comparePublic(spec) >= 0 {
+				return false
+			}
+			return spec.IsPrerelease() || !pv.IsPrerelease() || pv.compareBase(spec) != 0
+		}
+	case ">":
+		// post-releases and local versions of the specified version are excluded
+		c.match = func(v Version) bool {
+			pv := v.(*pep440Version)
+			if pv.comparePublic(spec) <= 0 {
+				return false
+			}
+			if pv.hasPost && !spec.hasPost && pv.compareBase(spec) == 0 {
+				return false
+			}
+			return true
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPEP440Compare(t *testing.T) {
+	assertAscending(t, PEP440, []string{
+		"1.0.dev0",
+		"1.0a1.dev1",
+		"1.0a1",
+		"1.0b1",
+		"1.0rc1",
+		"1.0",
+		"1.0+abc",
+		"1.0+abc.5",
+		"1.0+5",
+		"1.0.post1.dev0",
+		"1.0.post1",
+		"1.1",
+		"1!0.1",
+	})
+
+	assertEqual(t, PEP440, "1.0.0", "1.0")
+	assertEqual(t, PEP440, "1.0-1", "1.0.post1")
+
+	_, err := PEP440.ParseVersion("1.0-foo")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestPEP440Range(t *testing.T) {
+	assertSatisfies(t, PEP440, "~=1.4.2", []string{"1.4.2", "1.4.9"}, []string{"1.5", "1.4.1"})
+	assertSatisfies(t, PEP440, "~=2.2", []string{"2.2", "2.9"}, []string{"3.0"})
+	assertSatisfies(t, PEP440, "==1.1.*", []string{"1.1", "1.1.5", "1.1.post1"}, []string{"1.2", "1.10"})
+	assertSatisfies(t, PEP440, "!=1.5.*, >=1.0", []string{"1.0", "1.6"}, []string{"1.5.3", "0.9"})
+	assertSatisfies(t, PEP440, "==1.0", []string{"1.0.0", "1.0+local"}, []string{"1.0.post1"})
+	assertSatisfies(t, PEP440, ">=1.0,<2", []string{"1.0", "1.9.9"}, []string{"2.0", "2.0a1", "1.5rc1"})
+	assertSatisfies(t, PEP440, ">=2.0rc1", []string{"2.0rc1", "2.0"}, []string{"2.0b1"})
+	assertSatisfies(t, PEP440, ">1.7", []string{"1.8"}, []string{"1.7.post2", "1.7"})
+	assertSatisfies(t, PEP440, "===1.0", []string{"1.0"}, []string{"1.0.0"})
+	assertSatisfies(t, PEP440, "", []string{"0.1", "5"}, nil)
+
+	_, err := PEP440.ParseRange("~=1")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = PEP440.ParseRange(">=1.*")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
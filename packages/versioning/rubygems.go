@@ -0,0 +1,183 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RubyGems implements the Gem::Version semantics with requirements like ~> 1.2, >= 1.0, != 1.5
+var RubyGems Scheme = &rubygemsScheme{}
+
+// https://github.com/rubygems/rubygems/blob/master/lib/rubygems/version.rb
+var (
+	rubygemsPattern            = regexp.MustCompile(`\A\s*[0-9]+(?:\.[0-9a-zA-Z]+)*(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?\s*\z`)
+	rubygemsSegmentPattern     = regexp.MustCompile(`[0-9]+|[a-z]+`)
+	rubygemsRequirementPattern = regexp.MustCompile(`\A\s*(=|!=|>=|<=|>|<|~>)?\s*(\S+)\s*\z`)
+)
+
+type rubygemsVersion struct {
+	raw string
+	// segments contains the canonical strings of digits or letters without trailing zeros
+	segments []string
+	// original contains the segments as given, which is relevant for the ~> operator
+	original []string
+}
+
+func (v *rubygemsVersion) String() string {
+	return v.raw
+}
+
+func (v *rubygemsVersion) IsPrerelease() bool {
+	for _, s := range v.segments {
+		if !isNumeric(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *rubygemsVersion) Compare(o Version) int {
+	ov := o.(*rubygemsVersion)
+	for i := 0; i < len(v.segments) || i < len(ov.segments); i++ {
+		a, b := "0", "0"
+		if i < len(v.segments) {
+			a = v.segments[i]
+		}
+		if i < len(ov.segments) {
+			b = ov.segments[i]
+		}
+		na, nb := isNumeric(a), isNumeric(b)
+		var result int
+		switch {
+		case na && nb:
+			result = compareNumbers(a, b)
+		case na:
+			result = 1
+		case nb:
+			result = -1
+		default:
+			result = strings.Compare(a, b)
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// releaseSegments returns the segments before the first pre-release segment
+func releaseSegments(segments []string) []string {
+	for i, s := range segments {
+		if !isNumeric(s) {
+			return segments[:i]
+		}
+	}
+	return segments
+}
+
+// release returns the version without its pre-release segments
+func (v *rubygemsVersion) release() *rubygemsVersion {
+	return newRubyGemsVersion(releaseSegments(v.original))
+}
+
+// bump returns the release with the last segment removed and the new last segment incremented
+func (v *rubygemsVersion) bump() *rubygemsVersion {
+	segments := append([]string{}, releaseSegments(v.original)...)
+	if len(segments) > 1 {
+		segments = segments[:len(segments)-1]
+	}
+	segments[len(segments)-1] = incrementNumber(segments[len(segments)-1])
+	return newRubyGemsVersion(segments)
+}
+
+// incrementNumber adds one to a string of digits of any length
+func incrementNumber(s string) string {
+	digits := []byte(strings.TrimLeft(s, "0"))
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] < '9' {
+			digits[i]++
+			return string(digits)
+		}
+		digits[i] = '0'
+	}
+	return "1" + string(digits)
+}
+
+type rubygemsScheme struct{}
+
+func (s *rubygemsScheme) Name() string {
+	return "rubygems"
+}
+
+func (s *rubygemsScheme) ParseVersion(v string) (Version, error) {
+	rv := parseRubyGemsVersion(v)
+	if rv == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+	return rv, nil
+}
+
+func parseRubyGemsVersion(v string) *rubygemsVersion {
+	if !rubygemsPattern.MatchString(v) {
+		return nil
+	}
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(v), "-", ".pre."))
+
+	rv := newRubyGemsVersion(rubygemsSegmentPattern.FindAllString(normalized, -1))
+	rv.raw = v
+	return rv
+}
+
+func newRubyGemsVersion(segments []string) *rubygemsVersion {
+	// canonical segments drop trailing zeros of the release and of the pre-release part
+	release := releaseSegments(segments)
+	rest := trimZeroSegments(segments[len(release):])
+	release = trimZeroSegments(release)
+
+	return &rubygemsVersion{
+		raw:      strings.Join(segments, "."),
+		segments: append(append([]string{}, release...), rest...),
+		original: segments,
+	}
+}
+
+func trimZeroSegments(segments []string) []string {
+	for len(segments) > 0 && strings.TrimLeft(segments[len(segments)-1], "0") == "" {
+		segments = segments[:len(segments)-1]
+	}
+	return segments
+}
+
+// ParseRange parses comma separated requirements like ~> 1.2, >= 1.2.3, an omitted operator means equality
+func (s *rubygemsScheme) ParseRange(r string) (Range, error) {
+	var set []*comparator
+	for _, part := range strings.Split(r, ",") {
+		m := rubygemsRequirementPattern.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		v := parseRubyGemsVersion(m[2])
+		if v == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		c := &comparator{op: m[1], version: v}
+		switch c.op {
+		case "":
+			c.op = "="
+		case "~>":
+			upper := v.bump()
+			c.match = func(o Version) bool {
+				ov := o.(*rubygemsVersion)
+				return ov.Compare(v) >= 0 && ov.release().Compare(upper) < 0
+			}
+		}
+		set = append(set, c)
+	}
+	return &constraintRange{raw: r, sets: [][]*comparator{set}}, nil
+}
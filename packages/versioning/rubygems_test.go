@@ -0,0 +1,40 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRubyGemsCompare(t *testing.T) {
+	assertAscending(t, RubyGems, []string{"0.9", "1.0.a", "1.0.a2", "1.0.b1", "1.0-rc1", "1.0", "1.0.1", "1.10", "2"})
+
+	assertEqual(t, RubyGems, "1.0", "1")
+	assertEqual(t, RubyGems, "1.0.0.a", "1.a")
+
+	for version, prerelease := range map[string]bool{"1.0": false, "1.0.beta": true, "1.0-1": true} {
+		v, err := RubyGems.ParseVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, prerelease, v.IsPrerelease(), version)
+	}
+
+	_, err := RubyGems.ParseVersion("junk")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestRubyGemsRange(t *testing.T) {
+	assertSatisfies(t, RubyGems, "~> 1.2", []string{"1.2", "1.9.9"}, []string{"2.0", "1.1"})
+	assertSatisfies(t, RubyGems, "~> 1.2.0", []string{"1.2.0", "1.2.9"}, []string{"1.3"})
+	assertSatisfies(t, RubyGems, "~> 1.0", []string{"1.0", "1.5"}, []string{"2.0", "2.0.a"})
+	assertSatisfies(t, RubyGems, ">= 1.0, < 2", []string{"1.0", "1.9"}, []string{"2.0", "0.9"})
+	assertSatisfies(t, RubyGems, "!= 1.5", []string{"1.4", "1.6"}, []string{"1.5.0"})
+	assertSatisfies(t, RubyGems, "1.2.3", []string{"1.2.3"}, []string{"1.2.4"})
+
+	_, err := RubyGems.ParseRange("=> 1.0")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
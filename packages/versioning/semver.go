@@ -0,0 +1,303 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SemVer implements semantic versioning with npm ranges like ^1.2.3, ~1.2, 1.x and 1.0.0 - 2.0.0.
+// Versions may omit the minor and patch parts.
+var SemVer Scheme = &semverScheme{}
+
+// Cargo implements semantic versioning with Cargo requirements like 1.2, >=1.0, <2.0 where a bare version is a caret requirement
+var Cargo Scheme = &cargoScheme{}
+
+var (
+	semverPattern        = regexp.MustCompile(`\A\s*[vV=]?\s*(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?\s*\z`)
+	semverPartialPattern = regexp.MustCompile(`\A(<=|>=|<|>|=|~>|~|\^)?[vV=]?(?:(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?)?\z`)
+	semverHyphenPattern  = regexp.MustCompile(`\A(\S+)\s+-\s+(\S+)\z`)
+	semverOperatorSpace  = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+)
+
+type semanticVersion struct {
+	raw        string
+	parts      [3]int64
+	prerelease []string
+}
+
+func (v *semanticVersion) String() string {
+	return v.raw
+}
+
+func (v *semanticVersion) Compare(o Version) int {
+	ov := o.(*semanticVersion)
+	for i := range v.parts {
+		if result := compareInts(v.parts[i], ov.parts[i]); result != 0 {
+			return result
+		}
+	}
+	return comparePrerelease(v.prerelease, ov.prerelease)
+}
+
+func (v *semanticVersion) IsPrerelease() bool {
+	return len(v.prerelease) > 0
+}
+
+func newSemanticVersion(major, minor, patch int64, prerelease []string) *semanticVersion {
+	raw := fmt.Sprintf("%d.%d.%d", major, minor, patch)
+	if len(prerelease) > 0 {
+		raw += "-" + strings.Join(prerelease, ".")
+	}
+	return &semanticVersion{raw: raw, parts: [3]int64{major, minor, patch}, prerelease: prerelease}
+}
+
+func splitPrerelease(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ".")
+}
+
+type semverScheme struct{}
+
+func (s *semverScheme) Name() string {
+	return "semver"
+}
+
+func (s *semverScheme) ParseVersion(v string) (Version, error) {
+	m := semverPattern.FindStringSubmatch(v)
+	if m == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+	}
+	sv := &semanticVersion{raw: v, prerelease: splitPrerelease(m[4])}
+	for i := 0; i < 3; i++ {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, v)
+		}
+		sv.parts[i] = n
+	}
+	return sv, nil
+}
+
+func (s *semverScheme) ParseRange(r string) (Range, error) {
+	cr := &constraintRange{raw: r, allow: allowSemverPrerelease}
+	for _, part := range strings.Split(r, "||") {
+		set, err := parseSemverSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		cr.sets = append(cr.sets, set)
+	}
+	return cr, nil
+}
+
+// allowSemverPrerelease only accepts a pre-release if a comparator of the set is a pre-release of the same version
+func allowSemverPrerelease(set []*comparator, v Version) bool {
+	sv := v.(*semanticVersion)
+	if !sv.IsPrerelease() {
+		return true
+	}
+	for _, c := range set {
+		if c.version == nil {
+			continue
+		}
+		cv := c.version.(*semanticVersion)
+		if cv.IsPrerelease() && cv.parts == sv.parts {
+			return true
+		}
+	}
+	return false
+}
+
+// semverPartial is a version of a range which may lack parts, n is the number of given parts
+type semverPartial struct {
+	op         string
+	parts      [3]int64
+	n          int
+	prerelease []string
+}
+
+func parseSemverPartial(s string) (*semverPartial, error) {
+	m := semverPartialPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, ErrInvalidRange
+	}
+	p := &semverPartial{op: m[1]}
+	for i := 0; i < 3; i++ {
+		if m[i+2] == "" || strings.ContainsAny(m[i+2], "xX*") {
+			break
+		}
+		n, err := strconv.ParseInt(m[i+2], 10, 64)
+		if err != nil {
+			return nil, ErrInvalidRange
+		}
+		p.parts[i] = n
+		p.n++
+	}
+	if p.n == 3 {
+		p.prerelease = splitPrerelease(m[5])
+	}
+	return p, nil
+}
+
+// lower returns the smallest version matching the partial
+func (p *semverPartial) lower() *semanticVersion {
+	return newSemanticVersion(p.parts[0], p.parts[1], p.parts[2], p.prerelease)
+}
+
+// next returns the smallest version greater than all versions matching the partial
+func (p *semverPartial) next() *semanticVersion {
+	switch p.n {
+	case 1:
+		return newSemanticVersion(p.parts[0]+1, 0, 0, []string{"0"})
+	case 2:
+		return newSemanticVersion(p.parts[0], p.parts[1]+1, 0, []string{"0"})
+	}
+	return newSemanticVersion(p.parts[0], p.parts[1], p.parts[2]+1, []string{"0"})
+}
+
+func parseSemverSet(s string) ([]*comparator, error) {
+	if m := semverHyphenPattern.FindStringSubmatch(s); m != nil {
+		from, err := parseSemverPartial(m[1])
+		if err != nil || from.op != "" {
+			return nil, ErrInvalidRange
+		}
+		to, err := parseSemverPartial(m[2])
+		if err != nil || to.op != "" {
+			return nil, ErrInvalidRange
+		}
+		var set []*comparator
+		if from.n > 0 {
+			set = append(set, &comparator{op: ">=", version: from.lower()})
+		}
+		switch {
+		case to.n == 3:
+			set = append(set, &comparator{op: "<=", version: to.lower()})
+		case to.n > 0:
+			set = append(set, &comparator{op: "<", version: to.next()})
+		}
+		return set, nil
+	}
+
+	s = semverOperatorSpace.ReplaceAllString(strings.ReplaceAll(s, ",", " "), "$1")
+
+	var set []*comparator
+	for _, field := range strings.Fields(s) {
+		p, err := parseSemverPartial(field)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, p.comparators()...)
+	}
+	return set, nil
+}
+
+// comparators desugars the partial into primitive comparators like node-semver does
+func (p *semverPartial) comparators() []*comparator {
+	nothing := []*comparator{{op: "<", version: newSemanticVersion(0, 0, 0, []string{"0"})}}
+
+	switch p.op {
+	case "", "=":
+		switch p.n {
+		case 0:
+			return nil
+		case 3:
+			return []*comparator{{op: "=", version: p.lower()}}
+		}
+		return []*comparator{{op: ">=", version: p.lower()}, {op: "<", version: p.next()}}
+	case "~", "~>":
+		switch p.n {
+		case 0:
+			return nil
+		case 1:
+			return []*comparator{{op: ">=", version: p.lower()}, {op: "<", version: p.next()}}
+		}
+		return []*comparator{{op: ">=", version: p.lower()}, {op: "<", version: newSemanticVersion(p.parts[0], p.parts[1]+1, 0, []string{"0"})}}
+	case "^":
+		if p.n == 0 {
+			return nil
+		}
+		upper := newSemanticVersion(p.parts[0]+1, 0, 0, []string{"0"})
+		switch {
+		case p.parts[0] > 0 || p.n == 1:
+		case p.parts[1] > 0 || p.n == 2:
+			upper = newSemanticVersion(0, p.parts[1]+1, 0, []string{"0"})
+		default:
+			upper = newSemanticVersion(0, 0, p.parts[2]+1, []string{"0"})
+		}
+		return []*comparator{{op: ">=", version: p.lower()}, {op: "<", version: upper}}
+	case ">":
+		switch p.n {
+		case 0:
+			return nothing
+		case 3:
+			return []*comparator{{op: ">", version: p.lower()}}
+		}
+		next := p.next()
+		return []*comparator{{op: ">=", version: newSemanticVersion(next.parts[0], next.parts[1], next.parts[2], nil)}}
+	case ">=":
+		if p.n == 0 {
+			return nil
+		}
+		return []*comparator{{op: ">=", version: p.lower()}}
+	case "<":
+		switch p.n {
+		case 0:
+			return nothing
+		case 3:
+			return []*comparator{{op: "<", version: p.lower()}}
+		}
+		return []*comparator{{op: "<", version: newSemanticVersion(p.parts[0], p.parts[1], p.parts[2], []string{"0"})}}
+	case "<=":
+		switch p.n {
+		case 0:
+			return nil
+		case 3:
+			return []*comparator{{op: "<=", version: p.lower()}}
+		}
+		return []*comparator{{op: "<", version: p.next()}}
+	}
+	return nothing
+}
+
+type cargoScheme struct {
+	semverScheme
+}
+
+func (s *cargoScheme) Name() string {
+	return "cargo"
+}
+
+// ParseRange parses a comma separated Cargo requirement
+func (s *cargoScheme) ParseRange(r string) (Range, error) {
+	cr := &constraintRange{raw: r, allow: allowSemverPrerelease}
+
+	var set []*comparator
+	for _, part := range strings.Split(r, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		if part[0] >= '0' && part[0] <= '9' {
+			part = "^" + part
+		}
+		p, err := parseSemverPartial(semverOperatorSpace.ReplaceAllString(part, "$1"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, r)
+		}
+		set = append(set, p.comparators()...)
+	}
+	cr.sets = [][]*comparator{set}
+	return cr, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemVerCompare(t *testing.T) {
+	assertAscending(t, SemVer, []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.10.0", "2.0.0"})
+
+	assertEqual(t, SemVer, "v1.2", "1.2.0")
+	assertEqual(t, SemVer, "1.0.0+build.1", "1.0.0")
+
+	_, err := SemVer.ParseVersion("latest")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestSemVerRange(t *testing.T) {
+	assertSatisfies(t, SemVer, "^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0", "2.0.0-alpha", "1.5.0-beta"})
+	assertSatisfies(t, SemVer, "^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"})
+	assertSatisfies(t, SemVer, "^0.0.3", []string{"0.0.3"}, []string{"0.0.4"})
+	assertSatisfies(t, SemVer, "~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0"})
+	assertSatisfies(t, SemVer, "~1", []string{"1.0.0", "1.9.9"}, []string{"2.0.0"})
+	assertSatisfies(t, SemVer, "1.x", []string{"1.0.0", "1.5.0"}, []string{"2.0.0", "0.9.0"})
+	assertSatisfies(t, SemVer, "*", []string{"0.0.1", "5.0.0"}, []string{"5.0.0-beta"})
+	assertSatisfies(t, SemVer, "1.2.3 - 2.3", []string{"1.2.3", "2.3.9"}, []string{"1.2.2", "2.4.0"})
+	assertSatisfies(t, SemVer, ">= 1.0.0 < 2", []string{"1.0.0", "1.99.0"}, []string{"2.0.0", "0.1.0"})
+	assertSatisfies(t, SemVer, "<1.0.0 || >=3.0.0", []string{"0.5.0", "3.1.0"}, []string{"1.0.0", "2.9.9"})
+	assertSatisfies(t, SemVer, ">1.2", []string{"1.3.0"}, []string{"1.2.9"})
+	assertSatisfies(t, SemVer, "^1.2.3-beta.2", []string{"1.2.3-beta.3", "1.2.3", "1.3.0"}, []string{"1.2.3-beta.1", "1.3.0-beta"})
+
+	_, err := SemVer.ParseRange("^a.b")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestCargoRange(t *testing.T) {
+	assertSatisfies(t, Cargo, "1.2", []string{"1.2.0", "1.9.0"}, []string{"2.0.0", "1.1.0"})
+	assertSatisfies(t, Cargo, ">=1.0, <1.5", []string{"1.0.0", "1.4.9"}, []string{"1.5.0"})
+	assertSatisfies(t, Cargo, "=0.3.1", []string{"0.3.1"}, []string{"0.3.2"})
+	assertSatisfies(t, Cargo, "~1.2", []string{"1.2.5"}, []string{"1.3.0"})
+
+	_, err := Cargo.ParseRange("1.0,")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
@@ -0,0 +1,300 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalidVersion indicates a version which is not valid in the scheme
+	ErrInvalidVersion = errors.New("version is invalid")
+	// ErrInvalidRange indicates a version range or constraint which is not valid in the scheme
+	ErrInvalidRange = errors.New("version range is invalid")
+)
+
+// Version is a parsed version of a scheme
+type Version interface {
+	// String returns the original version
+	String() string
+	// Compare returns -1, 0 or 1 if the version is lower, equal or greater than o.
+	// o must be a version of the same scheme.
+	Compare(o Version) int
+	// IsPrerelease tests if the version is a pre-release
+	IsPrerelease() bool
+}
+
+// Range is a parsed version range or constraint of a scheme
+type Range interface {
+	// String returns the original range
+	String() string
+	// Contains tests if the version satisfies the range
+	Contains(v Version) bool
+}
+
+// Scheme defines the version semantics of a package type
+type Scheme interface {
+	Name() string
+	ParseVersion(v string) (Version, error)
+	ParseRange(r string) (Range, error)
+}
+
+// ForPackageType returns the version scheme of a package or package url type, semantic versioning is the default
+func ForPackageType(packageType string) Scheme {
+	switch strings.ToLower(packageType) {
+	case "cargo":
+		return Cargo
+	case "composer":
+		return Composer
+	case "maven":
+		return Maven
+	case "nuget":
+		return NuGet
+	case "pypi":
+		return PEP440
+	case "gem", "rubygems":
+		return RubyGems
+	}
+	return SemVer
+}
+
+// normalizedVersion is implemented by the versions of schemes which define a normalized form
+type normalizedVersion interface {
+	Normalized() string
+}
+
+// Normalize parses the version and returns its normalized form, like 1.0.0.0 for the Composer version v1.0.
+// Versions of schemes without a normalized form are returned unchanged.
+func Normalize(s Scheme, v string) (string, error) {
+	pv, err := s.ParseVersion(v)
+	if err != nil {
+		return "", err
+	}
+	if nv, ok := pv.(normalizedVersion); ok {
+		return nv.Normalized(), nil
+	}
+	return pv.String(), nil
+}
+
+// Compare parses and compares two versions
+func Compare(s Scheme, a, b string) (int, error) {
+	va, err := s.ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := s.ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// Sort sorts the versions from oldest to newest.
+// Versions which can't be parsed are placed first in their original order.
+func Sort(s Scheme, versions []string) {
+	parsed := make(map[string]Version, len(versions))
+	for _, v := range versions {
+		if pv, err := s.ParseVersion(v); err == nil {
+			parsed[v] = pv
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, oki := parsed[versions[i]]
+		vj, okj := parsed[versions[j]]
+		if !oki || !okj {
+			return !oki && okj
+		}
+		return vi.Compare(vj) < 0
+	})
+}
+
+// Latest returns the newest version or an empty string if there is none.
+// Pre-releases are only considered if includePrerelease is set.
+func Latest(s Scheme, versions []string, includePrerelease bool) string {
+	var latest Version
+	for _, v := range versions {
+		pv, err := s.ParseVersion(v)
+		if err != nil || (pv.IsPrerelease() && !includePrerelease) {
+			continue
+		}
+		if latest == nil || pv.Compare(latest) > 0 {
+			latest = pv
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.String()
+}
+
+// Satisfies tests if the version satisfies the range
+func Satisfies(s Scheme, version, constraint string) (bool, error) {
+	v, err := s.ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	r, err := s.ParseRange(constraint)
+	if err != nil {
+		return false, err
+	}
+	return r.Contains(v), nil
+}
+
+// MaxSatisfying returns the newest version which satisfies the range or an empty string if there is none.
+// Versions which can't be parsed are ignored.
+func MaxSatisfying(s Scheme, versions []string, constraint string) (string, error) {
+	r, err := s.ParseRange(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	var best Version
+	for _, v := range versions {
+		pv, err := s.ParseVersion(v)
+		if err != nil || !r.Contains(pv) {
+			continue
+		}
+		if best == nil || pv.Compare(best) > 0 {
+			best = pv
+		}
+	}
+	if best == nil {
+		return "", nil
+	}
+	return best.String(), nil
+}
+
+// comparator is a single condition of a range like >=1.0
+type comparator struct {
+	op      string
+	version Version
+	// match replaces the operator for conditions which can't be expressed by it, like wildcards
+	match func(v Version) bool
+}
+
+func (c *comparator) matches(v Version) bool {
+	if c.match != nil {
+		return c.match(v)
+	}
+	if c.version == nil {
+		return true
+	}
+	result := v.Compare(c.version)
+	switch c.op {
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "!=":
+		return result != 0
+	}
+	return result == 0
+}
+
+// constraintRange is a union of comparator sets, a version must match all comparators of one set
+type constraintRange struct {
+	raw  string
+	sets [][]*comparator
+	// allow is an optional additional check of a version against a matching set
+	allow func(set []*comparator, v Version) bool
+}
+
+func (r *constraintRange) String() string {
+	return r.raw
+}
+
+func (r *constraintRange) Contains(v Version) bool {
+	for _, set := range r.sets {
+		matches := true
+		for _, c := range set {
+			if !c.matches(v) {
+				matches = false
+				break
+			}
+		}
+		if matches && (r.allow == nil || r.allow(set, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowExplicitPrerelease only accepts pre-releases if a comparator of the set references a pre-release
+func allowExplicitPrerelease(set []*comparator, v Version) bool {
+	if !v.IsPrerelease() {
+		return true
+	}
+	for _, c := range set {
+		if c.version != nil && c.version.IsPrerelease() {
+			return true
+		}
+	}
+	return false
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareNumbers compares two strings of digits of any length
+func compareNumbers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return compareInts(int64(len(a)), int64(len(b)))
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// comparePrerelease compares dot separated pre-release identifiers with the semantic versioning rules,
+// an empty pre-release is greater than any pre-release
+func comparePrerelease(a, b []string) int {
+	if len(a) == 0 || len(b) == 0 {
+		return -compareInts(int64(len(a)), int64(len(b)))
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, nb := isNumeric(a[i]), isNumeric(b[i])
+		var result int
+		switch {
+		case na && nb:
+			result = compareNumbers(a[i], b[i])
+		case na:
+			result = -1
+		case nb:
+			result = 1
+		default:
+			result = strings.Compare(a[i], b[i])
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
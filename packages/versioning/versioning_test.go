@@ -0,0 +1,101 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package versioning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertAscending(t *testing.T, s Scheme, versions []string) {
+	for i := 1; i < len(versions); i++ {
+		result, err := Compare(s, versions[i-1], versions[i])
+		assert.NoError(t, err, versions[i])
+		assert.Equal(t, -1, result, "%s < %s", versions[i-1], versions[i])
+
+		result, _ = Compare(s, versions[i], versions[i-1])
+		assert.Equal(t, 1, result, "%s > %s", versions[i], versions[i-1])
+	}
+}
+
+func assertEqual(t *testing.T, s Scheme, a, b string) {
+	result, err := Compare(s, a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result, "%s = %s", a, b)
+}
+
+func assertSatisfies(t *testing.T, s Scheme, constraint string, matching, notMatching []string) {
+	r, err := s.ParseRange(constraint)
+	if !assert.NoError(t, err, constraint) {
+		return
+	}
+	for _, version := range matching {
+		v, err := s.ParseVersion(version)
+		assert.NoError(t, err)
+		assert.True(t, r.Contains(v), "%s should satisfy %s", version, constraint)
+	}
+	for _, version := range notMatching {
+		v, err := s.ParseVersion(version)
+		assert.NoError(t, err)
+		assert.False(t, r.Contains(v), "%s should not satisfy %s", version, constraint)
+	}
+}
+
+func TestForPackageType(t *testing.T) {
+	assert.Equal(t, Cargo, ForPackageType("cargo"))
+	assert.Equal(t, Composer, ForPackageType("composer"))
+	assert.Equal(t, Maven, ForPackageType("maven"))
+	assert.Equal(t, NuGet, ForPackageType("NuGet"))
+	assert.Equal(t, PEP440, ForPackageType("pypi"))
+	assert.Equal(t, RubyGems, ForPackageType("gem"))
+	assert.Equal(t, RubyGems, ForPackageType("rubygems"))
+	assert.Equal(t, SemVer, ForPackageType("npm"))
+	assert.Equal(t, SemVer, ForPackageType("helm"))
+}
+
+func TestSort(t *testing.T) {
+	versions := []string{"1.10.0", "latest", "1.2.0", "1.2.0-beta", "0.9"}
+	Sort(SemVer, versions)
+	assert.Equal(t, []string{"latest", "0.9", "1.2.0-beta", "1.2.0", "1.10.0"}, versions)
+}
+
+func TestLatest(t *testing.T) {
+	versions := []string{"1.0.0", "2.0.0-rc.1", "1.10.0", "invalid"}
+	assert.Equal(t, "1.10.0", Latest(SemVer, versions, false))
+	assert.Equal(t, "2.0.0-rc.1", Latest(SemVer, versions, true))
+	assert.Empty(t, Latest(SemVer, []string{"invalid"}, true))
+
+	assert.Equal(t, "1.10", Latest(PEP440, []string{"1.9", "1.10", "2.0.dev1"}, false))
+}
+
+func TestSatisfies(t *testing.T) {
+	ok, err := Satisfies(SemVer, "1.2.3", "^1.0.0")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = Satisfies(SemVer, "latest", "^1.0.0")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+
+	_, err = Satisfies(SemVer, "1.0.0", ">=a.b")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.3.0-beta", "2.0.0"}
+
+	v, err := MaxSatisfying(SemVer, versions, "^1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", v)
+
+	v, err = MaxSatisfying(SemVer, versions, ">=3")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+
+	v, err = MaxSatisfying(NuGet, []string{"1.0.0", "1.5.2", "2.0.0"}, "[1.0,2.0)")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5.2", v)
+}
@@ -28,6 +28,30 @@ type UniqueByteFIFO interface {
 	Has(ctx context.Context, data []byte) (bool, error)
 }
 
+// PeekableByteFIFO defines a FIFO whose contents can be read and removed without popping them
+type PeekableByteFIFO interface {
+	ByteFIFO
+	// Peek returns up to limit data from the start of the fifo without removing it, a limit <= 0 returns all data
+	Peek(ctx context.Context, limit int) ([][]byte, error)
+	// Remove removes the data from the fifo, it returns false if the fifo doesn't contain the data
+	Remove(ctx context.Context, data []byte) (bool, error)
+}
+
+// AcknowledgeableByteFIFO defines a FIFO backed by a broker which keeps popped data until it is acknowledged
+type AcknowledgeableByteFIFO interface {
+	ByteFIFO
+	// PopUnacknowledged pops data from the start of the fifo, the broker delivers it again unless it is acknowledged
+	PopUnacknowledged(ctx context.Context) ([]byte, Acknowledger, error)
+}
+
+// Acknowledger settles data popped from an AcknowledgeableByteFIFO
+type Acknowledger interface {
+	// Ack removes the data from the broker
+	Ack(ctx context.Context) error
+	// Nack hands the data back to the broker, which delivers it again
+	Nack(ctx context.Context) error
+}
+
 var _ ByteFIFO = &DummyByteFIFO{}
 
 // DummyByteFIFO represents a dummy fifo
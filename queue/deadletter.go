@@ -0,0 +1,257 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/log"
+	"github.com/gitbundle/modules/util"
+)
+
+// ErrNoDeadLetterQueue is returned if dead letters are requested from a queue without a dead letter queue
+var ErrNoDeadLetterQueue = fmt.Errorf("queue has no dead letter queue")
+
+// DeadLetter is data which could not be handled within the maximum number of attempts
+type DeadLetter struct {
+	ID       string
+	Queue    string
+	Attempts int
+	Failed   time.Time
+	// Data is the json representation of the data
+	Data []byte
+}
+
+// DeadLetterQueue stores dead letters in a ByteFIFO
+//
+// Dead letters in a PeekableByteFIFO are listed without popping them and removed one by one,
+// which is safe against concurrent access from other processes. Other fifos are inspected by
+// popping every dead letter and pushing it back, which is only safe within the same process.
+type DeadLetterQueue struct {
+	lock     sync.Mutex
+	name     string
+	byteFIFO ByteFIFO
+}
+
+// NewDeadLetterQueue creates a dead letter queue for the named queue
+func NewDeadLetterQueue(name string, byteFIFO ByteFIFO) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		name:     name,
+		byteFIFO: byteFIFO,
+	}
+}
+
+// Push adds data which failed the provided number of attempts
+func (d *DeadLetterQueue) Push(ctx context.Context, data Data, attempts int) error {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return d.PushBytes(ctx, bs, attempts)
+}
+
+// PushBytes adds the json representation of data which failed the provided number of attempts,
+// e.g. data which can't be unmarshalled anymore
+func (d *DeadLetterQueue) PushBytes(ctx context.Context, bs []byte, attempts int) error {
+	id, err := util.CryptoRandomString(16)
+	if err != nil {
+		return err
+	}
+	letter, err := json.Marshal(&DeadLetter{
+		ID:       id,
+		Queue:    d.name,
+		Attempts: attempts,
+		Failed:   time.Now(),
+		Data:     bs,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.byteFIFO.PushFunc(ctx, letter, nil)
+}
+
+// Len returns the number of dead letters
+func (d *DeadLetterQueue) Len(ctx context.Context) int64 {
+	return d.byteFIFO.Len(ctx)
+}
+
+// List returns up to limit dead letters, oldest first. A limit <= 0 returns all dead letters.
+func (d *DeadLetterQueue) List(ctx context.Context, limit int) ([]*DeadLetter, error) {
+	var letters []*DeadLetter
+	keep := func(letter *DeadLetter) bool {
+		if limit <= 0 || len(letters) < limit {
+			letters = append(letters, letter)
+		}
+		return true
+	}
+
+	if fifo, ok := d.byteFIFO.(PeekableByteFIFO); ok {
+		data, err := fifo.Peek(ctx, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, bs := range data {
+			if letter := d.unmarshal(bs); letter != nil {
+				keep(letter)
+			}
+		}
+		return letters, nil
+	}
+
+	err := d.cycle(ctx, keep)
+	return letters, err
+}
+
+// Remove removes the dead letters with the provided ids, or all dead letters if no id is provided, and returns them
+func (d *DeadLetterQueue) Remove(ctx context.Context, ids ...string) ([]*DeadLetter, error) {
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	matches := func(letter *DeadLetter) bool {
+		return len(ids) == 0 || remove[letter.ID]
+	}
+
+	var removed []*DeadLetter
+	if fifo, ok := d.byteFIFO.(PeekableByteFIFO); ok {
+		err := d.claim(ctx, fifo, matches, func(letter *DeadLetter) error {
+			removed = append(removed, letter)
+			return nil
+		})
+		return removed, err
+	}
+
+	err := d.cycle(ctx, func(letter *DeadLetter) bool {
+		if matches(letter) {
+			removed = append(removed, letter)
+			return false
+		}
+		return true
+	})
+	return removed, err
+}
+
+// Requeue passes the dead letters with the provided ids, or all dead letters if no id is provided, to push and
+// removes them. Dead letters are kept if push fails, the first error is returned.
+func (d *DeadLetterQueue) Requeue(ctx context.Context, push func(*DeadLetter) error, ids ...string) (int, error) {
+	requeue := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		requeue[id] = true
+	}
+	matches := func(letter *DeadLetter) bool {
+		return len(ids) == 0 || requeue[letter.ID]
+	}
+
+	requeued := 0
+	if fifo, ok := d.byteFIFO.(PeekableByteFIFO); ok {
+		err := d.claim(ctx, fifo, matches, func(letter *DeadLetter) error {
+			if err := push(letter); err != nil {
+				return err
+			}
+			requeued++
+			return nil
+		})
+		return requeued, err
+	}
+
+	var pushErr error
+	err := d.cycle(ctx, func(letter *DeadLetter) bool {
+		if pushErr != nil || !matches(letter) {
+			return true
+		}
+		if err := push(letter); err != nil {
+			pushErr = err
+			return true
+		}
+		requeued++
+		return false
+	})
+	if err != nil {
+		return requeued, err
+	}
+	return requeued, pushErr
+}
+
+// claim removes every matching dead letter from the fifo and passes it to fn. Dead letters which
+// another process has removed in the meantime are skipped. If fn fails the dead letter is pushed
+// back and the error is returned.
+func (d *DeadLetterQueue) claim(ctx context.Context, fifo PeekableByteFIFO, matches func(*DeadLetter) bool, fn func(*DeadLetter) error) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	data, err := fifo.Peek(ctx, 0)
+	if err != nil {
+		return err
+	}
+	for _, bs := range data {
+		letter := d.unmarshal(bs)
+		if letter == nil || !matches(letter) {
+			continue
+		}
+		removed, err := fifo.Remove(ctx, bs)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			continue
+		}
+		if err := fn(letter); err != nil {
+			if err := fifo.PushBack(ctx, bs); err != nil {
+				log.Error("Unable to keep dead letter %s in %s: %v", letter.ID, d.name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshal returns the dead letter or nil if the data is not a dead letter
+func (d *DeadLetterQueue) unmarshal(bs []byte) *DeadLetter {
+	letter := &DeadLetter{}
+	if err := json.Unmarshal(bs, letter); err != nil {
+		log.Error("Unable to unmarshal dead letter in %s: %v", d.name, err)
+		return nil
+	}
+	return letter
+}
+
+// Close closes the underlying fifo
+func (d *DeadLetterQueue) Close() error {
+	return d.byteFIFO.Close()
+}
+
+// cycle pops every dead letter once and pushes it back to the end of the fifo if keep returns true,
+// it is used for fifos which can't be peeked
+func (d *DeadLetterQueue) cycle(ctx context.Context, keep func(*DeadLetter) bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for n := d.byteFIFO.Len(ctx); n > 0; n-- {
+		bs, err := d.byteFIFO.Pop(ctx)
+		if err != nil {
+			return err
+		}
+		if len(bs) == 0 {
+			return nil
+		}
+
+		if letter := d.unmarshal(bs); letter != nil && !keep(letter) {
+			continue
+		}
+
+		if err := d.byteFIFO.PushFunc(ctx, bs, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "dead-letter-queue-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	fifo, err := NewLevelListByteFIFO(tmpDir, "dead")
+	assert.NoError(t, err)
+	deadLetters := NewDeadLetterQueue("test", fifo)
+	defer deadLetters.Close()
+
+	ctx := context.Background()
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, deadLetters.Push(ctx, &testData{"A", i}, i))
+	}
+	assert.EqualValues(t, 4, deadLetters.Len(ctx))
+
+	letters, err := deadLetters.List(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 4)
+	for i, letter := range letters {
+		assert.Equal(t, i+1, letter.Attempts)
+		assert.NotEmpty(t, letter.ID)
+	}
+
+	limited, err := deadLetters.List(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, letters[:2], limited)
+
+	removed, err := deadLetters.Remove(ctx, letters[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, letters[1:2], removed)
+	assert.EqualValues(t, 3, deadLetters.Len(ctx))
+
+	var requeued []*DeadLetter
+	n, err := deadLetters.Requeue(ctx, func(letter *DeadLetter) error {
+		requeued = append(requeued, letter)
+		return nil
+	}, letters[0].ID, letters[3].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []*DeadLetter{letters[0], letters[3]}, requeued)
+
+	// dead letters which fail to requeue are kept
+	errPush := errors.New("push failed")
+	n, err = deadLetters.Requeue(ctx, func(letter *DeadLetter) error {
+		return errPush
+	})
+	assert.ErrorIs(t, err, errPush)
+	assert.Equal(t, 0, n)
+
+	remaining, err := deadLetters.List(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, letters[2:3], remaining)
+
+	removed, err = deadLetters.Remove(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, letters[2:3], removed)
+	assert.EqualValues(t, 0, deadLetters.Len(ctx))
+}
+
+func TestDeadLetterQueueSharedFIFO(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "dead-letter-queue-shared-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	fifo, err := NewLevelListByteFIFO(tmpDir, "dead")
+	assert.NoError(t, err)
+	defer fifo.Close()
+	first := NewDeadLetterQueue("first", fifo)
+	second := NewDeadLetterQueue("second", fifo)
+
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, first.Push(ctx, &testData{"A", i}, i))
+	}
+
+	// listing doesn't pop, so the dead letters stay in order and visible to everyone
+	letters, err := first.List(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 3)
+	listed, err := second.List(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, letters, listed)
+	assert.EqualValues(t, 3, fifo.Len(ctx))
+
+	// a dead letter is only removed once
+	removed, err := first.Remove(ctx, letters[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, letters[1:2], removed)
+	removed, err = second.Remove(ctx, letters[1].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	remaining, err := second.List(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []*DeadLetter{letters[0], letters[2]}, remaining)
+}
+
+func TestLevelQueueDeadLetters(t *testing.T) {
+	var lock sync.Mutex
+	fail := true
+	handleChan := make(chan *testData, 10)
+	handle := func(data ...Data) []Data {
+		lock.Lock()
+		defer lock.Unlock()
+		if fail {
+			return data
+		}
+		for _, datum := range data {
+			handleChan <- datum.(*testData)
+		}
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "level-queue-dead-letter-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	q, err := NewLevelQueue(handle, LevelQueueConfiguration{
+		ByteFIFOQueueConfiguration: ByteFIFOQueueConfiguration{
+			WorkerPoolConfiguration: WorkerPoolConfiguration{
+				QueueLength:  20,
+				BatchLength:  1,
+				BlockTimeout: 1 * time.Second,
+				BoostTimeout: 5 * time.Minute,
+				BoostWorkers: 5,
+				MaxWorkers:   10,
+			},
+			Workers: 1,
+			RetryConfiguration: RetryConfiguration{
+				RetryMaxAttempts:    3,
+				RetryBackoff:        10 * time.Millisecond,
+				DeadLetterQueueName: "dead",
+			},
+		},
+		DataDir:   tmpDir,
+		QueueName: "queue",
+	}, &testData{})
+	assert.NoError(t, err)
+
+	var shutdown, terminate []func()
+	go q.Run(func(f func()) {
+		lock.Lock()
+		shutdown = append(shutdown, f)
+		lock.Unlock()
+	}, func(f func()) {
+		lock.Lock()
+		terminate = append(terminate, f)
+		lock.Unlock()
+	})
+
+	mq := GetManager().GetManagedQueue(q.(*LevelQueue).qid)
+	assert.True(t, mq.HasDeadLetterQueue())
+
+	assert.NoError(t, q.Push(&testData{"A", 1}))
+	assert.Eventually(t, func() bool {
+		return mq.NumberOfDeadLetters() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	letters, err := mq.DeadLetters(0)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, 3, letters[0].Attempts)
+
+	lock.Lock()
+	fail = false
+	lock.Unlock()
+
+	n, err := mq.RequeueDeadLetters()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	result := <-handleChan
+	assert.Equal(t, "A", result.TestString)
+	assert.EqualValues(t, 0, mq.NumberOfDeadLetters())
+
+	n, err = mq.PurgeDeadLetters()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	lock.Lock()
+	for _, f := range shutdown {
+		f()
+	}
+	for _, f := range terminate {
+		f()
+	}
+	lock.Unlock()
+	GetManager().Remove(mq.QID)
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"github.com/gitbundle/modules/log"
+	"github.com/gitbundle/modules/nosql"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// levelListFirstSequence is the sequence of the first data pushed to an empty LevelListByteFIFO,
+// the sequences below it are left for data pushed back to the start of the fifo
+const levelListFirstSequence = uint64(1) << 62
+
+var _ PeekableByteFIFO = &LevelListByteFIFO{}
+
+// LevelListByteFIFO represents a PeekableByteFIFO formed from LevelDB keys which end
+// with a big endian sequence, so iterating the keys returns the data in fifo order.
+//
+// Unlike the LevelQueueByteFIFO any data can be removed, which makes it suitable for dead letters.
+type LevelListByteFIFO struct {
+	lock       sync.Mutex
+	db         *leveldb.DB
+	prefix     []byte
+	connection string
+}
+
+// NewLevelListByteFIFO creates a PeekableByteFIFO which stores its data below the name in the LevelDB
+func NewLevelListByteFIFO(connection, name string) (*LevelListByteFIFO, error) {
+	db, err := nosql.GetManager().GetLevelDB(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LevelListByteFIFO{
+		db:         db,
+		prefix:     []byte("list:" + name + ":"),
+		connection: connection,
+	}, nil
+}
+
+func (fifo *LevelListByteFIFO) key(sequence uint64) []byte {
+	key := make([]byte, len(fifo.prefix)+8)
+	copy(key, fifo.prefix)
+	binary.BigEndian.PutUint64(key[len(fifo.prefix):], sequence)
+	return key
+}
+
+// bounds returns the sequences of the first and the last data, ok is false if the fifo is empty.
+// The caller must hold the lock.
+func (fifo *LevelListByteFIFO) bounds() (first, last uint64, ok bool, err error) {
+	iter := fifo.db.NewIterator(leveldb_util.BytesPrefix(fifo.prefix), nil)
+	defer iter.Release()
+
+	if !iter.First() {
+		return 0, 0, false, iter.Error()
+	}
+	first = binary.BigEndian.Uint64(iter.Key()[len(fifo.prefix):])
+	iter.Last()
+	last = binary.BigEndian.Uint64(iter.Key()[len(fifo.prefix):])
+	return first, last, true, iter.Error()
+}
+
+// PushFunc pushes data to the end of the fifo and calls the callback if it is added
+func (fifo *LevelListByteFIFO) PushFunc(ctx context.Context, data []byte, fn func() error) error {
+	fifo.lock.Lock()
+	defer fifo.lock.Unlock()
+
+	_, last, ok, err := fifo.bounds()
+	if err != nil {
+		return err
+	}
+	sequence := levelListFirstSequence
+	if ok {
+		sequence = last + 1
+	}
+	if fn != nil {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return fifo.db.Put(fifo.key(sequence), data, nil)
+}
+
+// PushBack pushes data to the start of the fifo
+func (fifo *LevelListByteFIFO) PushBack(ctx context.Context, data []byte) error {
+	fifo.lock.Lock()
+	defer fifo.lock.Unlock()
+
+	first, _, ok, err := fifo.bounds()
+	if err != nil {
+		return err
+	}
+	sequence := levelListFirstSequence
+	if ok {
+		sequence = first - 1
+	}
+	return fifo.db.Put(fifo.key(sequence), data, nil)
+}
+
+// Pop pops data from the start of the fifo
+func (fifo *LevelListByteFIFO) Pop(ctx context.Context) ([]byte, error) {
+	fifo.lock.Lock()
+	defer fifo.lock.Unlock()
+
+	iter := fifo.db.NewIterator(leveldb_util.BytesPrefix(fifo.prefix), nil)
+	defer iter.Release()
+
+	if !iter.First() {
+		return nil, iter.Error()
+	}
+	data := append([]byte{}, iter.Value()...)
+	if err := fifo.db.Delete(iter.Key(), nil); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Peek returns up to limit data from the start of the fifo without removing it
+func (fifo *LevelListByteFIFO) Peek(ctx context.Context, limit int) ([][]byte, error) {
+	iter := fifo.db.NewIterator(leveldb_util.BytesPrefix(fifo.prefix), nil)
+	defer iter.Release()
+
+	var data [][]byte
+	for (limit <= 0 || len(data) < limit) && iter.Next() {
+		data = append(data, append([]byte{}, iter.Value()...))
+	}
+	return data, iter.Error()
+}
+
+// Remove removes the first occurrence of the data from the fifo
+func (fifo *LevelListByteFIFO) Remove(ctx context.Context, data []byte) (bool, error) {
+	fifo.lock.Lock()
+	defer fifo.lock.Unlock()
+
+	iter := fifo.db.NewIterator(leveldb_util.BytesPrefix(fifo.prefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		if bytes.Equal(iter.Value(), data) {
+			return true, fifo.db.Delete(iter.Key(), nil)
+		}
+	}
+	return false, iter.Error()
+}
+
+// Len returns the length of the fifo
+func (fifo *LevelListByteFIFO) Len(ctx context.Context) int64 {
+	iter := fifo.db.NewIterator(leveldb_util.BytesPrefix(fifo.prefix), nil)
+	defer iter.Release()
+
+	var n int64
+	for iter.Next() {
+		n++
+	}
+	if err := iter.Error(); err != nil {
+		log.Error("Error whilst getting length of level list %s: Error: %v", fifo.prefix, err)
+		return -1
+	}
+	return n
+}
+
+// Close this fifo
+func (fifo *LevelListByteFIFO) Close() error {
+	return nosql.GetManager().CloseLevelDB(fifo.connection)
+}
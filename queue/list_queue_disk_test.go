@@ -0,0 +1,61 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/gitbundle/modules/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelListByteFIFO(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "level-list-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	fifo, err := NewLevelListByteFIFO(tmpDir, "list")
+	assert.NoError(t, err)
+	defer fifo.Close()
+
+	ctx := context.Background()
+	bs, err := fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, bs)
+
+	for _, data := range []string{"b", "c", "d"} {
+		assert.NoError(t, fifo.PushFunc(ctx, []byte(data), nil))
+	}
+	assert.NoError(t, fifo.PushBack(ctx, []byte("a")))
+	assert.EqualValues(t, 4, fifo.Len(ctx))
+
+	// peeking leaves the data in place
+	peeked, err := fifo.Peek(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, peeked)
+	peeked, err = fifo.Peek(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}, peeked)
+	assert.EqualValues(t, 4, fifo.Len(ctx))
+
+	removed, err := fifo.Remove(ctx, []byte("c"))
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = fifo.Remove(ctx, []byte("c"))
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	assert.EqualValues(t, 3, fifo.Len(ctx))
+
+	for _, expected := range []string{"a", "b", "d"} {
+		bs, err := fifo.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(bs))
+	}
+	assert.EqualValues(t, 0, fifo.Len(ctx))
+}
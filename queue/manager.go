@@ -67,6 +67,20 @@ type Pausable interface {
 	IsPausedIsResumed() (paused, resumed <-chan struct{})
 }
 
+// DeadLetterable represents a queue that keeps data which failed all attempts in a dead letter queue
+type DeadLetterable interface {
+	// HasDeadLetterQueue will return if the queue has a dead letter queue
+	HasDeadLetterQueue() bool
+	// NumberOfDeadLetters will return the number of dead letters
+	NumberOfDeadLetters() int64
+	// DeadLetters will return up to limit dead letters, oldest first. A limit <= 0 returns all dead letters
+	DeadLetters(limit int) ([]*DeadLetter, error)
+	// RequeueDeadLetters will push the dead letters with the provided ids back into the queue, all if no id is provided
+	RequeueDeadLetters(ids ...string) (int, error)
+	// PurgeDeadLetters will remove the dead letters with the provided ids, all if no id is provided
+	PurgeDeadLetters(ids ...string) (int, error)
+}
+
 // ManagedPool is a simple interface to get certain details from a worker pool
 type ManagedPool interface {
 	// AddWorkers adds a number of worker as group to the pool with the provided timeout. A CancelFunc is provided to cancel the group
@@ -383,6 +397,46 @@ func (q *ManagedQueue) Resume() {
 	}
 }
 
+// HasDeadLetterQueue returns whether the queue has a dead letter queue
+func (q *ManagedQueue) HasDeadLetterQueue() bool {
+	if dl, ok := q.Managed.(DeadLetterable); ok {
+		return dl.HasDeadLetterQueue()
+	}
+	return false
+}
+
+// NumberOfDeadLetters returns the number of dead letters of the queue
+func (q *ManagedQueue) NumberOfDeadLetters() int64 {
+	if dl, ok := q.Managed.(DeadLetterable); ok {
+		return dl.NumberOfDeadLetters()
+	}
+	return 0
+}
+
+// DeadLetters returns up to limit dead letters of the queue
+func (q *ManagedQueue) DeadLetters(limit int) ([]*DeadLetter, error) {
+	if dl, ok := q.Managed.(DeadLetterable); ok {
+		return dl.DeadLetters(limit)
+	}
+	return nil, ErrNoDeadLetterQueue
+}
+
+// RequeueDeadLetters pushes the dead letters with the provided ids, or all if no id is provided, back into the queue
+func (q *ManagedQueue) RequeueDeadLetters(ids ...string) (int, error) {
+	if dl, ok := q.Managed.(DeadLetterable); ok {
+		return dl.RequeueDeadLetters(ids...)
+	}
+	return 0, ErrNoDeadLetterQueue
+}
+
+// PurgeDeadLetters removes the dead letters with the provided ids, or all if no id is provided
+func (q *ManagedQueue) PurgeDeadLetters(ids ...string) (int, error) {
+	if dl, ok := q.Managed.(DeadLetterable); ok {
+		return dl.PurgeDeadLetters(ids...)
+	}
+	return 0, ErrNoDeadLetterQueue
+}
+
 // NumberOfWorkers returns the number of workers in the queue
 func (q *ManagedQueue) NumberOfWorkers() int {
 	if pool, ok := q.Managed.(ManagedPool); ok {
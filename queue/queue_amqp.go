@@ -0,0 +1,296 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gitbundle/modules/log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPQueueType is the type for AMQP 0.9.1 queue
+const AMQPQueueType Type = "amqp"
+
+// AMQPQueueConfiguration is the configuration for the AMQP queue
+type AMQPQueueConfiguration struct {
+	ByteFIFOQueueConfiguration
+	AMQPByteFIFOConfiguration
+}
+
+// AMQPQueue AMQP 0.9.1 queue
+type AMQPQueue struct {
+	*ByteFIFOQueue
+}
+
+// NewAMQPQueue creates a queue backed by a durable AMQP 0.9.1 queue
+func NewAMQPQueue(handle HandlerFunc, cfg, exemplar interface{}) (Queue, error) {
+	configInterface, err := toConfig(AMQPQueueConfiguration{}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	config := configInterface.(AMQPQueueConfiguration)
+
+	byteFIFO, err := NewAMQPByteFIFO(config.AMQPByteFIFOConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	byteFIFOQueue, err := NewByteFIFOQueue(AMQPQueueType, byteFIFO, handle, config.ByteFIFOQueueConfiguration, exemplar)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.HasDeadLetterQueue() {
+		deadLetters, err := NewAMQPByteFIFO(AMQPByteFIFOConfiguration{
+			ConnectionString: config.ConnectionString,
+			QueueName:        config.DeadLetterQueueName,
+		})
+		if err != nil {
+			_ = byteFIFO.Close()
+			return nil, err
+		}
+		byteFIFOQueue.setDeadLetterQueue(deadLetters)
+	}
+
+	queue := &AMQPQueue{
+		ByteFIFOQueue: byteFIFOQueue,
+	}
+
+	queue.qid = GetManager().Add(queue, AMQPQueueType, config, exemplar)
+
+	return queue, nil
+}
+
+// errAMQPNotConfirmed is returned if the broker nacks a published message
+var errAMQPNotConfirmed = fmt.Errorf("message was not confirmed by the broker")
+
+// amqpClient is the subset of AMQP channel operations used by the AMQPByteFIFO
+type amqpClient interface {
+	// Publish publishes persistent data to the queue and waits until the broker confirms it
+	Publish(ctx context.Context, queue string, data []byte) error
+	// Get gets the next message of the queue without acknowledging it, ok is false if the queue is empty
+	Get(queue string) (delivery amqp.Delivery, ok bool, err error)
+	// Len returns the number of messages of the queue which are ready to be delivered
+	Len(queue string) (int, error)
+	// Mark sets the marker, it returns false if the marker is already set
+	Mark(ctx context.Context, marker string) (bool, error)
+	// Unmark clears the marker
+	Unmark(marker string) error
+	// IsMarked returns whether the marker is set
+	IsMarked(marker string) (bool, error)
+	Close() error
+}
+
+var _ AcknowledgeableByteFIFO = &AMQPByteFIFO{}
+
+// AMQPByteFIFO represents a ByteFIFO formed from a durable AMQP queue
+//
+// The ByteFIFOQueue acknowledges the messages once they have been handled. Messages which
+// are not acknowledged, e.g. because the connection was lost, are requeued by the broker.
+type AMQPByteFIFO struct {
+	client    amqpClient
+	queueName string
+}
+
+// AMQPByteFIFOConfiguration is the configuration for the AMQPByteFIFO
+type AMQPByteFIFOConfiguration struct {
+	ConnectionString string
+	QueueName        string
+}
+
+// NewAMQPByteFIFO creates a ByteFIFO formed from a durable AMQP queue
+func NewAMQPByteFIFO(config AMQPByteFIFOConfiguration) (*AMQPByteFIFO, error) {
+	client, err := newAMQPChannelClient(config.ConnectionString, config.QueueName)
+	if err != nil {
+		return nil, err
+	}
+	return &AMQPByteFIFO{
+		client:    client,
+		queueName: config.QueueName,
+	}, nil
+}
+
+// PushFunc pushes data to the end of the fifo and calls the callback if it is added
+func (fifo *AMQPByteFIFO) PushFunc(ctx context.Context, data []byte, fn func() error) error {
+	if fn != nil {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return fifo.client.Publish(ctx, fifo.queueName, data)
+}
+
+// PushBack pushes data back to the fifo. AMQP queues can only be appended to,
+// so the data is retried after the data which is already waiting.
+func (fifo *AMQPByteFIFO) PushBack(ctx context.Context, data []byte) error {
+	return fifo.client.Publish(ctx, fifo.queueName, data)
+}
+
+// Pop pops data from the start of the fifo and acknowledges it
+func (fifo *AMQPByteFIFO) Pop(ctx context.Context) ([]byte, error) {
+	data, acknowledger, err := fifo.PopUnacknowledged(ctx)
+	if err != nil || acknowledger == nil {
+		return nil, err
+	}
+	if err := acknowledger.Ack(ctx); err != nil {
+		// the broker requeues the message once the channel is closed
+		return nil, err
+	}
+	return data, nil
+}
+
+// PopUnacknowledged pops data from the start of the fifo, the broker requeues it unless it is acknowledged
+func (fifo *AMQPByteFIFO) PopUnacknowledged(ctx context.Context) ([]byte, Acknowledger, error) {
+	delivery, ok, err := fifo.client.Get(fifo.queueName)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	return delivery.Body, amqpAcknowledger{delivery}, nil
+}
+
+// amqpAcknowledger settles a delivery
+type amqpAcknowledger struct {
+	delivery amqp.Delivery
+}
+
+func (a amqpAcknowledger) Ack(ctx context.Context) error {
+	return a.delivery.Ack(false)
+}
+
+func (a amqpAcknowledger) Nack(ctx context.Context) error {
+	return a.delivery.Nack(false, true)
+}
+
+// Close this fifo
+func (fifo *AMQPByteFIFO) Close() error {
+	return fifo.client.Close()
+}
+
+// Len returns the length of the fifo
+func (fifo *AMQPByteFIFO) Len(ctx context.Context) int64 {
+	val, err := fifo.client.Len(fifo.queueName)
+	if err != nil {
+		log.Error("Error whilst getting length of AMQP queue %s: Error: %v", fifo.queueName, err)
+		return -1
+	}
+	return int64(val)
+}
+
+// amqpChannelClient is the amqpClient of an AMQP connection
+type amqpChannelClient struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+// newAMQPChannelClient connects to the broker, puts the channel into confirm mode and declares the durable queue
+func newAMQPChannelClient(connection, queue string) (*amqpChannelClient, error) {
+	conn, err := amqp.Dial(connection)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := channel.Confirm(false); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if _, err := channel.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("unable to declare queue %s: %w", queue, err)
+	}
+	return &amqpChannelClient{
+		conn:    conn,
+		channel: channel,
+	}, nil
+}
+
+func (c *amqpChannelClient) Publish(ctx context.Context, queue string, data []byte) error {
+	confirmation, err := c.channel.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         data,
+	})
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errAMQPNotConfirmed
+	}
+	return nil
+}
+
+func (c *amqpChannelClient) Get(queue string) (amqp.Delivery, bool, error) {
+	return c.channel.Get(queue, false)
+}
+
+func (c *amqpChannelClient) Len(queue string) (int, error) {
+	q, err := c.channel.QueueDeclarePassive(queue, true, false, false, false, nil)
+	if err != nil {
+		return 0, err
+	}
+	return q.Messages, nil
+}
+
+// amqpMarkerArguments limit a marker queue to a single message and make the broker nack any further one,
+// which needs RabbitMQ 3.7 or later
+var amqpMarkerArguments = amqp.Table{
+	"x-max-length": int32(1),
+	"x-overflow":   "reject-publish",
+}
+
+func (c *amqpChannelClient) Mark(ctx context.Context, marker string) (bool, error) {
+	if _, err := c.channel.QueueDeclare(marker, true, false, false, false, amqpMarkerArguments); err != nil {
+		return false, fmt.Errorf("unable to declare marker %s: %w", marker, err)
+	}
+	err := c.Publish(ctx, marker, nil)
+	if err == errAMQPNotConfirmed {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *amqpChannelClient) Unmark(marker string) error {
+	_, err := c.channel.QueueDelete(marker, false, false, false)
+	return err
+}
+
+func (c *amqpChannelClient) IsMarked(marker string) (bool, error) {
+	// a missing queue closes the channel, so it is looked up on a channel of its own
+	channel, err := c.conn.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer channel.Close()
+
+	q, err := channel.QueueDeclarePassive(marker, true, false, false, false, amqpMarkerArguments)
+	if err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return q.Messages > 0, nil
+}
+
+func (c *amqpChannelClient) Close() error {
+	return c.conn.Close()
+}
+
+func init() {
+	queuesMap[AMQPQueueType] = NewAMQPQueue
+}
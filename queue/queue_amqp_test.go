@@ -0,0 +1,302 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAMQPClient stands in for an AMQP broker with a single channel
+type testAMQPClient struct {
+	lock    sync.Mutex
+	queues  map[string][][]byte
+	unacked map[uint64][]byte
+	queueOf map[uint64]string
+	markers map[string]bool
+	tag     uint64
+	ackErr  error
+}
+
+func newTestAMQPClient() *testAMQPClient {
+	return &testAMQPClient{
+		queues:  map[string][][]byte{},
+		unacked: map[uint64][]byte{},
+		queueOf: map[uint64]string{},
+		markers: map[string]bool{},
+	}
+}
+
+func (c *testAMQPClient) Publish(ctx context.Context, queue string, data []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.queues[queue] = append(c.queues[queue], data)
+	return nil
+}
+
+func (c *testAMQPClient) Get(queue string) (amqp.Delivery, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.queues[queue]) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+	data := c.queues[queue][0]
+	c.queues[queue] = c.queues[queue][1:]
+	c.tag++
+	c.unacked[c.tag] = data
+	c.queueOf[c.tag] = queue
+	return amqp.Delivery{Acknowledger: c, DeliveryTag: c.tag, Body: data}, true, nil
+}
+
+func (c *testAMQPClient) Len(queue string) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.queues[queue]), nil
+}
+
+func (c *testAMQPClient) Mark(ctx context.Context, marker string) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.markers[marker] {
+		return false, nil
+	}
+	c.markers[marker] = true
+	return true, nil
+}
+
+func (c *testAMQPClient) Unmark(marker string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.markers, marker)
+	return nil
+}
+
+func (c *testAMQPClient) IsMarked(marker string) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.markers[marker], nil
+}
+
+func (c *testAMQPClient) Close() error {
+	return nil
+}
+
+func (c *testAMQPClient) Ack(tag uint64, multiple bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ackErr != nil {
+		return c.ackErr
+	}
+	delete(c.unacked, tag)
+	delete(c.queueOf, tag)
+	return nil
+}
+
+func (c *testAMQPClient) Nack(tag uint64, multiple, requeue bool) error {
+	return c.Reject(tag, requeue)
+}
+
+func (c *testAMQPClient) Reject(tag uint64, requeue bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if requeue {
+		queue := c.queueOf[tag]
+		c.queues[queue] = append([][]byte{c.unacked[tag]}, c.queues[queue]...)
+	}
+	delete(c.unacked, tag)
+	delete(c.queueOf, tag)
+	return nil
+}
+
+// closeChannel requeues the unacknowledged messages like a broker does once the channel is closed
+func (c *testAMQPClient) closeChannel() {
+	c.lock.Lock()
+	tags := make([]uint64, 0, len(c.unacked))
+	for tag := range c.unacked {
+		tags = append(tags, tag)
+	}
+	c.lock.Unlock()
+	for _, tag := range tags {
+		_ = c.Reject(tag, true)
+	}
+}
+
+func TestAMQPByteFIFO(t *testing.T) {
+	client := newTestAMQPClient()
+	fifo := &AMQPByteFIFO{client: client, queueName: "test"}
+	ctx := context.Background()
+
+	called := false
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("a"), func() error {
+		called = true
+		return nil
+	}))
+	assert.True(t, called)
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("b"), nil))
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+
+	errFn := errors.New("fn failed")
+	assert.ErrorIs(t, fifo.PushFunc(ctx, []byte("c"), func() error {
+		return errFn
+	}), errFn)
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+
+	data, err := fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+	assert.Empty(t, client.unacked)
+
+	// data pushed back is retried after the waiting data
+	assert.NoError(t, fifo.PushBack(ctx, data))
+
+	// unacknowledged data is requeued by the broker
+	errAck := errors.New("ack failed")
+	client.ackErr = errAck
+	data, err = fifo.Pop(ctx)
+	assert.ErrorIs(t, err, errAck)
+	assert.Nil(t, data)
+	client.ackErr = nil
+	client.closeChannel()
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+
+	data, err = fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), data)
+	data, err = fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+
+	data, err = fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	assert.EqualValues(t, 0, fifo.Len(ctx))
+}
+
+func TestAMQPUniqueByteFIFO(t *testing.T) {
+	client := newTestAMQPClient()
+	fifo := &AMQPUniqueByteFIFO{
+		AMQPByteFIFO: AMQPByteFIFO{client: client, queueName: "test"},
+		setName:      "test_unique",
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("a"), nil))
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("b"), nil))
+	assert.Len(t, client.markers, 2)
+
+	called := false
+	assert.ErrorIs(t, fifo.PushFunc(ctx, []byte("a"), func() error {
+		called = true
+		return nil
+	}), ErrAlreadyInQueue)
+	assert.False(t, called)
+	assert.ErrorIs(t, fifo.PushBack(ctx, []byte("a")), ErrAlreadyInQueue)
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+
+	// the marker is removed if the callback fails
+	errFn := errors.New("fn failed")
+	assert.ErrorIs(t, fifo.PushFunc(ctx, []byte("c"), func() error {
+		return errFn
+	}), errFn)
+	has, err := fifo.Has(ctx, []byte("c"))
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	has, err = fifo.Has(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	// popped data can be pushed again whilst it is handled
+	data, acknowledger, err := fifo.PopUnacknowledged(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+	has, err = fifo.Has(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, has)
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("a"), nil))
+	assert.NoError(t, acknowledger.Ack(ctx))
+	assert.Empty(t, client.unacked)
+
+	data, err = fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), data)
+	data, err = fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+	assert.Empty(t, client.markers)
+	assert.EqualValues(t, 0, fifo.Len(ctx))
+}
+
+func TestAMQPByteFIFOQueueAcknowledge(t *testing.T) {
+	var lock sync.Mutex
+	fail := true
+	handleChan := make(chan *testData, 10)
+	handle := func(data ...Data) []Data {
+		lock.Lock()
+		defer lock.Unlock()
+		if fail {
+			fail = false
+			return data
+		}
+		for _, datum := range data {
+			handleChan <- datum.(*testData)
+		}
+		return nil
+	}
+
+	client := newTestAMQPClient()
+	fifo := &AMQPByteFIFO{client: client, queueName: "test"}
+	q, err := NewByteFIFOQueue(AMQPQueueType, fifo, handle, ByteFIFOQueueConfiguration{
+		WorkerPoolConfiguration: WorkerPoolConfiguration{
+			QueueLength:  20,
+			BatchLength:  1,
+			BlockTimeout: 1 * time.Second,
+			BoostTimeout: 5 * time.Minute,
+			BoostWorkers: 5,
+			MaxWorkers:   10,
+		},
+		Workers: 1,
+	}, &testData{})
+	assert.NoError(t, err)
+
+	var shutdown, terminate []func()
+	go q.Run(func(f func()) {
+		lock.Lock()
+		shutdown = append(shutdown, f)
+		lock.Unlock()
+	}, func(f func()) {
+		lock.Lock()
+		terminate = append(terminate, f)
+		lock.Unlock()
+	})
+
+	// the unhandled message is requeued rather than pushed again and acknowledged once it is handled
+	assert.NoError(t, q.Push(&testData{"A", 1}))
+	result := <-handleChan
+	assert.Equal(t, "A", result.TestString)
+	assert.Eventually(t, func() bool {
+		client.lock.Lock()
+		defer client.lock.Unlock()
+		return len(client.unacked) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 2, client.tag)
+	assert.EqualValues(t, 0, fifo.Len(context.Background()))
+
+	lock.Lock()
+	for _, f := range shutdown {
+		f()
+	}
+	for _, f := range terminate {
+		f()
+	}
+	lock.Unlock()
+}
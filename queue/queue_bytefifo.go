@@ -16,6 +16,8 @@ import (
 	"github.com/gitbundle/modules/json"
 	"github.com/gitbundle/modules/log"
 	"github.com/gitbundle/modules/util"
+
+	"gitea.com/lunny/levelqueue"
 )
 
 // ByteFIFOQueueConfiguration is the configuration for a ByteFIFOQueue
@@ -23,9 +25,14 @@ type ByteFIFOQueueConfiguration struct {
 	WorkerPoolConfiguration
 	Workers     int
 	WaitOnEmpty bool
+	RetryConfiguration
 }
 
-var _ Queue = &ByteFIFOQueue{}
+var (
+	_ Queue          = &ByteFIFOQueue{}
+	_ DeadLetterable = &ByteFIFOQueue{}
+	_ Schedulable    = &ByteFIFOQueue{}
+)
 
 // ByteFIFOQueue is a Queue formed from a ByteFIFO and WorkerPool
 type ByteFIFOQueue struct {
@@ -42,6 +49,9 @@ type ByteFIFOQueue struct {
 	lock               sync.Mutex
 	waitOnEmpty        bool
 	pushed             chan struct{}
+	retrier            *retrier
+	deadLetters        *DeadLetterQueue
+	scheduler          *scheduler
 }
 
 // NewByteFIFOQueue creates a new ByteFIFOQueue
@@ -68,16 +78,127 @@ func NewByteFIFOQueue(typ Type, byteFIFO ByteFIFO, handle HandlerFunc, cfg, exem
 		waitOnEmpty:        config.WaitOnEmpty,
 		pushed:             make(chan struct{}, 1),
 	}
-	q.WorkerPool = NewWorkerPool(func(data ...Data) (failed []Data) {
+	q.WorkerPool = NewWorkerPool(q.wrapHandler(handle, config.RetryConfiguration), config.WorkerPoolConfiguration)
+
+	return q, nil
+}
+
+// wrapHandler returns a handler which pushes unhandled data back into the fifo.
+// Data of an AcknowledgeableByteFIFO is settled once it has been handled, without
+// retries its unhandled data is handed back to the broker instead of being pushed back.
+func (q *ByteFIFOQueue) wrapHandler(handle HandlerFunc, retry RetryConfiguration) HandlerFunc {
+	_, acknowledgeable := q.byteFIFO.(AcknowledgeableByteFIFO)
+	if retry.IsEnabled() {
+		q.retrier = newRetrier(q.terminateCtx, q.name, retry.Policy())
+		retried := func(data ...Data) []Data {
+			return q.retrier.handle(handle, q.PushBack, data...)
+		}
+		if acknowledgeable {
+			return q.acknowledge(retried)
+		}
+		return retried
+	}
+	if acknowledgeable {
+		return q.acknowledge(handle)
+	}
+	return func(data ...Data) (failed []Data) {
 		for _, unhandled := range handle(data...) {
 			if fail := q.PushBack(unhandled); fail != nil {
 				failed = append(failed, fail)
 			}
 		}
 		return
-	}, config.WorkerPoolConfiguration)
+	}
+}
 
-	return q, nil
+// unacknowledged is data popped from an AcknowledgeableByteFIFO which has not been settled yet
+type unacknowledged struct {
+	data         Data
+	acknowledger Acknowledger
+}
+
+// acknowledge returns a handler which passes the data of unacknowledged deliveries to handle and settles them
+// afterwards: the deliveries whose data handle returns are nacked so the broker delivers them again, the others
+// are acked. Data which is not an unacknowledged delivery is passed through.
+func (q *ByteFIFOQueue) acknowledge(handle HandlerFunc) HandlerFunc {
+	return func(data ...Data) []Data {
+		deliveries := make(map[string][]Acknowledger, len(data))
+		unwrapped := make([]Data, 0, len(data))
+		for _, datum := range data {
+			delivery, ok := datum.(*unacknowledged)
+			if !ok {
+				unwrapped = append(unwrapped, datum)
+				continue
+			}
+			bs, err := json.Marshal(delivery.data)
+			if err != nil {
+				log.Error("%s: %s Unable to key delivered data, acknowledging it now: %v", q.typ, q.name, err)
+				q.settle(delivery.acknowledger, true)
+			} else {
+				deliveries[string(bs)] = append(deliveries[string(bs)], delivery.acknowledger)
+			}
+			unwrapped = append(unwrapped, delivery.data)
+		}
+
+		var failed []Data
+		for _, datum := range handle(unwrapped...) {
+			bs, err := json.Marshal(datum)
+			if err != nil || len(deliveries[string(bs)]) == 0 {
+				failed = append(failed, datum)
+				continue
+			}
+			acknowledgers := deliveries[string(bs)]
+			q.settle(acknowledgers[0], false)
+			deliveries[string(bs)] = acknowledgers[1:]
+		}
+		for _, acknowledgers := range deliveries {
+			for _, acknowledger := range acknowledgers {
+				q.settle(acknowledger, true)
+			}
+		}
+		return failed
+	}
+}
+
+// settle acks or nacks a delivery. Deliveries which can't be settled are delivered again by the broker.
+func (q *ByteFIFOQueue) settle(acknowledger Acknowledger, handled bool) {
+	if handled {
+		if err := acknowledger.Ack(q.terminateCtx); err != nil {
+			log.Error("%s: %s Unable to acknowledge handled data, it will be delivered again: %v", q.typ, q.name, err)
+		}
+		return
+	}
+	if err := acknowledger.Nack(q.terminateCtx); err != nil {
+		log.Error("%s: %s Unable to hand unhandled data back: %v", q.typ, q.name, err)
+	}
+}
+
+// setDeadLetterQueue sets the fifo which keeps the data that failed all attempts
+func (q *ByteFIFOQueue) setDeadLetterQueue(byteFIFO ByteFIFO) {
+	q.deadLetters = NewDeadLetterQueue(q.name, byteFIFO)
+	if q.retrier != nil {
+		q.retrier.deadLetters = q.deadLetters
+	}
+}
+
+// setScheduledByteFIFO sets the fifo which keeps the data pushed with PushAt and the delayed retries until they are due
+func (q *ByteFIFOQueue) setScheduledByteFIFO(byteFIFO ScheduledByteFIFO) {
+	if q.retrier != nil {
+		q.retrier.schedule = q.PushAfter
+	}
+	q.scheduler = newScheduler(q.name, byteFIFO, func(bs []byte) error {
+		err := q.byteFIFO.PushFunc(q.terminateCtx, bs, nil)
+		if err == ErrAlreadyInQueue || err == levelqueue.ErrAlreadyInQueue {
+			return nil
+		}
+		if err == nil {
+			select {
+			case q.pushed <- struct{}{}:
+			default:
+			}
+		}
+		return err
+	})
 }
 
 // Name returns the name of this queue
@@ -126,6 +247,38 @@ func (q *ByteFIFOQueue) PushFunc(data Data, fn func() error) error {
 	return q.byteFIFO.PushFunc(q.terminateCtx, bs, fn)
 }
 
+// PushAt pushes data which becomes available at the provided time.
+// The scheduled data is not part of NumberInQueue and doesn't prevent the queue from being empty.
+func (q *ByteFIFOQueue) PushAt(data Data, at time.Time) error {
+	if q.scheduler == nil {
+		return ErrSchedulingNotSupported
+	}
+	if !at.After(time.Now()) {
+		return q.Push(data)
+	}
+	if !assignableTo(data, q.exemplar) {
+		return fmt.Errorf("unable to assign data: %v to same type as exemplar: %v in %s", data, q.exemplar, q.name)
+	}
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return q.scheduler.Schedule(q.terminateCtx, bs, at)
+}
+
+// PushAfter pushes data which becomes available after the provided delay
+func (q *ByteFIFOQueue) PushAfter(data Data, delay time.Duration) error {
+	return q.PushAt(data, time.Now().Add(delay))
+}
+
+// NumberOfScheduled returns the number of data which is not due yet
+func (q *ByteFIFOQueue) NumberOfScheduled() int64 {
+	if q.scheduler == nil {
+		return 0
+	}
+	return q.scheduler.Len(q.terminateCtx)
+}
+
 // IsEmpty checks if the queue is empty
 func (q *ByteFIFOQueue) IsEmpty() bool {
 	q.lock.Lock()
@@ -152,6 +305,57 @@ func (q *ByteFIFOQueue) Flush(timeout time.Duration) error {
 	return q.WorkerPool.Flush(timeout)
 }
 
+// HasDeadLetterQueue returns if data which failed all attempts is kept in a dead letter queue
+func (q *ByteFIFOQueue) HasDeadLetterQueue() bool {
+	return q.deadLetters != nil
+}
+
+// NumberOfDeadLetters returns the number of dead letters
+func (q *ByteFIFOQueue) NumberOfDeadLetters() int64 {
+	if q.deadLetters == nil {
+		return 0
+	}
+	return q.deadLetters.Len(q.terminateCtx)
+}
+
+// DeadLetters returns up to limit dead letters, oldest first
+func (q *ByteFIFOQueue) DeadLetters(limit int) ([]*DeadLetter, error) {
+	if q.deadLetters == nil {
+		return nil, ErrNoDeadLetterQueue
+	}
+	return q.deadLetters.List(q.terminateCtx, limit)
+}
+
+// RequeueDeadLetters pushes the dead letters with the provided ids, or all if no id is provided, back into the queue
+func (q *ByteFIFOQueue) RequeueDeadLetters(ids ...string) (int, error) {
+	if q.deadLetters == nil {
+		return 0, ErrNoDeadLetterQueue
+	}
+	requeued, err := q.deadLetters.Requeue(q.terminateCtx, func(letter *DeadLetter) error {
+		err := q.byteFIFO.PushFunc(q.terminateCtx, letter.Data, nil)
+		if err == ErrAlreadyInQueue || err == levelqueue.ErrAlreadyInQueue {
+			return nil
+		}
+		return err
+	}, ids...)
+	if requeued > 0 {
+		select {
+		case q.pushed <- struct{}{}:
+		default:
+		}
+	}
+	return requeued, err
+}
+
+// PurgeDeadLetters removes the dead letters with the provided ids, or all if no id is provided
+func (q *ByteFIFOQueue) PurgeDeadLetters(ids ...string) (int, error) {
+	if q.deadLetters == nil {
+		return 0, ErrNoDeadLetterQueue
+	}
+	removed, err := q.deadLetters.Remove(q.terminateCtx, ids...)
+	return len(removed), err
+}
+
 // Run runs the bytefifo queue
 func (q *ByteFIFOQueue) Run(atShutdown, atTerminate func(func())) {
 	pprof.SetGoroutineLabels(q.baseCtx)
@@ -161,12 +365,19 @@ func (q *ByteFIFOQueue) Run(atShutdown, atTerminate func(func())) {
 
 	_ = q.AddWorkers(q.workers, 0)
 
+	if q.scheduler != nil {
+		go q.scheduler.Run(q.shutdownCtx)
+	}
+
 	log.Trace("%s: %s Now running", q.typ, q.name)
 	q.readToChan()
 
 	<-q.shutdownCtx.Done()
 	log.Trace("%s: %s Waiting til done", q.typ, q.name)
 	q.Wait()
+	if q.retrier != nil {
+		q.retrier.flush()
+	}
 
 	log.Trace("%s: %s Waiting til cleaned", q.typ, q.name)
 	q.CleanUp(q.terminateCtx)
@@ -218,7 +429,9 @@ loop:
 				if !ok {
 					return
 				}
-				if err := q.PushBack(data); err != nil {
+				if delivery, ok := data.(*unacknowledged); ok {
+					q.settle(delivery.acknowledger, false)
+				} else if err := q.PushBack(data); err != nil {
 					log.Error("Unable to push back data into queue %s", q.name)
 				}
 				atomic.AddInt64(&q.numInQueue, -1)
@@ -297,7 +510,14 @@ var (
 func (q *ByteFIFOQueue) doPop() error {
 	q.lock.Lock()
 	defer q.lock.Unlock()
-	bs, err := q.byteFIFO.Pop(q.shutdownCtx)
+	var acknowledger Acknowledger
+	var bs []byte
+	var err error
+	if fifo, ok := q.byteFIFO.(AcknowledgeableByteFIFO); ok {
+		bs, acknowledger, err = fifo.PopUnacknowledged(q.shutdownCtx)
+	} else {
+		bs, err = q.byteFIFO.Pop(q.shutdownCtx)
+	}
 	if err != nil {
 		if err == context.Canceled {
 			q.baseCtxCancel()
@@ -307,6 +527,9 @@ func (q *ByteFIFOQueue) doPop() error {
 		return err
 	}
 	if len(bs) == 0 {
+		if acknowledger != nil {
+			q.settle(acknowledger, true)
+		}
 		if q.waitOnEmpty && q.byteFIFO.Len(q.shutdownCtx) == 0 {
 			return errQueueEmpty
 		}
@@ -316,10 +539,18 @@ func (q *ByteFIFOQueue) doPop() error {
 	data, err := unmarshalAs(bs, q.exemplar)
 	if err != nil {
 		log.Error("%s: %s Failed to unmarshal with error: %v", q.typ, q.name, err)
+		if acknowledger != nil {
+			// the broker would deliver it again and again
+			q.settle(acknowledger, true)
+		}
 		return errUnmarshal
 	}
 
 	log.Trace("%s %s: Task found: %#v", q.typ, q.name, data)
+	if acknowledger != nil {
+		q.WorkerPool.Push(&unacknowledged{data: data, acknowledger: acknowledger})
+		return nil
+	}
 	q.WorkerPool.Push(data)
 	return nil
 }
@@ -353,10 +584,23 @@ func (q *ByteFIFOQueue) Terminate() {
 	if log.IsDebug() {
 		log.Debug("%s: %s Closing with %d tasks left in queue", q.typ, q.name, q.byteFIFO.Len(q.terminateCtx))
 	}
+	if q.retrier != nil {
+		q.retrier.flush()
+	}
 	q.terminateCtxCancel()
 	if err := q.byteFIFO.Close(); err != nil {
 		log.Error("Error whilst closing internal byte fifo in %s: %s: %v", q.typ, q.name, err)
 	}
+	if q.scheduler != nil {
+		if err := q.scheduler.fifo.Close(); err != nil {
+			log.Error("Error whilst closing scheduled byte fifo in %s: %s: %v", q.typ, q.name, err)
+		}
+	}
+	if q.deadLetters != nil {
+		if err := q.deadLetters.Close(); err != nil {
+			log.Error("Error whilst closing dead letter byte fifo in %s: %s: %v", q.typ, q.name, err)
+		}
+	}
 	q.baseCtxFinished()
 	log.Debug("%s: %s Terminated", q.typ, q.name)
 }
@@ -396,14 +640,7 @@ func NewByteFIFOUniqueQueue(typ Type, byteFIFO UniqueByteFIFO, handle HandlerFun
 			name:               config.Name,
 		},
 	}
-	q.WorkerPool = NewWorkerPool(func(data ...Data) (failed []Data) {
-		for _, unhandled := range handle(data...) {
-			if fail := q.PushBack(unhandled); fail != nil {
-				failed = append(failed, fail)
-			}
-		}
-		return
-	}, config.WorkerPoolConfiguration)
+	q.WorkerPool = NewWorkerPool(q.wrapHandler(handle, config.RetryConfiguration), config.WorkerPoolConfiguration)
 
 	return q, nil
 }
@@ -52,6 +52,25 @@ func NewLevelQueue(handle HandlerFunc, cfg, exemplar interface{}) (Queue, error)
 		return nil, err
 	}
 
+	if config.HasDeadLetterQueue() {
+		deadLetters, err := NewLevelListByteFIFO(config.ConnectionString, config.DeadLetterQueueName)
+		if err != nil {
+			_ = byteFIFO.Close()
+			return nil, err
+		}
+		byteFIFOQueue.setDeadLetterQueue(deadLetters)
+	}
+
+	scheduled, err := NewLevelScheduledByteFIFO(config.ConnectionString, config.QueueName)
+	if err != nil {
+		_ = byteFIFO.Close()
+		if byteFIFOQueue.deadLetters != nil {
+			_ = byteFIFOQueue.deadLetters.Close()
+		}
+		return nil, err
+	}
+	byteFIFOQueue.setScheduledByteFIFO(scheduled)
+
 	queue := &LevelQueue{
 		ByteFIFOQueue: byteFIFOQueue,
 	}
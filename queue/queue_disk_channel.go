@@ -13,6 +13,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/gitbundle/modules/json"
 	"github.com/gitbundle/modules/log"
 )
 
@@ -32,6 +33,7 @@ type PersistableChannelQueueConfiguration struct {
 	BlockTimeout time.Duration
 	BoostTimeout time.Duration
 	BoostWorkers int
+	RetryConfiguration
 }
 
 // PersistableChannelQueue wraps a channel queue and level queue together
@@ -40,8 +42,11 @@ type PersistableChannelQueueConfiguration struct {
 type PersistableChannelQueue struct {
 	channelQueue *ChannelQueue
 	delayedStarter
-	lock   sync.Mutex
-	closed chan struct{}
+	lock        sync.Mutex
+	closed      chan struct{}
+	retrier     *retrier
+	deadLetters *DeadLetterQueue
+	scheduler   *scheduler
 }
 
 // NewPersistableChannelQueue creates a wrapped batched channel queue with persistable level queue backend when shutting down
@@ -57,7 +62,43 @@ func NewPersistableChannelQueue(handle HandlerFunc, cfg, exemplar interface{}) (
 		closed: make(chan struct{}),
 	}
 
+	if config.RetryConfiguration.IsEnabled() {
+		queue.retrier = newRetrier(context.Background(), config.Name, config.RetryConfiguration.Policy())
+	}
+	if config.RetryConfiguration.HasDeadLetterQueue() {
+		deadLetters, err := NewLevelListByteFIFO(config.DataDir, config.DeadLetterQueueName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create dead letter queue for %s: %w", config.Name, err)
+		}
+		queue.deadLetters = NewDeadLetterQueue(config.Name, deadLetters)
+		queue.retrier.deadLetters = queue.deadLetters
+	}
+
+	scheduled, err := NewLevelScheduledByteFIFO(config.DataDir, config.Name)
+	if err != nil {
+		queue.closeStores()
+		return nil, fmt.Errorf("unable to create scheduled queue for %s: %w", config.Name, err)
+	}
+	queue.scheduler = newScheduler(config.Name, scheduled, func(bs []byte) error {
+		data, err := unmarshalAs(bs, exemplar)
+		if err != nil {
+			if queue.deadLetters == nil {
+				log.Error("Unable to unmarshal scheduled data in %s, dropping it: %v", config.Name, err)
+				return nil
+			}
+			log.Error("Unable to unmarshal scheduled data in %s, moving it to the dead letter queue: %v", config.Name, err)
+			return queue.deadLetters.PushBytes(context.Background(), bs, 0)
+		}
+		return queue.Push(data)
+	})
+	if queue.retrier != nil {
+		queue.retrier.schedule = queue.PushAfter
+	}
+
 	wrappedHandle := func(data ...Data) (failed []Data) {
+		if queue.retrier != nil {
+			return queue.retrier.handle(handle, queue.PushBack, data...)
+		}
 		for _, unhandled := range handle(data...) {
 			if fail := queue.PushBack(unhandled); fail != nil {
 				failed = append(failed, fail)
@@ -79,6 +120,7 @@ func NewPersistableChannelQueue(handle HandlerFunc, cfg, exemplar interface{}) (
 		Workers: config.Workers,
 	}, exemplar)
 	if err != nil {
+		queue.closeStores()
 		return nil, err
 	}
 
@@ -111,6 +153,7 @@ func NewPersistableChannelQueue(handle HandlerFunc, cfg, exemplar interface{}) (
 	}
 	if IsErrInvalidConfiguration(err) {
 		// Retrying ain't gonna make this any better...
+		queue.closeStores()
 		return nil, ErrInvalidConfiguration{cfg: cfg}
 	}
 
@@ -141,6 +184,37 @@ func (q *PersistableChannelQueue) Push(data Data) error {
 	}
 }
 
+// PushAt pushes data which becomes available at the provided time
+func (q *PersistableChannelQueue) PushAt(data Data, at time.Time) error {
+	if q.scheduler == nil {
+		return ErrSchedulingNotSupported
+	}
+	if !at.After(time.Now()) {
+		return q.Push(data)
+	}
+	if !assignableTo(data, q.channelQueue.exemplar) {
+		return fmt.Errorf("unable to assign data: %v to same type as exemplar: %v in queue: %s", data, q.channelQueue.exemplar, q.Name())
+	}
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return q.scheduler.Schedule(context.Background(), bs, at)
+}
+
+// PushAfter pushes data which becomes available after the provided delay
+func (q *PersistableChannelQueue) PushAfter(data Data, delay time.Duration) error {
+	return q.PushAt(data, time.Now().Add(delay))
+}
+
+// NumberOfScheduled returns the number of data which is not due yet
+func (q *PersistableChannelQueue) NumberOfScheduled() int64 {
+	if q.scheduler == nil {
+		return 0
+	}
+	return q.scheduler.Len(context.Background())
+}
+
 // PushBack will push the indexer data to queue
 func (q *PersistableChannelQueue) PushBack(data Data) error {
 	select {
@@ -174,6 +248,11 @@ func (q *PersistableChannelQueue) Run(atShutdown, atTerminate func(func())) {
 	atShutdown(q.Shutdown)
 	atTerminate(q.Terminate)
 
+	if q.scheduler != nil {
+		// scheduled data which is not due at shutdown stays in the level db until the next start
+		go q.scheduler.Run(q.channelQueue.shutdownCtx)
+	}
+
 	if lq, ok := q.internal.(*LevelQueue); ok && lq.byteFIFO.Len(lq.shutdownCtx) != 0 {
 		// Just run the level queue - we shut it down once it's flushed
 		go q.internal.Run(func(_ func()) {}, func(_ func()) {})
@@ -315,6 +394,10 @@ func (q *PersistableChannelQueue) Shutdown() {
 	log.Trace("PersistableChannelQueue: %s Waiting til done", q.delayedStarter.name)
 	q.channelQueue.Wait()
 	q.internal.(*LevelQueue).Wait()
+	if q.retrier != nil {
+		// the closed queue pushes the delayed retries back into the level queue
+		q.retrier.flush()
+	}
 	// Redirect all remaining data in the chan to the internal channel
 	log.Trace("PersistableChannelQueue: %s Redirecting remaining data", q.delayedStarter.name)
 	close(q.channelQueue.dataChan)
@@ -327,6 +410,50 @@ func (q *PersistableChannelQueue) Shutdown() {
 	log.Debug("PersistableChannelQueue: %s Shutdown", q.delayedStarter.name)
 }
 
+// HasDeadLetterQueue returns if data which failed all attempts is kept in a dead letter queue
+func (q *PersistableChannelQueue) HasDeadLetterQueue() bool {
+	return q.deadLetters != nil
+}
+
+// NumberOfDeadLetters returns the number of dead letters
+func (q *PersistableChannelQueue) NumberOfDeadLetters() int64 {
+	if q.deadLetters == nil {
+		return 0
+	}
+	return q.deadLetters.Len(context.Background())
+}
+
+// DeadLetters returns up to limit dead letters, oldest first
+func (q *PersistableChannelQueue) DeadLetters(limit int) ([]*DeadLetter, error) {
+	if q.deadLetters == nil {
+		return nil, ErrNoDeadLetterQueue
+	}
+	return q.deadLetters.List(context.Background(), limit)
+}
+
+// RequeueDeadLetters pushes the dead letters with the provided ids, or all if no id is provided, back into the queue
+func (q *PersistableChannelQueue) RequeueDeadLetters(ids ...string) (int, error) {
+	if q.deadLetters == nil {
+		return 0, ErrNoDeadLetterQueue
+	}
+	return q.deadLetters.Requeue(context.Background(), func(letter *DeadLetter) error {
+		data, err := unmarshalAs(letter.Data, q.channelQueue.exemplar)
+		if err != nil {
+			return err
+		}
+		return q.Push(data)
+	}, ids...)
+}
+
+// PurgeDeadLetters removes the dead letters with the provided ids, or all if no id is provided
+func (q *PersistableChannelQueue) PurgeDeadLetters(ids ...string) (int, error) {
+	if q.deadLetters == nil {
+		return 0, ErrNoDeadLetterQueue
+	}
+	removed, err := q.deadLetters.Remove(context.Background(), ids...)
+	return len(removed), err
+}
+
 // Terminate this queue and close the queue
 func (q *PersistableChannelQueue) Terminate() {
 	log.Trace("PersistableChannelQueue: %s Terminating", q.delayedStarter.name)
@@ -337,9 +464,24 @@ func (q *PersistableChannelQueue) Terminate() {
 	if q.internal != nil {
 		q.internal.(*LevelQueue).Terminate()
 	}
+	q.closeStores()
 	log.Debug("PersistableChannelQueue: %s Terminated", q.delayedStarter.name)
 }
 
+// closeStores closes the scheduled and the dead letter queue
+func (q *PersistableChannelQueue) closeStores() {
+	if q.scheduler != nil {
+		if err := q.scheduler.fifo.Close(); err != nil {
+			log.Error("Error whilst closing scheduled queue of %s: %v", q.delayedStarter.name, err)
+		}
+	}
+	if q.deadLetters != nil {
+		if err := q.deadLetters.Close(); err != nil {
+			log.Error("Error whilst closing dead letter queue of %s: %v", q.delayedStarter.name, err)
+		}
+	}
+}
+
 func init() {
 	queuesMap[PersistableChannelQueueType] = NewPersistableChannelQueue
 }
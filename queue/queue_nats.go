@@ -0,0 +1,358 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSQueueType is the type for NATS JetStream queue
+const NATSQueueType Type = "nats"
+
+// NATSQueueConfiguration is the configuration for the NATS JetStream queue
+type NATSQueueConfiguration struct {
+	ByteFIFOQueueConfiguration
+	NATSByteFIFOConfiguration
+}
+
+// NATSQueue NATS JetStream queue
+type NATSQueue struct {
+	*ByteFIFOQueue
+}
+
+// NewNATSQueue creates a queue backed by a NATS JetStream stream
+func NewNATSQueue(handle HandlerFunc, cfg, exemplar interface{}) (Queue, error) {
+	configInterface, err := toConfig(NATSQueueConfiguration{}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	config := configInterface.(NATSQueueConfiguration)
+
+	byteFIFO, err := NewNATSByteFIFO(config.NATSByteFIFOConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	byteFIFOQueue, err := NewByteFIFOQueue(NATSQueueType, byteFIFO, handle, config.ByteFIFOQueueConfiguration, exemplar)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.HasDeadLetterQueue() {
+		deadLetters, err := NewNATSByteFIFO(NATSByteFIFOConfiguration{
+			ConnectionString: config.ConnectionString,
+			QueueName:        config.DeadLetterQueueName,
+		})
+		if err != nil {
+			_ = byteFIFO.Close()
+			return nil, err
+		}
+		byteFIFOQueue.setDeadLetterQueue(deadLetters)
+	}
+
+	queue := &NATSQueue{
+		ByteFIFOQueue: byteFIFOQueue,
+	}
+
+	queue.qid = GetManager().Add(queue, NATSQueueType, config, exemplar)
+
+	return queue, nil
+}
+
+// errNATSSubjectNotEmpty is returned by natsClient.Publish if a message was expected to be the first on its subject
+var errNATSSubjectNotEmpty = fmt.Errorf("subject already has a message")
+
+// natsMessage is a message fetched from a JetStream consumer
+type natsMessage struct {
+	Subject string
+	Data    []byte
+	// Ack acknowledges the message, which removes it from the work queue stream
+	Ack func(ctx context.Context) error
+	// Nak tells JetStream to deliver the message again
+	Nak func(ctx context.Context) error
+}
+
+// natsClient is the subset of JetStream operations used by the NATS ByteFIFOs
+type natsClient interface {
+	// Publish stores data on the subject. If first is set the data is rejected with errNATSSubjectNotEmpty
+	// whilst the subject has a message which has not been acknowledged.
+	Publish(ctx context.Context, subject string, data []byte, first bool) error
+	// Fetch returns the next message of the consumer or nil if there is none
+	Fetch(ctx context.Context) (*natsMessage, error)
+	// HasSubject returns whether the subject has a message which has not been acknowledged
+	HasSubject(ctx context.Context, subject string) (bool, error)
+	// Len returns the number of messages which have not been acknowledged
+	Len(ctx context.Context) (int64, error)
+	Close() error
+}
+
+var _ AcknowledgeableByteFIFO = &NATSByteFIFO{}
+
+// NATSByteFIFO represents a ByteFIFO formed from a JetStream work queue stream
+//
+// The ByteFIFOQueue acknowledges the messages once they have been handled. Messages
+// which are not acknowledged, e.g. because the process stopped, are redelivered.
+type NATSByteFIFO struct {
+	client  natsClient
+	subject string
+}
+
+// NATSByteFIFOConfiguration is the configuration for the NATSByteFIFO
+type NATSByteFIFOConfiguration struct {
+	ConnectionString string
+	QueueName        string
+}
+
+// NewNATSByteFIFO creates a ByteFIFO formed from a JetStream stream named after the queue
+func NewNATSByteFIFO(config NATSByteFIFOConfiguration) (*NATSByteFIFO, error) {
+	subject := natsName(config.QueueName)
+	client, err := newJetStreamClient(config.ConnectionString, subject, subject, false)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSByteFIFO{
+		client:  client,
+		subject: subject,
+	}, nil
+}
+
+// PushFunc pushes data to the end of the fifo and calls the callback if it is added
+func (fifo *NATSByteFIFO) PushFunc(ctx context.Context, data []byte, fn func() error) error {
+	if fn != nil {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return fifo.client.Publish(ctx, fifo.subject, data, false)
+}
+
+// PushBack pushes data back to the fifo. JetStream streams can only be appended to,
+// so the data is retried after the data which is already waiting.
+func (fifo *NATSByteFIFO) PushBack(ctx context.Context, data []byte) error {
+	return fifo.client.Publish(ctx, fifo.subject, data, false)
+}
+
+// Pop pops data from the start of the fifo and acknowledges it
+func (fifo *NATSByteFIFO) Pop(ctx context.Context) ([]byte, error) {
+	data, acknowledger, err := fifo.PopUnacknowledged(ctx)
+	if err != nil || acknowledger == nil {
+		return nil, err
+	}
+	if err := acknowledger.Ack(ctx); err != nil {
+		// the message is redelivered once the ack wait has passed
+		return nil, err
+	}
+	return data, nil
+}
+
+// PopUnacknowledged pops data from the start of the fifo, it is redelivered unless it is acknowledged
+func (fifo *NATSByteFIFO) PopUnacknowledged(ctx context.Context) ([]byte, Acknowledger, error) {
+	msg, err := fifo.client.Fetch(ctx)
+	if err != nil || msg == nil {
+		return nil, nil, err
+	}
+	return msg.Data, natsAcknowledger{msg}, nil
+}
+
+// natsAcknowledger settles a fetched message
+type natsAcknowledger struct {
+	msg *natsMessage
+}
+
+func (a natsAcknowledger) Ack(ctx context.Context) error {
+	return a.msg.Ack(ctx)
+}
+
+func (a natsAcknowledger) Nack(ctx context.Context) error {
+	return a.msg.Nak(ctx)
+}
+
+// Close this fifo
+func (fifo *NATSByteFIFO) Close() error {
+	return fifo.client.Close()
+}
+
+// Len returns the length of the fifo
+func (fifo *NATSByteFIFO) Len(ctx context.Context) int64 {
+	val, err := fifo.client.Len(ctx)
+	if err != nil {
+		log.Error("Error whilst getting length of NATS queue %s: Error: %v", fifo.subject, err)
+		return -1
+	}
+	return val
+}
+
+var natsNameReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_")
+
+// natsName converts a queue name into a valid stream name and subject token
+func natsName(name string) string {
+	return natsNameReplacer.Replace(name)
+}
+
+const (
+	// natsFetchWait is the longest time Pop waits for a message
+	natsFetchWait = 100 * time.Millisecond
+	// natsWrongLastSequence is part of the error JetStream returns if an expected last subject sequence is not met
+	natsWrongLastSequence = "wrong last sequence"
+	// natsMaxMsgsPerSubject is part of the error JetStream returns if a stream which discards new messages
+	// already has a message on the subject
+	natsMaxMsgsPerSubject = "maximum messages per subject exceeded"
+)
+
+// jetStreamClient is the natsClient of a NATS connection
+type jetStreamClient struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	sub    *nats.Subscription
+	stream string
+}
+
+// newJetStreamClient connects to NATS and creates the work queue stream and its durable pull consumer if they do not exist.
+// A stream with one message per subject rejects further messages on a subject until its message is acknowledged.
+func newJetStreamClient(connection, stream, subject string, onePerSubject bool) (*jetStreamClient, error) {
+	conn, err := nats.Connect(connection)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err := js.StreamInfo(stream); err != nil {
+		config := &nats.StreamConfig{
+			Name:      stream,
+			Subjects:  []string{subject},
+			Retention: nats.WorkQueuePolicy,
+			Storage:   nats.FileStorage,
+		}
+		if onePerSubject {
+			// servers which ignore an expected last subject sequence of 0 reject the message instead
+			config.MaxMsgsPerSubject = 1
+			config.Discard = nats.DiscardNew
+		}
+		if _, err := js.AddStream(config); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to create stream %s: %w", stream, err)
+		}
+	}
+
+	sub, err := js.PullSubscribe(subject, stream, nats.BindStream(stream))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to subscribe to stream %s: %w", stream, err)
+	}
+
+	return &jetStreamClient{
+		conn:   conn,
+		js:     js,
+		sub:    sub,
+		stream: stream,
+	}, nil
+}
+
+func (c *jetStreamClient) Publish(ctx context.Context, subject string, data []byte, first bool) error {
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	if first {
+		msg.Header.Set("Nats-Expected-Last-Subject-Sequence", "0")
+	}
+	_, err := c.js.PublishMsg(msg, nats.Context(ctx))
+	if err != nil && first && (strings.Contains(err.Error(), natsWrongLastSequence) || strings.Contains(err.Error(), natsMaxMsgsPerSubject)) {
+		return errNATSSubjectNotEmpty
+	}
+	return err
+}
+
+func (c *jetStreamClient) Fetch(ctx context.Context) (*natsMessage, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, natsFetchWait)
+	defer cancel()
+
+	msgs, err := c.sub.Fetch(1, nats.Context(fetchCtx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == context.DeadlineExceeded || err == nats.ErrTimeout {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+
+	msg := msgs[0]
+	return &natsMessage{
+		Subject: msg.Subject,
+		Data:    msg.Data,
+		Ack: func(ctx context.Context) error {
+			return msg.AckSync(nats.Context(ctx))
+		},
+		Nak: func(ctx context.Context) error {
+			return msg.Nak(nats.Context(ctx))
+		},
+	}, nil
+}
+
+func (c *jetStreamClient) HasSubject(ctx context.Context, subject string) (bool, error) {
+	req, err := json.Marshal(&struct {
+		LastBySubject string `json:"last_by_subj"`
+	}{
+		LastBySubject: subject,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.conn.RequestWithContext(ctx, "$JS.API.STREAM.MSG.GET."+c.stream, req)
+	if err != nil {
+		return false, err
+	}
+
+	var msgResp struct {
+		Error *struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Data, &msgResp); err != nil {
+		return false, err
+	}
+	if msgResp.Error == nil {
+		return true, nil
+	}
+	if msgResp.Error.Code == 404 {
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to get last message of %s: %s", subject, msgResp.Error.Description)
+}
+
+func (c *jetStreamClient) Len(ctx context.Context) (int64, error) {
+	info, err := c.js.StreamInfo(c.stream, nats.Context(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.State.Msgs), nil
+}
+
+func (c *jetStreamClient) Close() error {
+	c.conn.Close()
+	return nil
+}
+
+func init() {
+	queuesMap[NATSQueueType] = NewNATSQueue
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runTestNATSServer starts an embedded NATS server with JetStream enabled and returns its url
+func runTestNATSServer(t *testing.T) string {
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+	go srv.Start()
+	require.True(t, srv.ReadyForConnections(10*time.Second), "NATS server is not ready")
+	t.Cleanup(srv.Shutdown)
+	return srv.ClientURL()
+}
+
+func TestNATSName(t *testing.T) {
+	assert.Equal(t, "issue_indexer", natsName("issue_indexer"))
+	assert.Equal(t, "a_b_c_d_e", natsName("a.b*c>d e"))
+}
+
+func TestNATSByteFIFO(t *testing.T) {
+	fifo, err := NewNATSByteFIFO(NATSByteFIFOConfiguration{
+		ConnectionString: runTestNATSServer(t),
+		QueueName:        "test.queue",
+	})
+	require.NoError(t, err)
+	defer fifo.Close()
+	ctx := context.Background()
+
+	called := false
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("a"), func() error {
+		called = true
+		return nil
+	}))
+	assert.True(t, called)
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("b"), nil))
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+
+	data, err := fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+	assert.EqualValues(t, 1, fifo.Len(ctx))
+
+	// data pushed back is retried after the waiting data
+	assert.NoError(t, fifo.PushBack(ctx, data))
+
+	// unacknowledged data stays in the stream and nacked data is delivered again
+	data, acknowledger, err := fifo.PopUnacknowledged(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), data)
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+	assert.NoError(t, acknowledger.Nack(ctx))
+
+	var popped []string
+	assert.Eventually(t, func() bool {
+		data, err := fifo.Pop(ctx)
+		assert.NoError(t, err)
+		if len(data) > 0 {
+			popped = append(popped, string(data))
+		}
+		return len(popped) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"a", "b"}, popped)
+	assert.EqualValues(t, 0, fifo.Len(ctx))
+
+	data, err = fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestNATSUniqueByteFIFO(t *testing.T) {
+	fifo, err := NewNATSUniqueByteFIFO(NATSUniqueByteFIFOConfiguration{
+		NATSByteFIFOConfiguration: NATSByteFIFOConfiguration{
+			ConnectionString: runTestNATSServer(t),
+			QueueName:        "test",
+		},
+		SetName: "test_unique",
+	})
+	require.NoError(t, err)
+	defer fifo.Close()
+	ctx := context.Background()
+
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("a"), nil))
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("b"), nil))
+
+	called := false
+	assert.ErrorIs(t, fifo.PushFunc(ctx, []byte("a"), func() error {
+		called = true
+		return nil
+	}), ErrAlreadyInQueue)
+	assert.False(t, called)
+	assert.ErrorIs(t, fifo.PushBack(ctx, []byte("a")), ErrAlreadyInQueue)
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+
+	has, err := fifo.Has(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	// the data is kept until it is acknowledged
+	data, acknowledger, err := fifo.PopUnacknowledged(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), data)
+	has, err = fifo.Has(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.ErrorIs(t, fifo.PushFunc(ctx, []byte("a"), nil), ErrAlreadyInQueue)
+
+	assert.NoError(t, acknowledger.Ack(ctx))
+	has, err = fifo.Has(ctx, []byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	// acknowledged data can be pushed again
+	assert.NoError(t, fifo.PushFunc(ctx, []byte("a"), nil))
+	assert.EqualValues(t, 2, fifo.Len(ctx))
+
+	data, err = fifo.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), data)
+}
+
+func TestNATSByteFIFOQueue(t *testing.T) {
+	handleChan := make(chan *testData, 10)
+	handle := func(data ...Data) []Data {
+		for _, datum := range data {
+			handleChan <- datum.(*testData)
+		}
+		return nil
+	}
+
+	fifo, err := NewNATSByteFIFO(NATSByteFIFOConfiguration{
+		ConnectionString: runTestNATSServer(t),
+		QueueName:        "test",
+	})
+	require.NoError(t, err)
+	q, err := NewByteFIFOQueue(NATSQueueType, fifo, handle, ByteFIFOQueueConfiguration{
+		WorkerPoolConfiguration: WorkerPoolConfiguration{
+			QueueLength:  20,
+			BatchLength:  1,
+			BlockTimeout: 1 * time.Second,
+			BoostTimeout: 5 * time.Minute,
+			BoostWorkers: 5,
+			MaxWorkers:   10,
+		},
+		Workers: 1,
+	}, &testData{})
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	var shutdown, terminate []func()
+	go q.Run(func(f func()) {
+		lock.Lock()
+		shutdown = append(shutdown, f)
+		lock.Unlock()
+	}, func(f func()) {
+		lock.Lock()
+		terminate = append(terminate, f)
+		lock.Unlock()
+	})
+
+	assert.NoError(t, q.Push(&testData{"A", 1}))
+	assert.NoError(t, q.Push(&testData{"B", 2}))
+	assert.ErrorIs(t, q.PushAfter(&testData{"C", 3}, time.Second), ErrSchedulingNotSupported)
+
+	result := <-handleChan
+	assert.Equal(t, "A", result.TestString)
+	result = <-handleChan
+	assert.Equal(t, "B", result.TestString)
+
+	lock.Lock()
+	for _, f := range shutdown {
+		f()
+	}
+	for _, f := range terminate {
+		f()
+	}
+	lock.Unlock()
+}
+
+func TestNATSByteFIFOQueueAcknowledge(t *testing.T) {
+	var lock sync.Mutex
+	fail := true
+	handling := make(chan *testData)
+	handled := make(chan struct{})
+	handle := func(data ...Data) []Data {
+		for _, datum := range data {
+			handling <- datum.(*testData)
+			<-handled
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if fail {
+			fail = false
+			return data
+		}
+		return nil
+	}
+
+	fifo, err := NewNATSByteFIFO(NATSByteFIFOConfiguration{
+		ConnectionString: runTestNATSServer(t),
+		QueueName:        "test",
+	})
+	require.NoError(t, err)
+	q, err := NewByteFIFOQueue(NATSQueueType, fifo, handle, ByteFIFOQueueConfiguration{
+		WorkerPoolConfiguration: WorkerPoolConfiguration{
+			QueueLength:  20,
+			BatchLength:  1,
+			BlockTimeout: 1 * time.Second,
+			BoostTimeout: 5 * time.Minute,
+			BoostWorkers: 5,
+			MaxWorkers:   10,
+		},
+		Workers: 1,
+	}, &testData{})
+	assert.NoError(t, err)
+
+	var shutdown, terminate []func()
+	go q.Run(func(f func()) {
+		lock.Lock()
+		shutdown = append(shutdown, f)
+		lock.Unlock()
+	}, func(f func()) {
+		lock.Lock()
+		terminate = append(terminate, f)
+		lock.Unlock()
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, q.Push(&testData{"A", 1}))
+
+	// the message stays in the stream whilst it is handled
+	result := <-handling
+	assert.Equal(t, "A", result.TestString)
+	assert.EqualValues(t, 1, fifo.Len(ctx))
+	handled <- struct{}{}
+
+	// the unhandled message is delivered again
+	result = <-handling
+	assert.Equal(t, "A", result.TestString)
+	assert.EqualValues(t, 1, fifo.Len(ctx))
+	handled <- struct{}{}
+
+	assert.Eventually(t, func() bool {
+		return fifo.Len(ctx) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	lock.Lock()
+	for _, f := range shutdown {
+		f()
+	}
+	for _, f := range terminate {
+		f()
+	}
+	lock.Unlock()
+}
@@ -47,6 +47,28 @@ func NewRedisQueue(handle HandlerFunc, cfg, exemplar interface{}) (Queue, error)
 		return nil, err
 	}
 
+	if config.HasDeadLetterQueue() {
+		deadLetters, err := NewRedisByteFIFO(RedisByteFIFOConfiguration{
+			ConnectionString: config.ConnectionString,
+			QueueName:        config.DeadLetterQueueName,
+		})
+		if err != nil {
+			_ = byteFIFO.Close()
+			return nil, err
+		}
+		byteFIFOQueue.setDeadLetterQueue(deadLetters)
+	}
+
+	scheduled, err := NewRedisScheduledByteFIFO(config.ConnectionString, config.QueueName+"_scheduled")
+	if err != nil {
+		_ = byteFIFO.Close()
+		if byteFIFOQueue.deadLetters != nil {
+			_ = byteFIFOQueue.deadLetters.Close()
+		}
+		return nil, err
+	}
+	byteFIFOQueue.setScheduledByteFIFO(scheduled)
+
 	queue := &RedisQueue{
 		ByteFIFOQueue: byteFIFOQueue,
 	}
@@ -61,14 +83,21 @@ type redisClient interface {
 	LPush(ctx context.Context, key string, args ...interface{}) *redis.IntCmd
 	LPop(ctx context.Context, key string) *redis.StringCmd
 	LLen(ctx context.Context, key string) *redis.IntCmd
+	LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	LRem(ctx context.Context, key string, count int64, value interface{}) *redis.IntCmd
 	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
 	SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
 	SIsMember(ctx context.Context, key string, member interface{}) *redis.BoolCmd
+	ZAdd(ctx context.Context, key string, members ...redis.Z) *redis.IntCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	ZCard(ctx context.Context, key string) *redis.IntCmd
+	ZRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd
+	ZRangeByScoreWithScores(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.ZSliceCmd
 	Ping(ctx context.Context) *redis.StatusCmd
 	Close() error
 }
 
-var _ ByteFIFO = &RedisByteFIFO{}
+var _ PeekableByteFIFO = &RedisByteFIFO{}
 
 // RedisByteFIFO represents a ByteFIFO formed from a redisClient
 type RedisByteFIFO struct {
@@ -119,6 +148,29 @@ func (fifo *RedisByteFIFO) Pop(ctx context.Context) ([]byte, error) {
 	return data, err
 }
 
+// Peek returns up to limit data from the start of the fifo without removing it
+func (fifo *RedisByteFIFO) Peek(ctx context.Context, limit int) ([][]byte, error) {
+	stop := int64(limit) - 1
+	if limit <= 0 {
+		stop = -1
+	}
+	values, err := fifo.client.LRange(ctx, fifo.queueName, 0, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	data := make([][]byte, 0, len(values))
+	for _, value := range values {
+		data = append(data, []byte(value))
+	}
+	return data, nil
+}
+
+// Remove removes the first occurrence of the data from the fifo
+func (fifo *RedisByteFIFO) Remove(ctx context.Context, data []byte) (bool, error) {
+	removed, err := fifo.client.LRem(ctx, fifo.queueName, 1, data).Result()
+	return removed > 0, err
+}
+
 // Close this fifo
 func (fifo *RedisByteFIFO) Close() error {
 	return fifo.client.Close()
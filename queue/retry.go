@@ -0,0 +1,276 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/log"
+)
+
+// RetryConfiguration is the configuration of the retries of unhandled data
+//
+// Retries are disabled if neither RetryMaxAttempts nor RetryBackoff is set,
+// unhandled data is then pushed back immediately as often as the handler returns it.
+//
+// Queues with a scheduled fifo keep the delayed retries in it, so they survive restarts.
+// Other queues hold the delayed retries in memory and push them back without further
+// delay once they are terminated.
+type RetryConfiguration struct {
+	// RetryMaxAttempts is the number of attempts before data is moved to the dead letter queue, 0 retries forever
+	RetryMaxAttempts int
+	// RetryBackoff is the delay before the first retry, it doubles with every further attempt up to RetryMaxBackoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// RetryJitter is the fraction of the delay which is randomly subtracted to spread out retries
+	RetryJitter float64
+	// DeadLetterQueueName is the name of the dead letter queue, an empty name drops data which failed all attempts
+	DeadLetterQueueName string
+}
+
+// IsEnabled returns if unhandled data is retried according to this configuration
+func (c RetryConfiguration) IsEnabled() bool {
+	return c.RetryMaxAttempts > 0 || c.RetryBackoff > 0
+}
+
+// HasDeadLetterQueue returns if data which failed all attempts is kept in a dead letter queue
+func (c RetryConfiguration) HasDeadLetterQueue() bool {
+	return c.RetryMaxAttempts > 0 && len(c.DeadLetterQueueName) > 0
+}
+
+// Policy returns the RetryPolicy of this configuration
+func (c RetryConfiguration) Policy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    c.RetryMaxAttempts,
+		InitialBackoff: c.RetryBackoff,
+		MaxBackoff:     c.RetryMaxBackoff,
+		Multiplier:     2,
+		Jitter:         c.RetryJitter,
+	}
+}
+
+// RetryPolicy defines how often and when unhandled data is retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts to handle data, 0 allows unlimited attempts
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Multiplier is applied to the backoff after every attempt, values below 1 default to 2
+	Multiplier float64
+	// Jitter is the fraction of the backoff which is randomly subtracted, between 0 and 1
+	Jitter float64
+}
+
+// Exhausted returns if data which failed the given number of attempts must not be retried again
+func (p RetryPolicy) Exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// Backoff returns the delay before retrying data which failed the given number of attempts
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	if p.InitialBackoff <= 0 || attempts <= 0 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempts-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if backoff >= math.MaxInt64 {
+		// the float can't represent math.MaxInt64 exactly, so the conversion would overflow
+		backoff = math.MaxInt64 / 2
+	}
+
+	if p.Jitter > 0 {
+		backoff -= backoff * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// retrier counts the failed attempts of unhandled data, delays their retries
+// and moves data which failed all attempts into the dead letter queue
+//
+// The attempts are kept in memory and start from zero again after a restart.
+type retrier struct {
+	name        string
+	policy      RetryPolicy
+	ctx         context.Context
+	deadLetters *DeadLetterQueue
+	// schedule pushes data back after a delay, without it the delayed retries are kept in memory
+	schedule func(data Data, delay time.Duration) error
+
+	lock     sync.Mutex
+	attempts map[string]int
+
+	// delayed holds the retries which wait for their timers, flushed is set once they have been pushed back
+	delayed   map[uint64]*delayedRetry
+	delayedID uint64
+	flushed   bool
+	pushing   sync.WaitGroup
+}
+
+type delayedRetry struct {
+	timer    *time.Timer
+	data     Data
+	pushBack func(Data) error
+}
+
+// newRetrier creates a retrier
+func newRetrier(ctx context.Context, name string, policy RetryPolicy) *retrier {
+	return &retrier{
+		name:     name,
+		policy:   policy,
+		ctx:      ctx,
+		attempts: make(map[string]int),
+		delayed:  make(map[uint64]*delayedRetry),
+	}
+}
+
+// handle calls the handler and retries the unhandled data with pushBack. Data which can't be pushed back is returned.
+func (r *retrier) handle(handle HandlerFunc, pushBack func(Data) error, data ...Data) (failed []Data) {
+	unhandled := handle(data...)
+
+	keys := make(map[string]struct{}, len(unhandled))
+	retries := make([]Data, 0, len(unhandled))
+	delays := make([]time.Duration, 0, len(unhandled))
+	var exhausted []Data
+	var exhaustedAttempts []int
+
+	r.lock.Lock()
+	for _, datum := range unhandled {
+		bs, err := json.Marshal(datum)
+		if err != nil {
+			// data which can't be keyed is retried without counting
+			retries = append(retries, datum)
+			delays = append(delays, 0)
+			continue
+		}
+		key := string(bs)
+		keys[key] = struct{}{}
+
+		attempts := r.attempts[key] + 1
+		if r.policy.Exhausted(attempts) {
+			delete(r.attempts, key)
+			exhausted = append(exhausted, datum)
+			exhaustedAttempts = append(exhaustedAttempts, attempts)
+			continue
+		}
+		r.attempts[key] = attempts
+		retries = append(retries, datum)
+		delays = append(delays, r.policy.Backoff(attempts))
+	}
+	if len(r.attempts) > 0 {
+		// forget the attempts of data which has been handled now
+		for _, datum := range data {
+			bs, err := json.Marshal(datum)
+			if err != nil {
+				continue
+			}
+			if _, ok := keys[string(bs)]; !ok {
+				delete(r.attempts, string(bs))
+			}
+		}
+	}
+	r.lock.Unlock()
+
+	for i, datum := range exhausted {
+		r.deadLetter(datum, exhaustedAttempts[i])
+	}
+
+	for i, datum := range retries {
+		if delays[i] > 0 {
+			if r.schedule == nil {
+				if r.delay(datum, delays[i], pushBack) {
+					continue
+				}
+			} else if err := r.schedule(datum, delays[i]); err == nil {
+				continue
+			} else {
+				log.Error("Unable to schedule the retry of data in queue %s, retrying it now: %v", r.name, err)
+			}
+		}
+		if err := pushBack(datum); err != nil {
+			failed = append(failed, datum)
+		}
+	}
+	return failed
+}
+
+// delay pushes data back once the delay has passed. It returns false if the retrier
+// has been flushed and the data must be pushed back immediately.
+func (r *retrier) delay(datum Data, delay time.Duration, pushBack func(Data) error) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.flushed {
+		return false
+	}
+	r.delayedID++
+	id := r.delayedID
+	r.delayed[id] = &delayedRetry{
+		timer: time.AfterFunc(delay, func() {
+			r.lock.Lock()
+			retry, ok := r.delayed[id]
+			if !ok {
+				// flush has taken it
+				r.lock.Unlock()
+				return
+			}
+			delete(r.delayed, id)
+			r.pushing.Add(1)
+			r.lock.Unlock()
+
+			defer r.pushing.Done()
+			if err := retry.pushBack(retry.data); err != nil {
+				log.Error("Unable to retry data in queue %s: %v", r.name, err)
+			}
+		}),
+		data:     datum,
+		pushBack: pushBack,
+	}
+	return true
+}
+
+// flush pushes the delayed retries back without waiting for their delay and returns
+// once all retries, including those of a concurrent flush, have been pushed back.
+// Later retries are pushed back immediately. It must be called before the fifo of the queue is closed.
+func (r *retrier) flush() {
+	r.lock.Lock()
+	r.flushed = true
+	delayed := r.delayed
+	r.delayed = make(map[uint64]*delayedRetry)
+	r.pushing.Add(len(delayed))
+	r.lock.Unlock()
+
+	for _, retry := range delayed {
+		retry.timer.Stop()
+		if err := retry.pushBack(retry.data); err != nil {
+			log.Error("Unable to retry data in queue %s: %v", r.name, err)
+		}
+		r.pushing.Done()
+	}
+	r.pushing.Wait()
+}
+
+func (r *retrier) deadLetter(datum Data, attempts int) {
+	if r.deadLetters == nil {
+		log.Error("Queue: %s dropping data which failed %d attempts: %v", r.name, attempts, datum)
+		return
+	}
+	log.Warn("Queue: %s moving data which failed %d attempts to the dead letter queue", r.name, attempts)
+	if err := r.deadLetters.Push(r.ctx, datum, attempts); err != nil {
+		log.Error("Queue: %s unable to push data to the dead letter queue: %v Error: %v", r.name, datum, err)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+
+	assert.False(t, p.Exhausted(1))
+	assert.False(t, p.Exhausted(2))
+	assert.True(t, p.Exhausted(3))
+	assert.False(t, RetryPolicy{}.Exhausted(100))
+
+	assert.Equal(t, time.Duration(0), p.Backoff(0))
+	assert.Equal(t, time.Second, p.Backoff(1))
+	assert.Equal(t, 2*time.Second, p.Backoff(2))
+	assert.Equal(t, 4*time.Second, p.Backoff(3))
+	assert.Equal(t, 5*time.Second, p.Backoff(4))
+	assert.Equal(t, 5*time.Second, p.Backoff(1000))
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.Backoff(1))
+
+	p.MaxBackoff = 0
+	assert.Positive(t, p.Backoff(1000))
+
+	p = RetryPolicy{InitialBackoff: time.Second, Multiplier: 3, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		backoff := p.Backoff(2)
+		assert.GreaterOrEqual(t, backoff, 1500*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 3*time.Second)
+	}
+}
+
+func TestRetryConfiguration(t *testing.T) {
+	assert.False(t, RetryConfiguration{}.IsEnabled())
+	assert.False(t, RetryConfiguration{DeadLetterQueueName: "dead"}.HasDeadLetterQueue())
+	assert.True(t, RetryConfiguration{RetryBackoff: time.Second}.IsEnabled())
+	assert.False(t, RetryConfiguration{RetryBackoff: time.Second, DeadLetterQueueName: "dead"}.HasDeadLetterQueue())
+
+	c := RetryConfiguration{RetryMaxAttempts: 2, DeadLetterQueueName: "dead"}
+	assert.True(t, c.IsEnabled())
+	assert.True(t, c.HasDeadLetterQueue())
+	assert.Equal(t, 2, c.Policy().MaxAttempts)
+}
+
+func TestRetrier(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "retrier-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	fifo, err := NewLevelListByteFIFO(tmpDir, "dead")
+	assert.NoError(t, err)
+	deadLetters := NewDeadLetterQueue("test", fifo)
+	defer deadLetters.Close()
+
+	r := newRetrier(context.Background(), "test", RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+	r.deadLetters = deadLetters
+
+	failing := &testData{"A", 1}
+	handled := &testData{"B", 2}
+	handle := func(data ...Data) []Data {
+		var unhandled []Data
+		for _, datum := range data {
+			if datum.(*testData).TestString == failing.TestString {
+				unhandled = append(unhandled, datum)
+			}
+		}
+		return unhandled
+	}
+
+	var pushedBack, scheduled []Data
+	var delays []time.Duration
+	pushBack := func(datum Data) error {
+		pushedBack = append(pushedBack, datum)
+		return nil
+	}
+
+	// without a schedule the retries wait in memory until they are flushed
+	assert.Empty(t, r.handle(handle, pushBack, failing, handled))
+	assert.Empty(t, pushedBack)
+	assert.Len(t, r.delayed, 1)
+	r.flush()
+	assert.Equal(t, []Data{failing}, pushedBack)
+	assert.Empty(t, r.delayed)
+	assert.Len(t, r.attempts, 1)
+
+	// the retries are delayed by the schedule
+	r.schedule = func(datum Data, delay time.Duration) error {
+		scheduled = append(scheduled, datum)
+		delays = append(delays, delay)
+		return nil
+	}
+	assert.Empty(t, r.handle(handle, pushBack, failing))
+	assert.Equal(t, []Data{failing}, pushedBack)
+	assert.Equal(t, []Data{failing}, scheduled)
+	assert.Equal(t, []time.Duration{2 * time.Hour}, delays)
+
+	// the third attempt moves the data into the dead letter queue
+	assert.Empty(t, r.handle(handle, pushBack, failing))
+	assert.Empty(t, r.attempts)
+	assert.EqualValues(t, 1, deadLetters.Len(context.Background()))
+
+	letters, err := deadLetters.List(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "test", letters[0].Queue)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.JSONEq(t, `{"TestString":"A","TestInt":1}`, string(letters[0].Data))
+
+	// handled data forgets its attempts, retries which can't be scheduled are pushed back immediately
+	r.schedule = func(Data, time.Duration) error {
+		return errors.New("schedule failed")
+	}
+	assert.Empty(t, r.handle(handle, pushBack, failing))
+	assert.Equal(t, []Data{failing, failing}, pushedBack)
+	assert.Len(t, r.attempts, 1)
+	assert.Empty(t, r.handle(func(...Data) []Data { return nil }, pushBack, failing, handled))
+	assert.Empty(t, r.attempts)
+}
+
+func TestRetrierDelay(t *testing.T) {
+	r := newRetrier(context.Background(), "test", RetryPolicy{InitialBackoff: 200 * time.Millisecond})
+
+	var lock sync.Mutex
+	var pushedBack []time.Time
+	pushBack := func(datum Data) error {
+		lock.Lock()
+		defer lock.Unlock()
+		pushedBack = append(pushedBack, time.Now())
+		return nil
+	}
+	failing := func(data ...Data) []Data {
+		return data
+	}
+
+	start := time.Now()
+	assert.Empty(t, r.handle(failing, pushBack, &testData{"A", 1}))
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(pushedBack) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, pushedBack[0].Sub(start), 200*time.Millisecond)
+
+	// once flushed the retries are pushed back immediately
+	r.flush()
+	assert.Empty(t, r.handle(failing, pushBack, &testData{"A", 1}))
+	lock.Lock()
+	assert.Len(t, pushedBack, 2)
+	lock.Unlock()
+}
+
+func TestUniqueQueueRetryBackoff(t *testing.T) {
+	const backoff = 200 * time.Millisecond
+	retry := RetryConfiguration{RetryBackoff: backoff}
+
+	test := func(t *testing.T, create func(handle HandlerFunc, tmpDir string) (Queue, error)) {
+		var lock sync.Mutex
+		var attempts []time.Time
+		handled := make(chan struct{})
+		handle := func(data ...Data) []Data {
+			lock.Lock()
+			defer lock.Unlock()
+			attempts = append(attempts, time.Now())
+			if len(attempts) < 3 {
+				return data
+			}
+			close(handled)
+			return nil
+		}
+
+		tmpDir, err := os.MkdirTemp("", "unique-queue-retry-test-data")
+		assert.NoError(t, err)
+		defer util.RemoveAll(tmpDir)
+
+		q, err := create(handle, tmpDir)
+		assert.NoError(t, err)
+
+		var shutdown, terminate []func()
+		go q.Run(func(f func()) {
+			lock.Lock()
+			shutdown = append(shutdown, f)
+			lock.Unlock()
+		}, func(f func()) {
+			lock.Lock()
+			terminate = append(terminate, f)
+			lock.Unlock()
+		})
+
+		assert.NoError(t, q.Push(&testData{"A", 1}))
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "the retried data was not handled")
+		}
+
+		lock.Lock()
+		if assert.Len(t, attempts, 3) {
+			assert.GreaterOrEqual(t, attempts[1].Sub(attempts[0]), backoff)
+			assert.GreaterOrEqual(t, attempts[2].Sub(attempts[1]), 2*backoff)
+		}
+		callbacks := append(shutdown, terminate...)
+		lock.Unlock()
+		for _, f := range callbacks {
+			f()
+		}
+	}
+
+	t.Run("Level", func(t *testing.T) {
+		test(t, func(handle HandlerFunc, tmpDir string) (Queue, error) {
+			return NewLevelUniqueQueue(handle, LevelUniqueQueueConfiguration{
+				ByteFIFOQueueConfiguration: ByteFIFOQueueConfiguration{
+					WorkerPoolConfiguration: WorkerPoolConfiguration{
+						QueueLength:  20,
+						BatchLength:  1,
+						BlockTimeout: 1 * time.Second,
+						BoostTimeout: 5 * time.Minute,
+						BoostWorkers: 5,
+						MaxWorkers:   10,
+					},
+					Workers:            1,
+					RetryConfiguration: retry,
+				},
+				DataDir:   tmpDir,
+				QueueName: "unique",
+			}, &testData{})
+		})
+	})
+
+	t.Run("PersistableChannel", func(t *testing.T) {
+		test(t, func(handle HandlerFunc, tmpDir string) (Queue, error) {
+			return NewPersistableChannelUniqueQueue(handle, PersistableChannelUniqueQueueConfiguration{
+				Name:               "unique-retry",
+				DataDir:            tmpDir,
+				BatchLength:        1,
+				QueueLength:        20,
+				Workers:            1,
+				MaxWorkers:         10,
+				RetryConfiguration: retry,
+			}, &testData{})
+		})
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/gitbundle/modules/nosql"
+	"github.com/gitbundle/modules/util"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var _ ScheduledByteFIFO = &LevelScheduledByteFIFO{}
+
+// LevelScheduledByteFIFO represents a ScheduledByteFIFO formed from LevelDB keys
+// which start with the due time, so iterating the keys returns the data in due order
+type LevelScheduledByteFIFO struct {
+	lock       sync.Mutex
+	db         *leveldb.DB
+	prefix     []byte
+	connection string
+}
+
+// NewLevelScheduledByteFIFO creates a ScheduledByteFIFO which stores its data below the name in the LevelDB
+func NewLevelScheduledByteFIFO(connection, name string) (*LevelScheduledByteFIFO, error) {
+	db, err := nosql.GetManager().GetLevelDB(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LevelScheduledByteFIFO{
+		db:         db,
+		prefix:     []byte("scheduled:" + name + ":"),
+		connection: connection,
+	}, nil
+}
+
+// key returns the prefix, the big endian due time and a random suffix which keeps equal due times apart
+func (fifo *LevelScheduledByteFIFO) key(at time.Time) ([]byte, error) {
+	suffix, err := util.CryptoRandomBytes(8)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 0, len(fifo.prefix)+8+len(suffix))
+	key = append(key, fifo.prefix...)
+	key = append(key, timeBytes(at)...)
+	return append(key, suffix...), nil
+}
+
+func timeBytes(at time.Time) []byte {
+	nanos := at.UnixNano()
+	if nanos < 0 {
+		nanos = 0
+	}
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, uint64(nanos))
+	return bs
+}
+
+// Schedule adds data which becomes due at the provided time
+func (fifo *LevelScheduledByteFIFO) Schedule(ctx context.Context, data []byte, at time.Time) error {
+	key, err := fifo.key(at)
+	if err != nil {
+		return err
+	}
+	return fifo.db.Put(key, data, nil)
+}
+
+// PopDue passes up to limit data which is due at now to fn, earliest first
+func (fifo *LevelScheduledByteFIFO) PopDue(ctx context.Context, now time.Time, limit int, fn func([]byte) error) (int, error) {
+	fifo.lock.Lock()
+	defer fifo.lock.Unlock()
+
+	limitKey := append(append([]byte{}, fifo.prefix...), timeBytes(now.Add(time.Nanosecond))...)
+	iter := fifo.db.NewIterator(&leveldb_util.Range{Start: fifo.prefix, Limit: limitKey}, nil)
+	defer iter.Release()
+
+	n := 0
+	for n < limit && iter.Next() {
+		key := append([]byte{}, iter.Key()...)
+		// push before deleting, a crash in between duplicates the data instead of losing it
+		if err := fn(append([]byte{}, iter.Value()...)); err != nil {
+			return n, err
+		}
+		if err := fifo.db.Delete(key, nil); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, iter.Error()
+}
+
+// Next returns the time the earliest data becomes due
+func (fifo *LevelScheduledByteFIFO) Next(ctx context.Context) (time.Time, bool, error) {
+	iter := fifo.db.NewIterator(leveldb_util.BytesPrefix(fifo.prefix), nil)
+	defer iter.Release()
+
+	if !iter.Next() {
+		return time.Time{}, false, iter.Error()
+	}
+	key := iter.Key()
+	if len(key) < len(fifo.prefix)+8 {
+		return time.Time{}, false, nil
+	}
+	nanos := binary.BigEndian.Uint64(key[len(fifo.prefix) : len(fifo.prefix)+8])
+	return time.Unix(0, int64(nanos)), true, nil
+}
+
+// Len returns the number of scheduled data
+func (fifo *LevelScheduledByteFIFO) Len(ctx context.Context) int64 {
+	iter := fifo.db.NewIterator(leveldb_util.BytesPrefix(fifo.prefix), nil)
+	defer iter.Release()
+
+	var n int64
+	for iter.Next() {
+		n++
+	}
+	return n
+}
+
+// Close this fifo
+func (fifo *LevelScheduledByteFIFO) Close() error {
+	return nosql.GetManager().CloseLevelDB(fifo.connection)
+}
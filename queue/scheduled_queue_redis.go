@@ -0,0 +1,125 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/graceful"
+	"github.com/gitbundle/modules/log"
+	"github.com/gitbundle/modules/nosql"
+	"github.com/gitbundle/modules/util"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ ScheduledByteFIFO = &RedisScheduledByteFIFO{}
+
+// RedisScheduledByteFIFO represents a ScheduledByteFIFO formed from a redis sorted set scored by the due time
+//
+// The members are prefixed with a random id so equal data can be scheduled more than once.
+// Several processes can share the set: a member is claimed by removing it, so only the process whose
+// ZREM removed it passes it on.
+type RedisScheduledByteFIFO struct {
+	client  redisClient
+	setName string
+}
+
+// NewRedisScheduledByteFIFO creates a ScheduledByteFIFO formed from a redisClient
+func NewRedisScheduledByteFIFO(connection, setName string) (*RedisScheduledByteFIFO, error) {
+	fifo := &RedisScheduledByteFIFO{
+		setName: setName,
+	}
+	fifo.client = nosql.GetManager().GetRedisClient(connection)
+	if err := fifo.client.Ping(graceful.GetManager().ShutdownContext()).Err(); err != nil {
+		return nil, err
+	}
+	return fifo, nil
+}
+
+const scheduledMemberSeparator = ":"
+
+func scoreOf(at time.Time) float64 {
+	return float64(at.UnixNano() / int64(time.Millisecond))
+}
+
+// Schedule adds data which becomes due at the provided time
+func (fifo *RedisScheduledByteFIFO) Schedule(ctx context.Context, data []byte, at time.Time) error {
+	id, err := util.CryptoRandomString(16)
+	if err != nil {
+		return err
+	}
+	return fifo.client.ZAdd(ctx, fifo.setName, redis.Z{
+		Score:  scoreOf(at),
+		Member: id + scheduledMemberSeparator + string(data),
+	}).Err()
+}
+
+// PopDue passes up to limit data which is due at now to fn, earliest first.
+// Each member is claimed by removing it from the set before fn is called, members
+// which another process claimed first are skipped. If fn fails the member is added
+// back with its score, so a crash in between loses the data rather than pushing it twice.
+func (fifo *RedisScheduledByteFIFO) PopDue(ctx context.Context, now time.Time, limit int, fn func([]byte) error) (int, error) {
+	due, err := fifo.client.ZRangeByScoreWithScores(ctx, fifo.setName, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatFloat(scoreOf(now), 'f', -1, 64),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	popped := 0
+	for _, z := range due {
+		member, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		removed, err := fifo.client.ZRem(ctx, fifo.setName, member).Result()
+		if err != nil {
+			return popped, err
+		}
+		if removed == 0 {
+			continue
+		}
+		_, data, _ := strings.Cut(member, scheduledMemberSeparator)
+		if err := fn([]byte(data)); err != nil {
+			if err := fifo.client.ZAdd(ctx, fifo.setName, z).Err(); err != nil {
+				log.Error("Unable to reschedule data in redis scheduled set %s: Error: %v", fifo.setName, err)
+			}
+			return popped, err
+		}
+		popped++
+	}
+	return popped, nil
+}
+
+// Next returns the time the earliest data becomes due
+func (fifo *RedisScheduledByteFIFO) Next(ctx context.Context) (time.Time, bool, error) {
+	first, err := fifo.client.ZRangeWithScores(ctx, fifo.setName, 0, 0).Result()
+	if err != nil || len(first) == 0 {
+		return time.Time{}, false, err
+	}
+	return time.UnixMilli(int64(first[0].Score)), true, nil
+}
+
+// Len returns the number of scheduled data
+func (fifo *RedisScheduledByteFIFO) Len(ctx context.Context) int64 {
+	val, err := fifo.client.ZCard(ctx, fifo.setName).Result()
+	if err != nil {
+		log.Error("Error whilst getting length of redis scheduled set %s: Error: %v", fifo.setName, err)
+		return -1
+	}
+	return val
+}
+
+// Close this fifo
+func (fifo *RedisScheduledByteFIFO) Close() error {
+	return fifo.client.Close()
+}
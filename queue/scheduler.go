@@ -0,0 +1,141 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"time"
+
+	"github.com/gitbundle/modules/log"
+	"github.com/gitbundle/modules/util"
+)
+
+// ErrSchedulingNotSupported is returned if data is scheduled on a queue without scheduled storage
+var ErrSchedulingNotSupported = fmt.Errorf("queue does not support scheduling")
+
+// Schedulable represents a queue that can delay data
+type Schedulable interface {
+	// PushAt pushes data which becomes available at the provided time
+	PushAt(data Data, at time.Time) error
+	// PushAfter pushes data which becomes available after the provided delay
+	PushAfter(data Data, delay time.Duration) error
+}
+
+// ScheduledByteFIFO stores data ordered by the time it becomes due
+type ScheduledByteFIFO interface {
+	// Schedule adds data which becomes due at the provided time
+	Schedule(ctx context.Context, data []byte, at time.Time) error
+	// PopDue passes up to limit data which is due at now to fn, earliest first.
+	// Data is only removed if fn succeeds. It returns the number of removed data.
+	PopDue(ctx context.Context, now time.Time, limit int, fn func([]byte) error) (int, error)
+	// Next returns the time the earliest data becomes due, ok is false if no data is scheduled
+	Next(ctx context.Context) (at time.Time, ok bool, err error)
+	// Len returns the number of scheduled data
+	Len(ctx context.Context) int64
+	// Close this fifo
+	Close() error
+}
+
+const (
+	// maxSchedulerWait is the longest time the scheduler sleeps, other processes sharing the fifo may schedule earlier data
+	maxSchedulerWait = time.Second
+	schedulerBatch   = 100
+)
+
+// scheduler moves scheduled data into its queue once it is due
+//
+// The scheduled data is kept in the fifo until it has been pushed, so it survives restarts
+// and is moved by the next scheduler which is run on the fifo.
+type scheduler struct {
+	name      string
+	fifo      ScheduledByteFIFO
+	push      func([]byte) error
+	scheduled chan struct{}
+}
+
+func newScheduler(name string, fifo ScheduledByteFIFO, push func([]byte) error) *scheduler {
+	return &scheduler{
+		name:      name,
+		fifo:      fifo,
+		push:      push,
+		scheduled: make(chan struct{}, 1),
+	}
+}
+
+// Schedule adds data which becomes due at the provided time
+func (s *scheduler) Schedule(ctx context.Context, data []byte, at time.Time) error {
+	if err := s.fifo.Schedule(ctx, data, at); err != nil {
+		return err
+	}
+	select {
+	case s.scheduled <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Len returns the number of scheduled data
+func (s *scheduler) Len(ctx context.Context) int64 {
+	return s.fifo.Len(ctx)
+}
+
+// Run moves due data until the context is done
+func (s *scheduler) Run(ctx context.Context) {
+	pprof.SetGoroutineLabels(ctx)
+	log.Trace("Scheduler for %s started", s.name)
+
+	timer := time.NewTimer(0)
+	util.StopTimer(timer)
+
+	for {
+		wait := maxSchedulerWait
+		if err := s.moveDue(ctx); err != nil {
+			log.Error("Unable to move scheduled data into queue %s: %v", s.name, err)
+		} else if at, ok, err := s.fifo.Next(ctx); err != nil {
+			log.Error("Unable to get the next scheduled data of queue %s: %v", s.name, err)
+		} else if ok {
+			if until := time.Until(at); until < wait {
+				wait = until
+			}
+		}
+
+		if wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				util.StopTimer(timer)
+				log.Trace("Scheduler for %s stopped", s.name)
+				return
+			case <-s.scheduled:
+				util.StopTimer(timer)
+			case <-timer.C:
+			}
+		} else {
+			select {
+			case <-ctx.Done():
+				log.Trace("Scheduler for %s stopped", s.name)
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (s *scheduler) moveDue(ctx context.Context) error {
+	for {
+		n, err := s.fifo.PopDue(ctx, time.Now(), schedulerBatch, s.push)
+		if err != nil || n < schedulerBatch {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/util"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelScheduledByteFIFO(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "level-scheduled-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	fifo, err := NewLevelScheduledByteFIFO(tmpDir, "test")
+	assert.NoError(t, err)
+	defer fifo.Close()
+
+	other, err := NewLevelScheduledByteFIFO(tmpDir, "other")
+	assert.NoError(t, err)
+	defer other.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	_, ok, err := fifo.Next(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, fifo.Schedule(ctx, []byte("c"), now.Add(time.Hour)))
+	assert.NoError(t, fifo.Schedule(ctx, []byte("b"), now.Add(-time.Minute)))
+	assert.NoError(t, fifo.Schedule(ctx, []byte("a"), now.Add(-time.Hour)))
+	assert.NoError(t, fifo.Schedule(ctx, []byte("a"), now.Add(-time.Hour)))
+	assert.NoError(t, other.Schedule(ctx, []byte("x"), now.Add(-time.Hour)))
+	assert.EqualValues(t, 4, fifo.Len(ctx))
+	assert.EqualValues(t, 1, other.Len(ctx))
+
+	next, ok, err := fifo.Next(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(-time.Hour).UnixNano(), next.UnixNano())
+
+	// data is kept if fn fails
+	errPush := errors.New("push failed")
+	n, err := fifo.PopDue(ctx, now, 10, func([]byte) error {
+		return errPush
+	})
+	assert.ErrorIs(t, err, errPush)
+	assert.Equal(t, 0, n)
+	assert.EqualValues(t, 4, fifo.Len(ctx))
+
+	var popped []string
+	n, err = fifo.PopDue(ctx, now, 2, func(data []byte) error {
+		popped = append(popped, string(data))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"a", "a"}, popped)
+
+	n, err = fifo.PopDue(ctx, now, 10, func(data []byte) error {
+		popped = append(popped, string(data))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"a", "a", "b"}, popped)
+	assert.EqualValues(t, 1, fifo.Len(ctx))
+	assert.EqualValues(t, 1, other.Len(ctx))
+
+	next, ok, err = fifo.Next(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(time.Hour).UnixNano(), next.UnixNano())
+}
+
+func TestRedisScheduledByteFIFOShared(t *testing.T) {
+	server := miniredis.RunT(t)
+	connection := "redis://" + server.Addr() + "/0"
+
+	first, err := NewRedisScheduledByteFIFO(connection, "scheduled")
+	assert.NoError(t, err)
+	defer first.Close()
+	second, err := NewRedisScheduledByteFIFO(connection, "scheduled")
+	assert.NoError(t, err)
+	defer second.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, first.Schedule(ctx, []byte(strconv.Itoa(i)), now.Add(-time.Minute)))
+	}
+	assert.EqualValues(t, 50, second.Len(ctx))
+
+	// data is kept if fn fails
+	errPush := errors.New("push failed")
+	n, err := second.PopDue(ctx, now, 10, func([]byte) error {
+		return errPush
+	})
+	assert.ErrorIs(t, err, errPush)
+	assert.Equal(t, 0, n)
+	assert.EqualValues(t, 50, first.Len(ctx))
+
+	var lock sync.Mutex
+	delivered := map[string]int{}
+	deliver := func(data []byte) error {
+		lock.Lock()
+		delivered[string(data)]++
+		lock.Unlock()
+		return nil
+	}
+
+	// the second fifo pops while the first one is handing over its first data, it
+	// must not get that data and the first fifo skips the data the second one claimed
+	secondPopped := 0
+	n, err = first.PopDue(ctx, now, 5, func(data []byte) error {
+		if secondPopped == 0 {
+			secondPopped, err = second.PopDue(ctx, now, 5, deliver)
+			if err != nil {
+				return err
+			}
+		}
+		return deliver(data)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 5, secondPopped)
+	assert.EqualValues(t, 44, first.Len(ctx))
+
+	var wg sync.WaitGroup
+	for _, fifo := range []*RedisScheduledByteFIFO{first, second} {
+		wg.Add(1)
+		go func(fifo *RedisScheduledByteFIFO) {
+			defer wg.Done()
+			for fifo.Len(ctx) > 0 {
+				if _, err := fifo.PopDue(ctx, now, 3, deliver); err != nil {
+					assert.NoError(t, err)
+					return
+				}
+			}
+		}(fifo)
+	}
+	wg.Wait()
+
+	assert.Len(t, delivered, 50)
+	for data, count := range delivered {
+		assert.Equal(t, 1, count, "%s delivered more than once", data)
+	}
+}
+
+func TestLevelQueuePushAfter(t *testing.T) {
+	handleChan := make(chan *testData, 10)
+	handle := func(data ...Data) []Data {
+		for _, datum := range data {
+			handleChan <- datum.(*testData)
+		}
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "level-queue-scheduled-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	q, err := NewLevelQueue(handle, LevelQueueConfiguration{
+		ByteFIFOQueueConfiguration: ByteFIFOQueueConfiguration{
+			WorkerPoolConfiguration: WorkerPoolConfiguration{
+				QueueLength:  20,
+				BatchLength:  1,
+				BlockTimeout: 1 * time.Second,
+				BoostTimeout: 5 * time.Minute,
+				BoostWorkers: 5,
+				MaxWorkers:   10,
+			},
+			Workers: 1,
+		},
+		DataDir: tmpDir,
+	}, &testData{})
+	assert.NoError(t, err)
+	lq := q.(*LevelQueue)
+
+	var lock sync.Mutex
+	var shutdown, terminate []func()
+	go q.Run(func(f func()) {
+		lock.Lock()
+		shutdown = append(shutdown, f)
+		lock.Unlock()
+	}, func(f func()) {
+		lock.Lock()
+		terminate = append(terminate, f)
+		lock.Unlock()
+	})
+
+	start := time.Now()
+	assert.NoError(t, lq.PushAfter(&testData{"B", 2}, 200*time.Millisecond))
+	assert.NoError(t, lq.PushAt(&testData{"A", 1}, start.Add(-time.Second)))
+	assert.Error(t, lq.PushAfter(testData{"C", 3}, time.Second))
+	assert.EqualValues(t, 1, lq.NumberOfScheduled())
+
+	result := <-handleChan
+	assert.Equal(t, "A", result.TestString)
+
+	result = <-handleChan
+	assert.Equal(t, "B", result.TestString)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.EqualValues(t, 0, lq.NumberOfScheduled())
+
+	lock.Lock()
+	for _, f := range shutdown {
+		f()
+	}
+	for _, f := range terminate {
+		f()
+	}
+	lock.Unlock()
+	GetManager().Remove(lq.qid)
+}
+
+func TestPersistableChannelQueuePushAfterRestart(t *testing.T) {
+	handleChan := make(chan *testData, 10)
+	handle := func(data ...Data) []Data {
+		for _, datum := range data {
+			handleChan <- datum.(*testData)
+		}
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "persistable-channel-queue-scheduled-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	config := PersistableChannelQueueConfiguration{
+		DataDir:     tmpDir,
+		BatchLength: 1,
+		QueueLength: 20,
+		Workers:     1,
+		MaxWorkers:  10,
+		Name:        "scheduled",
+	}
+
+	run := func(q Queue) (stop func()) {
+		var lock sync.Mutex
+		var shutdown, terminate []func()
+		go q.Run(func(f func()) {
+			lock.Lock()
+			shutdown = append(shutdown, f)
+			lock.Unlock()
+		}, func(f func()) {
+			lock.Lock()
+			terminate = append(terminate, f)
+			lock.Unlock()
+		})
+		return func() {
+			assert.Eventually(t, func() bool {
+				lock.Lock()
+				defer lock.Unlock()
+				return len(shutdown) > 0 && len(terminate) > 0
+			}, 5*time.Second, 10*time.Millisecond)
+			lock.Lock()
+			defer lock.Unlock()
+			for _, f := range shutdown {
+				f()
+			}
+			for _, f := range terminate {
+				f()
+			}
+		}
+	}
+
+	q, err := NewPersistableChannelQueue(handle, config, &testData{})
+	assert.NoError(t, err)
+	stop := run(q)
+
+	assert.NoError(t, q.(Schedulable).PushAfter(&testData{"A", 1}, 500*time.Millisecond))
+	assert.EqualValues(t, 1, q.(*PersistableChannelQueue).NumberOfScheduled())
+	stop()
+
+	select {
+	case <-handleChan:
+		assert.Fail(t, "scheduled data must not be handled before it is due")
+	default:
+	}
+
+	q, err = NewPersistableChannelQueue(handle, config, &testData{})
+	assert.NoError(t, err)
+	stop = run(q)
+	defer stop()
+
+	select {
+	case result := <-handleChan:
+		assert.Equal(t, "A", result.TestString)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "scheduled data was not handled after the restart")
+	}
+}
+
+func TestPersistableChannelQueueScheduledInvalidData(t *testing.T) {
+	handle := func(data ...Data) []Data {
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "persistable-channel-queue-scheduled-invalid-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	q, err := NewPersistableChannelQueue(handle, PersistableChannelQueueConfiguration{
+		DataDir:     tmpDir,
+		BatchLength: 1,
+		QueueLength: 20,
+		Workers:     1,
+		MaxWorkers:  10,
+		Name:        "scheduled-invalid",
+		RetryConfiguration: RetryConfiguration{
+			RetryMaxAttempts:    3,
+			DeadLetterQueueName: "dead",
+		},
+	}, &testData{})
+	assert.NoError(t, err)
+	pq := q.(*PersistableChannelQueue)
+	defer pq.closeStores()
+
+	ctx := context.Background()
+	assert.NoError(t, pq.scheduler.Schedule(ctx, []byte("not json"), time.Now().Add(-time.Second)))
+	assert.NoError(t, pq.scheduler.moveDue(ctx))
+	assert.EqualValues(t, 0, pq.NumberOfScheduled())
+
+	letters, err := pq.DeadLetters(0)
+	assert.NoError(t, err)
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, []byte("not json"), letters[0].Data)
+		assert.Equal(t, "scheduled-invalid", letters[0].Queue)
+	}
+}
+
+func TestPersistableChannelQueueScheduledOpenError(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "persistable-channel-queue-scheduled-open-test-data")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpDir)
+
+	// a file where the scheduled queue expects its directory
+	assert.NoError(t, os.WriteFile(tmpDir+"/data", nil, 0o644))
+
+	_, err = NewPersistableChannelQueue(func(data ...Data) []Data { return nil }, PersistableChannelQueueConfiguration{
+		DataDir:     tmpDir + "/data",
+		BatchLength: 1,
+		QueueLength: 20,
+		Workers:     1,
+		MaxWorkers:  10,
+		Name:        "scheduled-open",
+	}, &testData{})
+	assert.Error(t, err)
+}
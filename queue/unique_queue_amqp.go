@@ -0,0 +1,192 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gitbundle/modules/log"
+)
+
+// AMQPUniqueQueueType is the type for AMQP 0.9.1 unique queue
+const AMQPUniqueQueueType Type = "unique-amqp"
+
+// AMQPUniqueQueue AMQP 0.9.1 unique queue
+type AMQPUniqueQueue struct {
+	*ByteFIFOUniqueQueue
+}
+
+// AMQPUniqueQueueConfiguration is the configuration for the AMQP unique queue
+type AMQPUniqueQueueConfiguration struct {
+	ByteFIFOQueueConfiguration
+	AMQPUniqueByteFIFOConfiguration
+}
+
+// NewAMQPUniqueQueue creates a unique queue backed by a durable AMQP 0.9.1 queue.
+//
+// Please note that this Queue does not guarantee that a particular
+// task cannot be processed twice or more at the same time. Uniqueness is
+// only guaranteed whilst the task is waiting in the queue.
+func NewAMQPUniqueQueue(handle HandlerFunc, cfg, exemplar interface{}) (Queue, error) {
+	configInterface, err := toConfig(AMQPUniqueQueueConfiguration{}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	config := configInterface.(AMQPUniqueQueueConfiguration)
+
+	if len(config.SetName) == 0 {
+		config.SetName = config.QueueName + "_unique"
+	}
+
+	byteFIFO, err := NewAMQPUniqueByteFIFO(config.AMQPUniqueByteFIFOConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	byteFIFOQueue, err := NewByteFIFOUniqueQueue(AMQPUniqueQueueType, byteFIFO, handle, config.ByteFIFOQueueConfiguration, exemplar)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.HasDeadLetterQueue() {
+		deadLetters, err := NewAMQPByteFIFO(AMQPByteFIFOConfiguration{
+			ConnectionString: config.ConnectionString,
+			QueueName:        config.DeadLetterQueueName,
+		})
+		if err != nil {
+			_ = byteFIFO.Close()
+			return nil, err
+		}
+		byteFIFOQueue.setDeadLetterQueue(deadLetters)
+	}
+
+	queue := &AMQPUniqueQueue{
+		ByteFIFOUniqueQueue: byteFIFOQueue,
+	}
+
+	queue.qid = GetManager().Add(queue, AMQPUniqueQueueType, config, exemplar)
+
+	return queue, nil
+}
+
+var (
+	_ UniqueByteFIFO          = &AMQPUniqueByteFIFO{}
+	_ AcknowledgeableByteFIFO = &AMQPUniqueByteFIFO{}
+)
+
+// AMQPUniqueByteFIFO represents a UniqueByteFIFO formed from a durable AMQP queue
+//
+// AMQP can't tell whether a queue contains a message, so every waiting data has a marker:
+// a queue named after the set and the hash of the data which holds at most one message.
+// The broker nacks a second marker message, which makes setting the marker atomic.
+// The marker is deleted once the data is popped.
+type AMQPUniqueByteFIFO struct {
+	AMQPByteFIFO
+	setName string
+}
+
+// AMQPUniqueByteFIFOConfiguration is the configuration for the AMQPUniqueByteFIFO
+type AMQPUniqueByteFIFOConfiguration struct {
+	AMQPByteFIFOConfiguration
+	// SetName is the prefix of the marker queues, it defaults to the queue name
+	SetName string
+}
+
+// NewAMQPUniqueByteFIFO creates a UniqueByteFIFO formed from a durable AMQP queue
+func NewAMQPUniqueByteFIFO(config AMQPUniqueByteFIFOConfiguration) (*AMQPUniqueByteFIFO, error) {
+	byteFIFO, err := NewAMQPByteFIFO(config.AMQPByteFIFOConfiguration)
+	if err != nil {
+		return nil, err
+	}
+	setName := config.SetName
+	if len(setName) == 0 {
+		setName = config.QueueName
+	}
+	return &AMQPUniqueByteFIFO{
+		AMQPByteFIFO: *byteFIFO,
+		setName:      setName,
+	}, nil
+}
+
+// marker returns the name of the marker queue of the data
+func (fifo *AMQPUniqueByteFIFO) marker(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fifo.setName + "." + hex.EncodeToString(sum[:])
+}
+
+// PushFunc pushes data to the end of the fifo and calls the callback if it is added
+func (fifo *AMQPUniqueByteFIFO) PushFunc(ctx context.Context, data []byte, fn func() error) error {
+	marker := fifo.marker(data)
+	marked, err := fifo.client.Mark(ctx, marker)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrAlreadyInQueue
+	}
+	if fn != nil {
+		if err := fn(); err != nil {
+			fifo.unmark(marker)
+			return err
+		}
+	}
+	if err := fifo.client.Publish(ctx, fifo.queueName, data); err != nil {
+		fifo.unmark(marker)
+		return err
+	}
+	return nil
+}
+
+// PushBack pushes data back to the fifo, it is retried after the data which is already waiting
+func (fifo *AMQPUniqueByteFIFO) PushBack(ctx context.Context, data []byte) error {
+	return fifo.PushFunc(ctx, data, nil)
+}
+
+// Pop pops data from the start of the fifo and acknowledges it
+func (fifo *AMQPUniqueByteFIFO) Pop(ctx context.Context) ([]byte, error) {
+	data, acknowledger, err := fifo.PopUnacknowledged(ctx)
+	if err != nil || acknowledger == nil {
+		return nil, err
+	}
+	if err := acknowledger.Ack(ctx); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// PopUnacknowledged pops data from the start of the fifo and deletes its marker,
+// the broker requeues the data unless it is acknowledged
+func (fifo *AMQPUniqueByteFIFO) PopUnacknowledged(ctx context.Context) ([]byte, Acknowledger, error) {
+	data, acknowledger, err := fifo.AMQPByteFIFO.PopUnacknowledged(ctx)
+	if err != nil || acknowledger == nil {
+		return nil, nil, err
+	}
+	if err := fifo.client.Unmark(fifo.marker(data)); err != nil {
+		// the data would never be pushed again whilst the marker is set
+		if err := acknowledger.Nack(ctx); err != nil {
+			log.Error("Unable to requeue data in AMQP queue %s: Error: %v", fifo.queueName, err)
+		}
+		return nil, nil, err
+	}
+	return data, acknowledger, nil
+}
+
+// Has returns whether the fifo contains this data
+func (fifo *AMQPUniqueByteFIFO) Has(ctx context.Context, data []byte) (bool, error) {
+	return fifo.client.IsMarked(fifo.marker(data))
+}
+
+func (fifo *AMQPUniqueByteFIFO) unmark(marker string) {
+	if err := fifo.client.Unmark(marker); err != nil {
+		log.Error("Unable to delete marker %s of AMQP queue %s: Error: %v", marker, fifo.queueName, err)
+	}
+}
+
+func init() {
+	queuesMap[AMQPUniqueQueueType] = NewAMQPUniqueQueue
+}
@@ -30,6 +30,7 @@ type PersistableChannelUniqueQueueConfiguration struct {
 	BlockTimeout time.Duration
 	BoostTimeout time.Duration
 	BoostWorkers int
+	RetryConfiguration
 }
 
 // PersistableChannelUniqueQueue wraps a channel queue and level queue together
@@ -40,8 +41,10 @@ type PersistableChannelUniqueQueueConfiguration struct {
 type PersistableChannelUniqueQueue struct {
 	channelQueue *ChannelUniqueQueue
 	delayedStarter
-	lock   sync.Mutex
-	closed chan struct{}
+	lock        sync.Mutex
+	closed      chan struct{}
+	retrier     *retrier
+	deadLetters *DeadLetterQueue
 }
 
 // NewPersistableChannelUniqueQueue creates a wrapped batched channel queue with persistable level queue backend when shutting down
@@ -57,7 +60,23 @@ func NewPersistableChannelUniqueQueue(handle HandlerFunc, cfg, exemplar interfac
 		closed: make(chan struct{}),
 	}
 
+	if config.RetryConfiguration.IsEnabled() {
+		queue.retrier = newRetrier(context.Background(), config.Name, config.RetryConfiguration.Policy())
+	}
+	if config.RetryConfiguration.HasDeadLetterQueue() {
+		deadLetters, err := NewLevelListByteFIFO(config.DataDir, config.DeadLetterQueueName)
+		if err != nil {
+			log.Error("Unable to create dead letter queue for %s: %v", config.Name, err)
+		} else {
+			queue.deadLetters = NewDeadLetterQueue(config.Name, deadLetters)
+			queue.retrier.deadLetters = queue.deadLetters
+		}
+	}
+
 	wrappedHandle := func(data ...Data) (failed []Data) {
+		if queue.retrier != nil {
+			return queue.retrier.handle(handle, queue.PushBack, data...)
+		}
 		for _, unhandled := range handle(data...) {
 			if fail := queue.PushBack(unhandled); fail != nil {
 				failed = append(failed, fail)
@@ -284,6 +303,10 @@ func (q *PersistableChannelUniqueQueue) Shutdown() {
 	log.Trace("PersistableChannelUniqueQueue: %s Waiting til done", q.delayedStarter.name)
 	q.channelQueue.Wait()
 	q.internal.(*LevelUniqueQueue).Wait()
+	if q.retrier != nil {
+		// the closed queue pushes the delayed retries back into the level queue
+		q.retrier.flush()
+	}
 	// Redirect all remaining data in the chan to the internal channel
 	close(q.channelQueue.dataChan)
 	log.Trace("PersistableChannelUniqueQueue: %s Redirecting remaining data", q.delayedStarter.name)
@@ -295,6 +318,53 @@ func (q *PersistableChannelUniqueQueue) Shutdown() {
 	log.Debug("PersistableChannelUniqueQueue: %s Shutdown", q.delayedStarter.name)
 }
 
+// HasDeadLetterQueue returns if data which failed all attempts is kept in a dead letter queue
+func (q *PersistableChannelUniqueQueue) HasDeadLetterQueue() bool {
+	return q.deadLetters != nil
+}
+
+// NumberOfDeadLetters returns the number of dead letters
+func (q *PersistableChannelUniqueQueue) NumberOfDeadLetters() int64 {
+	if q.deadLetters == nil {
+		return 0
+	}
+	return q.deadLetters.Len(context.Background())
+}
+
+// DeadLetters returns up to limit dead letters, oldest first
+func (q *PersistableChannelUniqueQueue) DeadLetters(limit int) ([]*DeadLetter, error) {
+	if q.deadLetters == nil {
+		return nil, ErrNoDeadLetterQueue
+	}
+	return q.deadLetters.List(context.Background(), limit)
+}
+
+// RequeueDeadLetters pushes the dead letters with the provided ids, or all if no id is provided, back into the queue
+func (q *PersistableChannelUniqueQueue) RequeueDeadLetters(ids ...string) (int, error) {
+	if q.deadLetters == nil {
+		return 0, ErrNoDeadLetterQueue
+	}
+	return q.deadLetters.Requeue(context.Background(), func(letter *DeadLetter) error {
+		data, err := unmarshalAs(letter.Data, q.channelQueue.exemplar)
+		if err != nil {
+			return err
+		}
+		if err := q.Push(data); err != nil && err != ErrAlreadyInQueue {
+			return err
+		}
+		return nil
+	}, ids...)
+}
+
+// PurgeDeadLetters removes the dead letters with the provided ids, or all if no id is provided
+func (q *PersistableChannelUniqueQueue) PurgeDeadLetters(ids ...string) (int, error) {
+	if q.deadLetters == nil {
+		return 0, ErrNoDeadLetterQueue
+	}
+	removed, err := q.deadLetters.Remove(context.Background(), ids...)
+	return len(removed), err
+}
+
 // Terminate this queue and close the queue
 func (q *PersistableChannelUniqueQueue) Terminate() {
 	log.Trace("PersistableChannelUniqueQueue: %s Terminating", q.delayedStarter.name)
@@ -304,6 +374,11 @@ func (q *PersistableChannelUniqueQueue) Terminate() {
 	if q.internal != nil {
 		q.internal.(*LevelUniqueQueue).Terminate()
 	}
+	if q.deadLetters != nil {
+		if err := q.deadLetters.Close(); err != nil {
+			log.Error("Error whilst closing dead letter queue of %s: %v", q.delayedStarter.name, err)
+		}
+	}
 	q.channelQueue.baseCtxFinished()
 	log.Debug("PersistableChannelUniqueQueue: %s Terminated", q.delayedStarter.name)
 }
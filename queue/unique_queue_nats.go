@@ -0,0 +1,154 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// NATSUniqueQueueType is the type for NATS JetStream unique queue
+const NATSUniqueQueueType Type = "unique-nats"
+
+// NATSUniqueQueue NATS JetStream unique queue
+type NATSUniqueQueue struct {
+	*ByteFIFOUniqueQueue
+}
+
+// NATSUniqueQueueConfiguration is the configuration for the NATS JetStream unique queue
+type NATSUniqueQueueConfiguration struct {
+	ByteFIFOQueueConfiguration
+	NATSUniqueByteFIFOConfiguration
+}
+
+// NewNATSUniqueQueue creates a unique queue backed by a NATS JetStream stream.
+//
+// Unlike the other unique queues, a task is only acknowledged once it has
+// been handled, so uniqueness is guaranteed until then.
+func NewNATSUniqueQueue(handle HandlerFunc, cfg, exemplar interface{}) (Queue, error) {
+	configInterface, err := toConfig(NATSUniqueQueueConfiguration{}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	config := configInterface.(NATSUniqueQueueConfiguration)
+
+	if len(config.SetName) == 0 {
+		config.SetName = config.QueueName + "_unique"
+	}
+
+	byteFIFO, err := NewNATSUniqueByteFIFO(config.NATSUniqueByteFIFOConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	byteFIFOQueue, err := NewByteFIFOUniqueQueue(NATSUniqueQueueType, byteFIFO, handle, config.ByteFIFOQueueConfiguration, exemplar)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.HasDeadLetterQueue() {
+		deadLetters, err := NewNATSByteFIFO(NATSByteFIFOConfiguration{
+			ConnectionString: config.ConnectionString,
+			QueueName:        config.DeadLetterQueueName,
+		})
+		if err != nil {
+			_ = byteFIFO.Close()
+			return nil, err
+		}
+		byteFIFOQueue.setDeadLetterQueue(deadLetters)
+	}
+
+	queue := &NATSUniqueQueue{
+		ByteFIFOUniqueQueue: byteFIFOQueue,
+	}
+
+	queue.qid = GetManager().Add(queue, NATSUniqueQueueType, config, exemplar)
+
+	return queue, nil
+}
+
+var _ UniqueByteFIFO = &NATSUniqueByteFIFO{}
+
+// NATSUniqueByteFIFO represents a UniqueByteFIFO formed from a JetStream work queue stream
+//
+// Every data is published on its own subject below the set name. JetStream rejects data
+// whose subject still has a message, and acknowledging the message once it has been handled frees the subject.
+type NATSUniqueByteFIFO struct {
+	NATSByteFIFO
+}
+
+// NATSUniqueByteFIFOConfiguration is the configuration for the NATSUniqueByteFIFO
+type NATSUniqueByteFIFOConfiguration struct {
+	NATSByteFIFOConfiguration
+	// SetName is the name of the stream, it defaults to the queue name
+	SetName string
+}
+
+// NewNATSUniqueByteFIFO creates a UniqueByteFIFO formed from a JetStream stream named after the set
+func NewNATSUniqueByteFIFO(config NATSUniqueByteFIFOConfiguration) (*NATSUniqueByteFIFO, error) {
+	name := config.SetName
+	if len(name) == 0 {
+		name = config.QueueName
+	}
+	subject := natsName(name)
+	client, err := newJetStreamClient(config.ConnectionString, subject, subject+".*", true)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSUniqueByteFIFO{
+		NATSByteFIFO: NATSByteFIFO{
+			client:  client,
+			subject: subject,
+		},
+	}, nil
+}
+
+// dataSubject returns the subject of the data
+func (fifo *NATSUniqueByteFIFO) dataSubject(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fifo.subject + "." + hex.EncodeToString(sum[:])
+}
+
+// PushFunc pushes data to the end of the fifo and calls the callback if it is added
+func (fifo *NATSUniqueByteFIFO) PushFunc(ctx context.Context, data []byte, fn func() error) error {
+	subject := fifo.dataSubject(data)
+	has, err := fifo.client.HasSubject(ctx, subject)
+	if err != nil {
+		return err
+	}
+	if has {
+		return ErrAlreadyInQueue
+	}
+	if fn != nil {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return fifo.publish(ctx, subject, data)
+}
+
+// PushBack pushes data back to the fifo, it is retried after the data which is already waiting
+func (fifo *NATSUniqueByteFIFO) PushBack(ctx context.Context, data []byte) error {
+	return fifo.publish(ctx, fifo.dataSubject(data), data)
+}
+
+func (fifo *NATSUniqueByteFIFO) publish(ctx context.Context, subject string, data []byte) error {
+	err := fifo.client.Publish(ctx, subject, data, true)
+	if err == errNATSSubjectNotEmpty {
+		return ErrAlreadyInQueue
+	}
+	return err
+}
+
+// Has returns whether the fifo contains this data
+func (fifo *NATSUniqueByteFIFO) Has(ctx context.Context, data []byte) (bool, error) {
+	return fifo.client.HasSubject(ctx, fifo.dataSubject(data))
+}
+
+func init() {
+	queuesMap[NATSUniqueQueueType] = NewNATSUniqueQueue
+}
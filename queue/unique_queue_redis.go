@@ -51,6 +51,28 @@ func NewRedisUniqueQueue(handle HandlerFunc, cfg, exemplar interface{}) (Queue,
 		return nil, err
 	}
 
+	if config.HasDeadLetterQueue() {
+		deadLetters, err := NewRedisByteFIFO(RedisByteFIFOConfiguration{
+			ConnectionString: config.ConnectionString,
+			QueueName:        config.DeadLetterQueueName,
+		})
+		if err != nil {
+			_ = byteFIFO.Close()
+			return nil, err
+		}
+		byteFIFOQueue.setDeadLetterQueue(deadLetters)
+	}
+
+	scheduled, err := NewRedisScheduledByteFIFO(config.ConnectionString, config.QueueName+"_scheduled")
+	if err != nil {
+		_ = byteFIFO.Close()
+		if byteFIFOQueue.deadLetters != nil {
+			_ = byteFIFOQueue.deadLetters.Close()
+		}
+		return nil, err
+	}
+	byteFIFOQueue.setScheduledByteFIFO(scheduled)
+
 	queue := &RedisUniqueQueue{
 		ByteFIFOUniqueQueue: byteFIFOQueue,
 	}
@@ -133,6 +155,15 @@ func (fifo *RedisUniqueByteFIFO) Pop(ctx context.Context) ([]byte, error) {
 	return data, err
 }
 
+// Remove removes the data from the fifo and its set
+func (fifo *RedisUniqueByteFIFO) Remove(ctx context.Context, data []byte) (bool, error) {
+	removed, err := fifo.RedisByteFIFO.Remove(ctx, data)
+	if err != nil || !removed {
+		return removed, err
+	}
+	return true, fifo.client.SRem(ctx, fifo.setName, data).Err()
+}
+
 // Has returns whether the fifo contains this data
 func (fifo *RedisUniqueByteFIFO) Has(ctx context.Context, data []byte) (bool, error) {
 	return fifo.client.SIsMember(ctx, fifo.setName, data).Result()
@@ -17,22 +17,27 @@ import (
 
 // QueueSettings represent the settings for a queue from the ini
 type QueueSettings struct {
-	Name             string
-	DataDir          string
-	QueueLength      int `ini:"LENGTH"`
-	BatchLength      int
-	ConnectionString string
-	Type             string
-	QueueName        string
-	SetName          string
-	WrapIfNecessary  bool
-	MaxAttempts      int
-	Timeout          time.Duration
-	Workers          int
-	MaxWorkers       int
-	BlockTimeout     time.Duration
-	BoostTimeout     time.Duration
-	BoostWorkers     int
+	Name                string
+	DataDir             string
+	QueueLength         int `ini:"LENGTH"`
+	BatchLength         int
+	ConnectionString    string
+	Type                string
+	QueueName           string
+	SetName             string
+	WrapIfNecessary     bool
+	MaxAttempts         int
+	Timeout             time.Duration
+	Workers             int
+	MaxWorkers          int
+	BlockTimeout        time.Duration
+	BoostTimeout        time.Duration
+	BoostWorkers        int
+	RetryMaxAttempts    int
+	RetryBackoff        time.Duration
+	RetryMaxBackoff     time.Duration
+	RetryJitter         float64
+	DeadLetterQueueName string
 }
 
 // Queue settings
@@ -56,11 +61,16 @@ func GetQueueSettings(name string) QueueSettings {
 			q.QueueName = key.MustString(q.QueueName)
 		case "SET_NAME":
 			q.SetName = key.MustString(q.SetName)
+		case "DEAD_LETTER_QUEUE_NAME":
+			q.DeadLetterQueueName = key.MustString(q.DeadLetterQueueName)
 		}
 	}
 	if len(q.SetName) == 0 && len(Queue.SetName) > 0 {
 		q.SetName = q.QueueName + Queue.SetName
 	}
+	if len(q.DeadLetterQueueName) == 0 && len(Queue.DeadLetterQueueName) > 0 {
+		q.DeadLetterQueueName = q.QueueName + Queue.DeadLetterQueueName
+	}
 	if !filepath.IsAbs(q.DataDir) {
 		q.DataDir = filepath.ToSlash(filepath.Join(AppDataPath, q.DataDir))
 	}
@@ -79,6 +89,10 @@ func GetQueueSettings(name string) QueueSettings {
 	q.BlockTimeout = sec.Key("BLOCK_TIMEOUT").MustDuration(Queue.BlockTimeout)
 	q.BoostTimeout = sec.Key("BOOST_TIMEOUT").MustDuration(Queue.BoostTimeout)
 	q.BoostWorkers = sec.Key("BOOST_WORKERS").MustInt(Queue.BoostWorkers)
+	q.RetryMaxAttempts = sec.Key("RETRY_MAX_ATTEMPTS").MustInt(Queue.RetryMaxAttempts)
+	q.RetryBackoff = sec.Key("RETRY_BACKOFF").MustDuration(Queue.RetryBackoff)
+	q.RetryMaxBackoff = sec.Key("RETRY_MAX_BACKOFF").MustDuration(Queue.RetryMaxBackoff)
+	q.RetryJitter = sec.Key("RETRY_JITTER").MustFloat64(Queue.RetryJitter)
 
 	return q
 }
@@ -106,6 +120,11 @@ func NewQueueService() {
 	Queue.BoostWorkers = sec.Key("BOOST_WORKERS").MustInt(1)
 	Queue.QueueName = sec.Key("QUEUE_NAME").MustString("_queue")
 	Queue.SetName = sec.Key("SET_NAME").MustString("")
+	Queue.RetryMaxAttempts = sec.Key("RETRY_MAX_ATTEMPTS").MustInt(0)
+	Queue.RetryBackoff = sec.Key("RETRY_BACKOFF").MustDuration(0)
+	Queue.RetryMaxBackoff = sec.Key("RETRY_MAX_BACKOFF").MustDuration(10 * time.Minute)
+	Queue.RetryJitter = sec.Key("RETRY_JITTER").MustFloat64(0.2)
+	Queue.DeadLetterQueueName = sec.Key("DEAD_LETTER_QUEUE_NAME").MustString("_dead_letter")
 
 	// Now handle the old issue_indexer configuration
 	// FIXME: DEPRECATED to be removed in v1.18.0
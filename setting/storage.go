@@ -43,6 +43,15 @@ func getStorage(name, typ string, targetSec *ini.Section) Storage {
 	sec.Key("MINIO_BUCKET").MustString("gitbundle")
 	sec.Key("MINIO_LOCATION").MustString("us-east-1")
 	sec.Key("MINIO_USE_SSL").MustBool(false)
+	sec.Key("MINIO_INSECURE_SKIP_VERIFY").MustBool(false)
+	sec.Key("MINIO_BUCKET_LOOKUP_TYPE").MustString("auto")
+	sec.Key("AZURE_BLOB_ENDPOINT").MustString("")
+	sec.Key("AZURE_BLOB_ACCOUNT_NAME").MustString("")
+	sec.Key("AZURE_BLOB_ACCOUNT_KEY").MustString("")
+	sec.Key("AZURE_BLOB_CONTAINER").MustString("gitbundle")
+	sec.Key("GCS_ENDPOINT").MustString("https://storage.googleapis.com")
+	sec.Key("GCS_CREDENTIALS_FILE").MustString("")
+	sec.Key("GCS_BUCKET").MustString("gitbundle")
 
 	if targetSec == nil {
 		targetSec, _ = Cfg.NewSection(name)
@@ -87,6 +96,8 @@ func getStorage(name, typ string, targetSec *ini.Section) Storage {
 		storage.Section.Key("PATH").SetValue(storage.Path)
 	}
 	storage.Section.Key("MINIO_BASE_PATH").MustString(name + "/")
+	storage.Section.Key("AZURE_BLOB_BASE_PATH").MustString(name + "/")
+	storage.Section.Key("GCS_BASE_PATH").MustString(name + "/")
 
 	return storage
 }
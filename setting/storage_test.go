@@ -196,3 +196,40 @@ STORAGE_TYPE = minio
 
 	assert.EqualValues(t, "minio", storage.Type)
 }
+
+func Test_getStorageAzureBlobAndGCS(t *testing.T) {
+	iniStr := `
+[attachment]
+STORAGE_TYPE = azureblob
+
+[lfs]
+STORAGE_TYPE = gcs
+
+[storage.azureblob]
+AZURE_BLOB_ENDPOINT = http://127.0.0.1:10000/devstoreaccount1
+
+[storage]
+GCS_BUCKET = gitbundle-gcs
+`
+	Cfg, _ = ini.Load([]byte(iniStr))
+
+	{
+		sec := Cfg.Section("attachment")
+		storageType := sec.Key("STORAGE_TYPE").MustString("")
+		storage := getStorage("attachments", storageType, sec)
+
+		assert.EqualValues(t, "azureblob", storage.Type)
+		assert.EqualValues(t, "http://127.0.0.1:10000/devstoreaccount1", storage.Section.Key("AZURE_BLOB_ENDPOINT").String())
+		assert.EqualValues(t, "gitbundle", storage.Section.Key("AZURE_BLOB_CONTAINER").String())
+		assert.EqualValues(t, "attachments/", storage.Section.Key("AZURE_BLOB_BASE_PATH").String())
+	}
+	{
+		sec := Cfg.Section("lfs")
+		storageType := sec.Key("STORAGE_TYPE").MustString("")
+		storage := getStorage("lfs", storageType, sec)
+
+		assert.EqualValues(t, "gcs", storage.Type)
+		assert.EqualValues(t, "gitbundle-gcs", storage.Section.Key("GCS_BUCKET").String())
+		assert.EqualValues(t, "lfs/", storage.Section.Key("GCS_BASE_PATH").String())
+	}
+}
@@ -0,0 +1,475 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gitbundle/modules/log"
+)
+
+var (
+	_ ObjectStorage                = &AzureBlobStorage{}
+	_ PresignedStorage             = &AzureBlobStorage{}
+	_ PresignedUploadHeaderStorage = &AzureBlobStorage{}
+)
+
+// AzureBlobStorageType is the type descriptor for azure blob storage
+const AzureBlobStorageType Type = "azureblob"
+
+const (
+	azureBlobVersion = "2020-12-06"
+	// azureBlobMaxPutSize is the largest object which is uploaded with a single request
+	azureBlobMaxPutSize = 256 * 1024 * 1024
+	// azureBlobBlockSize is the size of the blocks used for larger and unknown size uploads
+	azureBlobBlockSize = 8 * 1024 * 1024
+)
+
+// AzureBlobStorageConfig represents the configuration for an azure blob storage
+type AzureBlobStorageConfig struct {
+	// Endpoint is the blob service endpoint, e.g. https://account.blob.core.windows.net
+	// or http://127.0.0.1:10000/devstoreaccount1 for Azurite
+	Endpoint    string `ini:"AZURE_BLOB_ENDPOINT"`
+	AccountName string `ini:"AZURE_BLOB_ACCOUNT_NAME"`
+	AccountKey  string `ini:"AZURE_BLOB_ACCOUNT_KEY"`
+	Container   string `ini:"AZURE_BLOB_CONTAINER"`
+	BasePath    string `ini:"AZURE_BLOB_BASE_PATH"`
+}
+
+// AzureBlobStorage represents an azure blob storage container
+type AzureBlobStorage struct {
+	ctx         context.Context
+	client      *http.Client
+	endpoint    *url.URL
+	accountName string
+	accountKey  []byte
+	container   string
+	basePath    string
+}
+
+// NewAzureBlobStorage returns an azure blob storage
+func NewAzureBlobStorage(ctx context.Context, cfg interface{}) (ObjectStorage, error) {
+	configInterface, err := toConfig(AzureBlobStorageConfig{}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	config := configInterface.(AzureBlobStorageConfig)
+
+	log.Info("Creating Azure Blob storage at %s:%s with base path %s", config.Endpoint, config.Container, config.BasePath)
+
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+	}
+	accountKey, err := base64.StdEncoding.DecodeString(config.AccountKey)
+	if err != nil {
+		return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+	}
+
+	a := &AzureBlobStorage{
+		ctx:         ctx,
+		client:      http.DefaultClient,
+		endpoint:    endpoint,
+		accountName: config.AccountName,
+		accountKey:  accountKey,
+		container:   config.Container,
+		basePath:    config.BasePath,
+	}
+
+	resp, err := a.do(http.MethodPut, a.containerURL(url.Values{"restype": {"container"}}), nil, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusConflict {
+		return nil, convertHTTPStatus(resp)
+	}
+
+	return a, nil
+}
+
+func (a *AzureBlobStorage) buildAzureBlobPath(p string) string {
+	return strings.TrimPrefix(path.Join(a.basePath, path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))[1:]), "/")
+}
+
+func (a *AzureBlobStorage) containerURL(query url.Values) *url.URL {
+	u := *a.endpoint
+	u.Path += "/" + a.container
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+func (a *AzureBlobStorage) blobURL(blob string, query url.Values) *url.URL {
+	u := *a.endpoint
+	u.Path += "/" + a.container + "/" + blob
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do sends a request authorized with the shared key of the account
+func (a *AzureBlobStorage) do(method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(a.ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	if body == nil {
+		req.ContentLength = 0
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureBlobVersion)
+	req.Header.Set("Authorization", "SharedKey "+a.accountName+":"+a.sign(azureSharedKeyStringToSign(a.accountName, req)))
+
+	return a.client.Do(req)
+}
+
+func (a *AzureBlobStorage) sign(stringToSign string) string {
+	h := hmac.New(sha256.New, a.accountKey)
+	_, _ = h.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// azureSharedKeyStringToSign builds the string to sign of the shared key authorization scheme
+// https://learn.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func azureSharedKeyStringToSign(accountName string, req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var msHeaders []string
+	for k := range req.Header {
+		if k := strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			msHeaders = append(msHeaders, k)
+		}
+	}
+	sort.Strings(msHeaders)
+
+	var sb strings.Builder
+	for _, v := range []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	} {
+		sb.WriteString(v)
+		sb.WriteByte('\n')
+	}
+	for _, k := range msHeaders {
+		sb.WriteString(k + ":" + strings.TrimSpace(req.Header.Get(k)) + "\n")
+	}
+
+	sb.WriteString("/" + accountName + req.URL.EscapedPath())
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		sb.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(values, ","))
+	}
+	return sb.String()
+}
+
+// convertHTTPStatus converts an unexpected response into an error
+func convertHTTPStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return os.ErrNotExist
+	case http.StatusForbidden:
+		return os.ErrPermission
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(body))
+}
+
+// Open opens a blob
+func (a *AzureBlobStorage) Open(path string) (Object, error) {
+	blob := a.buildAzureBlobPath(path)
+	info, err := a.stat(blob)
+	if err != nil {
+		return nil, err
+	}
+	return newRemoteObject(info, a.fetcher(blob)), nil
+}
+
+// fetcher returns a function which reads the blob starting at offset
+func (a *AzureBlobStorage) fetcher(blob string) func(offset int64) (io.ReadCloser, error) {
+	return func(offset int64) (io.ReadCloser, error) {
+		header := http.Header{}
+		if offset > 0 {
+			header.Set("x-ms-range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := a.do(http.MethodGet, a.blobURL(blob, nil), nil, -1, header)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			defer resp.Body.Close()
+			return nil, convertHTTPStatus(resp)
+		}
+		return resp.Body, nil
+	}
+}
+
+// Save saves a blob. Small blobs are uploaded at once, larger ones block by block.
+func (a *AzureBlobStorage) Save(path string, r io.Reader, size int64) (int64, error) {
+	blob := a.buildAzureBlobPath(path)
+	if size >= 0 && size <= azureBlobMaxPutSize {
+		header := http.Header{}
+		header.Set("x-ms-blob-type", "BlockBlob")
+		header.Set("Content-Type", "application/octet-stream")
+		cr := &countingReader{r: r}
+		resp, err := a.do(http.MethodPut, a.blobURL(blob, nil), cr, size, header)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			return 0, convertHTTPStatus(resp)
+		}
+		return cr.n, nil
+	}
+
+	var written int64
+	var blockIDs []string
+	buf := make([]byte, azureBlobBlockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", len(blockIDs))))
+			resp, err := a.do(http.MethodPut, a.blobURL(blob, url.Values{"comp": {"block"}, "blockid": {blockID}}), bytes.NewReader(buf[:n]), int64(n), nil)
+			if err != nil {
+				return written, err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				return written, convertHTTPStatus(resp)
+			}
+			blockIDs = append(blockIDs, blockID)
+			written += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return written, err
+		}
+	}
+
+	blockList, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: blockIDs})
+	if err != nil {
+		return written, err
+	}
+	header := http.Header{}
+	header.Set("x-ms-blob-content-type", "application/octet-stream")
+	resp, err := a.do(http.MethodPut, a.blobURL(blob, url.Values{"comp": {"blocklist"}}), bytes.NewReader(blockList), int64(len(blockList)), header)
+	if err != nil {
+		return written, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return written, convertHTTPStatus(resp)
+	}
+	return written, nil
+}
+
+func (a *AzureBlobStorage) stat(blob string) (os.FileInfo, error) {
+	resp, err := a.do(http.MethodHead, a.blobURL(blob, nil), nil, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, convertHTTPStatus(resp)
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return remoteFileInfo{key: blob, size: resp.ContentLength, modTime: modTime}, nil
+}
+
+// Stat returns the stat information of the blob
+func (a *AzureBlobStorage) Stat(path string) (os.FileInfo, error) {
+	return a.stat(a.buildAzureBlobPath(path))
+}
+
+// Delete deletes a blob
+func (a *AzureBlobStorage) Delete(path string) error {
+	resp, err := a.do(http.MethodDelete, a.blobURL(a.buildAzureBlobPath(path), nil), nil, -1, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return convertHTTPStatus(resp)
+	}
+	return nil
+}
+
+// URL gets the redirect URL to a file. The SAS link is valid for 5 minutes.
+func (a *AzureBlobStorage) URL(path, name string) (*url.URL, error) {
+	return a.sasURL(a.buildAzureBlobPath(path), "r", 5*time.Minute, "attachment; filename=\""+quoteEscaper.Replace(name)+"\""), nil
+}
+
+// PresignGet returns a SAS link to download the blob
+func (a *AzureBlobStorage) PresignGet(path string, expiry time.Duration) (*url.URL, error) {
+	return a.sasURL(a.buildAzureBlobPath(path), "r", expiry, ""), nil
+}
+
+// PresignPut returns a SAS link to upload the blob, see PresignPutHeaders for the required headers
+func (a *AzureBlobStorage) PresignPut(path string, expiry time.Duration) (*url.URL, error) {
+	return a.sasURL(a.buildAzureBlobPath(path), "cw", expiry, ""), nil
+}
+
+// PresignPutHeaders returns the headers of uploads to SAS links, Azure rejects a Put Blob without the blob type
+func (a *AzureBlobStorage) PresignPutHeaders() map[string]string {
+	return map[string]string{"x-ms-blob-type": "BlockBlob"}
+}
+
+// sasURL returns a blob URL authorized with a service SAS
+// https://learn.microsoft.com/en-us/rest/api/storageservices/create-service-sas
+func (a *AzureBlobStorage) sasURL(blob, permissions string, expiry time.Duration, contentDisposition string) *url.URL {
+	expiresAt := time.Now().UTC().Add(expiry).Format(time.RFC3339)
+	canonicalizedResource := "/blob/" + a.accountName + "/" + a.container + "/" + blob
+
+	stringToSign := strings.Join([]string{
+		permissions,
+		"", // signedStart
+		expiresAt,
+		canonicalizedResource,
+		"", // signedIdentifier
+		"", // signedIP
+		"", // signedProtocol
+		azureBlobVersion,
+		"b", // signedResource
+		"",  // signedSnapshotTime
+		"",  // signedEncryptionScope
+		"",  // rscc
+		contentDisposition,
+		"", // rsce
+		"", // rscl
+		"", // rsct
+	}, "\n")
+
+	query := url.Values{
+		"sv":  {azureBlobVersion},
+		"sr":  {"b"},
+		"sp":  {permissions},
+		"se":  {expiresAt},
+		"sig": {a.sign(stringToSign)},
+	}
+	if contentDisposition != "" {
+		query.Set("rscd", contentDisposition)
+	}
+	return a.blobURL(blob, query)
+}
+
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			ContentLength int64  `xml:"Content-Length"`
+			LastModified  string `xml:"Last-Modified"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// IterateObjects iterates across the objects in the azure blob storage
+func (a *AzureBlobStorage) IterateObjects(fn func(path string, obj Object) error) error {
+	marker := ""
+	for {
+		select {
+		case <-a.ctx.Done():
+			return a.ctx.Err()
+		default:
+		}
+
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {a.basePath}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		resp, err := a.do(http.MethodGet, a.containerURL(query), nil, -1, nil)
+		if err != nil {
+			return err
+		}
+		var list azureBlobList
+		if resp.StatusCode != http.StatusOK {
+			err = convertHTTPStatus(resp)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&list)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, blob := range list.Blobs {
+			modTime, _ := http.ParseTime(blob.Properties.LastModified)
+			info := remoteFileInfo{key: blob.Name, size: blob.Properties.ContentLength, modTime: modTime}
+			obj := newRemoteObject(info, a.fetcher(blob.Name))
+			if err := func() error {
+				defer obj.Close()
+				return fn(strings.TrimPrefix(blob.Name, a.basePath), obj)
+			}(); err != nil {
+				return err
+			}
+		}
+
+		if list.NextMarker == "" {
+			return nil
+		}
+		marker = list.NextMarker
+	}
+}
+
+// countingReader counts the bytes read from the wrapped reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func init() {
+	RegisterStorageType(AzureBlobStorageType, NewAzureBlobStorage)
+}
@@ -0,0 +1,224 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// fakeAzurite is a minimal in-process stand-in for the Azurite blob service
+type fakeAzurite struct {
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	blocks map[string][]byte
+}
+
+func (f *fakeAzurite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, _ := base64.StdEncoding.DecodeString(azuriteKey)
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(azureSharedKeyStringToSign(azuriteAccount, r)))
+	if r.Header.Get("Authorization") != "SharedKey "+azuriteAccount+":"+base64.StdEncoding.EncodeToString(h.Sum(nil)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// path is /account/container[/blob]
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	if len(parts) == 2 {
+		switch {
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		case query.Get("comp") == "list":
+			f.list(w, query.Get("prefix"), query.Get("marker"))
+		}
+		return
+	}
+
+	blob := parts[2]
+	switch r.Method {
+	case http.MethodPut:
+		switch query.Get("comp") {
+		case "block":
+			f.blocks[query.Get("blockid")] = body
+		case "blocklist":
+			var list struct {
+				Latest []string `xml:"Latest"`
+			}
+			_ = xml.Unmarshal(body, &list)
+			var content []byte
+			for _, id := range list.Latest {
+				content = append(content, f.blocks[id]...)
+			}
+			f.blobs[blob] = content
+		default:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f.blobs[blob] = body
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead, http.MethodGet:
+		content, ok := f.blobs[blob]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if rng := r.Header.Get("x-ms-range"); rng != "" {
+			offset, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)-offset))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(content[offset:])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	case http.MethodDelete:
+		if _, ok := f.blobs[blob]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.blobs, blob)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// list returns a single blob per page to exercise the marker handling
+func (f *fakeAzurite) list(w http.ResponseWriter, prefix, marker string) {
+	names := make([]string, 0, len(f.blobs))
+	for name := range f.blobs {
+		if strings.HasPrefix(name, prefix) && name >= marker {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("<EnumerationResults><Blobs>")
+	if len(names) > 0 {
+		buf.WriteString("<Blob><Name>" + names[0] + "</Name><Properties><Content-Length>" + strconv.Itoa(len(f.blobs[names[0]])) + "</Content-Length></Properties></Blob>")
+	}
+	buf.WriteString("</Blobs><NextMarker>")
+	if len(names) > 1 {
+		buf.WriteString(names[1])
+	}
+	buf.WriteString("</NextMarker></EnumerationResults>")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func newFakeAzureBlobStorage(t *testing.T) (*AzureBlobStorage, *fakeAzurite) {
+	fake := &fakeAzurite{blobs: map[string][]byte{}, blocks: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewAzureBlobStorage(context.Background(), AzureBlobStorageConfig{
+		Endpoint:    server.URL + "/" + azuriteAccount,
+		AccountName: azuriteAccount,
+		AccountKey:  azuriteKey,
+		Container:   "gitbundle",
+		BasePath:    "base/",
+	})
+	assert.NoError(t, err)
+	return s.(*AzureBlobStorage), fake
+}
+
+func TestAzureBlobStorage(t *testing.T) {
+	s, fake := newFakeAzureBlobStorage(t)
+
+	n, err := s.Save("a/b.txt", strings.NewReader("content"), 7)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	assert.Equal(t, "content", string(fake.blobs["base/a/b.txt"]))
+
+	fi, err := s.Stat("a/b.txt")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, fi.Size())
+	assert.Equal(t, "b.txt", fi.Name())
+
+	f, err := s.Open("a/b.txt")
+	assert.NoError(t, err)
+	_, err = f.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "tent", string(content))
+	assert.NoError(t, f.Close())
+
+	// unknown size uploads are sent as blocks
+	n, err = s.Save("c.txt", io.MultiReader(strings.NewReader("con"), strings.NewReader("tent")), -1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	assert.Equal(t, "content", string(fake.blobs["base/c.txt"]))
+
+	paths := map[string]string{}
+	assert.NoError(t, s.IterateObjects(func(path string, obj Object) error {
+		content, err := io.ReadAll(obj)
+		paths[path] = string(content)
+		return err
+	}))
+	assert.Equal(t, map[string]string{"a/b.txt": "content", "c.txt": "content"}, paths)
+
+	assert.NoError(t, s.Delete("a/b.txt"))
+	_, err = s.Stat("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = s.Open("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestAzureBlobStorageSAS(t *testing.T) {
+	s, _ := newFakeAzureBlobStorage(t)
+
+	u, err := s.URL("a/b.txt", `b".txt`)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(u.Path, "/gitbundle/base/a/b.txt"))
+	query := u.Query()
+	assert.Equal(t, "r", query.Get("sp"))
+	assert.Equal(t, "b", query.Get("sr"))
+	assert.Equal(t, azureBlobVersion, query.Get("sv"))
+	assert.Equal(t, `attachment; filename="b\".txt"`, query.Get("rscd"))
+	assert.NotEmpty(t, query.Get("sig"))
+
+	expiresAt, err := time.Parse(time.RFC3339, query.Get("se"))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), expiresAt, time.Minute)
+
+	u, err = s.PresignPut("a/b.txt", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "cw", u.Query().Get("sp"))
+	assert.Empty(t, u.Query().Get("rscd"))
+	assert.Equal(t, map[string]string{"x-ms-blob-type": "BlockBlob"}, s.PresignPutHeaders())
+}
@@ -0,0 +1,291 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gitbundle/modules/log"
+)
+
+var (
+	_ ExtendedObjectStorage        = &CachedStorage{}
+	_ PresignedUploadHeaderStorage = &CachedStorage{}
+)
+
+// CachedStorage is a read-through cache on the local disk in front of another storage.
+// Objects are cached on their first read and dropped from the cache when they are
+// written or deleted through this storage.
+//
+// Presigned links are handed out by the backend, objects uploaded through them
+// bypass the cache, which is only safe for content addressed objects like LFS.
+type CachedStorage struct {
+	ObjectStorage
+	cache   *LocalStorage
+	maxSize int64
+
+	lock sync.Mutex
+	// writes counts the writes through this storage, reads which overlap a write don't keep their cached copy
+	writes uint64
+	// lru holds the cached objects, the most recently used at the front
+	lru     *list.List
+	entries map[string]*list.Element
+	size    int64
+}
+
+type cachedEntry struct {
+	path string
+	size int64
+}
+
+// NewCachedStorage returns a storage which caches the objects of backend in cacheDir.
+// If maxSize is positive the least recently used objects are evicted once the cache grows above it.
+func NewCachedStorage(ctx context.Context, backend ObjectStorage, cacheDir string, maxSize int64) (*CachedStorage, error) {
+	cache, err := NewLocalStorage(ctx, LocalStorageConfig{Path: cacheDir, TemporaryPath: filepath.Join(cacheDir, ".tmp")})
+	if err != nil {
+		return nil, err
+	}
+	c := &CachedStorage{
+		ObjectStorage: backend,
+		cache:         cache.(*LocalStorage),
+		maxSize:       maxSize,
+		lru:           list.New(),
+		entries:       make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load indexes the objects left in the cache directory by a previous run, oldest last
+func (c *CachedStorage) load() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	type cachedFile struct {
+		cachedEntry
+		modTime time.Time
+	}
+	var files []cachedFile
+	err := filepath.Walk(c.cache.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if c.cache.isInternalDir(p) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(c.cache.dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, cachedFile{
+			cachedEntry: cachedEntry{path: filepath.ToSlash(relPath), size: info.Size()},
+			modTime:     info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	for _, f := range files {
+		c.entries[f.path] = c.lru.PushBack(&cachedEntry{path: f.path, size: f.size})
+		c.size += f.size
+	}
+	c.evict()
+	return nil
+}
+
+// Open opens the cached copy of the object, fetching it from the backend if necessary
+func (c *CachedStorage) Open(path string) (Object, error) {
+	if obj, err := c.cache.Open(path); err == nil {
+		// the modification time keeps the order of use across restarts
+		now := time.Now()
+		_ = os.Chtimes(c.cache.buildLocalPath(path), now, now)
+		c.lock.Lock()
+		if e, ok := c.entries[path]; ok {
+			c.lru.MoveToFront(e)
+		}
+		c.lock.Unlock()
+		return obj, nil
+	}
+
+	c.lock.Lock()
+	writes := c.writes
+	c.lock.Unlock()
+
+	obj, err := c.ObjectStorage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	size := int64(-1)
+	if fi, err := obj.Stat(); err == nil {
+		size = fi.Size()
+	}
+	written, err := c.cache.Save(path, obj, size)
+	if err != nil {
+		log.Warn("Unable to cache %s: %v", path, err)
+		return c.ObjectStorage.Open(path)
+	}
+
+	c.lock.Lock()
+	if writes != c.writes {
+		// the object may have changed whilst it was read, so the copy may be stale
+		c.remove(path)
+		c.lock.Unlock()
+		return c.ObjectStorage.Open(path)
+	}
+	c.add(path, written)
+	c.evict()
+	c.lock.Unlock()
+
+	// the object may have been evicted again if it is larger than the cache
+	if cached, err := c.cache.Open(path); err == nil {
+		return cached, nil
+	}
+	return c.ObjectStorage.Open(path)
+}
+
+// Save saves the object to the backend and drops the cached copy
+func (c *CachedStorage) Save(path string, r io.Reader, size int64) (int64, error) {
+	n, err := c.ObjectStorage.Save(path, r, size)
+	c.invalidate(path)
+	return n, err
+}
+
+// SaveWithOptions saves the object with its options to the backend and drops the cached copy
+func (c *CachedStorage) SaveWithOptions(path string, r io.Reader, size int64, opts SaveOptions) (int64, error) {
+	n, err := SaveWithOptions(c.ObjectStorage, path, r, size, opts)
+	c.invalidate(path)
+	return n, err
+}
+
+// OpenRange reads a range of the cached copy if there is one, otherwise of the backend object.
+// Ranged reads don't populate the cache.
+func (c *CachedStorage) OpenRange(path string, offset, length int64) (io.ReadCloser, error) {
+	if rc, err := c.cache.OpenRange(path, offset, length); err == nil {
+		return rc, nil
+	}
+	return OpenRange(c.ObjectStorage, path, offset, length)
+}
+
+// List lists the objects of the backend
+func (c *CachedStorage) List(opts ListOptions) (*ListResult, error) {
+	return List(c.ObjectStorage, opts)
+}
+
+// GetMetadata returns the metadata of the backend object
+func (c *CachedStorage) GetMetadata(path string) (*ObjectMetadata, error) {
+	return GetMetadata(c.ObjectStorage, path)
+}
+
+// Delete deletes the object from the backend and the cache
+func (c *CachedStorage) Delete(path string) error {
+	err := c.ObjectStorage.Delete(path)
+	c.invalidate(path)
+	return err
+}
+
+// URL gets the redirect URL of the backend
+func (c *CachedStorage) URL(path, name string) (*url.URL, error) {
+	return c.ObjectStorage.URL(path, name)
+}
+
+// PresignGet returns a presigned download link of the backend or ErrURLNotSupported
+func (c *CachedStorage) PresignGet(path string, expiry time.Duration) (*url.URL, error) {
+	if ps, ok := c.ObjectStorage.(PresignedStorage); ok {
+		return ps.PresignGet(path, expiry)
+	}
+	return nil, ErrURLNotSupported
+}
+
+// PresignPut returns a presigned upload link of the backend or ErrURLNotSupported
+func (c *CachedStorage) PresignPut(path string, expiry time.Duration) (*url.URL, error) {
+	if ps, ok := c.ObjectStorage.(PresignedStorage); ok {
+		return ps.PresignPut(path, expiry)
+	}
+	return nil, ErrURLNotSupported
+}
+
+// PresignPutHeaders returns the headers required by the presigned upload links of the backend
+func (c *CachedStorage) PresignPutHeaders() map[string]string {
+	return presignPutHeaders(c.ObjectStorage)
+}
+
+// Purge removes all objects from the cache
+func (c *CachedStorage) Purge() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.writes++
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.size = 0
+	return Clean(c.cache)
+}
+
+// invalidate drops the cached copy after the object has been written or deleted
+func (c *CachedStorage) invalidate(path string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.writes++
+	c.remove(path)
+}
+
+// add records a cached copy, the caller must hold the lock
+func (c *CachedStorage) add(path string, size int64) {
+	if e, ok := c.entries[path]; ok {
+		entry := e.Value.(*cachedEntry)
+		c.size += size - entry.size
+		entry.size = size
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[path] = c.lru.PushFront(&cachedEntry{path: path, size: size})
+	c.size += size
+}
+
+// remove deletes a cached copy, the caller must hold the lock
+func (c *CachedStorage) remove(path string) {
+	if e, ok := c.entries[path]; ok {
+		c.size -= e.Value.(*cachedEntry).size
+		c.lru.Remove(e)
+		delete(c.entries, path)
+	}
+	if err := c.cache.Delete(path); err != nil && !os.IsNotExist(err) {
+		log.Warn("Unable to remove %s from cache: %v", path, err)
+	}
+}
+
+// evict removes the least recently used objects until the cache fits into maxSize, the caller must hold the lock
+func (c *CachedStorage) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+	for c.size > c.maxSize {
+		e := c.lru.Back()
+		if e == nil {
+			return
+		}
+		c.remove(e.Value.(*cachedEntry).path)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLocalStorage(t *testing.T) ObjectStorage {
+	s, err := NewLocalStorage(context.Background(), LocalStorageConfig{Path: t.TempDir()})
+	assert.NoError(t, err)
+	return s
+}
+
+func readObject(t *testing.T, s ObjectStorage, path string) string {
+	obj, err := s.Open(path)
+	assert.NoError(t, err)
+	defer obj.Close()
+	content, err := io.ReadAll(obj)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestCachedStorage(t *testing.T) {
+	backend := newTestLocalStorage(t)
+	c, err := NewCachedStorage(context.Background(), backend, t.TempDir(), 0)
+	assert.NoError(t, err)
+
+	_, err = backend.Save("a/b.txt", strings.NewReader("content"), 7)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "content", readObject(t, c, "a/b.txt"))
+	_, err = c.cache.Stat("a/b.txt")
+	assert.NoError(t, err)
+
+	// served from the cache even if the backend changed behind its back
+	_, err = backend.Save("a/b.txt", strings.NewReader("changed"), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", readObject(t, c, "a/b.txt"))
+
+	// writes through the cached storage invalidate the cache
+	_, err = c.Save("a/b.txt", strings.NewReader("updated"), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", readObject(t, backend, "a/b.txt"))
+	assert.Equal(t, "updated", readObject(t, c, "a/b.txt"))
+
+	assert.NoError(t, c.Delete("a/b.txt"))
+	_, err = c.cache.Stat("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = c.Open("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// openHookStorage calls onOpen after the object has been opened
+type openHookStorage struct {
+	ObjectStorage
+	onOpen func()
+}
+
+func (s *openHookStorage) Open(path string) (Object, error) {
+	obj, err := s.ObjectStorage.Open(path)
+	if s.onOpen != nil {
+		onOpen := s.onOpen
+		s.onOpen = nil
+		onOpen()
+	}
+	return obj, err
+}
+
+func TestCachedStorageConcurrentWrite(t *testing.T) {
+	backend := &openHookStorage{ObjectStorage: newTestLocalStorage(t)}
+	c, err := NewCachedStorage(context.Background(), backend, t.TempDir(), 0)
+	assert.NoError(t, err)
+
+	_, err = backend.Save("a.txt", strings.NewReader("old"), 3)
+	assert.NoError(t, err)
+
+	// the object is replaced whilst the cache reads the old content
+	backend.onOpen = func() {
+		_, err := c.Save("a.txt", strings.NewReader("new"), 3)
+		assert.NoError(t, err)
+	}
+	readObject(t, c, "a.txt")
+
+	_, err = c.cache.Stat("a.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, "new", readObject(t, c, "a.txt"))
+}
+
+type presignedTestStorage struct {
+	ObjectStorage
+}
+
+func (s *presignedTestStorage) PresignGet(path string, expiry time.Duration) (*url.URL, error) {
+	return url.Parse("https://bucket.example.com/" + path)
+}
+
+func (s *presignedTestStorage) PresignPut(path string, expiry time.Duration) (*url.URL, error) {
+	return url.Parse("https://bucket.example.com/" + path + "?upload=1")
+}
+
+func (s *presignedTestStorage) PresignPutHeaders() map[string]string {
+	return map[string]string{"x-ms-blob-type": "BlockBlob"}
+}
+
+func TestCachedStoragePresign(t *testing.T) {
+	c, err := NewCachedStorage(context.Background(), newTestLocalStorage(t), t.TempDir(), 0)
+	assert.NoError(t, err)
+	_, err = c.PresignGet("a", time.Minute)
+	assert.ErrorIs(t, err, ErrURLNotSupported)
+	_, err = c.PresignPut("a", time.Minute)
+	assert.ErrorIs(t, err, ErrURLNotSupported)
+	assert.Nil(t, c.PresignPutHeaders())
+
+	c, err = NewCachedStorage(context.Background(), &presignedTestStorage{newTestLocalStorage(t)}, t.TempDir(), 0)
+	assert.NoError(t, err)
+	u, err := c.PresignGet("a", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://bucket.example.com/a", u.String())
+	u, err = c.PresignPut("a", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://bucket.example.com/a?upload=1", u.String())
+	assert.Equal(t, map[string]string{"x-ms-blob-type": "BlockBlob"}, c.PresignPutHeaders())
+}
+
+func TestCachedStorageEviction(t *testing.T) {
+	backend := newTestLocalStorage(t)
+	c, err := NewCachedStorage(context.Background(), backend, t.TempDir(), 10)
+	assert.NoError(t, err)
+
+	for _, p := range []string{"a", "b", "large"} {
+		content := "12345"
+		if p == "large" {
+			content = "123456789012"
+		}
+		_, err = backend.Save(p, strings.NewReader(content), int64(len(content)))
+		assert.NoError(t, err)
+	}
+
+	readObject(t, c, "a")
+	readObject(t, c, "b")
+	_, err = c.cache.Stat("a")
+	assert.NoError(t, err)
+
+	// a new cached storage picks up the objects of the existing cache
+	c, err = NewCachedStorage(context.Background(), backend, c.cache.dir, 10)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, c.size)
+	readObject(t, c, "a")
+	_, err = backend.Save("c", strings.NewReader("12345"), 5)
+	assert.NoError(t, err)
+	readObject(t, c, "c")
+	_, err = c.cache.Stat("b")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = c.cache.Stat("a")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, c.size)
+
+	// objects larger than the cache are still served
+	assert.Equal(t, "123456789012", readObject(t, c, "large"))
+	_, err = c.cache.Stat("large")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NoError(t, c.Purge())
+	_, err = c.cache.Stat("b")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.EqualValues(t, 0, c.size)
+}
+
+func TestCachedStorageExtended(t *testing.T) {
+	backend := newTestLocalStorage(t)
+	c, err := NewCachedStorage(context.Background(), backend, t.TempDir(), 0)
+	assert.NoError(t, err)
+
+	_, err = c.SaveWithOptions("a.txt", strings.NewReader("content"), 7, SaveOptions{ContentType: "text/plain"})
+	assert.NoError(t, err)
+	_, err = c.SaveWithOptions("a.txt", strings.NewReader("other"), 5, SaveOptions{IfNotExists: true})
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	// ranged reads are served by the backend until the object is cached
+	rc, err := c.OpenRange("a.txt", 1, 3)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.NoError(t, rc.Close())
+	assert.Equal(t, "ont", string(data))
+	_, err = c.cache.Stat("a.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Equal(t, "content", readObject(t, c, "a.txt"))
+	rc, err = c.OpenRange("a.txt", 4, -1)
+	assert.NoError(t, err)
+	data, err = io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.NoError(t, rc.Close())
+	assert.Equal(t, "ent", string(data))
+
+	result, err := c.List(ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, result.Objects, 1)
+
+	metadata, err := c.GetMetadata("a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", metadata.ContentType)
+
+	// writes with options drop the cached copy
+	_, err = c.SaveWithOptions("a.txt", strings.NewReader("changed"), 7, SaveOptions{})
+	assert.NoError(t, err)
+	_, err = c.cache.Stat("a.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, "changed", readObject(t, c, "a.txt"))
+}
@@ -0,0 +1,479 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+	"github.com/gitbundle/modules/log"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	_ ObjectStorage    = &GCSStorage{}
+	_ PresignedStorage = &GCSStorage{}
+)
+
+// GCSStorageType is the type descriptor for google cloud storage
+const GCSStorageType Type = "gcs"
+
+const (
+	gcsDefaultEndpoint = "https://storage.googleapis.com"
+	gcsScope           = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// GCSStorageConfig represents the configuration for a google cloud storage
+type GCSStorageConfig struct {
+	// Endpoint defaults to https://storage.googleapis.com, set it to use an emulator like fake-gcs-server
+	Endpoint string `ini:"GCS_ENDPOINT"`
+	// CredentialsFile is the JSON key of a service account.
+	// Requests are sent unauthenticated and signed URLs are unsupported without it.
+	CredentialsFile string `ini:"GCS_CREDENTIALS_FILE"`
+	Bucket          string `ini:"GCS_BUCKET"`
+	// ProjectID is used to create the bucket if it doesn't exist
+	ProjectID string `ini:"GCS_PROJECT_ID"`
+	BasePath  string `ini:"GCS_BASE_PATH"`
+}
+
+// gcsCredentials represents the relevant parts of a service account key file
+type gcsCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// GCSStorage represents a google cloud storage bucket
+type GCSStorage struct {
+	ctx         context.Context
+	client      *http.Client
+	endpoint    *url.URL
+	bucket      string
+	basePath    string
+	clientEmail string
+	privateKey  *rsa.PrivateKey
+	tokenURI    string
+
+	tokenLock   sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+type gcsObject struct {
+	Name    string    `json:"name"`
+	Size    string    `json:"size"`
+	Updated time.Time `json:"updated"`
+}
+
+func (o *gcsObject) fileInfo() os.FileInfo {
+	size, _ := strconv.ParseInt(o.Size, 10, 64)
+	return remoteFileInfo{key: o.Name, size: size, modTime: o.Updated}
+}
+
+// NewGCSStorage returns a google cloud storage
+func NewGCSStorage(ctx context.Context, cfg interface{}) (ObjectStorage, error) {
+	configInterface, err := toConfig(GCSStorageConfig{}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	config := configInterface.(GCSStorageConfig)
+
+	log.Info("Creating GCS storage at %s:%s with base path %s", config.Endpoint, config.Bucket, config.BasePath)
+
+	if config.Endpoint == "" {
+		config.Endpoint = gcsDefaultEndpoint
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+	}
+
+	g := &GCSStorage{
+		ctx:      ctx,
+		client:   http.DefaultClient,
+		endpoint: endpoint,
+		bucket:   config.Bucket,
+		basePath: config.BasePath,
+	}
+
+	if config.CredentialsFile != "" {
+		data, err := os.ReadFile(config.CredentialsFile)
+		if err != nil {
+			return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+		}
+		var creds gcsCredentials
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+		}
+		g.privateKey, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey))
+		if err != nil {
+			return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+		}
+		g.clientEmail = creds.ClientEmail
+		g.tokenURI = creds.TokenURI
+	}
+
+	resp, err := g.do(http.MethodGet, g.jsonURL("/b/"+url.PathEscape(g.bucket), nil), nil, -1, "")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && config.ProjectID != "" {
+		body, _ := json.Marshal(map[string]string{"name": g.bucket})
+		resp, err = g.do(http.MethodPost, g.jsonURL("/b", url.Values{"project": {config.ProjectID}}), strings.NewReader(string(body)), int64(len(body)), "application/json")
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+			return nil, convertHTTPStatus(resp)
+		}
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, convertHTTPStatus(resp)
+	}
+
+	return g, nil
+}
+
+func (g *GCSStorage) buildGCSPath(p string) string {
+	return strings.TrimPrefix(path.Join(g.basePath, path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))[1:]), "/")
+}
+
+func (g *GCSStorage) jsonURL(p string, query url.Values) *url.URL {
+	u := *g.endpoint
+	u.RawPath = g.endpoint.EscapedPath() + "/storage/v1" + p
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+func (g *GCSStorage) objectURL(object string, query url.Values) *url.URL {
+	return g.jsonURL("/b/"+url.PathEscape(g.bucket)+"/o/"+url.PathEscape(object), query)
+}
+
+// accessToken returns a cached OAuth2 token of the service account or requests a new one
+func (g *GCSStorage) accessToken() (string, error) {
+	g.tokenLock.Lock()
+	defer g.tokenLock.Unlock()
+
+	if g.token != "" && time.Now().Before(g.tokenExpiry) {
+		return g.token, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   g.clientEmail,
+		"scope": gcsScope,
+		"aud":   g.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(g.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(g.ctx, http.MethodPost, g.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", convertHTTPStatus(resp)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	g.token = token.AccessToken
+	// refresh the token a bit early to not use it while it expires
+	g.tokenExpiry = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return g.token, nil
+}
+
+// do sends a request authorized with the service account if one is configured
+func (g *GCSStorage) do(method string, u *url.URL, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	return g.doWithHeader(method, u, body, size, contentType, nil)
+}
+
+func (g *GCSStorage) doWithHeader(method string, u *url.URL, body io.Reader, size int64, contentType string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(g.ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if g.privateKey != nil {
+		token, err := g.accessToken()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return g.client.Do(req)
+}
+
+func (g *GCSStorage) stat(object string) (*gcsObject, error) {
+	resp, err := g.do(http.MethodGet, g.objectURL(object, nil), nil, -1, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, convertHTTPStatus(resp)
+	}
+	var obj gcsObject
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// fetcher returns a function which reads the object starting at offset
+func (g *GCSStorage) fetcher(object string) func(offset int64) (io.ReadCloser, error) {
+	return func(offset int64) (io.ReadCloser, error) {
+		header := http.Header{}
+		if offset > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := g.doWithHeader(http.MethodGet, g.objectURL(object, url.Values{"alt": {"media"}}), nil, -1, "", header)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			defer resp.Body.Close()
+			return nil, convertHTTPStatus(resp)
+		}
+		return resp.Body, nil
+	}
+}
+
+// Open opens an object
+func (g *GCSStorage) Open(path string) (Object, error) {
+	object := g.buildGCSPath(path)
+	obj, err := g.stat(object)
+	if err != nil {
+		return nil, err
+	}
+	return newRemoteObject(obj.fileInfo(), g.fetcher(object)), nil
+}
+
+// Save saves an object with a simple media upload
+func (g *GCSStorage) Save(path string, r io.Reader, size int64) (int64, error) {
+	u := *g.endpoint
+	u.Path += "/upload/storage/v1/b/" + g.bucket + "/o"
+	u.RawQuery = url.Values{"uploadType": {"media"}, "name": {g.buildGCSPath(path)}}.Encode()
+
+	cr := &countingReader{r: r}
+	resp, err := g.do(http.MethodPost, &u, cr, size, "application/octet-stream")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, convertHTTPStatus(resp)
+	}
+	return cr.n, nil
+}
+
+// Stat returns the stat information of the object
+func (g *GCSStorage) Stat(path string) (os.FileInfo, error) {
+	obj, err := g.stat(g.buildGCSPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return obj.fileInfo(), nil
+}
+
+// Delete deletes an object
+func (g *GCSStorage) Delete(path string) error {
+	resp, err := g.do(http.MethodDelete, g.objectURL(g.buildGCSPath(path), nil), nil, -1, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return convertHTTPStatus(resp)
+	}
+	return nil
+}
+
+// URL gets the redirect URL to a file. The signed link is valid for 5 minutes.
+func (g *GCSStorage) URL(path, name string) (*url.URL, error) {
+	return g.signedURL(http.MethodGet, g.buildGCSPath(path), 5*time.Minute, url.Values{
+		"response-content-disposition": {"attachment; filename=\"" + quoteEscaper.Replace(name) + "\""},
+	})
+}
+
+// PresignGet returns a signed link to download the object
+func (g *GCSStorage) PresignGet(path string, expiry time.Duration) (*url.URL, error) {
+	return g.signedURL(http.MethodGet, g.buildGCSPath(path), expiry, nil)
+}
+
+// PresignPut returns a signed link to upload the object
+func (g *GCSStorage) PresignPut(path string, expiry time.Duration) (*url.URL, error) {
+	return g.signedURL(http.MethodPut, g.buildGCSPath(path), expiry, nil)
+}
+
+// gcsEscapePath escapes an object path like the canonical requests of signed URLs expect it
+func gcsEscapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = strings.ReplaceAll(url.QueryEscape(parts[i]), "+", "%20")
+	}
+	return strings.Join(parts, "/")
+}
+
+// signedURL returns a V4 signed URL of the object
+// https://cloud.google.com/storage/docs/access-control/signing-urls-manually
+func (g *GCSStorage) signedURL(method, object string, expiry time.Duration, query url.Values) (*url.URL, error) {
+	if g.privateKey == nil {
+		return nil, ErrURLNotSupported
+	}
+
+	now := time.Now().UTC()
+	datestamp := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	scope := datestamp + "/auto/storage/goog4_request"
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("X-Goog-Algorithm", "GOOG4-RSA-SHA256")
+	query.Set("X-Goog-Credential", g.clientEmail+"/"+scope)
+	query.Set("X-Goog-Date", timestamp)
+	query.Set("X-Goog-Expires", strconv.FormatInt(int64(expiry/time.Second), 10))
+	query.Set("X-Goog-SignedHeaders", "host")
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	canonicalQuery := make([]string, 0, len(keys))
+	for _, k := range keys {
+		canonicalQuery = append(canonicalQuery, strings.ReplaceAll(url.QueryEscape(k), "+", "%20")+"="+strings.ReplaceAll(url.QueryEscape(query.Get(k)), "+", "%20"))
+	}
+	rawQuery := strings.Join(canonicalQuery, "&")
+
+	escapedPath := g.endpoint.EscapedPath() + "/" + gcsEscapePath(g.bucket+"/"+object)
+	canonicalRequest := strings.Join([]string{
+		method,
+		escapedPath,
+		rawQuery,
+		"host:" + g.endpoint.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"GOOG4-RSA-SHA256",
+		timestamp,
+		scope,
+		hex.EncodeToString(hashedRequest[:]),
+	}, "\n")
+
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	u := *g.endpoint
+	u.RawPath = escapedPath
+	u.Path, _ = url.PathUnescape(escapedPath)
+	u.RawQuery = rawQuery + "&X-Goog-Signature=" + hex.EncodeToString(signature)
+	return &u, nil
+}
+
+// IterateObjects iterates across the objects in the google cloud storage
+func (g *GCSStorage) IterateObjects(fn func(path string, obj Object) error) error {
+	pageToken := ""
+	for {
+		select {
+		case <-g.ctx.Done():
+			return g.ctx.Err()
+		default:
+		}
+
+		query := url.Values{"prefix": {g.basePath}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		resp, err := g.do(http.MethodGet, g.jsonURL("/b/"+url.PathEscape(g.bucket)+"/o", query), nil, -1, "")
+		if err != nil {
+			return err
+		}
+		var list struct {
+			Items         []*gcsObject `json:"items"`
+			NextPageToken string       `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = convertHTTPStatus(resp)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&list)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, item := range list.Items {
+			obj := newRemoteObject(item.fileInfo(), g.fetcher(item.Name))
+			if err := func() error {
+				defer obj.Close()
+				return fn(strings.TrimPrefix(item.Name, g.basePath), obj)
+			}(); err != nil {
+				return err
+			}
+		}
+
+		if list.NextPageToken == "" {
+			return nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+func init() {
+	RegisterStorageType(GCSStorageType, NewGCSStorage)
+}
@@ -0,0 +1,210 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gitbundle/modules/json"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGCS is a minimal in-process stand-in for fake-gcs-server including the OAuth2 token endpoint
+type fakeGCS struct {
+	mu            sync.Mutex
+	objects       map[string][]byte
+	tokenRequests int
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		f.tokenRequests++
+		_ = r.ParseForm()
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.PostForm.Get("assertion") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "secret-token", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer secret-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeObject := func(name string) {
+		_ = json.NewEncoder(w).Encode(gcsObject{Name: name, Size: strconv.Itoa(len(f.objects[name])), Updated: time.Unix(0, 0)})
+	}
+
+	switch p := r.URL.Path; {
+	case p == "/storage/v1/b/bucket":
+	case p == "/upload/storage/v1/b/bucket/o":
+		name := r.URL.Query().Get("name")
+		f.objects[name], _ = io.ReadAll(r.Body)
+		writeObject(name)
+	case p == "/storage/v1/b/bucket/o":
+		prefix := r.URL.Query().Get("prefix")
+		names := []string{}
+		for name := range f.objects {
+			if strings.HasPrefix(name, prefix) && name >= r.URL.Query().Get("pageToken") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		// a single object per page to exercise the page token handling
+		list := map[string]interface{}{}
+		if len(names) > 0 {
+			list["items"] = []gcsObject{{Name: names[0], Size: strconv.Itoa(len(f.objects[names[0]]))}}
+		}
+		if len(names) > 1 {
+			list["nextPageToken"] = names[1]
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	case strings.HasPrefix(p, "/storage/v1/b/bucket/o/"):
+		name := strings.TrimPrefix(p, "/storage/v1/b/bucket/o/")
+		content, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			delete(f.objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Query().Get("alt") == "media":
+			http.ServeContent(w, r, name, time.Unix(0, 0), strings.NewReader(string(content)))
+		default:
+			writeObject(name)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newFakeGCSStorage(t *testing.T) (*GCSStorage, *fakeGCS, *rsa.PublicKey) {
+	fake := &fakeGCS{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	creds, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "gitbundle@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"token_uri":    server.URL + "/token",
+	})
+	assert.NoError(t, err)
+	credsFile := filepath.Join(t.TempDir(), "creds.json")
+	assert.NoError(t, os.WriteFile(credsFile, creds, 0o600))
+
+	s, err := NewGCSStorage(context.Background(), GCSStorageConfig{
+		Endpoint:        server.URL,
+		CredentialsFile: credsFile,
+		Bucket:          "bucket",
+		BasePath:        "base/",
+	})
+	assert.NoError(t, err)
+	return s.(*GCSStorage), fake, &key.PublicKey
+}
+
+func TestGCSStorage(t *testing.T) {
+	s, fake, _ := newFakeGCSStorage(t)
+
+	n, err := s.Save("a/b.txt", strings.NewReader("content"), 7)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	assert.Equal(t, "content", string(fake.objects["base/a/b.txt"]))
+
+	fi, err := s.Stat("a/b.txt")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, fi.Size())
+	assert.Equal(t, "b.txt", fi.Name())
+
+	f, err := s.Open("a/b.txt")
+	assert.NoError(t, err)
+	_, err = f.Seek(-4, io.SeekEnd)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "tent", string(content))
+	assert.NoError(t, f.Close())
+
+	_, err = s.Save("c.txt", strings.NewReader("other"), -1)
+	assert.NoError(t, err)
+
+	paths := map[string]string{}
+	assert.NoError(t, s.IterateObjects(func(path string, obj Object) error {
+		content, err := io.ReadAll(obj)
+		paths[path] = string(content)
+		return err
+	}))
+	assert.Equal(t, map[string]string{"a/b.txt": "content", "c.txt": "other"}, paths)
+
+	assert.NoError(t, s.Delete("a/b.txt"))
+	_, err = s.Stat("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// the access token is cached
+	assert.Equal(t, 1, fake.tokenRequests)
+}
+
+func TestGCSStorageSignedURL(t *testing.T) {
+	s, _, publicKey := newFakeGCSStorage(t)
+
+	u, err := s.URL("a/b c.txt", "b c.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "/bucket/base/a/b c.txt", u.Path)
+
+	query := u.Query()
+	assert.Equal(t, "GOOG4-RSA-SHA256", query.Get("X-Goog-Algorithm"))
+	assert.True(t, strings.HasPrefix(query.Get("X-Goog-Credential"), "gitbundle@project.iam.gserviceaccount.com/"))
+	assert.Equal(t, "300", query.Get("X-Goog-Expires"))
+	assert.Equal(t, "host", query.Get("X-Goog-SignedHeaders"))
+	assert.Equal(t, `attachment; filename="b c.txt"`, query.Get("response-content-disposition"))
+
+	// verify the signature against the canonical request
+	rawQuery, sig, _ := strings.Cut(u.RawQuery, "&X-Goog-Signature=")
+	canonicalRequest := "GET\n" + u.EscapedPath() + "\n" + rawQuery + "\nhost:" + u.Host + "\n\nhost\nUNSIGNED-PAYLOAD"
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	credential, _ := url.QueryUnescape(query.Get("X-Goog-Credential"))
+	_, scope, _ := strings.Cut(credential, "/")
+	digest := sha256.Sum256([]byte("GOOG4-RSA-SHA256\n" + query.Get("X-Goog-Date") + "\n" + scope + "\n" + hex.EncodeToString(hashedRequest[:])))
+	signature, err := hex.DecodeString(sig)
+	assert.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature))
+
+	u, err = s.PresignPut("a/b.txt", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "3600", u.Query().Get("X-Goog-Expires"))
+
+	s.privateKey = nil
+	_, err = s.PresignGet("a/b.txt", time.Hour)
+	assert.ErrorIs(t, err, ErrURLNotSupported)
+}
@@ -14,15 +14,19 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/gitbundle/modules/json"
 	"github.com/gitbundle/modules/log"
 	"github.com/gitbundle/modules/util"
 )
 
-var _ ObjectStorage = &LocalStorage{}
+var _ ExtendedObjectStorage = &LocalStorage{}
 
 // LocalStorageType is the type descriptor for local storage
 const LocalStorageType Type = "local"
 
+// localMetadataDir is the directory below the storage path which holds the metadata of the objects
+const localMetadataDir = ".metadata"
+
 // LocalStorageConfig represents the configuration for a local storage
 type LocalStorageConfig struct {
 	Path          string `ini:"PATH"`
@@ -71,6 +75,11 @@ func (l *LocalStorage) Open(path string) (Object, error) {
 
 // Save a file
 func (l *LocalStorage) Save(path string, r io.Reader, size int64) (int64, error) {
+	return l.SaveWithOptions(path, r, size, SaveOptions{})
+}
+
+// SaveWithOptions saves a file with its metadata. IfNotExists relies on hard links to be atomic.
+func (l *LocalStorage) SaveWithOptions(path string, r io.Reader, size int64, opts SaveOptions) (int64, error) {
 	p := l.buildLocalPath(path)
 	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
 		return 0, err
@@ -100,15 +109,127 @@ func (l *LocalStorage) Save(path string, r io.Reader, size int64) (int64, error)
 		return 0, err
 	}
 
-	if err := util.Rename(tmp.Name(), p); err != nil {
-		return 0, err
+	if opts.IfNotExists {
+		// linking fails if the target exists, renaming would replace it
+		if err := os.Link(tmp.Name(), p); err != nil {
+			if os.IsExist(err) {
+				return 0, os.ErrExist
+			}
+			return 0, err
+		}
+	} else {
+		if err := util.Rename(tmp.Name(), p); err != nil {
+			return 0, err
+		}
+		tmpRemoved = true
 	}
 
-	tmpRemoved = true
+	if err := l.saveMetadata(path, opts); err != nil {
+		return 0, err
+	}
 
 	return n, nil
 }
 
+func (l *LocalStorage) buildMetadataPath(p string) string {
+	return filepath.Join(l.dir, localMetadataDir, path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))[1:]+".json")
+}
+
+func (l *LocalStorage) saveMetadata(path string, opts SaveOptions) error {
+	p := l.buildMetadataPath(path)
+	if opts.ContentType == "" && len(opts.Metadata) == 0 {
+		if err := util.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(&ObjectMetadata{ContentType: opts.ContentType, Metadata: canonicalMetadata(opts.Metadata)})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+// GetMetadata returns the metadata of a file
+func (l *LocalStorage) GetMetadata(path string) (*ObjectMetadata, error) {
+	if _, err := l.Stat(path); err != nil {
+		return nil, err
+	}
+
+	metadata := &ObjectMetadata{}
+	data, err := os.ReadFile(l.buildMetadataPath(path))
+	if err == nil {
+		err = json.Unmarshal(data, metadata)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if metadata.ContentType == "" {
+		metadata.ContentType = defaultContentType
+	}
+	if metadata.Metadata == nil {
+		metadata.Metadata = map[string]string{}
+	}
+	return metadata, nil
+}
+
+// OpenRange reads a part of a file
+func (l *LocalStorage) OpenRange(path string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(l.buildLocalPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return newRangeReadCloser(f, offset, length)
+}
+
+// List lists the files of the local storage
+func (l *LocalStorage) List(opts ListOptions) (*ListResult, error) {
+	// only walk the directory which contains all paths with the prefix
+	root := l.dir
+	if dir := path.Dir(opts.Prefix); strings.Contains(opts.Prefix, "/") && dir != "." {
+		root = l.buildLocalPath(dir)
+	}
+
+	var objects []ObjectInfo
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			if l.isInternalDir(p) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !strings.HasPrefix(relPath, opts.Prefix) || (opts.StartAfter != "" && relPath <= opts.StartAfter) {
+			return nil
+		}
+		objects = append(objects, ObjectInfo{Path: relPath, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paginateObjects(objects, opts.Limit), nil
+}
+
+// isInternalDir checks if p is used by the storage itself and doesn't contain objects
+func (l *LocalStorage) isInternalDir(p string) bool {
+	return p == filepath.Join(l.dir, localMetadataDir) || p == filepath.Clean(l.tmpdir)
+}
+
 // Stat returns the info of the file
 func (l *LocalStorage) Stat(path string) (os.FileInfo, error) {
 	return os.Stat(l.buildLocalPath(path))
@@ -116,6 +237,9 @@ func (l *LocalStorage) Stat(path string) (os.FileInfo, error) {
 
 // Delete delete a file
 func (l *LocalStorage) Delete(path string) error {
+	if err := util.Remove(l.buildMetadataPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return util.Remove(l.buildLocalPath(path))
 }
 
@@ -139,6 +263,9 @@ func (l *LocalStorage) IterateObjects(fn func(path string, obj Object) error) er
 			return nil
 		}
 		if info.IsDir() {
+			if l.isInternalDir(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		relPath, err := filepath.Rel(l.dir, path)
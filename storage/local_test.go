@@ -6,6 +6,11 @@
 package storage
 
 import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -52,3 +57,95 @@ func TestBuildLocalPath(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalStorageExtended(t *testing.T) {
+	s, err := NewLocalStorage(context.Background(), LocalStorageConfig{Path: t.TempDir()})
+	assert.NoError(t, err)
+
+	_, err = SaveWithOptions(s, "dir/file.json", strings.NewReader("0123456789"), 10, SaveOptions{
+		ContentType: "application/json",
+		Metadata:    map[string]string{"digest": "sha256:abc"},
+		IfNotExists: true,
+	})
+	assert.NoError(t, err)
+
+	_, err = SaveWithOptions(s, "dir/file.json", strings.NewReader("other"), 5, SaveOptions{IfNotExists: true})
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	metadata, err := GetMetadata(s, "dir/file.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", metadata.ContentType)
+	assert.Equal(t, map[string]string{"Digest": "sha256:abc"}, metadata.Metadata)
+
+	rc, err := OpenRange(s, "dir/file.json", 2, 3)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "234", string(content))
+	assert.NoError(t, rc.Close())
+
+	for _, p := range []string{"dir/a", "dir/b", "dir-c", "other"} {
+		_, err = s.Save(p, strings.NewReader("x"), 1)
+		assert.NoError(t, err)
+	}
+
+	metadata, err = GetMetadata(s, "dir/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", metadata.ContentType)
+	assert.Empty(t, metadata.Metadata)
+
+	result, err := List(s, ListOptions{Prefix: "dir", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, result.Objects, 2)
+	assert.Equal(t, "dir-c", result.Objects[0].Path)
+	assert.Equal(t, "dir/a", result.Objects[1].Path)
+	assert.Equal(t, "dir/a", result.NextStartAfter)
+
+	result, err = List(s, ListOptions{Prefix: "dir/", StartAfter: result.NextStartAfter})
+	assert.NoError(t, err)
+	assert.Len(t, result.Objects, 2)
+	assert.Equal(t, "dir/b", result.Objects[0].Path)
+	assert.Equal(t, "dir/file.json", result.Objects[1].Path)
+	assert.EqualValues(t, 10, result.Objects[1].Size)
+	assert.Empty(t, result.NextStartAfter)
+
+	// the metadata is neither listed nor iterated and removed with the object
+	var paths []string
+	assert.NoError(t, s.IterateObjects(func(path string, obj Object) error {
+		paths = append(paths, filepath.ToSlash(path))
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"dir/a", "dir/b", "dir-c", "dir/file.json", "other"}, paths)
+
+	assert.NoError(t, s.Delete("dir/file.json"))
+	_, err = os.Stat(s.(*LocalStorage).buildMetadataPath("dir/file.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestListFallback(t *testing.T) {
+	local, err := NewLocalStorage(context.Background(), LocalStorageConfig{Path: t.TempDir()})
+	assert.NoError(t, err)
+	// hide the extended methods of the local storage
+	s := struct{ ObjectStorage }{local}
+
+	for _, p := range []string{"b", "a", "c"} {
+		_, err = s.Save(p, strings.NewReader(p), 1)
+		assert.NoError(t, err)
+	}
+
+	result, err := List(s, ListOptions{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, result.Objects, 2)
+	assert.Equal(t, "a", result.Objects[0].Path)
+	assert.Equal(t, "b", result.NextStartAfter)
+
+	_, err = SaveWithOptions(s, "a", strings.NewReader("x"), 1, SaveOptions{IfNotExists: true})
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	rc, err := OpenRange(s, "c", 0, -1)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", string(content))
+	assert.NoError(t, rc.Close())
+}
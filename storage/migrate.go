@@ -0,0 +1,169 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gitbundle/modules/log"
+)
+
+// MigrateOptions represents the options of a migration between two storages
+type MigrateOptions struct {
+	// Verify compares the SHA-256 of the source and the destination objects.
+	// Without it only the sizes are compared.
+	Verify bool
+	// Progress is called after every object
+	Progress func(MigrateProgress)
+}
+
+// MigrateProgress represents the state of a running migration
+type MigrateProgress struct {
+	// Path is the last handled object
+	Path    string
+	Copied  int64
+	Skipped int64
+	Failed  int64
+	Bytes   int64
+}
+
+// ErrMigrateFailed is returned if some objects couldn't be migrated
+type ErrMigrateFailed struct {
+	Failed map[string]error
+}
+
+func (err ErrMigrateFailed) Error() string {
+	return fmt.Sprintf("migration failed for %d objects", len(err.Failed))
+}
+
+// IsErrMigrateFailed checks if an error is an ErrMigrateFailed
+func IsErrMigrateFailed(err error) bool {
+	_, ok := err.(ErrMigrateFailed)
+	return ok
+}
+
+// Migrate copies every object of src to dst and checks the copies.
+// Objects which already exist with the same content in dst are skipped, so an
+// interrupted migration can simply be run again to resume it.
+// Failing objects don't stop the migration, they are reported with an ErrMigrateFailed.
+func Migrate(ctx context.Context, dst, src ObjectStorage, opts MigrateOptions) (MigrateProgress, error) {
+	var progress MigrateProgress
+	failed := make(map[string]error)
+
+	err := src.IterateObjects(func(path string, obj Object) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		progress.Path = path
+		copied, n, err := migrateObject(dst, path, obj, opts.Verify)
+		if err != nil {
+			log.Error("Unable to migrate %s: %v", path, err)
+			failed[path] = err
+			progress.Failed++
+		} else if copied {
+			progress.Copied++
+			progress.Bytes += n
+		} else {
+			progress.Skipped++
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		return nil
+	})
+	if err != nil {
+		return progress, err
+	}
+	if len(failed) > 0 {
+		return progress, ErrMigrateFailed{Failed: failed}
+	}
+	return progress, nil
+}
+
+// migrateObject copies obj to dst unless dst already has it and checks the result
+func migrateObject(dst ObjectStorage, path string, obj Object, verify bool) (bool, int64, error) {
+	fi, err := obj.Stat()
+	if err != nil {
+		return false, 0, err
+	}
+
+	var srcHash []byte
+	if verify {
+		if srcHash, err = hashObject(obj); err != nil {
+			return false, 0, err
+		}
+		if _, err := obj.Seek(0, io.SeekStart); err != nil {
+			return false, 0, err
+		}
+	}
+
+	same, err := sameObject(dst, path, fi.Size(), srcHash)
+	if err != nil {
+		return false, 0, err
+	}
+	if same {
+		return false, 0, nil
+	}
+
+	n, err := dst.Save(path, obj, fi.Size())
+	if err != nil {
+		return false, n, err
+	}
+
+	same, err = sameObject(dst, path, fi.Size(), srcHash)
+	if err != nil {
+		return true, n, err
+	}
+	if !same {
+		return true, n, fmt.Errorf("copy of %s doesn't match the source", path)
+	}
+	return true, n, nil
+}
+
+// sameObject checks if the object in s has the given size and, if provided, SHA-256
+func sameObject(s ObjectStorage, path string, size int64, hash []byte) (bool, error) {
+	fi, err := s.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if fi.Size() != size {
+		return false, nil
+	}
+	if hash == nil {
+		return true, nil
+	}
+
+	obj, err := s.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer obj.Close()
+
+	dstHash, err := hashObject(obj)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hash, dstHash), nil
+}
+
+func hashObject(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
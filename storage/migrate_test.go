@@ -0,0 +1,62 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	src := newTestLocalStorage(t)
+	dst := newTestLocalStorage(t)
+
+	for p, content := range map[string]string{"a/1": "one", "a/2": "two", "b/3": "three"} {
+		_, err := src.Save(p, strings.NewReader(content), int64(len(content)))
+		assert.NoError(t, err)
+	}
+	// an interrupted earlier run copied one object and left a broken copy of another
+	_, err := dst.Save("a/1", strings.NewReader("one"), 3)
+	assert.NoError(t, err)
+	_, err = dst.Save("a/2", strings.NewReader("tw0"), 3)
+	assert.NoError(t, err)
+
+	var reports []MigrateProgress
+	progress, err := Migrate(context.Background(), dst, src, MigrateOptions{
+		Verify: true,
+		Progress: func(p MigrateProgress) {
+			reports = append(reports, p)
+		},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, progress.Copied)
+	assert.EqualValues(t, 1, progress.Skipped)
+	assert.EqualValues(t, 0, progress.Failed)
+	assert.EqualValues(t, 8, progress.Bytes)
+	assert.Len(t, reports, 3)
+
+	assert.Equal(t, "two", readObject(t, dst, "a/2"))
+	assert.Equal(t, "three", readObject(t, dst, "b/3"))
+
+	// running it again has nothing to do
+	progress, err = Migrate(context.Background(), dst, src, MigrateOptions{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, progress.Copied)
+	assert.EqualValues(t, 3, progress.Skipped)
+
+	progress, err = Migrate(context.Background(), &failingStorage{newTestLocalStorage(t)}, src, MigrateOptions{})
+	assert.True(t, IsErrMigrateFailed(err))
+	assert.Len(t, err.(ErrMigrateFailed).Failed, 3)
+	assert.EqualValues(t, 3, progress.Failed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Migrate(ctx, newTestLocalStorage(t), src, MigrateOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -7,21 +7,29 @@ package storage
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"strings"
 	"time"
 
+	"github.com/gitbundle/modules/json"
 	"github.com/gitbundle/modules/log"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
 var (
-	_ ObjectStorage = &MinioStorage{}
+	_ ExtendedObjectStorage = &MinioStorage{}
+	_ PresignedStorage      = &MinioStorage{}
 
 	quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
 )
@@ -42,6 +50,17 @@ func (m *minioObject) Stat() (os.FileInfo, error) {
 // MinioStorageType is the type descriptor for minio storage
 const MinioStorageType Type = "minio"
 
+// Server side encryption types supported by the minio storage
+const (
+	MinioSSENone = ""
+	MinioSSES3   = "SSE-S3"
+	MinioSSEKMS  = "SSE-KMS"
+	MinioSSEC    = "SSE-C"
+)
+
+// minPartSize is the smallest part size S3 accepts for multipart uploads
+const minPartSize = 5 * 1024 * 1024
+
 // MinioStorageConfig represents the configuration for a minio storage
 type MinioStorageConfig struct {
 	Endpoint        string `ini:"MINIO_ENDPOINT"`
@@ -51,14 +70,42 @@ type MinioStorageConfig struct {
 	Location        string `ini:"MINIO_LOCATION"`
 	BasePath        string `ini:"MINIO_BASE_PATH"`
 	UseSSL          bool   `ini:"MINIO_USE_SSL"`
+	// Region is the region requests are signed for, empty lets the client look up the region of the bucket.
+	// MINIO_LOCATION is only used to create the bucket.
+	Region string `ini:"MINIO_REGION"`
+
+	// CACertFile is a PEM bundle of additional trusted certificate authorities
+	CACertFile         string `ini:"MINIO_CA_CERT_FILE"`
+	InsecureSkipVerify bool   `ini:"MINIO_INSECURE_SKIP_VERIFY"`
+	// BucketLookupType is one of "auto", "dns" (virtual-host style) or "path"
+	BucketLookupType string `ini:"MINIO_BUCKET_LOOKUP_TYPE"`
+
+	// PartSize is the size in bytes of the parts of multipart uploads, 0 lets the client choose
+	PartSize uint64 `ini:"MINIO_PART_SIZE"`
+	// UploadConcurrency is the number of parts uploaded in parallel, 0 uses the client default
+	UploadConcurrency uint   `ini:"MINIO_UPLOAD_CONCURRENCY"`
+	StorageClass      string `ini:"MINIO_STORAGE_CLASS"`
+
+	// SSEType is one of "", "SSE-S3", "SSE-KMS" or "SSE-C"
+	SSEType string `ini:"MINIO_SSE_TYPE"`
+	// SSEKMSKeyID is the KMS key used for SSE-KMS, empty uses the bucket default
+	SSEKMSKeyID string `ini:"MINIO_SSE_KMS_KEY_ID"`
+	// SSEKMSContext is an optional JSON object used as the SSE-KMS encryption context
+	SSEKMSContext string `ini:"MINIO_SSE_KMS_CONTEXT"`
+	// SSECustomerKey is the base64 encoded 256 bit key used for SSE-C
+	SSECustomerKey string `ini:"MINIO_SSE_C_KEY"`
 }
 
 // MinioStorage returns a minio bucket storage
 type MinioStorage struct {
-	ctx      context.Context
-	client   *minio.Client
-	bucket   string
-	basePath string
+	ctx          context.Context
+	client       *minio.Client
+	bucket       string
+	basePath     string
+	partSize     uint64
+	numThreads   uint
+	storageClass string
+	sse          encrypt.ServerSide
 }
 
 func convertMinioErr(err error) error {
@@ -91,9 +138,44 @@ func NewMinioStorage(ctx context.Context, cfg interface{}) (ObjectStorage, error
 
 	log.Info("Creating Minio storage at %s:%s with base path %s", config.Endpoint, config.Bucket, config.BasePath)
 
+	if config.PartSize != 0 && config.PartSize < minPartSize {
+		return nil, ErrInvalidConfiguration{cfg: cfg, err: fmt.Errorf("part size %d is smaller than %d", config.PartSize, minPartSize)}
+	}
+
+	lookup, err := minioBucketLookupType(config.BucketLookupType)
+	if err != nil {
+		return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+	}
+
+	sse, err := minioServerSideEncryption(config)
+	if err != nil {
+		return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+	}
+
+	transport, err := minio.DefaultTransport(config.UseSSL)
+	if err != nil {
+		return nil, err
+	}
+	if config.UseSSL && (config.CACertFile != "" || config.InsecureSkipVerify) {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = config.InsecureSkipVerify
+		if config.CACertFile != "" {
+			pool, err := minioCertPool(config.CACertFile)
+			if err != nil {
+				return nil, ErrInvalidConfiguration{cfg: cfg, err: err}
+			}
+			transport.TLSClientConfig.RootCAs = pool
+		}
+	}
+
 	minioClient, err := minio.New(config.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
-		Secure: config.UseSSL,
+		Creds:        credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
+		Secure:       config.UseSSL,
+		Transport:    transport,
+		Region:       config.Region,
+		BucketLookup: lookup,
 	})
 	if err != nil {
 		return nil, convertMinioErr(err)
@@ -110,20 +192,83 @@ func NewMinioStorage(ctx context.Context, cfg interface{}) (ObjectStorage, error
 	}
 
 	return &MinioStorage{
-		ctx:      ctx,
-		client:   minioClient,
-		bucket:   config.Bucket,
-		basePath: config.BasePath,
+		ctx:          ctx,
+		client:       minioClient,
+		bucket:       config.Bucket,
+		basePath:     config.BasePath,
+		partSize:     config.PartSize,
+		numThreads:   config.UploadConcurrency,
+		storageClass: config.StorageClass,
+		sse:          sse,
 	}, nil
 }
 
+func minioBucketLookupType(typ string) (minio.BucketLookupType, error) {
+	switch strings.ToLower(typ) {
+	case "", "auto":
+		return minio.BucketLookupAuto, nil
+	case "dns", "virtual-host":
+		return minio.BucketLookupDNS, nil
+	case "path":
+		return minio.BucketLookupPath, nil
+	}
+	return minio.BucketLookupAuto, fmt.Errorf("unknown bucket lookup type: %s", typ)
+}
+
+func minioServerSideEncryption(config MinioStorageConfig) (encrypt.ServerSide, error) {
+	switch strings.ToUpper(config.SSEType) {
+	case MinioSSENone:
+		return nil, nil
+	case MinioSSES3:
+		return encrypt.NewSSE(), nil
+	case MinioSSEKMS:
+		var kmsContext interface{}
+		if config.SSEKMSContext != "" {
+			if err := json.Unmarshal([]byte(config.SSEKMSContext), &kmsContext); err != nil {
+				return nil, fmt.Errorf("invalid SSE-KMS context: %w", err)
+			}
+		}
+		return encrypt.NewSSEKMS(config.SSEKMSKeyID, kmsContext)
+	case MinioSSEC:
+		key, err := base64.StdEncoding.DecodeString(config.SSECustomerKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSE-C key: %w", err)
+		}
+		return encrypt.NewSSEC(key)
+	}
+	return nil, fmt.Errorf("unknown server side encryption type: %s", config.SSEType)
+}
+
+func minioCertPool(caCertFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caCertFile)
+	}
+	return pool, nil
+}
+
+// customerKey returns the SSE-C key which has to be sent with every read of an object
+func (m *MinioStorage) customerKey() encrypt.ServerSide {
+	if m.sse != nil && m.sse.Type() == encrypt.SSEC {
+		return m.sse
+	}
+	return nil
+}
+
 func (m *MinioStorage) buildMinioPath(p string) string {
 	return strings.TrimPrefix(path.Join(m.basePath, path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))[1:]), "/")
 }
 
 // Open open a file
 func (m *MinioStorage) Open(path string) (Object, error) {
-	opts := minio.GetObjectOptions{}
+	opts := minio.GetObjectOptions{ServerSideEncryption: m.customerKey()}
 	object, err := m.client.GetObject(m.ctx, m.bucket, m.buildMinioPath(path), opts)
 	if err != nil {
 		return nil, convertMinioErr(err)
@@ -133,13 +278,39 @@ func (m *MinioStorage) Open(path string) (Object, error) {
 
 // Save save a file to minio
 func (m *MinioStorage) Save(path string, r io.Reader, size int64) (int64, error) {
+	return m.SaveWithOptions(path, r, size, SaveOptions{})
+}
+
+// SaveWithOptions saves a file to minio with its metadata.
+// The minio client doesn't support conditional puts, IfNotExists is checked before the upload
+// and is not atomic.
+func (m *MinioStorage) SaveWithOptions(path string, r io.Reader, size int64, opts SaveOptions) (int64, error) {
+	if opts.IfNotExists {
+		if _, err := m.Stat(path); err == nil {
+			return 0, os.ErrExist
+		} else if !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = defaultContentType
+	}
 	uploadInfo, err := m.client.PutObject(
 		m.ctx,
 		m.bucket,
 		m.buildMinioPath(path),
 		r,
 		size,
-		minio.PutObjectOptions{ContentType: "application/octet-stream"},
+		minio.PutObjectOptions{
+			ContentType:          contentType,
+			UserMetadata:         opts.Metadata,
+			PartSize:             m.partSize,
+			NumThreads:           m.numThreads,
+			StorageClass:         m.storageClass,
+			ServerSideEncryption: m.sse,
+		},
 	)
 	if err != nil {
 		return 0, convertMinioErr(err)
@@ -147,6 +318,89 @@ func (m *MinioStorage) Save(path string, r io.Reader, size int64) (int64, error)
 	return uploadInfo.Size, nil
 }
 
+// GetMetadata returns the metadata of a file
+func (m *MinioStorage) GetMetadata(path string) (*ObjectMetadata, error) {
+	info, err := m.client.StatObject(
+		m.ctx,
+		m.bucket,
+		m.buildMinioPath(path),
+		minio.StatObjectOptions{ServerSideEncryption: m.customerKey()},
+	)
+	if err != nil {
+		return nil, convertMinioErr(err)
+	}
+	metadata := make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		if key := http.CanonicalHeaderKey(k); strings.HasPrefix(key, "X-Amz-Meta-") && len(v) > 0 {
+			metadata[strings.TrimPrefix(key, "X-Amz-Meta-")] = v[0]
+		}
+	}
+	return &ObjectMetadata{ContentType: info.ContentType, Metadata: metadata}, nil
+}
+
+// OpenRange reads a part of a file
+func (m *MinioStorage) OpenRange(path string, offset, length int64) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{ServerSideEncryption: m.customerKey()}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	} else if length > 0 {
+		if err := opts.SetRange(offset, offset+length-1); err != nil {
+			return nil, err
+		}
+	} else if offset > 0 {
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, err
+		}
+	}
+	object, err := m.client.GetObject(m.ctx, m.bucket, m.buildMinioPath(path), opts)
+	if err != nil {
+		return nil, convertMinioErr(err)
+	}
+	return &minioRangeReader{object}, nil
+}
+
+// minioRangeReader converts the errors of the lazily opened object
+type minioRangeReader struct {
+	*minio.Object
+}
+
+func (r *minioRangeReader) Read(p []byte) (int, error) {
+	n, err := r.Object.Read(p)
+	if err == io.EOF {
+		return n, err
+	}
+	return n, convertMinioErr(err)
+}
+
+// List lists the files of the minio storage
+func (m *MinioStorage) List(opts ListOptions) (*ListResult, error) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+
+	listOpts := minio.ListObjectsOptions{
+		Prefix:    m.basePath + opts.Prefix,
+		Recursive: true,
+	}
+	if opts.StartAfter != "" {
+		listOpts.StartAfter = m.basePath + opts.StartAfter
+	}
+	if opts.Limit > 0 {
+		listOpts.MaxKeys = opts.Limit + 1
+	}
+
+	var objects []ObjectInfo
+	for info := range m.client.ListObjects(ctx, m.bucket, listOpts) {
+		if info.Err != nil {
+			return nil, convertMinioErr(info.Err)
+		}
+		objects = append(objects, ObjectInfo{Path: strings.TrimPrefix(info.Key, m.basePath), Size: info.Size, ModTime: info.LastModified})
+		if opts.Limit > 0 && len(objects) > opts.Limit {
+			break
+		}
+	}
+	return paginateObjects(objects, opts.Limit), nil
+}
+
 type minioFileInfo struct {
 	minio.ObjectInfo
 }
@@ -181,7 +435,7 @@ func (m *MinioStorage) Stat(path string) (os.FileInfo, error) {
 		m.ctx,
 		m.bucket,
 		m.buildMinioPath(path),
-		minio.StatObjectOptions{},
+		minio.StatObjectOptions{ServerSideEncryption: m.customerKey()},
 	)
 	if err != nil {
 		return nil, convertMinioErr(err)
@@ -197,7 +451,11 @@ func (m *MinioStorage) Delete(path string) error {
 }
 
 // URL gets the redirect URL to a file. The presigned link is valid for 5 minutes.
+// Objects encrypted with SSE-C can't be served by a plain link.
 func (m *MinioStorage) URL(path, name string) (*url.URL, error) {
+	if m.customerKey() != nil {
+		return nil, ErrURLNotSupported
+	}
 	reqParams := make(url.Values)
 	// TODO it may be good to embed images with 'inline' like ServeData does, but we don't want to have to read the file, do we?
 	reqParams.Set("response-content-disposition", "attachment; filename=\""+quoteEscaper.Replace(name)+"\"")
@@ -205,9 +463,28 @@ func (m *MinioStorage) URL(path, name string) (*url.URL, error) {
 	return u, convertMinioErr(err)
 }
 
+// PresignGet returns a presigned link to download the object
+func (m *MinioStorage) PresignGet(path string, expiry time.Duration) (*url.URL, error) {
+	if m.customerKey() != nil {
+		return nil, ErrURLNotSupported
+	}
+	u, err := m.client.PresignedGetObject(m.ctx, m.bucket, m.buildMinioPath(path), expiry, nil)
+	return u, convertMinioErr(err)
+}
+
+// PresignPut returns a presigned link to upload the object
+func (m *MinioStorage) PresignPut(path string, expiry time.Duration) (*url.URL, error) {
+	if m.sse != nil {
+		// the encryption headers are not part of the presigned link
+		return nil, ErrURLNotSupported
+	}
+	u, err := m.client.PresignedPutObject(m.ctx, m.bucket, m.buildMinioPath(path), expiry)
+	return u, convertMinioErr(err)
+}
+
 // IterateObjects iterates across the objects in the miniostorage
 func (m *MinioStorage) IterateObjects(fn func(path string, obj Object) error) error {
-	opts := minio.GetObjectOptions{}
+	opts := minio.GetObjectOptions{ServerSideEncryption: m.customerKey()}
 	lobjectCtx, cancel := context.WithCancel(m.ctx)
 	defer cancel()
 	for mObjInfo := range m.client.ListObjects(lobjectCtx, m.bucket, minio.ListObjectsOptions{
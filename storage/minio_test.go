@@ -0,0 +1,387 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a minimal in-process S3 server which supports the requests the minio storage makes
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	headers  map[string]http.Header
+	uploads  map[string]map[int][]byte
+	requests []*http.Request
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects: make(map[string][]byte),
+		headers: make(map[string]http.Header),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func (f *fakeS3) requestsWith(method, query string) []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var res []*http.Request
+	for _, r := range f.requests {
+		if r.Method == method && (query == "" || r.URL.Query().Has(query)) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, r)
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) == 1 || parts[1] == "" {
+		f.serveBucket(w, r)
+		return
+	}
+	key := parts[1]
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[uploadID] = make(map[int][]byte)
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: parts[0], Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, partNumber))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		upload := f.uploads[query.Get("uploadId")]
+		numbers := make([]int, 0, len(upload))
+		for n := range upload {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var content []byte
+		for _, n := range numbers {
+			content = append(content, upload[n]...)
+		}
+		f.objects[key] = content
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: parts[0], Key: key, ETag: `"etag"`})
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.headers[key] = http.Header{}
+		for k, v := range r.Header {
+			if k == "Content-Type" || strings.HasPrefix(k, "X-Amz-Meta-") {
+				f.headers[key][k] = v
+			}
+		}
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		content, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				writeXML(w, minio.ErrorResponse{Code: "NoSuchKey", Message: "The specified key does not exist.", Key: key})
+			}
+			return
+		}
+		for k, v := range f.headers[key] {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		http.ServeContent(w, r, key, time.Unix(0, 0), bytes.NewReader(content))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) serveBucket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		return
+	}
+
+	type content struct {
+		Key          string
+		Size         int64
+		ETag         string
+		LastModified time.Time
+	}
+	prefix := r.URL.Query().Get("prefix")
+	startAfter := r.URL.Query().Get("start-after")
+	result := struct {
+		XMLName  xml.Name `xml:"ListBucketResult"`
+		Name     string
+		Prefix   string
+		KeyCount int
+		Contents []content
+	}{Prefix: prefix}
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && k > startAfter {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		result.Contents = append(result.Contents, content{Key: k, Size: int64(len(f.objects[k])), ETag: `"etag"`, LastModified: time.Unix(0, 0).UTC()})
+	}
+	result.KeyCount = len(result.Contents)
+	writeXML(w, result)
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func newFakeMinioStorage(t *testing.T, config MinioStorageConfig) (*MinioStorage, *fakeS3) {
+	fake := newFakeS3()
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	config.Endpoint = strings.TrimPrefix(server.URL, "https://")
+	config.AccessKeyID = "access"
+	config.SecretAccessKey = "secret"
+	config.Bucket = "bucket"
+	config.Location = "us-east-1"
+	config.Region = "us-east-1"
+	config.BasePath = "base/"
+	config.UseSSL = true
+	config.CACertFile = caFile
+
+	s, err := NewMinioStorage(context.Background(), config)
+	assert.NoError(t, err)
+	return s.(*MinioStorage), fake
+}
+
+func TestMinioStorage(t *testing.T) {
+	s, fake := newFakeMinioStorage(t, MinioStorageConfig{BucketLookupType: "path"})
+
+	n, err := s.Save("a/b.txt", strings.NewReader("content"), 7)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	assert.Contains(t, fake.objects, "base/a/b.txt")
+
+	fi, err := s.Stat("a/b.txt")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, fi.Size())
+	assert.Equal(t, "b.txt", fi.Name())
+
+	f, err := s.Open("a/b.txt")
+	assert.NoError(t, err)
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.NoError(t, f.Close())
+
+	var paths []string
+	assert.NoError(t, s.IterateObjects(func(path string, obj Object) error {
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.Equal(t, []string{"a/b.txt"}, paths)
+
+	assert.NoError(t, s.Delete("a/b.txt"))
+	_, err = s.Stat("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	for _, r := range fake.requestsWith(http.MethodPut, "") {
+		assert.True(t, strings.HasPrefix(r.URL.Path, "/bucket"), "expected path-style request: %s", r.URL.Path)
+	}
+}
+
+func TestMinioStorageMultipart(t *testing.T) {
+	s, fake := newFakeMinioStorage(t, MinioStorageConfig{
+		PartSize:          minPartSize,
+		UploadConcurrency: 2,
+		StorageClass:      "STANDARD_IA",
+	})
+
+	data := bytes.Repeat([]byte("0123456789"), (2*minPartSize+1024)/10)
+	n, err := s.Save("large", bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(data), n)
+
+	assert.Len(t, fake.requestsWith(http.MethodPut, "partNumber"), 3)
+	initiate := fake.requestsWith(http.MethodPost, "uploads")
+	assert.Len(t, initiate, 1)
+	assert.Equal(t, "STANDARD_IA", initiate[0].Header.Get("X-Amz-Storage-Class"))
+	assert.Equal(t, data, fake.objects["base/large"])
+}
+
+func TestMinioStorageServerSideEncryption(t *testing.T) {
+	t.Run("SSE-S3", func(t *testing.T) {
+		s, fake := newFakeMinioStorage(t, MinioStorageConfig{SSEType: MinioSSES3})
+
+		_, err := s.Save("file", strings.NewReader("content"), 7)
+		assert.NoError(t, err)
+
+		puts := fake.requestsWith(http.MethodPut, "")
+		assert.Equal(t, "AES256", puts[len(puts)-1].Header.Get("X-Amz-Server-Side-Encryption"))
+
+		_, err = s.PresignPut("file", time.Minute)
+		assert.ErrorIs(t, err, ErrURLNotSupported)
+		_, err = s.PresignGet("file", time.Minute)
+		assert.NoError(t, err)
+	})
+
+	t.Run("SSE-KMS", func(t *testing.T) {
+		s, fake := newFakeMinioStorage(t, MinioStorageConfig{SSEType: MinioSSEKMS, SSEKMSKeyID: "my-key", SSEKMSContext: `{"app":"gitbundle"}`})
+
+		_, err := s.Save("file", strings.NewReader("content"), 7)
+		assert.NoError(t, err)
+
+		puts := fake.requestsWith(http.MethodPut, "")
+		header := puts[len(puts)-1].Header
+		assert.Equal(t, "aws:kms", header.Get("X-Amz-Server-Side-Encryption"))
+		assert.Equal(t, "my-key", header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+		kmsContext, err := base64.StdEncoding.DecodeString(header.Get("X-Amz-Server-Side-Encryption-Context"))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"app":"gitbundle"}`, string(kmsContext))
+	})
+
+	t.Run("SSE-C", func(t *testing.T) {
+		key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+		s, fake := newFakeMinioStorage(t, MinioStorageConfig{SSEType: MinioSSEC, SSECustomerKey: key})
+
+		_, err := s.Save("file", strings.NewReader("content"), 7)
+		assert.NoError(t, err)
+		_, err = s.Stat("file")
+		assert.NoError(t, err)
+
+		for _, method := range []string{http.MethodPut, http.MethodHead} {
+			requests := fake.requestsWith(method, "")
+			assert.Equal(t, "AES256", requests[len(requests)-1].Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"), method)
+		}
+
+		_, err = s.URL("file", "file")
+		assert.ErrorIs(t, err, ErrURLNotSupported)
+		_, err = s.PresignGet("file", time.Minute)
+		assert.ErrorIs(t, err, ErrURLNotSupported)
+	})
+}
+
+func TestMinioStorageInvalidConfiguration(t *testing.T) {
+	for _, config := range []MinioStorageConfig{
+		{Endpoint: "localhost:9000", PartSize: 1024},
+		{Endpoint: "localhost:9000", BucketLookupType: "unknown"},
+		{Endpoint: "localhost:9000", SSEType: "SSE-UNKNOWN"},
+		{Endpoint: "localhost:9000", SSEType: MinioSSEC, SSECustomerKey: "c2hvcnQ="},
+		{Endpoint: "localhost:9000", SSEType: MinioSSEKMS, SSEKMSContext: "{"},
+		{Endpoint: "localhost:9000", UseSSL: true, CACertFile: "/does/not/exist.pem"},
+	} {
+		_, err := NewMinioStorage(context.Background(), config)
+		assert.True(t, IsErrInvalidConfiguration(err), "%+v: %v", config, err)
+	}
+}
+
+func TestMinioBucketLookupType(t *testing.T) {
+	for typ, expected := range map[string]minio.BucketLookupType{
+		"":             minio.BucketLookupAuto,
+		"auto":         minio.BucketLookupAuto,
+		"dns":          minio.BucketLookupDNS,
+		"virtual-host": minio.BucketLookupDNS,
+		"Path":         minio.BucketLookupPath,
+	} {
+		lookup, err := minioBucketLookupType(typ)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, lookup, typ)
+	}
+}
+
+func TestMinioStorageExtended(t *testing.T) {
+	s, _ := newFakeMinioStorage(t, MinioStorageConfig{})
+
+	_, err := s.SaveWithOptions("dir/file.json", strings.NewReader("0123456789"), 10, SaveOptions{
+		ContentType: "application/json",
+		Metadata:    map[string]string{"digest": "sha256:abc"},
+		IfNotExists: true,
+	})
+	assert.NoError(t, err)
+
+	_, err = s.SaveWithOptions("dir/file.json", strings.NewReader("other"), 5, SaveOptions{IfNotExists: true})
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	metadata, err := s.GetMetadata("dir/file.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", metadata.ContentType)
+	assert.Equal(t, map[string]string{"Digest": "sha256:abc"}, metadata.Metadata)
+
+	rc, err := s.OpenRange("dir/file.json", 2, 3)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "234", string(content))
+	assert.NoError(t, rc.Close())
+
+	rc, err = s.OpenRange("dir/file.json", 7, -1)
+	assert.NoError(t, err)
+	content, err = io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "789", string(content))
+	assert.NoError(t, rc.Close())
+
+	for _, p := range []string{"dir/a", "dir/b", "other"} {
+		_, err = s.Save(p, strings.NewReader("x"), 1)
+		assert.NoError(t, err)
+	}
+
+	result, err := s.List(ListOptions{Prefix: "dir/", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, result.Objects, 2)
+	assert.Equal(t, "dir/a", result.Objects[0].Path)
+	assert.Equal(t, "dir/b", result.Objects[1].Path)
+	assert.Equal(t, "dir/b", result.NextStartAfter)
+
+	result, err = s.List(ListOptions{Prefix: "dir/", Limit: 2, StartAfter: result.NextStartAfter})
+	assert.NoError(t, err)
+	assert.Len(t, result.Objects, 1)
+	assert.Equal(t, "dir/file.json", result.Objects[0].Path)
+	assert.EqualValues(t, 10, result.Objects[0].Size)
+	assert.Empty(t, result.NextStartAfter)
+}
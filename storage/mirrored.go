@@ -0,0 +1,169 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gitbundle/modules/log"
+)
+
+var (
+	_ ExtendedObjectStorage        = &MirroredStorage{}
+	_ PresignedUploadHeaderStorage = &MirroredStorage{}
+)
+
+// MirroredStorage writes every object to two storages and reads from the primary one,
+// falling back to the secondary one for objects the primary doesn't have (yet).
+// It allows switching backends without downtime: mirror to the new backend,
+// migrate the existing objects with Migrate and finally swap the storages.
+//
+// The primary storage is the source of truth, failures of the secondary storage are
+// only logged so they can be repaired by a later migration run. Presigned links are
+// handed out by the primary storage, objects uploaded through them are only copied
+// to the secondary storage by a migration run.
+type MirroredStorage struct {
+	primary   ObjectStorage
+	secondary ObjectStorage
+}
+
+// NewMirroredStorage returns a storage mirroring all writes of primary to secondary
+func NewMirroredStorage(primary, secondary ObjectStorage) *MirroredStorage {
+	return &MirroredStorage{primary: primary, secondary: secondary}
+}
+
+// Open opens the object of the primary storage or the secondary one if it doesn't exist
+func (m *MirroredStorage) Open(path string) (Object, error) {
+	obj, err := m.primary.Open(path)
+	if os.IsNotExist(err) {
+		return m.secondary.Open(path)
+	}
+	return obj, err
+}
+
+// Save saves the object to both storages, the content is streamed to both at once
+func (m *MirroredStorage) Save(path string, r io.Reader, size int64) (int64, error) {
+	return m.SaveWithOptions(path, r, size, SaveOptions{})
+}
+
+// SaveWithOptions saves the object with its options to both storages, the content is streamed to both at once.
+// IfNotExists is only checked by the primary storage.
+func (m *MirroredStorage) SaveWithOptions(path string, r io.Reader, size int64, opts SaveOptions) (int64, error) {
+	secondaryOpts := opts
+	secondaryOpts.IfNotExists = false
+
+	pr, pw := io.Pipe()
+	secondaryErr := make(chan error, 1)
+	go func() {
+		_, err := SaveWithOptions(m.secondary, path, pr, size, secondaryOpts)
+		// make sure the primary is never blocked by a failed secondary
+		_ = pr.CloseWithError(err)
+		secondaryErr <- err
+	}()
+
+	n, err := SaveWithOptions(m.primary, path, io.TeeReader(r, &ignoreErrorWriter{w: pw}), size, opts)
+	if err != nil {
+		_ = pw.CloseWithError(err)
+		<-secondaryErr
+		return n, err
+	}
+	_ = pw.Close()
+
+	if err := <-secondaryErr; err != nil {
+		log.Error("Unable to mirror %s to the secondary storage: %v", path, err)
+	}
+	return n, nil
+}
+
+// Stat returns the stat information of the object
+func (m *MirroredStorage) Stat(path string) (os.FileInfo, error) {
+	fi, err := m.primary.Stat(path)
+	if os.IsNotExist(err) {
+		return m.secondary.Stat(path)
+	}
+	return fi, err
+}
+
+// OpenRange reads a range of the object of the primary storage or the secondary one if it doesn't exist
+func (m *MirroredStorage) OpenRange(path string, offset, length int64) (io.ReadCloser, error) {
+	rc, err := OpenRange(m.primary, path, offset, length)
+	if os.IsNotExist(err) {
+		return OpenRange(m.secondary, path, offset, length)
+	}
+	return rc, err
+}
+
+// GetMetadata returns the metadata of the object of the primary storage or the secondary one if it doesn't exist
+func (m *MirroredStorage) GetMetadata(path string) (*ObjectMetadata, error) {
+	metadata, err := GetMetadata(m.primary, path)
+	if os.IsNotExist(err) {
+		return GetMetadata(m.secondary, path)
+	}
+	return metadata, err
+}
+
+// List lists the objects of the primary storage
+func (m *MirroredStorage) List(opts ListOptions) (*ListResult, error) {
+	return List(m.primary, opts)
+}
+
+// Delete deletes the object from both storages
+func (m *MirroredStorage) Delete(path string) error {
+	if err := m.primary.Delete(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := m.secondary.Delete(path); err != nil && !os.IsNotExist(err) {
+		log.Error("Unable to delete %s from the secondary storage: %v", path, err)
+	}
+	return nil
+}
+
+// URL gets the redirect URL of the primary storage
+func (m *MirroredStorage) URL(path, name string) (*url.URL, error) {
+	return m.primary.URL(path, name)
+}
+
+// PresignGet returns a presigned download link of the primary storage or ErrURLNotSupported
+func (m *MirroredStorage) PresignGet(path string, expiry time.Duration) (*url.URL, error) {
+	if ps, ok := m.primary.(PresignedStorage); ok {
+		return ps.PresignGet(path, expiry)
+	}
+	return nil, ErrURLNotSupported
+}
+
+// PresignPut returns a presigned upload link of the primary storage or ErrURLNotSupported
+func (m *MirroredStorage) PresignPut(path string, expiry time.Duration) (*url.URL, error) {
+	if ps, ok := m.primary.(PresignedStorage); ok {
+		return ps.PresignPut(path, expiry)
+	}
+	return nil, ErrURLNotSupported
+}
+
+// PresignPutHeaders returns the headers required by the presigned upload links of the primary storage
+func (m *MirroredStorage) PresignPutHeaders() map[string]string {
+	return presignPutHeaders(m.primary)
+}
+
+// IterateObjects iterates across the objects of the primary storage
+func (m *MirroredStorage) IterateObjects(fn func(path string, obj Object) error) error {
+	return m.primary.IterateObjects(fn)
+}
+
+// ignoreErrorWriter stops writing to w after the first error but always reports success
+type ignoreErrorWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (i *ignoreErrorWriter) Write(p []byte) (int, error) {
+	if i.err == nil {
+		_, i.err = i.w.Write(p)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStorage struct {
+	ObjectStorage
+}
+
+func (f *failingStorage) Save(path string, r io.Reader, size int64) (int64, error) {
+	return 0, errors.New("broken")
+}
+
+func TestMirroredStorage(t *testing.T) {
+	primary := newTestLocalStorage(t)
+	secondary := newTestLocalStorage(t)
+	m := NewMirroredStorage(primary, secondary)
+
+	n, err := m.Save("a/b.txt", strings.NewReader("content"), -1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	assert.Equal(t, "content", readObject(t, primary, "a/b.txt"))
+	assert.Equal(t, "content", readObject(t, secondary, "a/b.txt"))
+
+	// objects only known to the secondary storage are still readable
+	_, err = secondary.Save("c.txt", strings.NewReader("other"), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", readObject(t, m, "c.txt"))
+	fi, err := m.Stat("c.txt")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, fi.Size())
+
+	assert.NoError(t, m.Delete("a/b.txt"))
+	_, err = primary.Stat("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = secondary.Stat("a/b.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// a broken secondary storage doesn't break writes
+	m = NewMirroredStorage(primary, &failingStorage{secondary})
+	_, err = m.Save("d.txt", strings.NewReader(strings.Repeat("x", 1<<20)), 1<<20)
+	assert.NoError(t, err)
+	fi, err = primary.Stat("d.txt")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1<<20, fi.Size())
+}
+
+func TestMirroredStoragePresign(t *testing.T) {
+	m := NewMirroredStorage(&presignedTestStorage{newTestLocalStorage(t)}, newTestLocalStorage(t))
+	u, err := m.PresignPut("a", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://bucket.example.com/a?upload=1", u.String())
+	assert.Equal(t, map[string]string{"x-ms-blob-type": "BlockBlob"}, m.PresignPutHeaders())
+
+	m = NewMirroredStorage(newTestLocalStorage(t), &presignedTestStorage{newTestLocalStorage(t)})
+	_, err = m.PresignGet("a", time.Minute)
+	assert.ErrorIs(t, err, ErrURLNotSupported)
+}
+
+func TestMirroredStorageExtended(t *testing.T) {
+	primary := newTestLocalStorage(t)
+	secondary := newTestLocalStorage(t)
+	m := NewMirroredStorage(primary, secondary)
+
+	_, err := m.SaveWithOptions("a.txt", strings.NewReader("content"), 7, SaveOptions{IfNotExists: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "content", readObject(t, secondary, "a.txt"))
+	_, err = m.SaveWithOptions("a.txt", strings.NewReader("other"), 5, SaveOptions{IfNotExists: true})
+	assert.ErrorIs(t, err, os.ErrExist)
+	assert.Equal(t, "content", readObject(t, secondary, "a.txt"))
+
+	// objects only known to the secondary storage are still readable
+	_, err = SaveWithOptions(secondary, "b.txt", strings.NewReader("other"), 5, SaveOptions{ContentType: "text/plain"})
+	assert.NoError(t, err)
+	rc, err := m.OpenRange("b.txt", 1, 2)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.NoError(t, rc.Close())
+	assert.Equal(t, "th", string(data))
+	metadata, err := m.GetMetadata("b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", metadata.ContentType)
+
+	result, err := m.List(ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, result.Objects, 1)
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 The GitBundle Inc. All rights reserved.
+// Copyright 2017 The Gitea Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+var _ Object = &remoteObject{}
+
+// remoteObject is an Object of a storage which is only reachable with ranged reads.
+// The content is fetched lazily and a new ranged read is started after a Seek.
+type remoteObject struct {
+	info   os.FileInfo
+	fetch  func(offset int64) (io.ReadCloser, error)
+	offset int64
+	rc     io.ReadCloser
+}
+
+func newRemoteObject(info os.FileInfo, fetch func(offset int64) (io.ReadCloser, error)) *remoteObject {
+	return &remoteObject{info: info, fetch: fetch}
+}
+
+func (o *remoteObject) Read(p []byte) (int, error) {
+	if o.offset >= o.info.Size() {
+		return 0, io.EOF
+	}
+	if o.rc == nil {
+		rc, err := o.fetch(o.offset)
+		if err != nil {
+			return 0, err
+		}
+		o.rc = rc
+	}
+	n, err := o.rc.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *remoteObject) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = o.offset + offset
+	case io.SeekEnd:
+		abs = o.info.Size() + offset
+	default:
+		return 0, errors.New("Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("Seek: negative position")
+	}
+	if abs != o.offset && o.rc != nil {
+		_ = o.rc.Close()
+		o.rc = nil
+	}
+	o.offset = abs
+	return abs, nil
+}
+
+func (o *remoteObject) Close() error {
+	if o.rc == nil {
+		return nil
+	}
+	err := o.rc.Close()
+	o.rc = nil
+	return err
+}
+
+func (o *remoteObject) Stat() (os.FileInfo, error) {
+	return o.info, nil
+}
+
+// remoteFileInfo implements os.FileInfo for objects of remote storages
+type remoteFileInfo struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+func (fi remoteFileInfo) Name() string {
+	return path.Base(fi.key)
+}
+
+func (fi remoteFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi remoteFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi remoteFileInfo) IsDir() bool {
+	return false
+}
+
+func (fi remoteFileInfo) Mode() os.FileMode {
+	return os.ModePerm
+}
+
+func (fi remoteFileInfo) Sys() interface{} {
+	return nil
+}
@@ -10,8 +10,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gitbundle/modules/log"
 	"github.com/gitbundle/modules/setting"
@@ -20,6 +24,8 @@ import (
 // ErrURLNotSupported represents url is not supported
 var ErrURLNotSupported = errors.New("url method not supported")
 
+const defaultContentType = "application/octet-stream"
+
 // ErrInvalidConfiguration is called when there is invalid configuration for a storage
 type ErrInvalidConfiguration struct {
 	cfg interface{}
@@ -70,6 +76,192 @@ type ObjectStorage interface {
 	IterateObjects(func(path string, obj Object) error) error
 }
 
+// ListOptions represents the options of a paginated listing
+type ListOptions struct {
+	// Prefix limits the listing to the paths starting with it
+	Prefix string
+	// StartAfter skips all paths up to and including it, pass the NextStartAfter of the previous page
+	StartAfter string
+	// Limit is the maximum number of objects per page, 0 means no limit
+	Limit int
+}
+
+// ObjectInfo represents a listed object
+type ObjectInfo struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ListResult represents a page of a listing
+type ListResult struct {
+	Objects []ObjectInfo
+	// NextStartAfter is set if there are more objects to list
+	NextStartAfter string
+}
+
+// SaveOptions represents the options of SaveWithOptions
+type SaveOptions struct {
+	ContentType string
+	// Metadata is stored with the object, its keys are case insensitive
+	Metadata map[string]string
+	// IfNotExists fails the save with os.ErrExist if the object exists already.
+	// Only the local storage checks this atomically, the other storages check before
+	// the upload, so concurrent saves of the same path may both succeed.
+	IfNotExists bool
+}
+
+// ObjectMetadata represents the metadata stored with an object
+type ObjectMetadata struct {
+	ContentType string
+	// Metadata keys are canonicalized like http header keys
+	Metadata map[string]string
+}
+
+// ExtendedObjectStorage represents an ObjectStorage which natively supports listing,
+// ranged reads, metadata and conditional writes.
+// Use the List, OpenRange, SaveWithOptions and GetMetadata functions to fall back
+// gracefully for other storages.
+type ExtendedObjectStorage interface {
+	ObjectStorage
+	List(opts ListOptions) (*ListResult, error)
+	// OpenRange reads length bytes starting at offset, a negative length reads up to the end
+	OpenRange(path string, offset, length int64) (io.ReadCloser, error)
+	SaveWithOptions(path string, r io.Reader, size int64, opts SaveOptions) (int64, error)
+	GetMetadata(path string) (*ObjectMetadata, error)
+}
+
+// PresignedStorage represents an ObjectStorage that can hand out time limited
+// links which allow clients to talk directly to the underlying backend
+type PresignedStorage interface {
+	// PresignGet returns a link to download the object at path which expires after expiry
+	PresignGet(path string, expiry time.Duration) (*url.URL, error)
+	// PresignPut returns a link to upload the object at path which expires after expiry
+	PresignPut(path string, expiry time.Duration) (*url.URL, error)
+}
+
+// PresignedUploadHeaderStorage represents a PresignedStorage whose upload links
+// are only accepted if the request carries additional headers
+type PresignedUploadHeaderStorage interface {
+	PresignedStorage
+	// PresignPutHeaders returns the headers clients have to send to links returned by PresignPut
+	PresignPutHeaders() map[string]string
+}
+
+// presignPutHeaders returns the headers required by the presigned upload links of s
+func presignPutHeaders(s ObjectStorage) map[string]string {
+	if hs, ok := s.(PresignedUploadHeaderStorage); ok {
+		return hs.PresignPutHeaders()
+	}
+	return nil
+}
+
+// List lists the objects of the storage page by page
+func List(s ObjectStorage, opts ListOptions) (*ListResult, error) {
+	if es, ok := s.(ExtendedObjectStorage); ok {
+		return es.List(opts)
+	}
+
+	var objects []ObjectInfo
+	err := s.IterateObjects(func(path string, obj Object) error {
+		if !strings.HasPrefix(path, opts.Prefix) || (opts.StartAfter != "" && path <= opts.StartAfter) {
+			return nil
+		}
+		fi, err := obj.Stat()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Path: path, Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paginateObjects(objects, opts.Limit), nil
+}
+
+// paginateObjects sorts the objects and cuts them to a page of at most limit objects
+func paginateObjects(objects []ObjectInfo, limit int) *ListResult {
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Path < objects[j].Path
+	})
+	result := &ListResult{Objects: objects}
+	if limit > 0 && len(objects) > limit {
+		result.Objects = objects[:limit]
+		result.NextStartAfter = objects[limit-1].Path
+	}
+	return result
+}
+
+// OpenRange reads length bytes of an object starting at offset, a negative length reads up to the end
+func OpenRange(s ObjectStorage, path string, offset, length int64) (io.ReadCloser, error) {
+	if es, ok := s.(ExtendedObjectStorage); ok {
+		return es.OpenRange(path, offset, length)
+	}
+
+	obj, err := s.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newRangeReadCloser(obj, offset, length)
+}
+
+type rangeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newRangeReadCloser(r io.ReadSeekCloser, offset, length int64) (io.ReadCloser, error) {
+	if offset > 0 {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
+	if length < 0 {
+		return r, nil
+	}
+	return &rangeReadCloser{Reader: io.LimitReader(r, length), Closer: r}, nil
+}
+
+// SaveWithOptions saves an object with the given options. Storages which don't support
+// them natively store neither content type nor metadata and check IfNotExists before writing.
+func SaveWithOptions(s ObjectStorage, path string, r io.Reader, size int64, opts SaveOptions) (int64, error) {
+	if es, ok := s.(ExtendedObjectStorage); ok {
+		return es.SaveWithOptions(path, r, size, opts)
+	}
+
+	if opts.IfNotExists {
+		if _, err := s.Stat(path); err == nil {
+			return 0, os.ErrExist
+		} else if !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	return s.Save(path, r, size)
+}
+
+// GetMetadata returns the metadata of an object
+func GetMetadata(s ObjectStorage, path string) (*ObjectMetadata, error) {
+	if es, ok := s.(ExtendedObjectStorage); ok {
+		return es.GetMetadata(path)
+	}
+
+	if _, err := s.Stat(path); err != nil {
+		return nil, err
+	}
+	return &ObjectMetadata{ContentType: defaultContentType, Metadata: map[string]string{}}, nil
+}
+
+// canonicalMetadata canonicalizes the keys of the metadata
+func canonicalMetadata(metadata map[string]string) map[string]string {
+	m := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		m[http.CanonicalHeaderKey(k)] = v
+	}
+	return m
+}
+
 // Copy copies a file from source ObjectStorage to dest ObjectStorage
 func Copy(dstStorage ObjectStorage, dstPath string, srcStorage ObjectStorage, srcPath string) (int64, error) {
 	f, err := srcStorage.Open(srcPath)